@@ -0,0 +1,68 @@
+package rdb
+
+import "math/bits"
+
+// AccessStats segments keys by how recently or how often they were used,
+// from the LRU idle times or LFU counters stored in dumps saved under an
+// LRU or LFU maxmemory-policy. It shows how much memory goes to keys that
+// are rarely touched. Feed it every entry with Add, then call Report.
+type AccessStats struct {
+	Config EncodingConfig
+
+	none AccessBucket
+	idle [65]AccessBucket // by bits.Len64 of the idle time
+	freq [9]AccessBucket  // by bits.Len8 of the counter
+}
+
+// AccessBucket counts the keys whose idle time or counter is at most Max
+// and greater than the Max of the previous bucket.
+type AccessBucket struct {
+	Max    uint64
+	Keys   uint64
+	Memory uint64 // estimated bytes
+}
+
+// AccessReport is the result of AccessStats. Empty buckets are omitted.
+type AccessReport struct {
+	None AccessBucket   // keys without access information; Max is not set
+	Idle []AccessBucket // by LRU idle time in seconds, most recent first
+	Freq []AccessBucket // by LFU counter, least frequent first
+}
+
+// NewAccessStats returns an AccessStats estimating memory with cfg.
+func NewAccessStats(cfg EncodingConfig) *AccessStats {
+	return &AccessStats{Config: cfg}
+}
+
+// Add accounts for a single entry.
+func (s *AccessStats) Add(e *Entry) {
+	b := &s.none
+	switch e.Access {
+	case AccessLRU:
+		b = &s.idle[bits.Len64(e.Idle)]
+	case AccessLFU:
+		b = &s.freq[bits.Len8(e.Freq)]
+	}
+	_, size := EstimateMemory(e, s.Config)
+	b.Keys++
+	b.Memory += size
+}
+
+// Report returns the statistics collected so far.
+func (s *AccessStats) Report() AccessReport {
+	r := AccessReport{None: s.none}
+	r.Idle = accessBuckets(s.idle[:])
+	r.Freq = accessBuckets(s.freq[:])
+	return r
+}
+
+func accessBuckets(b []AccessBucket) []AccessBucket {
+	var out []AccessBucket
+	for i, a := range b {
+		if a.Keys > 0 {
+			a.Max = 1<<uint(i) - 1
+			out = append(out, a)
+		}
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package rdb
+
+// DefaultArenaChunk is the chunk size of an Arena created with a size of 0.
+const DefaultArenaChunk = 4 << 20
+
+// An Arena holds the strings decoded by the Readers using it in large
+// chunks instead of one heap object per string. Loading hundreds of
+// millions of small keys and values then allocates a few thousand chunks
+// rather than as many objects, which takes most of the work off the
+// garbage collector.
+//
+// The strings live until Reset is called, which releases them all at once
+// and makes the chunks available for the next batch of entries: keep the
+// entries of a batch, process them, then Reset before reading the next
+// batch. Strings must not be used after the Reset that released them.
+//
+// An Arena is meant for a single goroutine, and for one Reader at a time.
+type Arena struct {
+	chunk int
+	cur   []byte   // unused part of the current chunk
+	used  [][]byte // chunks in use, including the current one
+	free  [][]byte // chunks released by Reset
+}
+
+// NewArena returns an Arena allocating chunks of the given size in bytes,
+// or DefaultArenaChunk if size is 0. Strings larger than a quarter of a
+// chunk are allocated from the heap.
+func NewArena(size int) *Arena {
+	if size <= 0 {
+		size = DefaultArenaChunk
+	}
+	return &Arena{chunk: size}
+}
+
+// WithArena makes the Reader place the keys and values it decodes in a.
+// Values decoded by Record.Decode, which may run on other goroutines, are
+// allocated from the heap.
+func WithArena(a *Arena) Option {
+	return func(r *Reader) {
+		r.strings.Alloc = a.alloc
+	}
+}
+
+// alloc returns n bytes of the arena.
+func (a *Arena) alloc(n int) []byte {
+	if n > a.chunk/4 {
+		return make([]byte, n)
+	}
+	if n > len(a.cur) {
+		if k := len(a.free); k > 0 {
+			a.cur, a.free = a.free[k-1], a.free[:k-1]
+		} else {
+			a.cur = make([]byte, a.chunk)
+		}
+		a.used = append(a.used, a.cur)
+	}
+	b := a.cur[:n:n]
+	a.cur = a.cur[n:]
+	return b
+}
+
+// Reset releases every string allocated so far, keeping the chunks for
+// reuse.
+func (a *Arena) Reset() {
+	a.free = append(a.free, a.used...)
+	a.used = a.used[:0]
+	a.cur = nil
+}
+
+// Size returns the number of bytes held by the chunks in use.
+func (a *Arena) Size() int {
+	return len(a.used) * a.chunk
+}
+
+// Release drops the chunks kept for reuse, returning their memory to the
+// heap.
+func (a *Arena) Release() {
+	clear(a.free)
+	a.free = a.free[:0]
+}
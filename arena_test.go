@@ -0,0 +1,80 @@
+package rdb_test
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+func TestArena(t *testing.T) {
+	var entries []*rdb.Entry
+	for i := 0; i < 20; i++ {
+		entries = append(entries, str(0, "key:"+strconv.Itoa(i), "value "+strconv.Itoa(i), 0))
+	}
+	big := strings.Repeat("x", 300)
+	entries = append(entries, str(0, "big", big, 0))
+	dump := rdbtest.Dump(t, 11, entries...)
+
+	a := rdb.NewArena(1024)
+	load := func() []*rdb.Entry {
+		r, err := rdb.NewReader(bytes.NewReader(dump), rdb.WithArena(a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		var got []*rdb.Entry
+		for i := range entries {
+			e, err := r.ReadEntry()
+			if err != nil {
+				t.Fatalf("entry %d: %v", i, err)
+			}
+			got = append(got, e)
+		}
+		return got
+	}
+
+	first := load()
+	rdbtest.AssertEntries(t, first, entries)
+	// The small keys and values, under 300 bytes in all, fit in one
+	// chunk; the big value, over a quarter of a chunk, comes from the heap.
+	if a.Size() != 1024 {
+		t.Errorf("got size %d, want one chunk", a.Size())
+	}
+	// Strings are capped, so appending to one does not overwrite the next.
+	k := first[0].Key
+	if cap(k) != len(k) {
+		t.Errorf("key has capacity %d for %d bytes", cap(k), len(k))
+	}
+	_ = append(k, '!')
+	if string(first[1].Key) != "key:1" {
+		t.Errorf("appending to a key changed the next to %q", first[1].Key)
+	}
+
+	// After Reset, the next batch reuses the chunk.
+	addr := &first[0].Key[0]
+	a.Reset()
+	if a.Size() != 0 {
+		t.Errorf("got size %d after Reset", a.Size())
+	}
+	second := load()
+	rdbtest.AssertEntries(t, second, entries)
+	if &second[0].Key[0] != addr {
+		t.Error("the chunk was not reused")
+	}
+
+	// Release drops the free chunks: a new one is allocated.
+	a.Reset()
+	a.Release()
+	third := load()
+	if &third[0].Key[0] == addr {
+		t.Error("a released chunk was reused")
+	}
+
+	if n := rdb.NewArena(0); n.Size() != 0 {
+		t.Errorf("new arena holds %d bytes", n.Size())
+	}
+}
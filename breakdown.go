@@ -0,0 +1,98 @@
+package rdb
+
+import "sort"
+
+// MemoryBreakdown splits the estimated memory of a dump by logical type
+// and by encoding, for a quick view of where the memory goes. The encoding
+// is the one Redis would use after loading the dump under Config. Feed it
+// every entry with Add, then call Report.
+type MemoryBreakdown struct {
+	Config EncodingConfig
+
+	types     map[Type]*MemoryShare
+	encodings map[typeEncoding]*MemoryShare
+}
+
+type typeEncoding struct {
+	t Type
+	e Encoding
+}
+
+// MemoryShare is the part of the memory used by keys of one type, or of
+// one type and encoding.
+type MemoryShare struct {
+	Type     Type
+	Encoding Encoding // not set in MemoryReport.ByType
+	Keys     uint64
+	Bytes    uint64
+	Share    float64 // fraction of the total bytes
+}
+
+// MemoryReport is the result of MemoryBreakdown.
+type MemoryReport struct {
+	Keys       uint64
+	Bytes      uint64
+	ByType     []MemoryShare // largest first
+	ByEncoding []MemoryShare // largest first
+}
+
+// NewMemoryBreakdown returns a MemoryBreakdown estimating memory with cfg.
+func NewMemoryBreakdown(cfg EncodingConfig) *MemoryBreakdown {
+	return &MemoryBreakdown{Config: cfg}
+}
+
+// Add accounts for a single entry.
+func (b *MemoryBreakdown) Add(e *Entry) {
+	if b.types == nil {
+		b.types = make(map[Type]*MemoryShare)
+		b.encodings = make(map[typeEncoding]*MemoryShare)
+	}
+	enc, size := EstimateMemory(e, b.Config)
+	t := e.Type()
+	s := b.types[t]
+	if s == nil {
+		s = &MemoryShare{Type: t}
+		b.types[t] = s
+	}
+	s.Keys++
+	s.Bytes += size
+	k := typeEncoding{t, enc}
+	s = b.encodings[k]
+	if s == nil {
+		s = &MemoryShare{Type: t, Encoding: enc}
+		b.encodings[k] = s
+	}
+	s.Keys++
+	s.Bytes += size
+}
+
+// Report returns the breakdown of the entries added so far.
+func (b *MemoryBreakdown) Report() MemoryReport {
+	var r MemoryReport
+	for _, s := range b.types {
+		r.Keys += s.Keys
+		r.Bytes += s.Bytes
+		r.ByType = append(r.ByType, *s)
+	}
+	for _, s := range b.encodings {
+		r.ByEncoding = append(r.ByEncoding, *s)
+	}
+	for _, shares := range [][]MemoryShare{r.ByType, r.ByEncoding} {
+		for i := range shares {
+			if r.Bytes > 0 {
+				shares[i].Share = float64(shares[i].Bytes) / float64(r.Bytes)
+			}
+		}
+		sort.Slice(shares, func(i, j int) bool {
+			a, b := shares[i], shares[j]
+			if a.Bytes != b.Bytes {
+				return a.Bytes > b.Bytes
+			}
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+			return a.Encoding < b.Encoding
+		})
+	}
+	return r
+}
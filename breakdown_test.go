@@ -0,0 +1,60 @@
+package rdb_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestMemoryBreakdown(t *testing.T) {
+	var big []rdb.HashField
+	for i := 0; i < 200; i++ {
+		big = append(big, rdb.HashField{Field: rdb.RedisString("f" + strconv.Itoa(i)), Value: rdb.RedisString("v")})
+	}
+	entries := []*rdb.Entry{
+		{Key: rdb.RedisString("big"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: big}},
+		{Key: rdb.RedisString("small"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields("f", "v")}},
+		str(0, "s1", "v", 0),
+		str(0, "s2", "v", 0),
+	}
+	cfg := rdb.DefaultEncodingConfig()
+	var sizes []uint64
+	var total uint64
+	b := rdb.NewMemoryBreakdown(cfg)
+	for _, e := range entries {
+		_, n := rdb.EstimateMemory(e, cfg)
+		sizes = append(sizes, n)
+		total += n
+		b.Add(e)
+	}
+	share := func(n uint64) float64 { return float64(n) / float64(total) }
+	hashes, plain := sizes[0]+sizes[1], sizes[2]+sizes[3]
+
+	r := b.Report()
+	if r.Keys != 4 || r.Bytes != total {
+		t.Errorf("got %d keys, %d bytes, want 4, %d", r.Keys, r.Bytes, total)
+	}
+	byType := []rdb.MemoryShare{
+		{Type: rdb.TypeHash, Keys: 2, Bytes: hashes, Share: share(hashes)},
+		{Type: rdb.TypeString, Keys: 2, Bytes: plain, Share: share(plain)},
+	}
+	if !reflect.DeepEqual(r.ByType, byType) {
+		t.Errorf("got by type %+v, want %+v", r.ByType, byType)
+	}
+	// The big hash is a hash table once loaded, although the dump stored
+	// it as a listpack.
+	byEncoding := []rdb.MemoryShare{
+		{Type: rdb.TypeHash, Encoding: rdb.EncodingHashtable, Keys: 1, Bytes: sizes[0], Share: share(sizes[0])},
+		{Type: rdb.TypeString, Encoding: rdb.EncodingRaw, Keys: 2, Bytes: plain, Share: share(plain)},
+		{Type: rdb.TypeHash, Encoding: rdb.EncodingListpack, Keys: 1, Bytes: sizes[1], Share: share(sizes[1])},
+	}
+	if !reflect.DeepEqual(r.ByEncoding, byEncoding) {
+		t.Errorf("got by encoding %+v, want %+v", r.ByEncoding, byEncoding)
+	}
+
+	if r := rdb.NewMemoryBreakdown(cfg).Report(); r.Keys != 0 || r.ByType != nil {
+		t.Errorf("empty report %+v", r)
+	}
+}
@@ -0,0 +1,193 @@
+package rdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A Checkpoint records the state of a Reader between two keys, so that a
+// long read interrupted by a crash can resume there with Resume instead of
+// starting over. It holds everything the Reader has gathered from the
+// opcodes before the next key. It can be stored as JSON.
+type Checkpoint struct {
+	Offset    int64 // position in the stream of the next key
+	Version   int
+	DB        uint64
+	Keys      uint64 // keys returned before the checkpoint
+	Aux       []AuxField
+	Hints     map[uint64]DBSizeHint `json:",omitempty"`
+	Slot      *SlotInfo             `json:",omitempty"` // see Entry.Slot
+	Functions []RedisString         `json:",omitempty"`
+	ModuleAux []ModuleAux           `json:",omitempty"`
+
+	// CRC is the checksum of the bytes before Offset, set unless the
+	// Reader was created WithoutChecksum.
+	CRC     uint64    `json:",omitempty"`
+	HasCRC  bool      `json:",omitempty"`
+	Created time.Time // when the checkpoint was taken, see WithClock
+}
+
+// Checkpoint returns the state of r after the last key it returned. It
+// does not change as r reads on.
+func (r *Reader) Checkpoint() Checkpoint {
+	return Checkpoint{
+		Offset:    r.in.off,
+		Version:   r.version,
+		DB:        r.db,
+		Keys:      r.keys,
+		Aux:       r.aux[:len(r.aux):len(r.aux)],
+		Hints:     maps.Clone(r.hints),
+		Slot:      r.slot,
+		Functions: r.functions[:len(r.functions):len(r.functions)],
+		ModuleAux: r.moduleAux[:len(r.moduleAux):len(r.moduleAux)],
+		CRC:       r.in.crc,
+		HasCRC:    r.in.hash,
+		Created:   r.clock(),
+	}
+}
+
+// Resume returns a Reader continuing from cp. r must start at cp.Offset of
+// the same dump, for example a file seeked to that offset. The options
+// should be those of the Reader the checkpoint was taken from: the
+// checksum is only verified if that Reader verified it too, and the
+// compatibility modes of WithCompat must include those the dump needs.
+func Resume(r io.Reader, cp Checkpoint, opts ...Option) (*Reader, error) {
+	rd := &Reader{in: newInput(r), clock: time.Now}
+	rd.in.hash = true
+	for _, opt := range opts {
+		opt(rd)
+	}
+	if err := rd.checkVersion(cp.Version); err != nil {
+		return nil, err
+	}
+	rd.startPrefetch(r)
+	rd.in.off = cp.Offset
+	rd.in.hash, rd.in.crc = rd.in.hash && cp.HasCRC, cp.CRC
+	rd.version = cp.Version
+	rd.db = cp.DB
+	rd.keys = cp.Keys
+	rd.aux = cp.Aux
+	rd.hints = cp.Hints
+	rd.slot = cp.Slot
+	rd.functions = cp.Functions
+	rd.moduleAux = cp.ModuleAux
+	rd.seenKeys = cp.Keys > 0
+	return rd, nil
+}
+
+// A CheckpointStore persists checkpoints.
+type CheckpointStore interface {
+	// Save stores cp, replacing the previous checkpoint.
+	Save(cp Checkpoint) error
+
+	// Load returns the last checkpoint saved, and false if there is none.
+	Load() (Checkpoint, bool, error)
+}
+
+// FileCheckpointStore returns a CheckpointStore keeping the checkpoint as
+// JSON in the file at path. The file is replaced atomically, so a crash
+// while saving leaves the previous checkpoint intact.
+func FileCheckpointStore(path string) CheckpointStore {
+	return fileCheckpointStore(path)
+}
+
+type fileCheckpointStore string
+
+func (path fileCheckpointStore) Save(cp Checkpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(string(path)), filepath.Base(string(path))+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), string(path))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (path fileCheckpointStore) Load() (Checkpoint, bool, error) {
+	var cp Checkpoint
+	b, err := os.ReadFile(string(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return cp, false, nil
+	}
+	if err != nil {
+		return cp, false, err
+	}
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return cp, false, fmt.Errorf("rdb: reading checkpoint %s: %w", path, err)
+	}
+	return cp, true, nil
+}
+
+// WithAutoCheckpoint makes the Reader save a checkpoint to store every
+// interval, so that a long read resumes from the last checkpoint after a
+// crash rather than from the start of the dump. Checkpoints are taken when
+// the next key is requested, once the caller is done with the previous
+// ones; a failure to save one is returned by ReadEntry. DecodeParallel,
+// which reads ahead of the entries it delivers, takes them when it
+// delivers the next entry instead.
+func WithAutoCheckpoint(interval time.Duration, store CheckpointStore) Option {
+	return func(r *Reader) {
+		r.checkpointEvery, r.checkpoints = interval, store
+	}
+}
+
+// WithClock makes the Reader take the current time from now instead of
+// the system clock, for the Created time of checkpoints and the interval
+// of WithAutoCheckpoint.
+func WithClock(now func() time.Time) Option {
+	return func(r *Reader) {
+		r.clock = now
+	}
+}
+
+// autoCheckpoint saves a checkpoint if one is due.
+func (r *Reader) autoCheckpoint() error {
+	if !r.checkpointDue() {
+		return nil
+	}
+	return saveCheckpoint(r.checkpoints, r.Checkpoint())
+}
+
+// checkpointDue reports whether the interval of WithAutoCheckpoint has
+// passed since the last checkpoint, or since the first call.
+func (r *Reader) checkpointDue() bool {
+	now := r.clock()
+	if r.lastCheckpoint.IsZero() {
+		r.lastCheckpoint = now
+		return false
+	}
+	if now.Sub(r.lastCheckpoint) < r.checkpointEvery {
+		return false
+	}
+	r.lastCheckpoint = now
+	return true
+}
+
+func saveCheckpoint(store CheckpointStore, cp Checkpoint) error {
+	if err := store.Save(cp); err != nil {
+		return fmt.Errorf("rdb: saving checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package rdb_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// checkpointDump returns a dump of the given version with a function
+// library, slot information and n string keys.
+func checkpointDump(t *testing.T, version, n int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteAux([]byte("redis-ver"), []byte("7.4.0"))
+	w.WriteRaw(codec.AppendString([]byte{0xf5}, []byte("#!lua name=lib"), false))
+	w.SelectDB(0)
+	w.ResizeDB(uint64(n), 0)
+	w.WriteRaw(codec.AppendLength(codec.AppendLength(codec.AppendLength([]byte{0xf4}, 42), uint64(n)), 0))
+	for i := 0; i < n; i++ {
+		w.WriteEntry(&rdb.Entry{Key: rdb.RedisString("key:" + strconv.Itoa(i)), Value: rdb.StringValue("v")})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestResume(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		opts    []rdb.Option
+	}{
+		{"redis", 12, nil},
+		{"valkey", 80, []rdb.Option{rdb.WithCompat(rdb.CompatValkey)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dump := checkpointDump(t, tt.version, 5)
+			opts := append([]rdb.Option{rdb.WithVerifyChecksum()}, tt.opts...)
+			r, err := rdb.NewReader(bytes.NewReader(dump), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := r.ReadEntry(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b, err := json.Marshal(r.Checkpoint())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cp rdb.Checkpoint
+			if err := json.Unmarshal(b, &cp); err != nil {
+				t.Fatal(err)
+			}
+			if cp.Keys != 2 || cp.Slot == nil || cp.Slot.Slot != 42 || len(cp.Functions) != 1 || !cp.HasCRC {
+				t.Fatalf("checkpoint %+v", cp)
+			}
+
+			r, err = rdb.Resume(bytes.NewReader(dump[cp.Offset:]), cp, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for {
+				e, err := r.ReadEntry()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if e.Slot == nil || e.Slot.Slot != 42 {
+					t.Errorf("%s: slot %v, want 42", e.Key, e.Slot)
+				}
+				keys = append(keys, string(e.Key))
+			}
+			if want := []string{"key:2", "key:3", "key:4"}; !reflect.DeepEqual(keys, want) {
+				t.Errorf("resumed with %v, want %v", keys, want)
+			}
+			if len(r.Functions()) != 1 || len(r.Aux()) != 1 {
+				t.Errorf("resumed with functions %q and aux %q", r.Functions(), r.Aux())
+			}
+		})
+	}
+}
+
+func TestResumeVersion(t *testing.T) {
+	cp := rdb.Checkpoint{Version: 80}
+	if _, err := rdb.Resume(bytes.NewReader(nil), cp); err == nil {
+		t.Error("resumed a Valkey dump without CompatValkey")
+	}
+}
+
+type memoryStore []rdb.Checkpoint
+
+func (s *memoryStore) Save(cp rdb.Checkpoint) error {
+	*s = append(*s, cp)
+	return nil
+}
+
+func (s *memoryStore) Load() (rdb.Checkpoint, bool, error) {
+	if len(*s) == 0 {
+		return rdb.Checkpoint{}, false, nil
+	}
+	return (*s)[len(*s)-1], true, nil
+}
+
+// TestDecodeParallelCheckpoint checks that the checkpoints taken while
+// decoding in parallel are those of the entries delivered, not those of
+// the records read ahead.
+func TestDecodeParallelCheckpoint(t *testing.T) {
+	dump := checkpointDump(t, 12, 200)
+	var store memoryStore
+	r, err := rdb.NewReader(bytes.NewReader(dump), rdb.WithAutoCheckpoint(time.Nanosecond, &store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delivered uint64
+	err = rdb.DecodeParallel(r, 4, func(e *rdb.Entry) error {
+		if n := len(store); n > 0 && store[n-1].Keys > delivered {
+			t.Fatalf("checkpoint after %d keys saved before delivering key %d", store[n-1].Keys, delivered)
+		}
+		delivered++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 200 || len(store) == 0 {
+		t.Fatalf("delivered %d keys with %d checkpoints", delivered, len(store))
+	}
+}
+
+// TestAutoCheckpointClock checks that automatic checkpoints follow the
+// clock given with WithClock.
+func TestAutoCheckpointClock(t *testing.T) {
+	dump := checkpointDump(t, 12, 10)
+	t0 := time.Unix(1700000000, 0)
+	read := func(clock func() time.Time) memoryStore {
+		var store memoryStore
+		if err := readToEnd(dump, rdb.WithAutoCheckpoint(3*time.Second, &store), rdb.WithClock(clock)); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}
+
+	if store := read(func() time.Time { return t0 }); len(store) != 0 {
+		t.Errorf("saved %d checkpoints while the clock stood still", len(store))
+	}
+
+	// Every reading of the clock advances it by a second.
+	ticks := 0
+	store := read(func() time.Time {
+		ticks++
+		return t0.Add(time.Duration(ticks) * time.Second)
+	})
+	if len(store) < 2 {
+		t.Fatalf("saved %d checkpoints", len(store))
+	}
+	for i, cp := range store {
+		if cp.Created.Before(t0) || cp.Created.After(t0.Add(time.Duration(ticks)*time.Second)) {
+			t.Errorf("checkpoint %d created at %v, not by the clock", i, cp.Created)
+		}
+		if i > 0 && cp.Created.Sub(store[i-1].Created) < 3*time.Second {
+			t.Errorf("checkpoint %d taken %v after the previous one", i, cp.Created.Sub(store[i-1].Created))
+		}
+	}
+}
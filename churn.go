@@ -0,0 +1,152 @@
+package rdb
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ChurnOptions configures Churn.
+type ChurnOptions struct {
+	// From and To are the times the snapshots were taken. If zero, the
+	// creation times recorded in the dumps are used.
+	From, To time.Time
+
+	// Prefix groups keys in the report. If nil, keys are grouped by the
+	// part before the first ':'.
+	Prefix func(key []byte) string
+
+	// Diff controls which keys count as modified.
+	Diff DiffOptions
+}
+
+// ChurnPrefix counts the changes to the keys of one prefix between two
+// snapshots.
+type ChurnPrefix struct {
+	Prefix string
+	Keys   uint64 // keys in the earlier snapshot
+
+	Created  uint64 // keys only in the later snapshot
+	Expired  uint64 // keys gone whose expiry fell before the later snapshot
+	Deleted  uint64 // keys gone that had not expired: deleted or evicted
+	Modified uint64
+}
+
+// ChurnReport is the result of Churn. Rates are per hour of Interval.
+type ChurnReport struct {
+	From, To time.Time
+	Interval time.Duration
+
+	Total    ChurnPrefix
+	Prefixes []ChurnPrefix // most changes first
+}
+
+// Rate returns n as a rate per hour over the interval between the
+// snapshots, or 0 if the interval is not positive.
+func (r *ChurnReport) Rate(n uint64) float64 {
+	if r.Interval <= 0 {
+		return 0
+	}
+	return float64(n) / r.Interval.Hours()
+}
+
+// changes returns the number of keys of p that changed.
+func (p *ChurnPrefix) changes() uint64 {
+	return p.Created + p.Expired + p.Deleted + p.Modified
+}
+
+// Churn reports the keys created, expired, deleted and modified between
+// two snapshots of the same server, by prefix. Keys that disappeared are
+// told apart by their expiry: a key whose expiry fell before the later
+// snapshot was taken went away as intended, while a key without one, or
+// whose expiry was still to come, was deleted or evicted, which is the
+// churn worth investigating.
+func Churn(from, to *Database, opts ChurnOptions) (ChurnReport, error) {
+	rep := ChurnReport{From: opts.From, To: opts.To}
+	if rep.From.IsZero() {
+		rep.From = from.Created
+	}
+	if rep.To.IsZero() {
+		rep.To = to.Created
+	}
+	if rep.From.IsZero() || rep.To.IsZero() {
+		return rep, errors.New("rdb: churn: snapshot time unknown")
+	}
+	rep.Interval = rep.To.Sub(rep.From)
+	toMs := rep.To.UnixMilli()
+
+	prefixOf := opts.Prefix
+	if prefixOf == nil {
+		prefixOf = func(key []byte) string { return KeyPrefix(key, ':', 1) }
+	}
+	prefixes := make(map[string]*ChurnPrefix)
+	prefix := func(key []byte) *ChurnPrefix {
+		name := prefixOf(key)
+		p := prefixes[name]
+		if p == nil {
+			p = &ChurnPrefix{Prefix: name}
+			prefixes[name] = p
+		}
+		return p
+	}
+	for _, de := range from.entries {
+		prefix(de.e.Key).Keys++
+	}
+
+	diff, err := Diff(from, to, opts.Diff)
+	if err != nil {
+		return rep, err
+	}
+	for _, c := range diff.Changes {
+		p := prefix(c.Key)
+		switch c.Change {
+		case ChangeAdded:
+			p.Created++
+		case ChangeModified:
+			p.Modified++
+		case ChangeRemoved:
+			if c.Old.HasExpiry() && c.Old.ExpiryAt <= toMs {
+				p.Expired++
+			} else {
+				p.Deleted++
+			}
+		}
+	}
+
+	for _, p := range prefixes {
+		rep.Total.Keys += p.Keys
+		rep.Total.Created += p.Created
+		rep.Total.Expired += p.Expired
+		rep.Total.Deleted += p.Deleted
+		rep.Total.Modified += p.Modified
+		rep.Prefixes = append(rep.Prefixes, *p)
+	}
+	sort.Slice(rep.Prefixes, func(i, j int) bool {
+		a, b := &rep.Prefixes[i], &rep.Prefixes[j]
+		if a.changes() != b.changes() {
+			return a.changes() > b.changes()
+		}
+		return a.Prefix < b.Prefix
+	})
+	return rep, nil
+}
+
+// Tables implements Tabular, with counts and rates per hour.
+func (r ChurnReport) Tables() []Table {
+	rate := func(n uint64) string {
+		return formatCount(n) + " (" + strconv.FormatFloat(r.Rate(n), 'f', 1, 64) + "/h)"
+	}
+	t := Table{
+		Title:   "Churn from " + r.From.UTC().Format(time.RFC3339) + " to " + r.To.UTC().Format(time.RFC3339),
+		Columns: []string{"Prefix", "Keys", "Created", "Expired", "Deleted", "Modified"},
+	}
+	row := func(name string, p ChurnPrefix) {
+		t.Rows = append(t.Rows, []string{name, formatCount(p.Keys), rate(p.Created), rate(p.Expired), rate(p.Deleted), rate(p.Modified)})
+	}
+	for _, p := range r.Prefixes {
+		row(formatKey([]byte(p.Prefix)), p)
+	}
+	row("(total)", r.Total)
+	return []Table{t}
+}
@@ -0,0 +1,81 @@
+package rdb_test
+
+import (
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestChurn(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	t1 := t0.Add(2 * time.Hour)
+	ms := func(d time.Duration) int64 { return t0.Add(d).UnixMilli() }
+	from := loadDatabase(t, &rdb.Loader{},
+		str(0, "session:1", "a", ms(time.Hour)),   // expired
+		str(0, "session:2", "b", ms(3*time.Hour)), // deleted before its expiry
+		str(0, "session:3", "c", ms(2*time.Hour)), // expired as the later snapshot was taken
+		str(0, "user:1", "ann", 0),                // deleted
+		str(0, "user:2", "bob", 0),                // modified
+		str(0, "user:3", "eve", 0),                // unchanged
+		str(1, "user:1", "other database", 0),     // unchanged
+	)
+	to := loadDatabase(t, &rdb.Loader{},
+		str(0, "user:2", "robert", 0),
+		str(0, "user:3", "eve", 0),
+		str(1, "user:1", "other database", 0),
+		str(0, "cart:1", "x", 0),
+		str(0, "cart:2", "y", 0),
+	)
+
+	rep, err := rdb.Churn(from, to, rdb.ChurnOptions{From: t0, To: t1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []rdb.ChurnPrefix{
+		{Prefix: "session", Keys: 3, Expired: 2, Deleted: 1},
+		{Prefix: "cart", Created: 2},
+		{Prefix: "user", Keys: 4, Deleted: 1, Modified: 1},
+	}
+	if len(rep.Prefixes) != len(want) {
+		t.Fatalf("got %+v, want %+v", rep.Prefixes, want)
+	}
+	for i := range want {
+		if rep.Prefixes[i] != want[i] {
+			t.Errorf("got %+v, want %+v", rep.Prefixes[i], want[i])
+		}
+	}
+	total := rdb.ChurnPrefix{Keys: 7, Created: 2, Expired: 2, Deleted: 2, Modified: 1}
+	if rep.Total != total {
+		t.Errorf("got total %+v, want %+v", rep.Total, total)
+	}
+	if rep.Interval != 2*time.Hour || rep.Rate(rep.Total.Created) != 1 {
+		t.Errorf("got interval %v, rate %v", rep.Interval, rep.Rate(rep.Total.Created))
+	}
+
+	// Prefix groups keys its own way.
+	rep, err = rdb.Churn(from, to, rdb.ChurnOptions{From: t0, To: t1, Prefix: func([]byte) string { return "all" }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Prefixes) != 1 || rep.Prefixes[0].Prefix != "all" || rep.Prefixes[0].Keys != 7 {
+		t.Errorf("one prefix: got %+v", rep.Prefixes)
+	}
+
+	// The times default to those the dumps were created at.
+	if _, err := rdb.Churn(from, to, rdb.ChurnOptions{}); err == nil {
+		t.Error("no error without snapshot times")
+	}
+	from.Created, to.Created = t0, t0.Add(90*time.Minute)
+	rep, err = rdb.Churn(from, to, rdb.ChurnOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.From.Equal(t0) || rep.Interval != 90*time.Minute {
+		t.Errorf("got from %v, interval %v", rep.From, rep.Interval)
+	}
+	// session:3 expires after the later snapshot now.
+	if p := rep.Prefixes[0]; p.Prefix != "session" || p.Expired != 1 || p.Deleted != 2 {
+		t.Errorf("got %+v", p)
+	}
+}
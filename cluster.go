@@ -0,0 +1,255 @@
+package rdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ClusterSlots is the number of hash slots of a Redis Cluster.
+const ClusterSlots = 16384
+
+// HashTag returns the part of key used to compute its hash slot: the
+// bytes between the first '{' and the following '}', if that is not
+// empty, and otherwise the whole key.
+func HashTag(key []byte) []byte {
+	for i, c := range key {
+		if c != '{' {
+			continue
+		}
+		for j := i + 1; j < len(key); j++ {
+			if key[j] == '}' {
+				if j == i+1 {
+					return key
+				}
+				return key[i+1 : j]
+			}
+		}
+		return key
+	}
+	return key
+}
+
+// KeySlot returns the Redis Cluster hash slot of key.
+func KeySlot(key []byte) int {
+	return int(crc16(HashTag(key)) % ClusterSlots)
+}
+
+var crc16Table = func() (t [256]uint16) {
+	for i := range t {
+		c := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if c&0x8000 != 0 {
+				c = c<<1 ^ 0x1021
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}()
+
+// crc16 is the CRC-16/XMODEM checksum Redis Cluster hashes keys with.
+func crc16(p []byte) uint16 {
+	var crc uint16
+	for _, c := range p {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^c]
+	}
+	return crc
+}
+
+// SlotRange assigns the slots From to To, inclusive, to a node.
+type SlotRange struct {
+	From, To int
+	Node     string
+}
+
+// slotNodes maps every slot to a node, "" for unassigned slots.
+func slotNodes(ranges []SlotRange) (*[ClusterSlots]string, error) {
+	var m [ClusterSlots]string
+	for _, r := range ranges {
+		if r.From < 0 || r.To >= ClusterSlots || r.From > r.To {
+			return nil, fmt.Errorf("rdb: bad slot range %d-%d", r.From, r.To)
+		}
+		for s := r.From; s <= r.To; s++ {
+			m[s] = r.Node
+		}
+	}
+	return &m, nil
+}
+
+// MigrationPlan works out how the keys of a dump spread over a target
+// cluster topology before any resharding is attempted: how many keys and
+// bytes each node receives, and which slots and hash tags concentrate so
+// much data that they unbalance their node. Feed it every entry with Add,
+// then call Report.
+type MigrationPlan struct {
+	Config EncodingConfig
+
+	// Top limits the number of slots and hash tags reported; 0 means 20.
+	Top int
+
+	// MaxTags bounds the number of distinct hash tags tracked; further
+	// tags are ignored. 0 means 100000.
+	MaxTags int
+
+	current, target *[ClusterSlots]string
+	slots           [ClusterSlots]KeyspaceSize
+	moving          [ClusterSlots]KeyspaceSize
+	tags            map[string]*HashTagLoad
+}
+
+// NodeLoad is what a node of the target topology holds.
+type NodeLoad struct {
+	Node     string
+	Slots    int
+	Size     KeyspaceSize // keys in the node's slots
+	Incoming KeyspaceSize // keys that move to the node
+	Outgoing KeyspaceSize // keys that move away from it under the current topology
+	Share    float64      // fraction of the total memory
+}
+
+// SlotLoad is the data held in a slot.
+type SlotLoad struct {
+	Slot  int
+	Node  string // in the target topology
+	Size  KeyspaceSize
+	Share float64 // fraction of its node's memory
+}
+
+// HashTagLoad is the data of the keys sharing a hash tag, which all land
+// in the same slot.
+type HashTagLoad struct {
+	Tag  string
+	Slot int
+	Size KeyspaceSize
+}
+
+// MigrationReport is the result of MigrationPlan.
+type MigrationReport struct {
+	Total KeyspaceSize
+	Nodes []NodeLoad // sorted by name
+
+	// Unassigned sums the keys in slots the target topology leaves
+	// without a node.
+	Unassigned KeyspaceSize
+
+	Slots []SlotLoad    // the largest slots, most memory first
+	Tags  []HashTagLoad // the largest hash tags, most memory first
+}
+
+// NewMigrationPlan returns a MigrationPlan for moving to the target
+// topology, estimating memory with cfg. If current is nil, every key moves,
+// as when the dump comes from a standalone server; otherwise keys move
+// when their slot changes node.
+func NewMigrationPlan(cfg EncodingConfig, current, target []SlotRange) (*MigrationPlan, error) {
+	p := &MigrationPlan{Config: cfg}
+	var err error
+	if p.target, err = slotNodes(target); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		if p.current, err = slotNodes(current); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Add accounts for a single entry.
+func (p *MigrationPlan) Add(e *Entry) {
+	_, size := EstimateMemory(e, p.Config)
+	tag := HashTag(e.Key)
+	slot := int(crc16(tag) % ClusterSlots)
+	p.slots[slot].add(e, size)
+	if p.current == nil || p.current[slot] != p.target[slot] {
+		p.moving[slot].add(e, size)
+	}
+	if len(tag) == len(e.Key) {
+		return
+	}
+	if p.tags == nil {
+		p.tags = make(map[string]*HashTagLoad)
+	}
+	t := p.tags[string(tag)]
+	if t == nil {
+		limit := p.MaxTags
+		if limit == 0 {
+			limit = 100000
+		}
+		if len(p.tags) >= limit {
+			return
+		}
+		t = &HashTagLoad{Tag: string(tag), Slot: slot}
+		p.tags[t.Tag] = t
+	}
+	t.Size.add(e, size)
+}
+
+// Report returns the plan for the entries added so far.
+func (p *MigrationPlan) Report() MigrationReport {
+	top := p.Top
+	if top == 0 {
+		top = 20
+	}
+	var r MigrationReport
+	nodes := make(map[string]*NodeLoad)
+	node := func(name string) *NodeLoad {
+		n := nodes[name]
+		if n == nil {
+			n = &NodeLoad{Node: name}
+			nodes[name] = n
+		}
+		return n
+	}
+	for s, size := range p.slots {
+		r.Total.merge(size)
+		name := p.target[s]
+		if name == "" {
+			r.Unassigned.merge(size)
+		} else {
+			n := node(name)
+			n.Slots++
+			n.Size.merge(size)
+			n.Incoming.merge(p.moving[s])
+		}
+		if p.current != nil && p.current[s] != "" && p.current[s] != name {
+			node(p.current[s]).Outgoing.merge(p.moving[s])
+		}
+		if size.Keys > 0 {
+			r.Slots = append(r.Slots, SlotLoad{Slot: s, Node: name, Size: size})
+		}
+	}
+	for _, n := range nodes {
+		if r.Total.Memory > 0 {
+			n.Share = float64(n.Size.Memory) / float64(r.Total.Memory)
+		}
+		r.Nodes = append(r.Nodes, *n)
+	}
+	sort.Slice(r.Nodes, func(i, j int) bool { return r.Nodes[i].Node < r.Nodes[j].Node })
+
+	sort.SliceStable(r.Slots, func(i, j int) bool { return r.Slots[i].Size.Memory > r.Slots[j].Size.Memory })
+	if len(r.Slots) > top {
+		r.Slots = r.Slots[:top]
+	}
+	for i := range r.Slots {
+		sl := &r.Slots[i]
+		if n := nodes[sl.Node]; n != nil && n.Size.Memory > 0 {
+			sl.Share = float64(sl.Size.Memory) / float64(n.Size.Memory)
+		}
+	}
+
+	for _, t := range p.tags {
+		r.Tags = append(r.Tags, *t)
+	}
+	sort.Slice(r.Tags, func(i, j int) bool {
+		if r.Tags[i].Size.Memory != r.Tags[j].Size.Memory {
+			return r.Tags[i].Size.Memory > r.Tags[j].Size.Memory
+		}
+		return r.Tags[i].Tag < r.Tags[j].Tag
+	})
+	if len(r.Tags) > top {
+		r.Tags = r.Tags[:top]
+	}
+	return r
+}
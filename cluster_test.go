@@ -0,0 +1,128 @@
+package rdb_test
+
+import (
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestKeySlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		tag  string
+		slot int
+	}{
+		// Slots as reported by CLUSTER KEYSLOT.
+		{"foo", "foo", 12182},
+		{"bar", "bar", 5061},
+		{"hello", "hello", 866},
+		{"123456789", "123456789", 12739},
+		{"{user1000}.following", "user1000", 3443},
+		{"{user1000}.followers", "user1000", 3443},
+		{"foo{}{bar}", "foo{}{bar}", 8363},
+		{"foo{{bar}}zap", "{bar", 4015},
+		{"foo{bar}{zap}", "bar", 5061},
+		{"{unclosed", "{unclosed", -1},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		if got := string(rdb.HashTag([]byte(tt.key))); got != tt.tag {
+			t.Errorf("HashTag(%q) = %q, want %q", tt.key, got, tt.tag)
+		}
+		if tt.slot < 0 {
+			continue
+		}
+		if got := rdb.KeySlot([]byte(tt.key)); got != tt.slot {
+			t.Errorf("KeySlot(%q) = %d, want %d", tt.key, got, tt.slot)
+		}
+	}
+}
+
+func TestMigrationPlan(t *testing.T) {
+	cfg := rdb.DefaultEncodingConfig()
+	entries := []*rdb.Entry{
+		str(0, "foo", "v", 0),    // slot 12182, moves to b
+		str(0, "bar", "v", 0),    // slot 5061, stays on a
+		str(0, "{bar}1", "v", 0), // same slot as bar
+		str(0, "{bar}2", "v", 7), // same slot as bar, with an expiry
+		str(0, "hello", "v", 0),  // slot 866, unassigned in the target
+		str(1, "{foo}x", "v", 0), // slot 12182, another database
+	}
+	size := func(i int) uint64 {
+		_, n := rdb.EstimateMemory(entries[i], cfg)
+		return n
+	}
+	current := []rdb.SlotRange{{From: 0, To: rdb.ClusterSlots - 1, Node: "a"}}
+	target := []rdb.SlotRange{{From: 1000, To: 8191, Node: "a"}, {From: 8192, To: rdb.ClusterSlots - 1, Node: "b"}}
+	p, err := rdb.NewMigrationPlan(cfg, current, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		p.Add(e)
+	}
+	r := p.Report()
+
+	all := size(0) + size(1) + size(2) + size(3) + size(4) + size(5)
+	if r.Total != (rdb.KeyspaceSize{Keys: 6, Expires: 1, Memory: all}) {
+		t.Errorf("got total %+v", r.Total)
+	}
+	if r.Unassigned != (rdb.KeyspaceSize{Keys: 1, Memory: size(4)}) {
+		t.Errorf("got unassigned %+v", r.Unassigned)
+	}
+	if len(r.Nodes) != 2 || r.Nodes[0].Node != "a" || r.Nodes[1].Node != "b" {
+		t.Fatalf("got nodes %+v", r.Nodes)
+	}
+	a, b := r.Nodes[0], r.Nodes[1]
+	onA := rdb.KeyspaceSize{Keys: 3, Expires: 1, Memory: size(1) + size(2) + size(3)}
+	onB := rdb.KeyspaceSize{Keys: 2, Memory: size(0) + size(5)}
+	if a.Slots != 7192 || a.Size != onA || a.Incoming != (rdb.KeyspaceSize{}) {
+		t.Errorf("got a %+v", a)
+	}
+	// a gives up foo, {foo}x and the unassigned hello.
+	if a.Outgoing != (rdb.KeyspaceSize{Keys: 3, Memory: size(0) + size(4) + size(5)}) {
+		t.Errorf("got a outgoing %+v", a.Outgoing)
+	}
+	if b.Slots != 8192 || b.Size != onB || b.Incoming != onB || b.Outgoing != (rdb.KeyspaceSize{}) {
+		t.Errorf("got b %+v", b)
+	}
+	if a.Share != float64(onA.Memory)/float64(all) {
+		t.Errorf("got a share %v", a.Share)
+	}
+
+	if len(r.Slots) != 3 || r.Slots[0].Slot != 5061 || r.Slots[0].Node != "a" || r.Slots[0].Share != 1 {
+		t.Errorf("got slots %+v", r.Slots)
+	}
+	if len(r.Tags) != 2 || r.Tags[0].Tag != "bar" || r.Tags[0].Slot != 5061 || r.Tags[0].Size.Keys != 2 ||
+		r.Tags[1].Tag != "foo" || r.Tags[1].Size.Keys != 1 {
+		t.Errorf("got tags %+v", r.Tags)
+	}
+
+	// Top and MaxTags bound the lists.
+	p, _ = rdb.NewMigrationPlan(cfg, nil, target)
+	p.Top, p.MaxTags = 1, 1
+	for _, e := range entries {
+		p.Add(e)
+	}
+	r = p.Report()
+	if len(r.Slots) != 1 || len(r.Tags) != 1 || r.Tags[0].Tag != "bar" {
+		t.Errorf("got slots %+v, tags %+v", r.Slots, r.Tags)
+	}
+	// Without a current topology every key moves.
+	if in := r.Nodes[0].Incoming.Keys + r.Nodes[1].Incoming.Keys; in != 5 {
+		t.Errorf("%d keys move, want 5", in)
+	}
+
+	for _, bad := range [][]rdb.SlotRange{
+		{{From: 10, To: 5, Node: "a"}},
+		{{From: -1, To: 5, Node: "a"}},
+		{{From: 0, To: rdb.ClusterSlots, Node: "a"}},
+	} {
+		if _, err := rdb.NewMigrationPlan(cfg, nil, bad); err == nil {
+			t.Errorf("%+v was accepted", bad)
+		}
+		if _, err := rdb.NewMigrationPlan(cfg, bad, target); err == nil {
+			t.Errorf("current %+v was accepted", bad)
+		}
+	}
+}
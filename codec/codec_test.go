@@ -0,0 +1,186 @@
+package codec_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// elements covers the integer and string encodings of ziplists and
+// listpacks.
+func elements() [][]byte {
+	var elems [][]byte
+	for _, v := range []int64{0, 12, 13, -1, 127, 128, -4096, 4096, 32767, -32769, 1 << 23, 1 << 31, -1 << 63, 1<<63 - 1} {
+		elems = append(elems, []byte(strconv.FormatInt(v, 10)))
+	}
+	for _, n := range []int{0, 1, 63, 64, 127, 4095, 4096, 16383, 16384, 70000} {
+		elems = append(elems, []byte(strings.Repeat("x", n)))
+	}
+	return append(elems, []byte("007"), []byte("-0"), []byte("+1"))
+}
+
+func equalElems(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestZiplistRoundTrip(t *testing.T) {
+	want := elements()
+	zl := codec.EncodeZiplist(want)
+	if err := codec.ValidateZiplist(zl); err != nil {
+		t.Fatal(err)
+	}
+	got, err := codec.DecodeZiplist(zl)
+	if err != nil || !equalElems(got, want) {
+		t.Fatalf("got %d elements, err %v", len(got), err)
+	}
+}
+
+func TestListpackRoundTrip(t *testing.T) {
+	want := elements()
+	lp := codec.EncodeListpack(want)
+	if err := codec.ValidateListpack(lp); err != nil {
+		t.Fatal(err)
+	}
+	got, err := codec.DecodeListpack(lp)
+	if err != nil || !equalElems(got, want) {
+		t.Fatalf("got %d elements, err %v", len(got), err)
+	}
+}
+
+// corruptions returns damaged copies of a valid blob.
+func corruptions(b []byte) map[string][]byte {
+	damage := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), b...))
+	}
+	return map[string][]byte{
+		"truncated":      b[:len(b)-3],
+		"no terminator":  damage(func(b []byte) []byte { b[len(b)-1] = 0; return b }),
+		"short header":   b[:4],
+		"bad total size": damage(func(b []byte) []byte { binary.LittleEndian.PutUint32(b, uint32(len(b)+1)); return b }),
+		"trailing bytes": damage(func(b []byte) []byte {
+			b = append(b, 0)
+			binary.LittleEndian.PutUint32(b, uint32(len(b)))
+			return b
+		}),
+	}
+}
+
+func checkCorrupt(t *testing.T, name string, err error) {
+	t.Helper()
+	var ce *codec.CorruptError
+	if !errors.Is(err, codec.ErrFormat) || !errors.As(err, &ce) {
+		t.Errorf("%s: got %v, want a CorruptError", name, err)
+	}
+}
+
+func TestValidateZiplist(t *testing.T) {
+	zl := codec.EncodeZiplist([][]byte{[]byte("a"), []byte("hello"), []byte("42")})
+	for name, b := range corruptions(zl) {
+		checkCorrupt(t, name, codec.ValidateZiplist(b))
+	}
+	bad := append([]byte(nil), zl...)
+	binary.LittleEndian.PutUint16(bad[8:], 5) // entry count
+	checkCorrupt(t, "count", codec.ValidateZiplist(bad))
+	bad = append([]byte(nil), zl...)
+	binary.LittleEndian.PutUint32(bad[4:], 3) // tail offset
+	checkCorrupt(t, "tail", codec.ValidateZiplist(bad))
+	bad = append([]byte(nil), zl...)
+	bad[10+2+1] = 9 // prevlen of the second entry
+	checkCorrupt(t, "prevlen", codec.ValidateZiplist(bad))
+}
+
+func TestValidateListpack(t *testing.T) {
+	lp := codec.EncodeListpack([][]byte{[]byte("a"), []byte("hello"), []byte("42")})
+	for name, b := range corruptions(lp) {
+		checkCorrupt(t, name, codec.ValidateListpack(b))
+	}
+	bad := append([]byte(nil), lp...)
+	binary.LittleEndian.PutUint16(bad[4:], 5) // element count
+	checkCorrupt(t, "count", codec.ValidateListpack(bad))
+	bad = append([]byte(nil), lp...)
+	bad[6+2] = 7 // back-length of the first element
+	checkCorrupt(t, "backlen", codec.ValidateListpack(bad))
+}
+
+func TestIntset(t *testing.T) {
+	for _, members := range [][]int64{{3, 1, 2, 2}, {-70000, 5}, {1 << 40, -1}} {
+		var s codec.Intset
+		if err := s.Decode(codec.EncodeIntset(members)); err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i < s.Len(); i++ {
+			if s.At(i-1) >= s.At(i) {
+				t.Errorf("%v: members not ascending", members)
+			}
+		}
+	}
+	b := codec.EncodeIntset([]int64{1, 2})
+	binary.LittleEndian.PutUint16(b[8:], 2) // duplicate
+	var s codec.Intset
+	checkCorrupt(t, "duplicate", s.Decode(b))
+	checkCorrupt(t, "encoding", s.Decode([]byte{3, 0, 0, 0, 0, 0, 0, 0}))
+	checkCorrupt(t, "length", s.Decode(codec.EncodeIntset([]int64{1, 2})[:11]))
+}
+
+func TestZipmapRoundTrip(t *testing.T) {
+	want := [][]byte{[]byte("name"), []byte("ann"), []byte(strings.Repeat("k", 300)), []byte("")}
+	got, err := codec.DecodeZipmap(codec.EncodeZipmap(want))
+	if err != nil || !equalElems(got, want) {
+		t.Fatalf("got %q, err %v", got, err)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	for _, s := range []string{"", "0", "-1", "127", "-32768", "2147483647", "2147483648", "007", strings.Repeat("ab", 100), "hello"} {
+		for _, compress := range []bool{false, true} {
+			b := codec.AppendString(nil, []byte(s), compress)
+			got, size, err := codec.DecodeString(b)
+			if err != nil || string(got) != s || size != len(b) {
+				t.Errorf("%q (compress %v): got %q, size %d of %d, err %v", s, compress, got, size, len(b), err)
+			}
+			if err := codec.SkipString(bytes.NewReader(b)); err != nil {
+				t.Errorf("skip %q: %v", s, err)
+			}
+			// Skipping accounts for decompression as reading does.
+			var read, skipped codec.StringDecoder
+			read.Read(bytes.NewReader(b))
+			if err := skipped.Skip(bytes.NewReader(b)); err != nil || skipped.Expanded != read.Expanded {
+				t.Errorf("skip %q: expanded %d, want %d, err %v", s, skipped.Expanded, read.Expanded, err)
+			}
+		}
+	}
+	long := codec.AppendString(nil, []byte(strings.Repeat("ab", 100)), true)
+	if _, _, err := codec.DecodeString(long[:len(long)-1]); err == nil {
+		t.Error("truncated LZF string decoded")
+	}
+}
+
+func TestLengths(t *testing.T) {
+	for _, n := range []uint64{0, 63, 64, 16383, 16384, 1<<32 - 1, 1 << 32, 1<<64 - 1} {
+		b := codec.AppendLength(nil, n)
+		got, encoded, size, err := codec.DecodeLength(b)
+		if err != nil || encoded || got != n || size != len(b) || size != codec.LengthSize(n) {
+			t.Errorf("%d: got %d, size %d, err %v", n, got, size, err)
+		}
+	}
+}
+
+func TestCRC64(t *testing.T) {
+	// The check value of Redis' crc64 (Jones polynomial, reflected).
+	if got := codec.CRC64(0, []byte("123456789")); got != 0xe9c6d914c4b8d9ca {
+		t.Errorf("got %016x", got)
+	}
+}
@@ -0,0 +1,14 @@
+package codec
+
+import "hash/crc64"
+
+// crc64Jones is the reflected Jones polynomial Redis checksums dumps and
+// DUMP payloads with.
+var crc64Jones = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// CRC64 returns crc updated with p, using Redis' CRC-64 (Jones polynomial,
+// no initial or final inversion). Start with 0.
+func CRC64(crc uint64, p []byte) uint64 {
+	// hash/crc64 inverts the value on the way in and out; undo both.
+	return ^crc64.Update(^crc, crc64Jones, p)
+}
@@ -0,0 +1,9 @@
+// Package codec implements the low-level encodings found in Redis RDB files
+// and DUMP payloads: length and string encoding, LZF compression, and the
+// compact collection blobs (ziplist, listpack, intset and zipmap).
+//
+// Every encoding has a decoder and an encoder, so the package can be used
+// both to read dumps and to produce values that Redis will load. Decoders
+// validate their input the way Redis does with deep sanitization enabled and
+// report problems as a *CorruptError.
+package codec
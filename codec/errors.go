@@ -0,0 +1,53 @@
+package codec
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrFormat is returned when the input is malformed. Package rdb uses the
+// same value for malformed RDB streams.
+var ErrFormat = errors.New("rdb: invalid format")
+
+// ErrTooLarge is returned for lengths read from the input that exceed a
+// configured maximum or the largest int of the platform.
+var ErrTooLarge = errors.New("rdb: length too large")
+
+// LengthError reports a length read from the input that exceeds Max. It
+// wraps ErrTooLarge.
+type LengthError struct {
+	What   string // what the length counts, e.g. "string"
+	Length uint64
+	Max    uint64
+}
+
+func (e *LengthError) Error() string {
+	return "rdb: " + e.What + " length " + strconv.FormatUint(e.Length, 10) +
+		" exceeds the maximum of " + strconv.FormatUint(e.Max, 10)
+}
+
+// Unwrap returns ErrTooLarge so that errors.Is(err, ErrTooLarge) holds.
+func (e *LengthError) Unwrap() error {
+	return ErrTooLarge
+}
+
+// CorruptError describes a structural problem found in an encoded blob such
+// as a ziplist or listpack. It wraps ErrFormat.
+type CorruptError struct {
+	Encoding string // name of the encoding, e.g. "ziplist"
+	Offset   int    // byte offset of the problem within the blob
+	Reason   string
+}
+
+func (e *CorruptError) Error() string {
+	return "rdb: corrupt " + e.Encoding + " at offset " + strconv.Itoa(e.Offset) + ": " + e.Reason
+}
+
+// Unwrap returns ErrFormat so that errors.Is(err, ErrFormat) holds.
+func (e *CorruptError) Unwrap() error {
+	return ErrFormat
+}
+
+func corrupt(encoding string, off int, reason string) error {
+	return &CorruptError{Encoding: encoding, Offset: off, Reason: reason}
+}
@@ -0,0 +1,147 @@
+package codec
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+const intsetHeaderSize = 8 // encoding, length
+
+// Intset is a decoded intset blob: the sorted array of integers Redis uses
+// to store small sets of integers (the SetIntSet encoding). The zero value
+// is an empty set.
+type Intset struct {
+	width   int // bytes per member: 2, 4 or 8
+	members []byte
+}
+
+// Decode parses an intset blob, replacing the contents of s. The blob is
+// validated the way Redis does with deep sanitization: the encoding must be
+// 16, 32 or 64 bits, the length must match the blob size and the members
+// must be strictly ascending. s keeps a reference to b, which must not be
+// modified afterwards.
+func (s *Intset) Decode(b []byte) error {
+	if len(b) < intsetHeaderSize {
+		return corrupt("intset", 0, "blob shorter than header")
+	}
+	width := binary.LittleEndian.Uint32(b)
+	if width != 2 && width != 4 && width != 8 {
+		return corrupt("intset", 0, "invalid encoding")
+	}
+	n := int64(binary.LittleEndian.Uint32(b[4:]))
+	if n*int64(width) != int64(len(b)-intsetHeaderSize) {
+		return corrupt("intset", 4, "length does not match blob size")
+	}
+	t := Intset{width: int(width), members: b[intsetHeaderSize:]}
+	for i := 1; i < t.Len(); i++ {
+		if t.At(i-1) >= t.At(i) {
+			return corrupt("intset", intsetHeaderSize+i*t.width, "members are not strictly ascending")
+		}
+	}
+	*s = t
+	return nil
+}
+
+// EncodeIntset builds an intset blob holding members, using the narrowest
+// integer width that fits all of them. Members are sorted and duplicates
+// removed.
+func EncodeIntset(members []int64) []byte {
+	m := append([]int64(nil), members...)
+	sort.Slice(m, func(i, j int) bool { return m[i] < m[j] })
+	width := 2
+	uniq := m[:0]
+	for _, v := range m {
+		if len(uniq) > 0 && v == uniq[len(uniq)-1] {
+			continue
+		}
+		uniq = append(uniq, v)
+		if (v < math.MinInt32 || v > math.MaxInt32) && width < 8 {
+			width = 8
+		} else if (v < math.MinInt16 || v > math.MaxInt16) && width < 4 {
+			width = 4
+		}
+	}
+	b := make([]byte, intsetHeaderSize, intsetHeaderSize+width*len(uniq))
+	binary.LittleEndian.PutUint32(b, uint32(width))
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(uniq)))
+	for _, v := range uniq {
+		switch width {
+		case 2:
+			b = binary.LittleEndian.AppendUint16(b, uint16(v))
+		case 4:
+			b = binary.LittleEndian.AppendUint32(b, uint32(v))
+		default:
+			b = binary.LittleEndian.AppendUint64(b, uint64(v))
+		}
+	}
+	return b
+}
+
+// Len returns the number of members.
+func (s *Intset) Len() int {
+	if s.width == 0 {
+		return 0
+	}
+	return len(s.members) / s.width
+}
+
+// At returns the i-th smallest member. It panics if i is out of range.
+func (s *Intset) At(i int) int64 {
+	p := s.members[i*s.width : (i+1)*s.width]
+	switch s.width {
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(p)))
+	case 4:
+		return int64(int32(binary.LittleEndian.Uint32(p)))
+	}
+	return int64(binary.LittleEndian.Uint64(p))
+}
+
+// Contains reports whether v is a member of the set.
+func (s *Intset) Contains(v int64) bool {
+	n := s.Len()
+	i := sort.Search(n, func(i int) bool { return s.At(i) >= v })
+	return i < n && s.At(i) == v
+}
+
+// Members returns all members in ascending order.
+func (s *Intset) Members() []int64 {
+	m := make([]int64, s.Len())
+	for i := range m {
+		m[i] = s.At(i)
+	}
+	return m
+}
+
+// Iterator returns an iterator over the members in ascending order.
+func (s *Intset) Iterator() *IntsetIterator {
+	return &IntsetIterator{set: s, i: -1}
+}
+
+// IntsetIterator iterates over the members of an Intset.
+//
+//	it := set.Iterator()
+//	for it.Next() {
+//		v := it.Value()
+//		...
+//	}
+type IntsetIterator struct {
+	set *Intset
+	i   int
+}
+
+// Next advances to the next member and reports whether there is one.
+func (it *IntsetIterator) Next() bool {
+	if it.i+1 >= it.set.Len() {
+		it.i = it.set.Len()
+		return false
+	}
+	it.i++
+	return true
+}
+
+// Value returns the current member.
+func (it *IntsetIterator) Value() int64 {
+	return it.set.At(it.i)
+}
@@ -0,0 +1,138 @@
+package codec
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Length encoding type, stored in the two most significant bits of the
+// first byte.
+const (
+	len6Bit   = 0
+	len14Bit  = 1
+	len32Bit  = 0x80
+	len64Bit  = 0x81
+	lenEncVal = 3
+)
+
+// Special string encodings. When a length's two most significant bits are
+// both set, the value is not a length but one of these, and the string that
+// follows is stored as an integer or LZF-compressed.
+const (
+	EncInt8  = 0
+	EncInt16 = 1
+	EncInt32 = 2
+	EncLZF   = 3
+)
+
+// ReadLength reads a length-encoded integer. If encoded is true, n is not a
+// length but one of the Enc* special string encodings.
+func ReadLength(r io.ByteReader) (n uint64, encoded bool, err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch b >> 6 {
+	case len6Bit:
+		return uint64(b & 0x3f), false, nil
+	case len14Bit:
+		b2, err := r.ReadByte()
+		if err != nil {
+			return 0, false, noEOF(err)
+		}
+		return uint64(b&0x3f)<<8 | uint64(b2), false, nil
+	case lenEncVal:
+		return uint64(b & 0x3f), true, nil
+	}
+	var size int
+	switch b {
+	case len32Bit:
+		size = 4
+	case len64Bit:
+		size = 8
+	default:
+		return 0, false, corrupt("length", 0, "invalid length encoding")
+	}
+	for i := 0; i < size; i++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, false, noEOF(err)
+		}
+		n = n<<8 | uint64(c)
+	}
+	return n, false, nil
+}
+
+// DecodeLength decodes a length-encoded integer at the start of b and also
+// returns the number of bytes it occupies.
+func DecodeLength(b []byte) (n uint64, encoded bool, size int, err error) {
+	r := &sliceReader{b: b}
+	n, encoded, err = ReadLength(r)
+	if err != nil {
+		return 0, false, 0, noEOF(err)
+	}
+	return n, encoded, r.off, nil
+}
+
+// AppendLength appends the length encoding of n to dst, using the shortest
+// form that can represent it.
+func AppendLength(dst []byte, n uint64) []byte {
+	switch {
+	case n < 1<<6:
+		return append(dst, byte(n))
+	case n < 1<<14:
+		return append(dst, byte(n>>8)|len14Bit<<6, byte(n))
+	case n <= math.MaxUint32:
+		dst = append(dst, len32Bit)
+		return binary.BigEndian.AppendUint32(dst, uint32(n))
+	}
+	dst = append(dst, len64Bit)
+	return binary.BigEndian.AppendUint64(dst, n)
+}
+
+// LengthSize returns the number of bytes AppendLength uses to encode n.
+func LengthSize(n uint64) int {
+	switch {
+	case n < 1<<6:
+		return 1
+	case n < 1<<14:
+		return 2
+	case n <= math.MaxUint32:
+		return 5
+	}
+	return 9
+}
+
+// sliceReader is a minimal io.Reader and io.ByteReader over a byte slice
+// that tracks how much was consumed.
+type sliceReader struct {
+	b   []byte
+	off int
+}
+
+func (r *sliceReader) Read(p []byte) (int, error) {
+	if r.off >= len(r.b) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.b[r.off:])
+	r.off += n
+	return n, nil
+}
+
+func (r *sliceReader) ReadByte() (byte, error) {
+	if r.off >= len(r.b) {
+		return 0, io.EOF
+	}
+	c := r.b[r.off]
+	r.off++
+	return c, nil
+}
+
+// noEOF turns io.EOF in the middle of a value into io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
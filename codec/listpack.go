@@ -0,0 +1,257 @@
+package codec
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+const (
+	listpackHeaderSize = 6 // total bytes, number of elements
+	listpackEnd        = 0xff
+)
+
+// ValidateListpack performs a deep integrity check of a listpack blob, the
+// equivalent of Redis' lpValidateIntegrity with deep sanitization enabled.
+// It checks the total-bytes header, every element's encoding and length,
+// that each element's back-length matches its size, the element count and
+// the terminator byte.
+func ValidateListpack(lp []byte) error {
+	return WalkListpack(lp, nil)
+}
+
+// DecodeListpack validates a listpack blob and returns its elements.
+// Integer elements are returned in decimal form; string elements share
+// memory with lp.
+func DecodeListpack(lp []byte) ([][]byte, error) {
+	var elems [][]byte
+	err := WalkListpack(lp, func(s []byte, v int64, isInt bool) {
+		if isInt {
+			s = strconv.AppendInt(nil, v, 10)
+		}
+		elems = append(elems, s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return elems, nil
+}
+
+// EncodeListpack builds a listpack holding elems. Elements that are
+// canonical decimal integers are stored in the integer encodings, as Redis
+// does.
+func EncodeListpack(elems [][]byte) []byte {
+	lp := make([]byte, listpackHeaderSize, listpackHeaderSize+16*len(elems)+1)
+	for _, e := range elems {
+		start := len(lp)
+		if v, ok := ParseInt(e); ok {
+			lp = appendListpackInt(lp, v)
+		} else {
+			lp = appendListpackString(lp, e)
+		}
+		lp = appendListpackBacklen(lp, uint64(len(lp)-start))
+	}
+	lp = append(lp, listpackEnd)
+	binary.LittleEndian.PutUint32(lp, uint32(len(lp)))
+	n := len(elems)
+	if n > 0xffff {
+		n = 0xffff
+	}
+	binary.LittleEndian.PutUint16(lp[4:], uint16(n))
+	return lp
+}
+
+func appendListpackInt(lp []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v <= 127:
+		return append(lp, byte(v))
+	case v >= -4096 && v <= 4095:
+		u := uint16(v) & 0x1fff
+		return append(lp, 0xc0|byte(u>>8), byte(u))
+	case v >= -1<<15 && v < 1<<15:
+		lp = append(lp, 0xf1)
+		return binary.LittleEndian.AppendUint16(lp, uint16(v))
+	case v >= -1<<23 && v < 1<<23:
+		return append(lp, 0xf2, byte(v), byte(v>>8), byte(v>>16))
+	case v >= -1<<31 && v < 1<<31:
+		lp = append(lp, 0xf3)
+		return binary.LittleEndian.AppendUint32(lp, uint32(v))
+	}
+	lp = append(lp, 0xf4)
+	return binary.LittleEndian.AppendUint64(lp, uint64(v))
+}
+
+func appendListpackString(lp, s []byte) []byte {
+	switch n := len(s); {
+	case n < 1<<6:
+		lp = append(lp, 0x80|byte(n))
+	case n < 1<<12:
+		lp = append(lp, 0xe0|byte(n>>8), byte(n))
+	default:
+		lp = append(lp, 0xf0)
+		lp = binary.LittleEndian.AppendUint32(lp, uint32(n))
+	}
+	return append(lp, s...)
+}
+
+func appendListpackBacklen(lp []byte, n uint64) []byte {
+	// The most significant 7-bit group comes first with its high bit clear,
+	// every following group has the high bit set.
+	size := listpackBacklenSize(n)
+	for i := size - 1; i >= 0; i-- {
+		b := byte(n>>(7*uint(i))) & 0x7f
+		if i != size-1 {
+			b |= 0x80
+		}
+		lp = append(lp, b)
+	}
+	return lp
+}
+
+// WalkListpack validates lp and calls fn, if not nil, for every element in
+// order: for integers with isInt set and the value in v, for strings with
+// the string in s, which shares memory with lp. Unlike DecodeListpack it
+// allocates nothing, so a few elements of a large blob can be picked out
+// cheaply. fn may have been called for some elements when an error is
+// returned.
+func WalkListpack(lp []byte, fn func(s []byte, v int64, isInt bool)) error {
+	size := len(lp)
+	if size < listpackHeaderSize+1 {
+		return corrupt("listpack", 0, "blob shorter than header")
+	}
+	if n := binary.LittleEndian.Uint32(lp); int64(n) != int64(size) {
+		return corrupt("listpack", 0, "total bytes does not match blob size")
+	}
+	if lp[size-1] != listpackEnd {
+		return corrupt("listpack", size-1, "missing terminator")
+	}
+	header := int(binary.LittleEndian.Uint16(lp[4:]))
+
+	p, count := listpackHeaderSize, 0
+	for lp[p] != listpackEnd {
+		e, err := listpackElementAt(lp, p)
+		if err != nil {
+			return err
+		}
+		if fn != nil {
+			fn(e.str, e.val, e.isInt)
+		}
+		p += e.size
+		count++
+	}
+	if p != size-1 {
+		return corrupt("listpack", p, "unexpected terminator before the end of the blob")
+	}
+	if header != 0xffff && header != count {
+		return corrupt("listpack", 4, "element count does not match the number of elements")
+	}
+	return nil
+}
+
+type listpackElement struct {
+	size  int // total size including the back-length
+	str   []byte
+	val   int64
+	isInt bool
+}
+
+// listpackElementAt decodes the element at offset p, making sure it lies
+// within lp and that its back-length is consistent.
+func listpackElementAt(lp []byte, p int) (listpackElement, error) {
+	var e listpackElement
+	end := int64(len(lp) - 1) // elements may not overlap the terminator
+	off := int64(p)
+
+	var hdr, data int64
+	b := lp[p]
+	switch {
+	case b&0x80 == 0:
+		hdr, data = 1, 0
+	case b&0xc0 == 0x80:
+		hdr, data = 1, int64(b&0x3f)
+	case b&0xe0 == 0xc0:
+		hdr, data = 2, 0
+	case b&0xf0 == 0xe0:
+		if off+2 > end {
+			return e, corrupt("listpack", p, "element header out of range")
+		}
+		hdr, data = 2, int64(b&0x0f)<<8|int64(lp[p+1])
+	case b == 0xf0:
+		if off+5 > end {
+			return e, corrupt("listpack", p, "element header out of range")
+		}
+		hdr, data = 5, int64(binary.LittleEndian.Uint32(lp[p+1:]))
+	case b == 0xf1:
+		hdr, data = 1, 2
+	case b == 0xf2:
+		hdr, data = 1, 3
+	case b == 0xf3:
+		hdr, data = 1, 4
+	case b == 0xf4:
+		hdr, data = 1, 8
+	default:
+		return e, corrupt("listpack", p, "invalid element encoding")
+	}
+	n := hdr + data
+	bl := int64(listpackBacklenSize(uint64(n)))
+	if off+n+bl > end {
+		return e, corrupt("listpack", p, "element extends past the end")
+	}
+	if got := listpackDecodeBacklen(lp[:off+n+bl]); got != uint64(n) {
+		return e, corrupt("listpack", p, "back-length does not match element size")
+	}
+	e.size = int(n + bl)
+
+	d := lp[off+hdr : off+n]
+	switch {
+	case b&0x80 == 0:
+		e.val, e.isInt = int64(b), true
+	case b&0xe0 == 0xc0:
+		u := int64(b&0x1f)<<8 | int64(lp[p+1])
+		if u >= 1<<12 {
+			u -= 1 << 13
+		}
+		e.val, e.isInt = u, true
+	case b == 0xf1:
+		e.val, e.isInt = int64(int16(binary.LittleEndian.Uint16(d))), true
+	case b == 0xf2:
+		e.val, e.isInt = int64(int32(uint32(d[0])<<8|uint32(d[1])<<16|uint32(d[2])<<24)>>8), true
+	case b == 0xf3:
+		e.val, e.isInt = int64(int32(binary.LittleEndian.Uint32(d))), true
+	case b == 0xf4:
+		e.val, e.isInt = int64(binary.LittleEndian.Uint64(d)), true
+	default:
+		e.str = d
+	}
+	return e, nil
+}
+
+// listpackBacklenSize returns the number of bytes used to encode the
+// back-length of an element of n bytes.
+func listpackBacklenSize(n uint64) int {
+	switch {
+	case n <= 127:
+		return 1
+	case n < 16383:
+		return 2
+	case n < 2097151:
+		return 3
+	case n < 268435455:
+		return 4
+	}
+	return 5
+}
+
+// listpackDecodeBacklen decodes the back-length that ends at the last byte
+// of b, reading backwards as Redis does.
+func listpackDecodeBacklen(b []byte) uint64 {
+	var v uint64
+	var shift uint
+	for i := len(b) - 1; i >= 0 && shift < 35; i-- {
+		v |= uint64(b[i]&0x7f) << shift
+		if b[i]&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	return v
+}
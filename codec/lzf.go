@@ -0,0 +1,212 @@
+package codec
+
+import "sync"
+
+const (
+	lzfHashLog  = 16
+	lzfHashSize = 1 << lzfHashLog
+	lzfMaxLit   = 1 << 5
+	lzfMaxOff   = 1 << 13
+	lzfMaxRef   = 1<<8 + 1<<3
+
+	// lzfMaxRatio bounds the expansion of LZF data: the best case is a
+	// three byte back reference producing 264 bytes.
+	lzfMaxRatio = 88
+)
+
+// lzfState is the compressor's hash table. Slots are tagged with a
+// generation so the table does not need clearing between calls.
+type lzfState struct {
+	gen  uint32
+	pos  [lzfHashSize]int32
+	seen [lzfHashSize]uint32
+}
+
+var lzfPool = sync.Pool{New: func() any { return new(lzfState) }}
+
+func lzfIndex(h uint32) uint32 {
+	return ((h >> (3*8 - lzfHashLog)) - h*5) & (lzfHashSize - 1)
+}
+
+// CompressLZF compresses src into dst with the LZF algorithm used by Redis
+// (liblzf in its VERY_FAST configuration) and returns the compressed size.
+// It returns 0 if src is empty or the output does not fit in dst.
+func CompressLZF(dst, src []byte) int {
+	inLen, outLen := len(src), len(dst)
+	if inLen == 0 || outLen == 0 {
+		return 0
+	}
+	st := lzfPool.Get().(*lzfState)
+	defer lzfPool.Put(st)
+	st.gen++
+	if st.gen == 0 {
+		st.seen = [lzfHashSize]uint32{}
+		st.gen = 1
+	}
+	set := func(h uint32, ip int) {
+		i := lzfIndex(h)
+		st.pos[i], st.seen[i] = int32(ip), st.gen
+	}
+
+	ip, op, lit := 0, 1, 0 // op skips the first literal run header
+	var hval uint32
+	if inLen >= 2 {
+		hval = uint32(src[0])<<8 | uint32(src[1])
+	}
+	for ip < inLen-2 {
+		hval = hval<<8 | uint32(src[ip+2])
+		i := lzfIndex(hval)
+		ref := 0
+		if st.seen[i] == st.gen {
+			ref = int(st.pos[i])
+		}
+		st.pos[i], st.seen[i] = int32(ip), st.gen
+
+		off := ip - ref - 1
+		if ref > 0 && ref < ip && off < lzfMaxOff &&
+			src[ref+2] == src[ip+2] && src[ref] == src[ip] && src[ref+1] == src[ip+1] {
+			n := 2
+			maxN := inLen - ip - n
+			if maxN > lzfMaxRef {
+				maxN = lzfMaxRef
+			}
+			if op+3+1 >= outLen {
+				undo := 0
+				if lit == 0 {
+					undo = 1
+				}
+				if op-undo+3+1 >= outLen {
+					return 0
+				}
+			}
+			dst[op-lit-1] = byte(lit - 1) // stop run
+			if lit == 0 {
+				op-- // undo run if length is zero
+			}
+			for {
+				n++
+				if n >= maxN || src[ref+n] != src[ip+n] {
+					break
+				}
+			}
+			n -= 2 // n is now the match length - 1
+			ip++
+			if n < 7 {
+				dst[op] = byte(off>>8 + n<<5)
+				op++
+			} else {
+				dst[op] = byte(off>>8 + 7<<5)
+				dst[op+1] = byte(n - 7)
+				op += 2
+			}
+			dst[op] = byte(off)
+			op += 2 // start run
+			lit = 0
+			ip += n + 1
+			if ip >= inLen-2 {
+				break
+			}
+			ip -= 2
+			hval = uint32(src[ip])<<8 | uint32(src[ip+1])
+			hval = hval<<8 | uint32(src[ip+2])
+			set(hval, ip)
+			ip++
+			hval = hval<<8 | uint32(src[ip+2])
+			set(hval, ip)
+			ip++
+		} else {
+			if op >= outLen {
+				return 0
+			}
+			dst[op] = src[ip]
+			op++
+			ip++
+			lit++
+			if lit == lzfMaxLit {
+				dst[op-lit-1] = byte(lit - 1)
+				lit = 0
+				op++
+			}
+		}
+	}
+	if op+3 > outLen {
+		return 0
+	}
+	for ip < inLen {
+		dst[op] = src[ip]
+		op++
+		ip++
+		lit++
+		if lit == lzfMaxLit {
+			dst[op-lit-1] = byte(lit - 1)
+			lit = 0
+			op++
+		}
+	}
+	dst[op-lit-1] = byte(lit - 1) // end run
+	if lit == 0 {
+		op--
+	}
+	return op
+}
+
+// DecompressLZF decompresses src, which must expand to exactly n bytes.
+func DecompressLZF(src []byte, n int) ([]byte, error) {
+	dst := make([]byte, n)
+	if err := decompressLZF(dst, src); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// decompressLZF decompresses src into dst, which it must fill exactly.
+func decompressLZF(dst, src []byte) error {
+	n := len(dst)
+	ip, op := 0, 0
+	for ip < len(src) {
+		ctrl := int(src[ip])
+		ip++
+		if ctrl < 1<<5 {
+			ctrl++ // literal run of ctrl bytes
+			if op+ctrl > n {
+				return corrupt("lzf", ip-1, "output overflow")
+			}
+			if ip+ctrl > len(src) {
+				return corrupt("lzf", ip-1, "input overflow")
+			}
+			copy(dst[op:], src[ip:ip+ctrl])
+			ip += ctrl
+			op += ctrl
+			continue
+		}
+		length := ctrl >> 5 // back reference
+		if length == 7 {
+			if ip >= len(src) {
+				return corrupt("lzf", ip-1, "input overflow")
+			}
+			length += int(src[ip])
+			ip++
+		}
+		if ip >= len(src) {
+			return corrupt("lzf", ip-1, "input overflow")
+		}
+		ref := op - (ctrl&0x1f)<<8 - 1 - int(src[ip])
+		ip++
+		length += 2
+		if op+length > n {
+			return corrupt("lzf", ip-1, "output overflow")
+		}
+		if ref < 0 {
+			return corrupt("lzf", ip-1, "back reference before start")
+		}
+		for i := 0; i < length; i++ {
+			dst[op] = dst[ref]
+			op++
+			ref++
+		}
+	}
+	if op != n {
+		return corrupt("lzf", ip, "short output")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package codec
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Length bytes of the string score encoding that stand for special values
+// and are not followed by any data.
+const (
+	ScoreNaN    = 253
+	ScorePosInf = 254
+	ScoreNegInf = 255
+)
+
+// ReadScore reads a sorted set score in the string encoding of the legacy
+// ZSet type: a length byte followed by the score in decimal. The lengths
+// ScoreNaN, ScorePosInf and ScoreNegInf stand for those values.
+func ReadScore(r Reader) (float64, error) {
+	n, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case ScoreNaN:
+		return math.NaN(), nil
+	case ScorePosInf:
+		return math.Inf(1), nil
+	case ScoreNegInf:
+		return math.Inf(-1), nil
+	}
+	var buf [252]byte
+	if _, err := io.ReadFull(r, buf[:n]); err != nil {
+		return 0, noEOF(err)
+	}
+	f, err := strconv.ParseFloat(string(buf[:n]), 64)
+	if err != nil && !isRangeError(err) {
+		return 0, corrupt("score", 0, "invalid score "+strconv.Quote(string(buf[:n])))
+	}
+	return f, nil
+}
+
+// isRangeError reports whether err is ParseFloat's out of range error, for
+// which it returns ±Inf or 0 as strtod does.
+func isRangeError(err error) bool {
+	ne, ok := err.(*strconv.NumError)
+	return ok && ne.Err == strconv.ErrRange
+}
+
+// AppendScore appends f in the string score encoding, formatted the way
+// Redis does with "%.17g".
+func AppendScore(dst []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(dst, ScoreNaN)
+	case math.IsInf(f, 1):
+		return append(dst, ScorePosInf)
+	case math.IsInf(f, -1):
+		return append(dst, ScoreNegInf)
+	}
+	var buf [32]byte
+	s := strconv.AppendFloat(buf[:0], f, 'g', 17, 64)
+	dst = append(dst, byte(len(s)))
+	return append(dst, s...)
+}
+
+// ReadBinaryScore reads a sorted set score in the binary encoding of the
+// ZSet2 type, a little-endian IEEE 754 double.
+func ReadBinaryScore(r io.Reader) (float64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+}
+
+// AppendBinaryScore appends f in the binary score encoding.
+func AppendBinaryScore(dst []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(dst, math.Float64bits(f))
+}
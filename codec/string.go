@@ -0,0 +1,324 @@
+package codec
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Reader is the input the streaming decoders consume.
+type Reader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// maxChunk bounds the up-front allocation for a string so that a corrupt
+// length cannot exhaust memory before the input runs out.
+const maxChunk = 1 << 20
+
+// ReadString reads a string in any of the RDB string encodings: raw,
+// integer or LZF-compressed. Integers are returned in decimal form.
+func ReadString(r Reader) ([]byte, error) {
+	var d StringDecoder
+	return d.Read(r)
+}
+
+// A StringDecoder reads strings like ReadString, with control over their
+// allocation and size.
+type StringDecoder struct {
+	// Alloc returns a slice of length n whose contents are then
+	// overwritten. Strings longer than 1 MiB, whose length cannot be
+	// trusted before they have been read, are allocated from the heap, as
+	// are all strings if Alloc is nil.
+	Alloc func(n int) []byte
+
+	// MaxLen is the length of the longest string accepted, after
+	// decompression. Longer strings fail with a *LengthError. 0 means no
+	// limit other than the largest int, which matters on 32-bit platforms.
+	MaxLen uint64
+
+	// Expanded accumulates, over the LZF-compressed strings read, the
+	// difference between their decompressed and compressed lengths.
+	Expanded uint64
+}
+
+// Read reads a string from r.
+func (d *StringDecoder) Read(r Reader) ([]byte, error) {
+	alloc := d.Alloc
+	if alloc == nil {
+		alloc = makeBytes
+	}
+	n, encoded, err := ReadLength(r)
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		if err := d.check(n); err != nil {
+			return nil, err
+		}
+		return readBytes(r, n, alloc)
+	}
+	switch n {
+	case EncInt8, EncInt16, EncInt32:
+		v, err := readInt(r, n)
+		if err != nil {
+			return nil, err
+		}
+		var buf [20]byte
+		s := strconv.AppendInt(buf[:0], v, 10)
+		if err := d.check(uint64(len(s))); err != nil {
+			return nil, err
+		}
+		b := alloc(len(s))
+		copy(b, s)
+		return b, nil
+	case EncLZF:
+		clen, _, err := ReadLength(r)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		ulen, _, err := ReadLength(r)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if err := d.check(ulen); err != nil {
+			return nil, err
+		}
+		if clen <= math.MaxUint64/lzfMaxRatio && ulen > clen*lzfMaxRatio {
+			return nil, corrupt("lzf", 0, "uncompressed length out of range")
+		}
+		c, err := readBytes(r, clen, makeBytes)
+		if err != nil {
+			return nil, err
+		}
+		if ulen > clen {
+			d.Expanded += ulen - clen
+		}
+		if ulen > maxChunk {
+			return DecompressLZF(c, int(ulen))
+		}
+		b := alloc(int(ulen))
+		if err := decompressLZF(b, c); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	return nil, corrupt("string", 0, "invalid string encoding")
+}
+
+// check returns a *LengthError if a string of length n is not accepted.
+func (d *StringDecoder) check(n uint64) error {
+	return CheckLength("string", n, d.MaxLen)
+}
+
+// CheckLength returns a *LengthError if the length n of what exceeds
+// limit or, whatever the limit, the largest int. A limit of 0 means no
+// limit.
+func CheckLength(what string, n, limit uint64) error {
+	if limit == 0 || limit > math.MaxInt {
+		limit = math.MaxInt
+	}
+	if n > limit {
+		return &LengthError{What: what, Length: n, Max: limit}
+	}
+	return nil
+}
+
+// SkipString reads past a string in any of the RDB string encodings
+// without decoding it.
+func SkipString(r Reader) error {
+	_, err := skipString(r)
+	return err
+}
+
+// Skip reads past a string like SkipString, adding to d.Expanded what the
+// string would gain if it was LZF-compressed and decompressed.
+func (d *StringDecoder) Skip(r Reader) error {
+	expanded, err := skipString(r)
+	d.Expanded += expanded
+	return err
+}
+
+// skipString reads past a string and returns the difference between its
+// decompressed and compressed lengths, 0 unless it is LZF-compressed.
+func skipString(r Reader) (uint64, error) {
+	n, encoded, err := ReadLength(r)
+	if err != nil {
+		return 0, err
+	}
+	var expanded uint64
+	if encoded {
+		switch n {
+		case EncInt8, EncInt16, EncInt32:
+			n = 1 << n
+		case EncLZF:
+			var ulen uint64
+			if n, _, err = ReadLength(r); err == nil {
+				ulen, _, err = ReadLength(r)
+			}
+			if err != nil {
+				return 0, noEOF(err)
+			}
+			if ulen > n {
+				expanded = ulen - n
+			}
+		default:
+			return 0, corrupt("string", 0, "invalid string encoding")
+		}
+	}
+	if err := Skip(r, n); err != nil {
+		return 0, err
+	}
+	return expanded, nil
+}
+
+// Skip discards n bytes from r.
+func Skip(r io.Reader, n uint64) error {
+	if n > math.MaxInt64 {
+		return corrupt("string", 0, "length out of range")
+	}
+	if _, err := io.CopyN(io.Discard, r, int64(n)); err != nil {
+		return noEOF(err)
+	}
+	return nil
+}
+
+// DecodeString decodes a string at the start of b and also returns the
+// number of bytes it occupies.
+func DecodeString(b []byte) (s []byte, size int, err error) {
+	r := &sliceReader{b: b}
+	s, err = ReadString(r)
+	if err != nil {
+		return nil, 0, noEOF(err)
+	}
+	return s, r.off, nil
+}
+
+// AppendString appends the RDB encoding of s to dst the way Redis saves
+// strings: short strings holding a canonical integer in the int32 range
+// are integer-encoded, strings longer than 20 bytes are LZF-compressed when
+// compress is set and compression saves at least 4 bytes, and everything
+// else is stored raw.
+func AppendString(dst, s []byte, compress bool) []byte {
+	if len(s) <= 11 {
+		if v, ok := ParseInt(s); ok && v >= math.MinInt32 && v <= math.MaxInt32 {
+			return AppendInt(dst, v)
+		}
+	}
+	if compress && len(s) > 20 {
+		if out, ok := AppendLZFString(dst, s); ok {
+			return out
+		}
+	}
+	return AppendRawString(dst, s)
+}
+
+// AppendRawString appends s to dst as a length-prefixed raw string.
+func AppendRawString(dst, s []byte) []byte {
+	dst = AppendLength(dst, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// AppendLZFString appends s to dst in the LZF-compressed string encoding.
+// It reports false and leaves dst unchanged if compression would not save
+// at least 4 bytes.
+func AppendLZFString(dst, s []byte) ([]byte, bool) {
+	if len(s) <= 4 {
+		return dst, false
+	}
+	buf := make([]byte, len(s)-4)
+	n := CompressLZF(buf, s)
+	if n == 0 {
+		return dst, false
+	}
+	dst = append(dst, lenEncVal<<6|EncLZF)
+	dst = AppendLength(dst, uint64(n))
+	dst = AppendLength(dst, uint64(len(s)))
+	return append(dst, buf[:n]...), true
+}
+
+// AppendInt appends v in the smallest integer string encoding. It panics if
+// v does not fit in 32 bits; larger integers must be saved as strings.
+func AppendInt(dst []byte, v int64) []byte {
+	switch {
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return append(dst, lenEncVal<<6|EncInt8, byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		dst = append(dst, lenEncVal<<6|EncInt16)
+		return binary.LittleEndian.AppendUint16(dst, uint16(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		dst = append(dst, lenEncVal<<6|EncInt32)
+		return binary.LittleEndian.AppendUint32(dst, uint32(v))
+	}
+	panic("codec: integer out of range for string encoding")
+}
+
+// ParseInt parses s as a decimal integer the way Redis' string2ll does: it
+// only accepts the canonical form, so that formatting the result yields s
+// again (no sign on zero, no leading zeros, no '+').
+func ParseInt(s []byte) (int64, bool) {
+	if len(s) == 0 || len(s) > 20 {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(string(s), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	var buf [20]byte
+	if string(strconv.AppendInt(buf[:0], v, 10)) != string(s) {
+		return 0, false
+	}
+	return v, true
+}
+
+func readInt(r io.ByteReader, enc uint64) (int64, error) {
+	size := 1 << enc // 1, 2 or 4 bytes
+	var u uint32
+	for i := 0; i < size; i++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, noEOF(err)
+		}
+		u |= uint32(c) << (8 * i)
+	}
+	switch size {
+	case 1:
+		return int64(int8(u)), nil
+	case 2:
+		return int64(int16(u)), nil
+	}
+	return int64(int32(u)), nil
+}
+
+// readBytes reads exactly n bytes, growing the buffer as data arrives
+// rather than trusting n up front.
+func readBytes(r io.Reader, n uint64, alloc func(n int) []byte) ([]byte, error) {
+	if err := CheckLength("string", n, 0); err != nil {
+		return nil, err
+	}
+	if n <= maxChunk {
+		b := alloc(int(n))
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, noEOF(err)
+		}
+		return b, nil
+	}
+	b := make([]byte, 0, maxChunk)
+	for uint64(len(b)) < n {
+		chunk := n - uint64(len(b))
+		if chunk > maxChunk {
+			chunk = maxChunk
+		}
+		off := len(b)
+		b = append(b, make([]byte, chunk)...)
+		if _, err := io.ReadFull(r, b[off:]); err != nil {
+			return nil, noEOF(err)
+		}
+	}
+	return b, nil
+}
+
+func makeBytes(n int) []byte {
+	return make([]byte, n)
+}
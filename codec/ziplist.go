@@ -0,0 +1,239 @@
+package codec
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+const (
+	ziplistHeaderSize = 10 // zlbytes, zltail, zllen
+	ziplistEnd        = 0xff
+	ziplistBigPrevLen = 0xfe
+)
+
+// ValidateZiplist performs a deep integrity check of a ziplist blob, the
+// equivalent of Redis' ziplistValidateIntegrity with deep sanitization
+// enabled. It checks the zlbytes and zltail header fields, every entry's
+// prevlen and encoding header, that no entry extends past the end of the
+// blob, the entry count and the zlend terminator.
+func ValidateZiplist(zl []byte) error {
+	return WalkZiplist(zl, nil)
+}
+
+// DecodeZiplist validates a ziplist blob and returns its entries. Integer
+// entries are returned in decimal form, as Redis replies with them; string
+// entries share memory with zl.
+func DecodeZiplist(zl []byte) ([][]byte, error) {
+	var entries [][]byte
+	err := WalkZiplist(zl, func(s []byte, v int64, isInt bool) {
+		if isInt {
+			s = strconv.AppendInt(nil, v, 10)
+		}
+		entries = append(entries, s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// EncodeZiplist builds a ziplist holding entries. Entries that are
+// canonical decimal integers are stored in the integer encodings, as Redis
+// does.
+func EncodeZiplist(entries [][]byte) []byte {
+	zl := make([]byte, ziplistHeaderSize, ziplistHeaderSize+16*len(entries)+1)
+	tail, prevLen := ziplistHeaderSize, 0
+	for _, e := range entries {
+		start := len(zl)
+		if prevLen < ziplistBigPrevLen {
+			zl = append(zl, byte(prevLen))
+		} else {
+			zl = append(zl, ziplistBigPrevLen)
+			zl = binary.LittleEndian.AppendUint32(zl, uint32(prevLen))
+		}
+		if v, ok := ParseInt(e); ok {
+			zl = appendZiplistInt(zl, v)
+		} else {
+			zl = appendZiplistString(zl, e)
+		}
+		tail, prevLen = start, len(zl)-start
+	}
+	zl = append(zl, ziplistEnd)
+	binary.LittleEndian.PutUint32(zl, uint32(len(zl)))
+	binary.LittleEndian.PutUint32(zl[4:], uint32(tail))
+	n := len(entries)
+	if n > 0xffff {
+		n = 0xffff
+	}
+	binary.LittleEndian.PutUint16(zl[8:], uint16(n))
+	return zl
+}
+
+func appendZiplistInt(zl []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v <= 12:
+		return append(zl, 0xf1+byte(v))
+	case v >= -1<<7 && v < 1<<7:
+		return append(zl, 0xfe, byte(v))
+	case v >= -1<<15 && v < 1<<15:
+		zl = append(zl, 0xc0)
+		return binary.LittleEndian.AppendUint16(zl, uint16(v))
+	case v >= -1<<23 && v < 1<<23:
+		return append(zl, 0xf0, byte(v), byte(v>>8), byte(v>>16))
+	case v >= -1<<31 && v < 1<<31:
+		zl = append(zl, 0xd0)
+		return binary.LittleEndian.AppendUint32(zl, uint32(v))
+	}
+	zl = append(zl, 0xe0)
+	return binary.LittleEndian.AppendUint64(zl, uint64(v))
+}
+
+func appendZiplistString(zl, s []byte) []byte {
+	switch n := len(s); {
+	case n < 1<<6:
+		zl = append(zl, byte(n))
+	case n < 1<<14:
+		zl = append(zl, 0x40|byte(n>>8), byte(n))
+	default:
+		zl = append(zl, 0x80)
+		zl = binary.BigEndian.AppendUint32(zl, uint32(n))
+	}
+	return append(zl, s...)
+}
+
+// WalkZiplist validates zl and calls fn, if not nil, for every entry in
+// order: for integers with isInt set and the value in v, for strings with
+// the string in s, which shares memory with zl. Unlike DecodeZiplist it
+// allocates nothing, so a few entries of a large blob can be picked out
+// cheaply. fn may have been called for some entries when an error is
+// returned.
+func WalkZiplist(zl []byte, fn func(s []byte, v int64, isInt bool)) error {
+	size := len(zl)
+	if size < ziplistHeaderSize+1 {
+		return corrupt("ziplist", 0, "blob shorter than header")
+	}
+	if n := binary.LittleEndian.Uint32(zl); int64(n) != int64(size) {
+		return corrupt("ziplist", 0, "zlbytes does not match blob size")
+	}
+	tail := int64(binary.LittleEndian.Uint32(zl[4:]))
+	if tail > int64(size-1) {
+		return corrupt("ziplist", 4, "zltail points past the end")
+	}
+	if zl[size-1] != ziplistEnd {
+		return corrupt("ziplist", size-1, "missing zlend terminator")
+	}
+	header := int(binary.LittleEndian.Uint16(zl[8:]))
+
+	p, prev, prevLen, count := ziplistHeaderSize, ziplistHeaderSize, 0, 0
+	for zl[p] != ziplistEnd {
+		e, err := ziplistEntryAt(zl, p)
+		if err != nil {
+			return err
+		}
+		if e.prevLen != prevLen {
+			return corrupt("ziplist", p, "prevlen does not match previous entry length")
+		}
+		if fn != nil {
+			fn(e.str, e.val, e.isInt)
+		}
+		prev, prevLen = p, e.size
+		p += e.size
+		count++
+	}
+	if p != size-1 {
+		return corrupt("ziplist", p, "unexpected zlend before the end of the blob")
+	}
+	if int64(prev) != tail {
+		return corrupt("ziplist", 4, "zltail does not point to the last entry")
+	}
+	if header != 0xffff && header != count {
+		return corrupt("ziplist", 8, "zllen does not match the number of entries")
+	}
+	return nil
+}
+
+type ziplistEntry struct {
+	size    int // total size including prevlen and header
+	prevLen int
+	str     []byte
+	val     int64
+	isInt   bool
+}
+
+// ziplistEntryAt decodes the entry at offset p, making sure it lies within
+// zl.
+func ziplistEntryAt(zl []byte, p int) (ziplistEntry, error) {
+	var e ziplistEntry
+	end := int64(len(zl) - 1) // entries may not overlap zlend
+	off := int64(p)
+	if zl[p] == ziplistBigPrevLen {
+		if off+5 > end {
+			return e, corrupt("ziplist", p, "prevlen out of range")
+		}
+		e.prevLen = int(binary.LittleEndian.Uint32(zl[p+1:]))
+		off += 5
+	} else {
+		e.prevLen = int(zl[p])
+		off++
+	}
+	if off >= end {
+		return e, corrupt("ziplist", p, "entry header out of range")
+	}
+
+	var hdr, data int64
+	enc := zl[off]
+	switch {
+	case enc>>6 == 0:
+		hdr, data = 1, int64(enc&0x3f)
+	case enc>>6 == 1:
+		if off+2 > end {
+			return e, corrupt("ziplist", p, "entry header out of range")
+		}
+		hdr, data = 2, int64(enc&0x3f)<<8|int64(zl[off+1])
+	case enc>>6 == 2:
+		if off+5 > end {
+			return e, corrupt("ziplist", p, "entry header out of range")
+		}
+		hdr, data = 5, int64(binary.BigEndian.Uint32(zl[off+1:]))
+	case enc == 0xc0:
+		hdr, data = 1, 2
+	case enc == 0xd0:
+		hdr, data = 1, 4
+	case enc == 0xe0:
+		hdr, data = 1, 8
+	case enc == 0xf0:
+		hdr, data = 1, 3
+	case enc == 0xfe:
+		hdr, data = 1, 1
+	case enc >= 0xf1 && enc <= 0xfd:
+		hdr, data = 1, 0
+	default:
+		return e, corrupt("ziplist", p, "invalid entry encoding")
+	}
+	if off+hdr+data > end {
+		return e, corrupt("ziplist", p, "entry extends past the end")
+	}
+	e.size = int(off + hdr + data - int64(p))
+
+	b := zl[off+hdr : off+hdr+data]
+	if enc>>6 != 3 {
+		e.str = b
+		return e, nil
+	}
+	e.isInt = true
+	switch enc {
+	case 0xc0:
+		e.val = int64(int16(binary.LittleEndian.Uint16(b)))
+	case 0xd0:
+		e.val = int64(int32(binary.LittleEndian.Uint32(b)))
+	case 0xe0:
+		e.val = int64(binary.LittleEndian.Uint64(b))
+	case 0xf0:
+		e.val = int64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8)
+	case 0xfe:
+		e.val = int64(int8(b[0]))
+	default:
+		e.val = int64(enc&0x0f) - 1
+	}
+	return e, nil
+}
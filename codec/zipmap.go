@@ -0,0 +1,111 @@
+package codec
+
+import "encoding/binary"
+
+const (
+	zipmapBigLen = 254
+	zipmapEnd    = 0xff
+)
+
+// DecodeZipmap parses a zipmap blob, the hash encoding used by Redis before
+// 2.6 and still found in legacy DUMP payloads. It returns the fields and
+// values in stored order, alternating field, value, field, value, ...
+//
+// The blob is validated as it is walked: every length must lie within the
+// blob, the 0xff terminator must be the last byte, the count in the header
+// (when it is below 254) must match and fields must be unique. The returned
+// strings share memory with zm.
+func DecodeZipmap(zm []byte) ([][]byte, error) {
+	if len(zm) < 2 {
+		return nil, corrupt("zipmap", 0, "blob shorter than header")
+	}
+	if zm[len(zm)-1] != zipmapEnd {
+		return nil, corrupt("zipmap", len(zm)-1, "missing terminator")
+	}
+	header := int(zm[0])
+
+	var pairs [][]byte
+	seen := make(map[string]struct{})
+	p := 1
+	for zm[p] != zipmapEnd {
+		field, next, err := zipmapString(zm, p, false)
+		if err != nil {
+			return nil, err
+		}
+		if zm[next] == zipmapEnd {
+			return nil, corrupt("zipmap", next, "field without a value")
+		}
+		value, end, err := zipmapString(zm, next, true)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := seen[string(field)]; dup {
+			return nil, corrupt("zipmap", p, "duplicate field")
+		}
+		seen[string(field)] = struct{}{}
+		pairs = append(pairs, field, value)
+		p = end
+	}
+	if p != len(zm)-1 {
+		return nil, corrupt("zipmap", p, "unexpected terminator before the end of the blob")
+	}
+	if header < zipmapBigLen && header != len(pairs)/2 {
+		return nil, corrupt("zipmap", 0, "length does not match the number of pairs")
+	}
+	return pairs, nil
+}
+
+// EncodeZipmap builds a zipmap from alternating fields and values. It
+// panics if pairs has an odd length.
+func EncodeZipmap(pairs [][]byte) []byte {
+	if len(pairs)%2 != 0 {
+		panic("codec: odd number of zipmap elements")
+	}
+	n := len(pairs) / 2
+	if n > zipmapBigLen {
+		n = zipmapBigLen
+	}
+	zm := []byte{byte(n)}
+	for i, s := range pairs {
+		if len(s) < zipmapBigLen {
+			zm = append(zm, byte(len(s)))
+		} else {
+			zm = append(zm, zipmapBigLen)
+			zm = binary.LittleEndian.AppendUint32(zm, uint32(len(s)))
+		}
+		if i%2 == 1 {
+			zm = append(zm, 0) // no free bytes after the value
+		}
+		zm = append(zm, s...)
+	}
+	return append(zm, zipmapEnd)
+}
+
+// zipmapString reads the length-prefixed string at offset p. Values are
+// followed by a free byte counting unused bytes after the value, which are
+// skipped. It returns the string and the offset following it.
+func zipmapString(zm []byte, p int, value bool) ([]byte, int, error) {
+	end := int64(len(zm) - 1) // strings may not overlap the terminator
+	off := int64(p)
+	n := int64(zm[p])
+	off++
+	if n == zipmapBigLen {
+		if off+4 > end {
+			return nil, 0, corrupt("zipmap", p, "length out of range")
+		}
+		n = int64(binary.LittleEndian.Uint32(zm[off:]))
+		off += 4
+	}
+	var free int64
+	if value {
+		if off+1 > end {
+			return nil, 0, corrupt("zipmap", p, "free byte out of range")
+		}
+		free = int64(zm[off])
+		off++
+	}
+	if off+n+free > end {
+		return nil, 0, corrupt("zipmap", p, "string extends past the end")
+	}
+	return zm[off : off+n], int(off + n + free), nil
+}
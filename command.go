@@ -0,0 +1,55 @@
+package rdb
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Command is a Redis command name followed by its arguments.
+type Command []RedisString
+
+// NewCommand builds a Command from plain Go strings.
+func NewCommand(args ...string) Command {
+	c := make(Command, len(args))
+	for i, a := range args {
+		c[i] = RedisString(a)
+	}
+	return c
+}
+
+// Name returns the upper-cased command name, or "" for an empty command.
+func (c Command) Name() string {
+	if len(c) == 0 {
+		return ""
+	}
+	return strings.ToUpper(string(c[0]))
+}
+
+// String renders the command on a single line the way redis-cli accepts it:
+// plain words are written as is, anything else is double-quoted and escaped.
+func (c Command) String() string {
+	var b strings.Builder
+	for i, arg := range c {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if isPlainWord(arg) {
+			b.Write(arg)
+		} else {
+			b.WriteString(strconv.Quote(string(arg)))
+		}
+	}
+	return b.String()
+}
+
+func isPlainWord(s RedisString) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, c := range s {
+		if c <= ' ' || c >= 0x7f || c == '"' || c == '\'' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
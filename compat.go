@@ -0,0 +1,123 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Compat selects tolerance for dumps written by Redis forks whose output
+// deviates slightly from Redis'. Flags can be combined.
+type Compat uint
+
+const (
+	// CompatValkey accepts Valkey's own header, "VALKEY" followed by a
+	// three digit version, used from RDB version 80 on.
+	CompatValkey Compat = 1 << iota
+
+	// CompatDragonfly accepts the extra opcodes found in Dragonfly
+	// snapshots (.rdb and per-shard .dfs files): key property masks,
+	// full-sync markers and journal offsets. Snapshots using Dragonfly's
+	// zstd or lz4 blob compression are reported as ErrNotSupported.
+	CompatDragonfly
+
+	// CompatElastiCache accepts backups exported from AWS ElastiCache: an
+	// export that ends right after the EOF opcode, without its checksum,
+	// is not an error.
+	CompatElastiCache
+
+	// CompatAll enables every compatibility mode.
+	CompatAll = CompatValkey | CompatDragonfly | CompatElastiCache
+)
+
+// WithCompat enables compatibility with the given Redis forks.
+//
+// KeyDB dumps need no flag: KeyDB writes Redis' header and RDB version 10.
+// Forks also write auxiliary fields Redis does not know about, including
+// per-key fields between keys (KeyDB's mvcc-tstamp and keydb-subexpire-*,
+// for instance). These need no flag: auxiliary fields found before the
+// first key are returned by Reader.Aux, later ones are attached to the
+// following Entry. Note that KeyDB writes keydb-subexpire-* after the key
+// they describe, so they arrive with the entry after it.
+func WithCompat(c Compat) Option {
+	return func(r *Reader) {
+		r.compat |= c
+	}
+}
+
+const valkeyMinVersion = 80
+
+// Dragonfly opcodes, from Dragonfly's rdb_extensions.h.
+const (
+	dfOpFullSyncEnd        = 200
+	dfOpZstdBlobStart      = 201
+	dfOpLZ4BlobStart       = 202
+	dfOpCompressedBlobEnd  = 203
+	dfOpJournalBlob        = 210
+	dfOpJournalOffset      = 211
+	dfOpMask               = 220
+	dfMaskFlagMemcacheFlag = 1 << 1
+)
+
+// parseHeader checks the magic string and returns the RDB version.
+func (r *Reader) parseHeader(h [9]byte) (int, error) {
+	var digits []byte
+	switch {
+	case string(h[:5]) == "REDIS":
+		digits = h[5:]
+	case r.compat&CompatValkey != 0 && string(h[:6]) == "VALKEY":
+		digits = h[6:]
+	default:
+		return 0, fmt.Errorf("%w: bad magic %q", ErrFormat, h[:5])
+	}
+	v := 0
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%w: bad version %q", ErrFormat, digits)
+		}
+		v = v*10 + int(c-'0')
+	}
+	return v, r.checkVersion(v)
+}
+
+// checkVersion returns ErrVersion if the enabled compatibility modes do
+// not make v a supported version.
+func (r *Reader) checkVersion(v int) error {
+	switch {
+	case v >= MinVersion && v <= MaxVersion:
+	case r.compat&CompatValkey != 0 && v >= valkeyMinVersion:
+	default:
+		return fmt.Errorf("%w: %d", ErrVersion, v)
+	}
+	return nil
+}
+
+// readCompatOpcode consumes a fork specific opcode. It reports false if op
+// is not one the enabled compatibility modes know about.
+func (r *Reader) readCompatOpcode(op byte) (bool, error) {
+	if r.compat&CompatDragonfly == 0 {
+		return false, nil
+	}
+	switch op {
+	case dfOpFullSyncEnd, dfOpCompressedBlobEnd:
+		return true, nil
+	case dfOpJournalOffset:
+		var b [8]byte
+		_, err := io.ReadFull(r.in, b[:])
+		return true, err
+	case dfOpMask:
+		var b [4]byte
+		if _, err := io.ReadFull(r.in, b[:]); err != nil {
+			return true, err
+		}
+		if binary.LittleEndian.Uint32(b[:])&dfMaskFlagMemcacheFlag != 0 {
+			if _, err := io.ReadFull(r.in, b[:]); err != nil {
+				return true, err
+			}
+		}
+		return true, nil
+	case dfOpZstdBlobStart, dfOpLZ4BlobStart, dfOpJournalBlob:
+		return true, fmt.Errorf("%w: Dragonfly opcode %d", ErrNotSupported, op)
+	}
+	return false, nil
+}
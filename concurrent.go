@@ -0,0 +1,166 @@
+package rdb
+
+import (
+	"bytes"
+	"io"
+)
+
+// A Record is a key whose value has been read from the dump but not yet
+// decoded. Records let the decoding, which is where most of the work of
+// reading a dump goes, happen on other goroutines: a Record does not refer
+// to the Reader's mutable state, so it may be handed to another goroutine,
+// which then owns it.
+type Record struct {
+	// Entry holds the key and its metadata. Its Value is nil until Decode
+	// is called.
+	Entry *Entry
+
+	// Data holds the serialized value. It is nil if ReadRecord completed
+	// the entry itself: with WithKeysOnly, and for module values and
+	// those of registered types.
+	Data []byte
+
+	offset  int64
+	version int
+	compat  Compat
+	fields  func(field []byte) bool
+	limits  Limits
+}
+
+// ReadRecord reads the next key from the stream like ReadEntry but leaves
+// its value undecoded. Keys of types the Reader cannot decode fail with
+// ErrNotSupported or are skipped, as with ReadEntry. The entry's sizes are
+// set already.
+func (r *Reader) ReadRecord() (*Record, error) {
+	r.deferDecode = true
+	e, err := r.ReadEntry()
+	r.deferDecode = false
+	if err != nil {
+		return nil, err
+	}
+	rec := &Record{
+		Entry:   e,
+		Data:    r.deferred,
+		offset:  r.deferredOff,
+		version: r.version,
+		compat:  r.compat,
+		fields:  r.hashFields,
+		limits:  r.limits(),
+	}
+	r.deferred = nil
+	return rec, nil
+}
+
+// Decode decodes the value, sets it in rec.Entry and returns the entry,
+// which it returns as is if Data is nil. Options that affect decoding,
+// such as WithHashFields, are those of the Reader the record was read
+// with.
+func (rec *Record) Decode() (*Entry, error) {
+	decode := valueDecoders[rec.Entry.ValueType]
+	if rec.Data == nil || decode == nil {
+		return rec.Entry, nil
+	}
+	d := &Reader{
+		in:         newInput(bytes.NewReader(rec.Data)),
+		version:    rec.version,
+		compat:     rec.compat,
+		hashFields: rec.fields,
+	}
+	WithLimits(rec.limits)(d)
+	v, err := decode(d, rec.Entry.ValueType)
+	if err != nil {
+		return nil, d.fail(rec.offset, err)
+	}
+	rec.Entry.Value = v
+	return rec.Entry, nil
+}
+
+// DecodeParallel reads src to the end and decodes the values on the given
+// number of goroutines, calling fn for every entry in dump order.
+//
+// A Reader is not safe for concurrent use, and guarding it with a mutex
+// does not make reading faster, as every read depends on the previous one.
+// DecodeParallel instead reads records on a single goroutine, which is the
+// only one to use src until DecodeParallel returns, and hands each record
+// to one worker that decodes it. fn runs on the calling goroutine and may
+// keep the entries it is passed. DecodeParallel stops at the first error,
+// including one returned by fn.
+//
+// With WithAutoCheckpoint, checkpoints are saved before fn is called with
+// the entry that follows them, as the records read ahead of fn would be
+// lost if a checkpoint taken when reading them were resumed from.
+func DecodeParallel(src *Reader, workers int, fn func(e *Entry) error) error {
+	workers = max(workers, 1)
+	type job struct {
+		rec  *Record
+		cp   *Checkpoint // to save before delivering rec
+		done chan error
+	}
+	store := src.checkpoints
+	src.checkpoints = nil
+	defer func() { src.checkpoints = store }()
+	work := make(chan job, workers)
+	order := make(chan job, 2*workers)
+	stop := make(chan struct{})
+
+	go func() {
+		defer close(order)
+		defer close(work)
+		for {
+			var cp *Checkpoint
+			if store != nil && src.checkpointDue() {
+				c := src.Checkpoint()
+				cp = &c
+			}
+			rec, err := src.ReadRecord()
+			j := job{rec: rec, cp: cp, done: make(chan error, 1)}
+			if err != nil {
+				j.done <- err
+			} else {
+				select {
+				case work <- j:
+				case <-stop:
+					return
+				}
+			}
+			select {
+			case order <- j:
+			case <-stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range work {
+				_, err := j.rec.Decode()
+				j.done <- err
+			}
+		}()
+	}
+
+	var err error
+	for j := range order {
+		err = <-j.done
+		if err == nil && j.cp != nil {
+			err = saveCheckpoint(store, *j.cp)
+		}
+		if err == nil {
+			err = fn(j.rec.Entry)
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(stop)
+	for range order {
+		// Let the reading goroutine exit.
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package rdb_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+// parallelDump returns a dump with compressed strings and collections.
+func parallelDump(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := rdb.RedisString(strings.Repeat("compressible ", 20))
+	for i := 0; i < 300; i++ {
+		key := rdb.RedisString("key:" + strconv.Itoa(i))
+		var v rdb.Value
+		switch i % 3 {
+		case 0:
+			v = rdb.StringValue(long)
+		case 1:
+			v = rdb.ListValue{Elements: []rdb.RedisString{long, rdb.RedisString(strconv.Itoa(i))}}
+		default:
+			v = rdb.HashValue{Fields: []rdb.HashField{{Field: rdb.RedisString("f"), Value: long}}}
+		}
+		if err := w.WriteEntry(&rdb.Entry{DB: uint64(i % 2), Key: key, Value: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeParallel(t *testing.T, dump []byte, opts ...rdb.Option) []*rdb.Entry {
+	t.Helper()
+	r, err := rdb.NewReader(bytes.NewReader(dump), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var got []*rdb.Entry
+	err = rdb.DecodeParallel(r, 4, func(e *rdb.Entry) error {
+		got = append(got, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestDecodeParallel(t *testing.T) {
+	dump := parallelDump(t)
+	want := rdbtest.LoadBytes(t, dump)
+	got := decodeParallel(t, dump)
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("entry %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestKeysOnly(t *testing.T) {
+	dump := parallelDump(t)
+	want := rdbtest.LoadBytes(t, dump)
+	if want[0].UncompressedSize <= want[0].Size {
+		t.Fatal("the dump has no compressed strings")
+	}
+	check := func(name string, got []*rdb.Entry) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %d entries, want %d", name, len(got), len(want))
+		}
+		for i, w := range want {
+			g := got[i]
+			if g.Value != nil || string(g.Key) != string(w.Key) || g.DB != w.DB || g.ValueType != w.ValueType ||
+				g.Size != w.Size || g.UncompressedSize != w.UncompressedSize {
+				t.Errorf("%s: got %+v, want %+v without its value", name, g, w)
+			}
+		}
+	}
+	check("ReadEntry", rdbtest.LoadBytes(t, dump, rdb.WithKeysOnly()))
+	check("DecodeParallel", decodeParallel(t, dump, rdb.WithKeysOnly()))
+
+	var got []*rdb.Entry
+	p := rdb.NewPipeline(rdb.ReaderSource(bytes.NewReader(dump), -1), rdb.WithKeysOnly()).
+		To(rdb.SinkFunc(func(e *rdb.Entry) error {
+			got = append(got, e)
+			return nil
+		}))
+	p.Workers = 4
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	check("Pipeline", got)
+}
@@ -0,0 +1,200 @@
+package rdb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// A Database holds a whole dump for random access by key, where a Reader
+// only streams it. Load it with Load or with a Loader, which can keep the
+// memory it uses within a budget by spilling values to a temporary file.
+// A Database is safe for concurrent reads.
+type Database struct {
+	Version int
+	Aux     []AuxField
+	Created time.Time // from the ctime auxiliary field, zero if missing
+
+	entries []dbEntry // in dump order
+	index   map[dbKey]int
+	spill   *os.File
+	proto   Record // decoding settings of spilled values
+	inMem   uint64 // serialized size of the values kept in memory
+}
+
+type dbKey struct {
+	db  uint64
+	key string
+}
+
+// dbEntry is a key of a Database. Spilled values have a nil Entry.Value
+// and are found at off in the spill file.
+type dbEntry struct {
+	e       *Entry
+	spilled bool
+	off     int64
+	size    int
+}
+
+// Loader loads dumps into Databases.
+type Loader struct {
+	// MemoryBudget bounds the serialized size of the values kept in
+	// memory. Once it is reached, the values of further keys are written
+	// to a temporary file and only their offsets are kept, so dumps larger
+	// than the available memory can still be inspected. Keys and metadata
+	// always stay in memory. 0 keeps everything in memory.
+	MemoryBudget uint64
+
+	// SpillDir is the directory of the temporary file; "" means the
+	// default directory for temporary files.
+	SpillDir string
+
+	// Options configure the Reader.
+	Options []Option
+}
+
+// Load reads the whole dump from r into a Database held in memory.
+func Load(r io.Reader, opts ...Option) (*Database, error) {
+	l := Loader{Options: opts}
+	return l.Load(r)
+}
+
+// Load reads the whole dump from r. The Database must be closed to remove
+// its temporary file, if any.
+func (l *Loader) Load(r io.Reader) (*Database, error) {
+	src, err := NewReader(r, l.Options...)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	d := &Database{Version: src.Version(), index: make(map[dbKey]int)}
+	if err := d.load(src, l); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Database) load(src *Reader, l *Loader) error {
+	var spillOff int64
+	for {
+		rec, err := src.ReadRecord()
+		if err == io.EOF {
+			d.Aux = src.Aux()
+			d.Created, _ = src.Created()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		de := dbEntry{e: rec.Entry}
+		if rec.Data == nil || l.MemoryBudget == 0 || d.inMem+uint64(len(rec.Data)) <= l.MemoryBudget {
+			if _, err := rec.Decode(); err != nil {
+				return err
+			}
+			d.inMem += uint64(len(rec.Data))
+		} else {
+			if d.spill == nil {
+				if d.spill, err = os.CreateTemp(l.SpillDir, "rdb-spill-"); err != nil {
+					return err
+				}
+				d.proto = Record{version: rec.version, compat: rec.compat, fields: rec.fields, limits: rec.limits}
+			}
+			if _, err := d.spill.Write(rec.Data); err != nil {
+				return err
+			}
+			de.spilled, de.off, de.size = true, spillOff, len(rec.Data)
+			spillOff += int64(len(rec.Data))
+		}
+		k := dbKey{rec.Entry.DB, string(rec.Entry.Key)}
+		if i, ok := d.index[k]; ok {
+			d.entries[i] = de // keep the last copy of a duplicated key
+		} else {
+			d.index[k] = len(d.entries)
+			d.entries = append(d.entries, de)
+		}
+	}
+}
+
+// Len returns the number of keys.
+func (d *Database) Len() int {
+	return len(d.entries)
+}
+
+// Spilled reports how many values were written to the temporary file.
+func (d *Database) Spilled() int {
+	n := 0
+	for _, de := range d.entries {
+		if de.spilled {
+			n++
+		}
+	}
+	return n
+}
+
+// Get returns the named key of database db, or nil if there is none.
+// Spilled values are read back from the temporary file on every call and
+// not kept, so the entry returned for them is a new one.
+func (d *Database) Get(db uint64, key string) (*Entry, error) {
+	i, ok := d.index[dbKey{db, key}]
+	if !ok {
+		return nil, nil
+	}
+	return d.entry(i)
+}
+
+func (d *Database) entry(i int) (*Entry, error) {
+	de := d.entries[i]
+	if !de.spilled {
+		return de.e, nil
+	}
+	rec := d.proto
+	e := *de.e
+	rec.Entry = &e
+	rec.Data = make([]byte, de.size)
+	if _, err := d.spill.ReadAt(rec.Data, de.off); err != nil {
+		return nil, fmt.Errorf("rdb: reading spilled value of %q: %w", de.e.Key, err)
+	}
+	return rec.Decode()
+}
+
+// Keys returns the keys of database db in byte order.
+func (d *Database) Keys(db uint64) []RedisString {
+	var keys []RedisString
+	for _, de := range d.entries {
+		if de.e.DB == db {
+			keys = append(keys, de.e.Key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Compare(keys[j]) < 0 })
+	return keys
+}
+
+// Each calls fn for every key in dump order, stopping at the first error.
+func (d *Database) Each(fn func(e *Entry) error) error {
+	for i := range d.entries {
+		e, err := d.entry(i)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close removes the temporary file of spilled values.
+func (d *Database) Close() error {
+	if d.spill == nil {
+		return nil
+	}
+	err := d.spill.Close()
+	if rerr := os.Remove(d.spill.Name()); err == nil {
+		err = rerr
+	}
+	d.spill = nil
+	return err
+}
@@ -0,0 +1,158 @@
+package rdb_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+// databaseDump is a dump with a ctime auxiliary field, keys a, b and c of
+// serialized sizes 4, 5 and 2 in database 0, then a list and a hash in
+// database 1, and finally a second copy of a.
+func databaseDump(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteAux([]byte("ctime"), []byte("1700000000"))
+	for _, e := range []*rdb.Entry{
+		str(0, "a", "abc", 0),
+		str(0, "b", "defg", 0),
+		str(0, "c", "h", 0),
+		{DB: 1, Key: rdb.RedisString("l"), Value: rdb.ListValue{Elements: strs("x", "y")}},
+		{DB: 1, Key: rdb.RedisString("h"), Value: rdb.HashValue{Fields: fields("f1", "v1", "f2", "v2")}},
+		str(0, "a", "zz", 0),
+	} {
+		if err := w.WriteEntry(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDatabase(t *testing.T) {
+	dump := databaseDump(t)
+	dir := t.TempDir()
+	loaders := map[string]*rdb.Loader{
+		"in memory": {Options: []rdb.Option{rdb.WithHashFields(rdb.FieldsIn("f1"))}},
+		// a and b take 9 bytes, over the budget: b is spilled, c still
+		// fits, and everything after it is spilled.
+		"spilled": {MemoryBudget: 8, SpillDir: dir, Options: []rdb.Option{rdb.WithHashFields(rdb.FieldsIn("f1"))}},
+	}
+	for name, l := range loaders {
+		t.Run(name, func(t *testing.T) {
+			d, err := l.Load(bytes.NewReader(dump))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			if d.Version != 11 || !d.Created.Equal(time.Unix(1700000000, 0)) || len(d.Aux) != 1 {
+				t.Errorf("got version %d, created %v, aux %v", d.Version, d.Created, d.Aux)
+			}
+			if d.Len() != 5 {
+				t.Errorf("got %d keys, want 5", d.Len())
+			}
+			wantSpilled := 0
+			if l.MemoryBudget > 0 {
+				wantSpilled = 4 // b, l, h and the second a
+			}
+			if n := d.Spilled(); n != wantSpilled {
+				t.Errorf("got %d spilled values, want %d", n, wantSpilled)
+			}
+
+			// The last copy of a duplicated key wins, keeping the place
+			// of the first.
+			var got []string
+			d.Each(func(e *rdb.Entry) error {
+				got = append(got, string(e.Key))
+				return nil
+			})
+			if want := []string{"a", "b", "c", "l", "h"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got keys %q in dump order, want %q", got, want)
+			}
+			if keys := goStrings(d.Keys(1)); !reflect.DeepEqual(keys, []string{"h", "l"}) {
+				t.Errorf("got keys %q in database 1", keys)
+			}
+
+			for _, tt := range []struct {
+				db    uint64
+				key   string
+				value rdb.Value
+			}{
+				{0, "a", rdb.StringValue("zz")},
+				{0, "b", rdb.StringValue("defg")},
+				{0, "c", rdb.StringValue("h")},
+				{1, "l", rdb.ListValue{Elements: strs("x", "y"), Enc: rdb.EncodingLinkedList}},
+				// The Reader options apply to spilled values too.
+				{1, "h", rdb.HashValue{Fields: fields("f1", "v1"), Enc: rdb.EncodingHashtable}},
+			} {
+				e, err := d.Get(tt.db, tt.key)
+				if err != nil || e == nil || !reflect.DeepEqual(e.Value, tt.value) {
+					t.Errorf("%d %s: got %+v, %v, want %v", tt.db, tt.key, e, err, tt.value)
+				}
+			}
+			if e, err := d.Get(1, "a"); e != nil || err != nil {
+				t.Errorf("got %+v, %v for a missing key", e, err)
+			}
+
+			// Spilled values are decoded anew on every call.
+			c1, _ := d.Get(0, "c")
+			c2, _ := d.Get(0, "c")
+			b1, _ := d.Get(0, "b")
+			b2, _ := d.Get(0, "b")
+			if c1 != c2 || (b1 == b2) != (l.MemoryBudget == 0) {
+				t.Error("spilled values are cached, or values in memory are not")
+			}
+		})
+	}
+
+	// Close removes the spill file, and may be called again.
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("left %d files in the spill directory", len(files))
+	}
+	d, err := rdb.Load(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Spilled() != 0 || d.Close() != nil || d.Close() != nil {
+		t.Error("closing a Database held in memory failed")
+	}
+}
+
+func TestDatabaseSpillFile(t *testing.T) {
+	dir := t.TempDir()
+	l := &rdb.Loader{MemoryBudget: 1, SpillDir: dir}
+	d, err := l.Load(bytes.NewReader(databaseDump(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("got %d files in the spill directory, want 1", len(files))
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("left %d files after Close", len(files))
+	}
+	if err := d.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+
+	// A spill directory that does not exist fails the load.
+	l.SpillDir = filepath.Join(dir, "missing")
+	if _, err := l.Load(bytes.NewReader(databaseDump(t))); err == nil {
+		t.Error("loaded with a missing spill directory")
+	}
+}
@@ -0,0 +1,86 @@
+package rdb
+
+import "sort"
+
+// DBSummary counts the keys of each database and compares the counts with
+// the size hints recorded in the dump. Redis writes exact counts as hints,
+// so a mismatch points at a truncated dump or a faulty writer. Feed it
+// every entry with Add, and every skipped key with AddSkipped, then call
+// Report.
+type DBSummary struct {
+	Config EncodingConfig // used to estimate memory
+
+	dbs map[uint64]*DBStats
+}
+
+// DBStats summarises one database.
+type DBStats struct {
+	DB      uint64
+	Keys    uint64
+	Expires uint64 // keys with an expiry
+	Memory  uint64 // estimated memory of the keys that were decoded
+	Stored  uint64 // bytes of the key records in the dump
+
+	Hint    DBSizeHint
+	HasHint bool
+}
+
+// Mismatch reports whether the counted keys or expiries differ from the
+// size hint. Databases without a hint never mismatch.
+func (s *DBStats) Mismatch() bool {
+	return s.HasHint && (s.Keys != s.Hint.Keys || s.Expires != s.Hint.Expires)
+}
+
+// NewDBSummary returns a DBSummary estimating memory with cfg.
+func NewDBSummary(cfg EncodingConfig) *DBSummary {
+	return &DBSummary{Config: cfg}
+}
+
+func (s *DBSummary) db(n uint64) *DBStats {
+	if s.dbs == nil {
+		s.dbs = make(map[uint64]*DBStats)
+	}
+	d := s.dbs[n]
+	if d == nil {
+		d = &DBStats{DB: n}
+		s.dbs[n] = d
+	}
+	return d
+}
+
+// Add accounts for a single entry.
+func (s *DBSummary) Add(e *Entry) {
+	d := s.db(e.DB)
+	d.Keys++
+	if e.HasExpiry() {
+		d.Expires++
+	}
+	_, m := EstimateMemory(e, s.Config)
+	d.Memory += m
+	d.Stored += uint64(e.Size)
+}
+
+// AddSkipped accounts for a key the Reader skipped.
+func (s *DBSummary) AddSkipped(e SkippedEntry) {
+	d := s.db(e.DB)
+	d.Keys++
+	if e.ExpiryAt != 0 {
+		d.Expires++
+	}
+	d.Stored += uint64(e.Size)
+}
+
+// Report returns the statistics of every database that has keys or a
+// hint, in database order.
+func (s *DBSummary) Report(hints map[uint64]DBSizeHint) []DBStats {
+	for n, h := range hints {
+		d := s.db(n)
+		d.Hint, d.HasHint = h, true
+	}
+	out := make([]DBStats, 0, len(s.dbs))
+	for _, d := range s.dbs {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DB < out[j].DB })
+	return out
+}
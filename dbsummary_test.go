@@ -0,0 +1,82 @@
+package rdb_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestDBSummary(t *testing.T) {
+	// Database 0 announces one key more than it holds, database 1 one key
+	// fewer once a skipped key is added, and database 3 is announced but
+	// empty.
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SelectDB(0)
+	w.ResizeDB(3, 1)
+	w.WriteEntry(str(0, "a", "v", 0))
+	w.WriteEntry(str(0, "b", "v", 1700000000000))
+	w.SelectDB(1)
+	w.ResizeDB(1, 0)
+	w.WriteEntry(str(1, "c", "v", 0))
+	w.SelectDB(3)
+	w.ResizeDB(2, 2)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := rdb.DefaultEncodingConfig()
+	s := rdb.NewDBSummary(cfg)
+	r, err := rdb.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var memory [2]uint64
+	var stored [2]uint64
+	for {
+		e, err := r.ReadEntry()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		s.Add(e)
+		_, m := rdb.EstimateMemory(e, cfg)
+		memory[e.DB] += m
+		stored[e.DB] += uint64(e.Size)
+	}
+	// A skipped key counts, but takes no estimated memory.
+	s.AddSkipped(rdb.SkippedEntry{DB: 1, Key: rdb.RedisString("m"), ExpiryAt: 5, Size: 40})
+
+	got := s.Report(r.SizeHints())
+	want := []rdb.DBStats{
+		{DB: 0, Keys: 2, Expires: 1, Memory: memory[0], Stored: stored[0], Hint: rdb.DBSizeHint{Keys: 3, Expires: 1}, HasHint: true},
+		{DB: 1, Keys: 2, Expires: 1, Memory: memory[1], Stored: stored[1] + 40, Hint: rdb.DBSizeHint{Keys: 1}, HasHint: true},
+		{DB: 3, Hint: rdb.DBSizeHint{Keys: 2, Expires: 2}, HasHint: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %+v, want %+v", got[i], want[i])
+		}
+		if !got[i].Mismatch() {
+			t.Errorf("database %d matches its hint", got[i].DB)
+		}
+	}
+	if stored[0] == 0 {
+		t.Error("entries have no size")
+	}
+
+	exact := rdb.DBStats{Keys: 1, Hint: rdb.DBSizeHint{Keys: 1}, HasHint: true}
+	if exact.Mismatch() || (&rdb.DBStats{Keys: 7}).Mismatch() {
+		t.Error("a matching or missing hint mismatches")
+	}
+}
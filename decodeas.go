@@ -0,0 +1,181 @@
+package rdb
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// DecodeAs converts the value of e into a common Go type, validating it on
+// the way, for code that handles keys of a known shape and would otherwise
+// repeat the same type assertions and conversions. The supported types are:
+//
+//	string, []byte                  a string
+//	int64, float64                  a string holding a number
+//	[]string, [][]byte              the elements of a list or set, or the
+//	                                members of a sorted set in order
+//	[]int64, []float64              the same, holding numbers
+//	map[string]struct{}             the members of a set
+//	map[string]string               the fields of a hash
+//	map[string][]byte               the fields of a hash
+//	map[string]int64                the fields of a hash, holding integers
+//	map[string]float64              the members of a sorted set with their
+//	                                scores, or the fields of a hash holding
+//	                                numbers
+//
+// Numbers are parsed the way Redis parses them for INCRBY and INCRBYFLOAT;
+// floats must not be NaN. Errors wrap ErrConvert.
+func DecodeAs[T any](e *Entry) (T, error) {
+	var v T
+	err := decodeInto(e, &v)
+	return v, err
+}
+
+func decodeInto(e *Entry, dst any) error {
+	switch d := dst.(type) {
+	case *string:
+		s, err := asString(e, dst)
+		*d = string(s)
+		return err
+	case *[]byte:
+		s, err := asString(e, dst)
+		*d = []byte(s)
+		return err
+	case *int64:
+		s, err := asString(e, dst)
+		if err != nil {
+			return err
+		}
+		*d, err = convertInt(e, s)
+		return err
+	case *float64:
+		s, err := asString(e, dst)
+		if err != nil {
+			return err
+		}
+		*d, err = convertFloat(e, s)
+		return err
+	case *[]string:
+		return convertElements(e, d, func(s RedisString) (string, error) { return string(s), nil })
+	case *[][]byte:
+		return convertElements(e, d, func(s RedisString) ([]byte, error) { return []byte(s), nil })
+	case *[]int64:
+		return convertElements(e, d, func(s RedisString) (int64, error) { return convertInt(e, s) })
+	case *[]float64:
+		return convertElements(e, d, func(s RedisString) (float64, error) { return convertFloat(e, s) })
+	case *map[string]struct{}:
+		set, ok := e.Value.(SetValue)
+		if !ok {
+			return convertError(e, dst)
+		}
+		m := make(map[string]struct{}, len(set.Members))
+		for _, s := range set.Members {
+			if _, dup := m[string(s)]; dup {
+				return fmt.Errorf("%w: duplicate member %q in set %q", ErrConvert, s, e.Key)
+			}
+			m[string(s)] = struct{}{}
+		}
+		*d = m
+		return nil
+	case *map[string]string:
+		return convertFields(e, d, func(s RedisString) (string, error) { return string(s), nil })
+	case *map[string][]byte:
+		return convertFields(e, d, func(s RedisString) ([]byte, error) { return []byte(s), nil })
+	case *map[string]int64:
+		return convertFields(e, d, func(s RedisString) (int64, error) { return convertInt(e, s) })
+	case *map[string]float64:
+		if z, ok := e.Value.(ZSetValue); ok {
+			m := make(map[string]float64, len(z.Members))
+			for _, z := range z.Members {
+				if _, dup := m[string(z.Member)]; dup {
+					return fmt.Errorf("%w: duplicate member %q in sorted set %q", ErrConvert, z.Member, e.Key)
+				}
+				m[string(z.Member)] = z.Score
+			}
+			*d = m
+			return nil
+		}
+		return convertFields(e, d, func(s RedisString) (float64, error) { return convertFloat(e, s) })
+	}
+	return fmt.Errorf("%w: unsupported type %s", ErrConvert, reflect.TypeOf(dst).Elem())
+}
+
+func convertError(e *Entry, dst any) error {
+	return fmt.Errorf("%w: %s value of %q to %s", ErrConvert, e.Type(), e.Key, reflect.TypeOf(dst).Elem())
+}
+
+func asString(e *Entry, dst any) (RedisString, error) {
+	s, ok := e.Value.(StringValue)
+	if !ok {
+		return nil, convertError(e, dst)
+	}
+	return RedisString(s), nil
+}
+
+// convertInt parses s as Redis' string2ll does: base 10, without a sign
+// other than '-', leading zeros or spaces.
+func convertInt(e *Entry, s RedisString) (int64, error) {
+	n, err := strconv.ParseInt(string(s), 10, 64)
+	if err != nil || len(s) > 1 && (s[0] == '0' || s[0] == '+' || s[0] == '-' && s[1] == '0') {
+		return 0, fmt.Errorf("%w: %q in %q is not an integer", ErrConvert, s, e.Key)
+	}
+	return n, nil
+}
+
+func convertFloat(e *Entry, s RedisString) (float64, error) {
+	f, err := strconv.ParseFloat(string(s), 64)
+	if err != nil || math.IsNaN(f) || len(s) == 0 || s[0] == ' ' {
+		return 0, fmt.Errorf("%w: %q in %q is not a number", ErrConvert, s, e.Key)
+	}
+	return f, nil
+}
+
+func convertElements[V any](e *Entry, d *[]V, conv func(RedisString) (V, error)) error {
+	var out []V
+	switch v := e.Value.(type) {
+	case ListValue, SetValue:
+		elems, _ := stringsOf(v)
+		out = make([]V, 0, len(elems))
+		for _, s := range elems {
+			x, err := conv(s)
+			if err != nil {
+				return err
+			}
+			out = append(out, x)
+		}
+	case ZSetValue:
+		out = make([]V, 0, len(v.Members))
+		for _, z := range v.Members {
+			x, err := conv(z.Member)
+			if err != nil {
+				return err
+			}
+			out = append(out, x)
+		}
+	default:
+		return convertError(e, d)
+	}
+	*d = out
+	return nil
+}
+
+func convertFields[V any](e *Entry, d *map[string]V, conv func(RedisString) (V, error)) error {
+	h, ok := e.Value.(HashValue)
+	if !ok {
+		return convertError(e, d)
+	}
+	m := make(map[string]V, len(h.Fields))
+	for _, f := range h.Fields {
+		if _, dup := m[string(f.Field)]; dup {
+			return fmt.Errorf("%w: duplicate field %q in hash %q", ErrConvert, f.Field, e.Key)
+		}
+		x, err := conv(f.Value)
+		if err != nil {
+			return err
+		}
+		m[string(f.Field)] = x
+	}
+	*d = m
+	return nil
+}
@@ -0,0 +1,94 @@
+package rdb_test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestDecodeAs(t *testing.T) {
+	key := func(v rdb.Value) *rdb.Entry { return &rdb.Entry{Key: rdb.RedisString("k"), Value: v} }
+	list := func(s ...string) *rdb.Entry { return key(rdb.ListValue{Elements: strs(s...)}) }
+	set := func(s ...string) *rdb.Entry { return key(rdb.SetValue{Members: strs(s...)}) }
+	hash := func(pairs ...string) *rdb.Entry { return key(rdb.HashValue{Fields: fields(pairs...)}) }
+	zset := key(rdb.ZSetValue{Members: []rdb.ZSetMember{
+		{Member: rdb.RedisString("1.5"), Score: 2}, {Member: rdb.RedisString("-3"), Score: math.Inf(1)},
+	}})
+	tests := []struct {
+		name   string
+		decode func() (any, error)
+		want   any // nil for an error
+	}{
+		{"string", func() (any, error) { return rdb.DecodeAs[string](key(rdb.StringValue("hi"))) }, "hi"},
+		{"bytes", func() (any, error) { return rdb.DecodeAs[[]byte](key(rdb.StringValue("hi"))) }, []byte("hi")},
+		{"string of a list", func() (any, error) { return rdb.DecodeAs[string](list("a")) }, nil},
+
+		{"int", func() (any, error) { return rdb.DecodeAs[int64](key(rdb.StringValue("-42"))) }, int64(-42)},
+		{"int zero", func() (any, error) { return rdb.DecodeAs[int64](key(rdb.StringValue("0"))) }, int64(0)},
+		{"int leading zero", func() (any, error) { return rdb.DecodeAs[int64](key(rdb.StringValue("042"))) }, nil},
+		{"int negative zero", func() (any, error) { return rdb.DecodeAs[int64](key(rdb.StringValue("-0"))) }, nil},
+		{"int plus", func() (any, error) { return rdb.DecodeAs[int64](key(rdb.StringValue("+1"))) }, nil},
+		{"int space", func() (any, error) { return rdb.DecodeAs[int64](key(rdb.StringValue(" 1"))) }, nil},
+		{"int fraction", func() (any, error) { return rdb.DecodeAs[int64](key(rdb.StringValue("1.5"))) }, nil},
+		{"int overflow", func() (any, error) { return rdb.DecodeAs[int64](key(rdb.StringValue("9223372036854775808"))) }, nil},
+
+		{"float", func() (any, error) { return rdb.DecodeAs[float64](key(rdb.StringValue("1e3"))) }, 1000.0},
+		{"float infinite", func() (any, error) { return rdb.DecodeAs[float64](key(rdb.StringValue("-inf"))) }, math.Inf(-1)},
+		{"float NaN", func() (any, error) { return rdb.DecodeAs[float64](key(rdb.StringValue("nan"))) }, nil},
+		{"float empty", func() (any, error) { return rdb.DecodeAs[float64](key(rdb.StringValue(""))) }, nil},
+		{"float space", func() (any, error) { return rdb.DecodeAs[float64](key(rdb.StringValue(" 1"))) }, nil},
+
+		{"strings of a list", func() (any, error) { return rdb.DecodeAs[[]string](list("b", "a", "b")) }, []string{"b", "a", "b"}},
+		{"strings of a set", func() (any, error) { return rdb.DecodeAs[[]string](set("x")) }, []string{"x"}},
+		{"strings of a zset", func() (any, error) { return rdb.DecodeAs[[]string](zset) }, []string{"1.5", "-3"}},
+		{"strings of a hash", func() (any, error) { return rdb.DecodeAs[[]string](hash("f", "v")) }, nil},
+		{"byte slices", func() (any, error) { return rdb.DecodeAs[[][]byte](list("a")) }, [][]byte{[]byte("a")}},
+		{"ints", func() (any, error) { return rdb.DecodeAs[[]int64](list("1", "-2")) }, []int64{1, -2}},
+		{"ints with a word", func() (any, error) { return rdb.DecodeAs[[]int64](list("1", "two")) }, nil},
+		{"floats of a zset", func() (any, error) { return rdb.DecodeAs[[]float64](zset) }, []float64{1.5, -3}},
+
+		{"set", func() (any, error) { return rdb.DecodeAs[map[string]struct{}](set("a", "b")) },
+			map[string]struct{}{"a": {}, "b": {}}},
+		{"set with a duplicate", func() (any, error) { return rdb.DecodeAs[map[string]struct{}](set("a", "a")) }, nil},
+		{"set of a list", func() (any, error) { return rdb.DecodeAs[map[string]struct{}](list("a")) }, nil},
+
+		{"hash", func() (any, error) { return rdb.DecodeAs[map[string]string](hash("f", "1", "g", "x")) },
+			map[string]string{"f": "1", "g": "x"}},
+		{"hash of bytes", func() (any, error) { return rdb.DecodeAs[map[string][]byte](hash("f", "1")) },
+			map[string][]byte{"f": []byte("1")}},
+		{"hash with a duplicate", func() (any, error) { return rdb.DecodeAs[map[string]string](hash("f", "1", "f", "2")) }, nil},
+		{"hash of ints", func() (any, error) { return rdb.DecodeAs[map[string]int64](hash("f", "1", "g", "-2")) },
+			map[string]int64{"f": 1, "g": -2}},
+		{"hash of ints with a word", func() (any, error) { return rdb.DecodeAs[map[string]int64](hash("f", "one")) }, nil},
+		{"hash of a set", func() (any, error) { return rdb.DecodeAs[map[string]string](set("a")) }, nil},
+		{"hash of floats", func() (any, error) { return rdb.DecodeAs[map[string]float64](hash("f", "0.5")) },
+			map[string]float64{"f": 0.5}},
+
+		{"zset scores", func() (any, error) { return rdb.DecodeAs[map[string]float64](zset) },
+			map[string]float64{"1.5": 2, "-3": math.Inf(1)}},
+		{"zset with a duplicate", func() (any, error) {
+			return rdb.DecodeAs[map[string]float64](key(rdb.ZSetValue{Members: []rdb.ZSetMember{
+				{Member: rdb.RedisString("a"), Score: 1}, {Member: rdb.RedisString("a"), Score: 2},
+			}}))
+		}, nil},
+
+		{"unsupported", func() (any, error) { return rdb.DecodeAs[int](key(rdb.StringValue("1"))) }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.decode()
+			if tt.want == nil {
+				if !errors.Is(err, rdb.ErrConvert) {
+					t.Errorf("got %v, %v, want ErrConvert", got, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package rdb
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// DiffOptions controls how Diff compares keys present in both dumps. Sets,
+// hashes and sorted sets are compared as unordered collections and
+// encodings are ignored, as Equivalent does, so that keys written in
+// another order or encoding by another server are not reported as
+// modified.
+type DiffOptions struct {
+	// IgnoreExpiry compares values only, for dumps taken at different
+	// times from servers that set expiries relative to the current time.
+	IgnoreExpiry bool
+
+	// ListRotation makes lists that are rotations of each other equal, so
+	// that circular queues rotated with LMOVE or RPOPLPUSH between the
+	// dumps do not show as modified.
+	ListRotation bool
+}
+
+// Equal reports whether a and b are the same under o.
+func (o DiffOptions) Equal(a, b *Entry) bool {
+	if !o.IgnoreExpiry && a.ExpiryAt != b.ExpiryAt {
+		return false
+	}
+	if o.ListRotation && a.Type() == TypeList && b.Type() == TypeList {
+		av, aok := a.Value.(ListValue)
+		bv, bok := b.Value.(ListValue)
+		if aok && bok {
+			return isRotation(av.Elements, bv.Elements)
+		}
+	}
+	return EquivalentValues(a, b)
+}
+
+// isRotation reports whether b is a rotation of a, searching b in a+a with
+// the Knuth-Morris-Pratt algorithm.
+func isRotation(a, b []RedisString) bool {
+	n := len(a)
+	if n != len(b) {
+		return false
+	}
+	if n == 0 {
+		return true
+	}
+	fail := make([]int, n)
+	for i, k := 1, 0; i < n; i++ {
+		for k > 0 && !bytes.Equal(b[i], b[k]) {
+			k = fail[k-1]
+		}
+		if bytes.Equal(b[i], b[k]) {
+			k++
+		}
+		fail[i] = k
+	}
+	for i, k := 0, 0; i < 2*n-1; i++ {
+		for k > 0 && !bytes.Equal(a[i%n], b[k]) {
+			k = fail[k-1]
+		}
+		if bytes.Equal(a[i%n], b[k]) {
+			k++
+		}
+		if k == n {
+			return true
+		}
+	}
+	return false
+}
+
+// Change is the way a key differs between two dumps.
+type Change uint8
+
+const (
+	ChangeAdded    Change = iota + 1 // only in the new dump
+	ChangeRemoved                    // only in the old dump
+	ChangeModified                   // in both, with different contents
+)
+
+var changeNames = [...]string{"", "added", "removed", "modified"}
+
+func (c Change) String() string {
+	if int(c) < len(changeNames) && c != 0 {
+		return changeNames[c]
+	}
+	return "Change(" + strconv.Itoa(int(c)) + ")"
+}
+
+// MarshalText formats the change as String does.
+func (c Change) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// KeyChange is a key that differs between two dumps. Old is nil for added
+// keys, New for removed ones.
+type KeyChange struct {
+	DB     uint64
+	Key    RedisString
+	Change Change
+	Old    *Entry `json:"-"`
+	New    *Entry `json:"-"`
+}
+
+// DiffReport is the result of Diff.
+type DiffReport struct {
+	Unchanged uint64
+	Added     uint64
+	Removed   uint64
+	Modified  uint64
+
+	// Changes lists the keys that differ: those of the old dump in its
+	// order, then those added in the new dump in its order.
+	Changes []KeyChange
+}
+
+// Diff compares the dump from with the later dump to, key by key.
+func Diff(from, to *Database, opts DiffOptions) (DiffReport, error) {
+	var rep DiffReport
+	for i, de := range from.entries {
+		k := dbKey{de.e.DB, string(de.e.Key)}
+		j, ok := to.index[k]
+		if !ok {
+			e, err := from.entry(i)
+			if err != nil {
+				return rep, err
+			}
+			rep.Removed++
+			rep.Changes = append(rep.Changes, KeyChange{DB: k.db, Key: de.e.Key, Change: ChangeRemoved, Old: e})
+			continue
+		}
+		a, err := from.entry(i)
+		if err != nil {
+			return rep, err
+		}
+		b, err := to.entry(j)
+		if err != nil {
+			return rep, err
+		}
+		if opts.Equal(a, b) {
+			rep.Unchanged++
+			continue
+		}
+		rep.Modified++
+		rep.Changes = append(rep.Changes, KeyChange{DB: k.db, Key: de.e.Key, Change: ChangeModified, Old: a, New: b})
+	}
+	for j, de := range to.entries {
+		if _, ok := from.index[dbKey{de.e.DB, string(de.e.Key)}]; ok {
+			continue
+		}
+		e, err := to.entry(j)
+		if err != nil {
+			return rep, err
+		}
+		rep.Added++
+		rep.Changes = append(rep.Changes, KeyChange{DB: de.e.DB, Key: de.e.Key, Change: ChangeAdded, New: e})
+	}
+	return rep, nil
+}
+
+// Tables implements Tabular.
+func (r DiffReport) Tables() []Table {
+	summary := Table{Title: "Diff", Columns: []string{"Keys", "Count"}, Rows: [][]string{
+		{"Unchanged", formatCount(r.Unchanged)},
+		{"Added", formatCount(r.Added)},
+		{"Removed", formatCount(r.Removed)},
+		{"Modified", formatCount(r.Modified)},
+	}}
+	changes := Table{Title: "Changes", Columns: []string{"DB", "Key", "Change", "Old type", "New type"}}
+	for _, c := range r.Changes {
+		ot, nt := "", ""
+		if c.Old != nil {
+			ot = c.Old.Type().String()
+		}
+		if c.New != nil {
+			nt = c.New.Type().String()
+		}
+		changes.Rows = append(changes.Rows, []string{formatCount(c.DB), formatKey(c.Key), c.Change.String(), ot, nt})
+	}
+	return []Table{summary, changes}
+}
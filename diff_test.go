@@ -0,0 +1,141 @@
+package rdb_test
+
+import (
+	"bytes"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+// loadDatabase loads the entries into a Database, through a dump written
+// with rdbtest.Dump.
+func loadDatabase(t *testing.T, l *rdb.Loader, entries ...*rdb.Entry) *rdb.Database {
+	t.Helper()
+	d, err := l.Load(bytes.NewReader(rdbtest.Dump(t, 11, entries...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func str(db uint64, key, value string, expiry int64) *rdb.Entry {
+	return &rdb.Entry{DB: db, Key: rdb.RedisString(key), Value: rdb.StringValue(value), ExpiryAt: expiry}
+}
+
+// diffDumps returns the entries of two dumps of the same server: a is
+// unchanged, set b is rewritten in another order, list c is rotated,
+// hash d has a value changed, e is removed, f is added and a of database
+// 1 gains an expiry.
+func diffDumps() (from, to []*rdb.Entry) {
+	from = []*rdb.Entry{
+		str(0, "a", "1", 0),
+		{Key: rdb.RedisString("b"), Value: rdb.SetValue{Members: strs("x", "y", "z")}},
+		{Key: rdb.RedisString("c"), Value: rdb.ListValue{Elements: strs("1", "2", "3")}},
+		{Key: rdb.RedisString("d"), Value: rdb.HashValue{Fields: fields("f", "1", "g", "2")}},
+		str(0, "e", "gone", 0),
+		str(1, "a", "other", 0),
+	}
+	to = []*rdb.Entry{
+		str(0, "a", "1", 0),
+		{Key: rdb.RedisString("b"), Value: rdb.SetValue{Members: strs("z", "x", "y")}},
+		{Key: rdb.RedisString("c"), Value: rdb.ListValue{Elements: strs("2", "3", "1")}},
+		{Key: rdb.RedisString("d"), Value: rdb.HashValue{Fields: fields("g", "2", "f", "one")}},
+		str(1, "a", "other", 1893456000000),
+		str(0, "f", "new", 0),
+	}
+	return from, to
+}
+
+func TestDiff(t *testing.T) {
+	type change struct {
+		db     uint64
+		key    string
+		change rdb.Change
+	}
+	tests := []struct {
+		name      string
+		opts      rdb.DiffOptions
+		unchanged uint64
+		changes   []change
+	}{
+		{"default", rdb.DiffOptions{}, 2, []change{
+			{0, "c", rdb.ChangeModified}, {0, "d", rdb.ChangeModified}, {0, "e", rdb.ChangeRemoved},
+			{1, "a", rdb.ChangeModified}, {0, "f", rdb.ChangeAdded},
+		}},
+		{"ListRotation", rdb.DiffOptions{ListRotation: true}, 3, []change{
+			{0, "d", rdb.ChangeModified}, {0, "e", rdb.ChangeRemoved},
+			{1, "a", rdb.ChangeModified}, {0, "f", rdb.ChangeAdded},
+		}},
+		{"IgnoreExpiry", rdb.DiffOptions{IgnoreExpiry: true}, 3, []change{
+			{0, "c", rdb.ChangeModified}, {0, "d", rdb.ChangeModified}, {0, "e", rdb.ChangeRemoved},
+			{0, "f", rdb.ChangeAdded},
+		}},
+	}
+	from, to := diffDumps()
+	loaders := map[string]*rdb.Loader{
+		"in memory": {},
+		"spilled":   {MemoryBudget: 1, SpillDir: t.TempDir()},
+	}
+	for lname, l := range loaders {
+		a, b := loadDatabase(t, l, from...), loadDatabase(t, l, to...)
+		if l.MemoryBudget > 0 && a.Spilled() == 0 {
+			t.Fatal("no value was spilled")
+		}
+		for _, tt := range tests {
+			t.Run(lname+"/"+tt.name, func(t *testing.T) {
+				rep, err := rdb.Diff(a, b, tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []change
+				var added, removed, modified uint64
+				for _, c := range rep.Changes {
+					got = append(got, change{c.DB, string(c.Key), c.Change})
+					switch c.Change {
+					case rdb.ChangeAdded:
+						added++
+						if c.Old != nil || c.New == nil {
+							t.Errorf("%s: old %v, new %v", c.Key, c.Old, c.New)
+						}
+					case rdb.ChangeRemoved:
+						removed++
+						if c.Old == nil || c.New != nil {
+							t.Errorf("%s: old %v, new %v", c.Key, c.Old, c.New)
+						}
+					case rdb.ChangeModified:
+						modified++
+						if c.Old == nil || c.New == nil || c.Old.Value == nil || c.New.Value == nil {
+							t.Errorf("%s: old %v, new %v", c.Key, c.Old, c.New)
+						}
+					}
+				}
+				if len(got) != len(tt.changes) {
+					t.Fatalf("got changes %v, want %v", got, tt.changes)
+				}
+				for i := range got {
+					if got[i] != tt.changes[i] {
+						t.Errorf("got changes %v, want %v", got, tt.changes)
+						break
+					}
+				}
+				if rep.Unchanged != tt.unchanged || rep.Added != added || rep.Removed != removed || rep.Modified != modified {
+					t.Errorf("got counts %d unchanged, %d added, %d removed, %d modified, changes %v",
+						rep.Unchanged, rep.Added, rep.Removed, rep.Modified, got)
+				}
+			})
+		}
+	}
+}
+
+func TestChangeString(t *testing.T) {
+	for c, want := range map[rdb.Change]string{
+		rdb.ChangeAdded: "added", rdb.ChangeRemoved: "removed", rdb.ChangeModified: "modified",
+		0: "Change(0)", 9: "Change(9)",
+	} {
+		if got := c.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
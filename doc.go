@@ -0,0 +1,12 @@
+// Package rdb reads Redis RDB dump files.
+//
+// The RDB format is the point-in-time snapshot Redis writes on SAVE, BGSAVE
+// and during full replication. This package decodes it into Go values and
+// provides helpers for replaying, inspecting and analysing those values.
+//
+// A Reader, a Writer and the collectors fed with Add are meant for a single
+// goroutine. Entries returned by a Reader are not referenced by it
+// afterwards and may be passed to other goroutines. To spread the decoding
+// of a dump over several cores, use DecodeParallel or hand the Records
+// returned by ReadRecord to workers.
+package rdb
@@ -0,0 +1,89 @@
+package rdb
+
+import (
+	"io"
+	"time"
+)
+
+// Report is the result of DryRun: what a dump holds and whether it is
+// sound.
+type Report struct {
+	Version int
+	Aux     []AuxField
+	Created time.Time // from the ctime auxiliary field, zero if missing
+	Size    int64     // bytes read
+
+	Keys    uint64 // decoded and skipped keys
+	Expires uint64 // keys with an expiry
+	Skipped map[ValueType]uint64
+
+	// Memory splits the estimated memory of the decoded keys by type and
+	// encoding under the default encoding configuration.
+	Memory MemoryReport
+
+	// DBs holds the counts of every database, with the size hints of the
+	// dump. A database whose Mismatch method reports true points at a
+	// truncated dump or a faulty writer.
+	DBs []DBStats
+
+	// Checksum is the CRC-64 stored in the dump, checked against its
+	// contents. It is 0 if the dump was saved with rdbchecksum off.
+	Checksum uint64
+}
+
+// DryRun reads the whole dump from r without keeping its entries, the
+// equivalent of loading a backup to see whether it is sound and what it
+// holds. It decodes every value, checks the checksum and gathers the counts
+// and memory estimates of the Report in a single pass, using memory
+// independent of the size of the dump. Keys of types the Reader cannot
+// decode are skipped and counted in Report.Skipped.
+//
+// If the dump is malformed, DryRun returns the error along with a Report of
+// what was read before it.
+func DryRun(r io.Reader, opts ...Option) (Report, error) {
+	opts = append(opts[:len(opts):len(opts)], WithSkipUnsupported(), WithVerifyChecksum())
+	var rep Report
+	src, err := NewReader(r, opts...)
+	if err != nil {
+		return rep, err
+	}
+	defer src.Close()
+	rep.Version = src.Version()
+	cfg := DefaultEncodingConfig()
+	dbs, mem := NewDBSummary(cfg), NewMemoryBreakdown(cfg)
+	finish := func() {
+		rep.Aux = src.Aux()
+		rep.Created, _ = src.Created()
+		rep.Size = src.in.off
+		rep.Memory = mem.Report()
+		rep.DBs = dbs.Report(src.SizeHints())
+		for _, d := range rep.DBs {
+			rep.Keys += d.Keys
+			rep.Expires += d.Expires
+		}
+		rep.Checksum = src.Checksum()
+	}
+	for {
+		e, err := src.ReadEntry()
+		// Count the skipped keys without letting the Reader accumulate
+		// them.
+		for _, s := range src.skipped {
+			dbs.AddSkipped(s)
+			if rep.Skipped == nil {
+				rep.Skipped = make(map[ValueType]uint64)
+			}
+			rep.Skipped[s.ValueType]++
+		}
+		src.skipped = src.skipped[:0]
+		if err == io.EOF {
+			finish()
+			return rep, nil
+		}
+		if err != nil {
+			finish()
+			return rep, err
+		}
+		dbs.Add(e)
+		mem.Add(e)
+	}
+}
@@ -0,0 +1,35 @@
+package rdb
+
+import "github.com/areian/go-redis-rdb/codec"
+
+// ValidateZiplist performs a deep integrity check of a ziplist blob. See
+// codec.ValidateZiplist.
+func ValidateZiplist(zl []byte) error {
+	return codec.ValidateZiplist(zl)
+}
+
+// ValidateListpack performs a deep integrity check of a listpack blob. See
+// codec.ValidateListpack.
+func ValidateListpack(lp []byte) error {
+	return codec.ValidateListpack(lp)
+}
+
+// Intset is a decoded intset blob. See codec.Intset.
+type Intset = codec.Intset
+
+// IntsetIterator iterates over the members of an Intset.
+type IntsetIterator = codec.IntsetIterator
+
+// DecodeZipmap parses a legacy zipmap blob and returns its field/value
+// pairs in stored order. The returned strings share memory with zm.
+func DecodeZipmap(zm []byte) ([]HashField, error) {
+	pairs, err := codec.DecodeZipmap(zm)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]HashField, len(pairs)/2)
+	for i := range fields {
+		fields[i] = HashField{Field: pairs[2*i], Value: pairs[2*i+1]}
+	}
+	return fields, nil
+}
@@ -0,0 +1,98 @@
+package rdb
+
+import "time"
+
+// Entry is a key read from a dump together with its value and metadata.
+type Entry struct {
+	DB        uint64
+	Key       RedisString
+	ValueType ValueType // type byte the value was stored with
+	ExpiryAt  int64     // milliseconds since the Unix epoch, 0 if the key does not expire
+
+	// Access tells whether the dump recorded how recently or how often the
+	// key was used, which Redis does when it runs with an LRU or LFU
+	// maxmemory-policy. Idle is the time since the last access in seconds
+	// (AccessLRU), Freq the logarithmic access counter (AccessLFU).
+	Access AccessKind
+	Idle   uint64
+	Freq   uint8
+
+	// Value holds the decoded value: a StringValue, ListValue, SetValue,
+	// ZSetValue, HashValue or *StreamValue, what the registered decoder
+	// returns for other types (see RegisterDecoder) or a *ModuleValue for
+	// module values without one. It is nil when the Reader was created
+	// with WithKeysOnly.
+	Value Value
+
+	// Aux holds auxiliary fields written between the previous key and this
+	// one. Redis never writes any; some forks store per-key metadata this
+	// way (see WithCompat).
+	Aux []AuxField
+
+	// Slot holds the slot information that preceded the key in dumps of
+	// Redis Cluster nodes, which write it before the keys of every slot;
+	// it is nil in other dumps. Entries of the same slot share it.
+	Slot *SlotInfo
+
+	// Size is the number of bytes of the key's record in the dump: its
+	// type, name and value, as stored, possibly LZF-compressed. Sizes add
+	// up to the progress through the file, less the opcodes between keys.
+	// UncompressedSize counts compressed strings at their decompressed
+	// length instead, the amount of data the value holds; it is what
+	// memory estimates build on.
+	Size             int64
+	UncompressedSize int64
+
+	// Raw holds the bytes of the dump consumed to read the entry when
+	// WithRaw is in effect: the opcodes since the previous entry (including
+	// any skipped keys) and the key's own record. RawVersion is the RDB
+	// version of that dump. A Writer of the same version writes Raw
+	// verbatim when it is set, so it must be cleared when the entry is
+	// modified; Writers of other versions encode Value instead.
+	Raw        []byte
+	RawVersion int
+}
+
+// AccessKind identifies the access information stored with a key.
+type AccessKind uint8
+
+const (
+	AccessNone AccessKind = iota // no access information
+	AccessLRU                    // LRU idle time
+	AccessLFU                    // LFU access counter
+)
+
+// String returns "none", "lru" or "lfu".
+func (k AccessKind) String() string {
+	switch k {
+	case AccessLRU:
+		return "lru"
+	case AccessLFU:
+		return "lfu"
+	}
+	return "none"
+}
+
+// Type returns the logical type of the entry's value.
+func (e *Entry) Type() Type {
+	return e.ValueType.Type()
+}
+
+// Encoding returns the encoding the value was stored with in the dump.
+func (e *Entry) Encoding() Encoding {
+	return e.ValueType.Encoding()
+}
+
+// HasExpiry reports whether the key has an expiry set.
+func (e *Entry) HasExpiry() bool {
+	return e.ExpiryAt != 0
+}
+
+// ExpiryTime returns the key's expiry as a time.Time. It returns the zero
+// time if the key does not expire.
+func (e *Entry) ExpiryTime() time.Time {
+	if e.ExpiryAt == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(e.ExpiryAt)
+}
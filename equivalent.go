@@ -0,0 +1,95 @@
+package rdb
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Equivalent reports whether a and b hold the same data: the same key of
+// the same database, with the same expiry and logical value. The encoding
+// the values were stored with does not matter, so a list stored as a
+// ziplist by one version of Redis is equivalent to the same list stored as
+// a quicklist of listpacks by another, and compressed strings to their
+// uncompressed form. Neither does the order of the members of sets, the
+// fields of hashes and the members of sorted sets, which Redis does not
+// preserve. Access information and auxiliary fields are ignored. This is
+// the comparison to use when checking that a migration to another version
+// kept the data intact.
+func Equivalent(a, b *Entry) bool {
+	return a.DB == b.DB && bytes.Equal(a.Key, b.Key) && a.ExpiryAt == b.ExpiryAt &&
+		EquivalentValues(a, b)
+}
+
+// EquivalentValues reports whether the values of a and b are equivalent as
+// Equivalent defines it, whatever their keys and expiries.
+func EquivalentValues(a, b *Entry) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch av := a.Value.(type) {
+	case StringValue:
+		bv, ok := b.Value.(StringValue)
+		return ok && bytes.Equal(av, bv)
+	case ListValue, SetValue:
+		as, _ := stringsOf(av)
+		bs, ok := stringsOf(b.Value)
+		if !ok || len(as) != len(bs) {
+			return false
+		}
+		if _, ok := av.(SetValue); ok {
+			as, bs = sortedStrings(as), sortedStrings(bs)
+		}
+		for i := range as {
+			if !bytes.Equal(as[i], bs[i]) {
+				return false
+			}
+		}
+		return true
+	case HashValue:
+		bv, ok := b.Value.(HashValue)
+		if !ok || len(av.Fields) != len(bv.Fields) {
+			return false
+		}
+		af, bf := sortedFields(av.Fields), sortedFields(bv.Fields)
+		for i := range af {
+			if !bytes.Equal(af[i].Field, bf[i].Field) || !bytes.Equal(af[i].Value, bf[i].Value) {
+				return false
+			}
+		}
+		return true
+	case ZSetValue:
+		bv, ok := b.Value.(ZSetValue)
+		if !ok || len(av.Members) != len(bv.Members) {
+			return false
+		}
+		am, bm := sortedMembers(av.Members), sortedMembers(bv.Members)
+		for i := range am {
+			x, y := am[i].Score, bm[i].Score
+			if !bytes.Equal(am[i].Member, bm[i].Member) || x != y && !(math.IsNaN(x) && math.IsNaN(y)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a.Value, b.Value)
+}
+
+func sortedStrings(s []RedisString) []RedisString {
+	s = append([]RedisString(nil), s...)
+	sort.Slice(s, func(i, j int) bool { return bytes.Compare(s[i], s[j]) < 0 })
+	return s
+}
+
+func sortedFields(f []HashField) []HashField {
+	f = append([]HashField(nil), f...)
+	sort.Slice(f, func(i, j int) bool { return bytes.Compare(f[i].Field, f[j].Field) < 0 })
+	return f
+}
+
+func sortedMembers(m []ZSetMember) []ZSetMember {
+	m = append([]ZSetMember(nil), m...)
+	sort.Slice(m, func(i, j int) bool { return bytes.Compare(m[i].Member, m[j].Member) < 0 })
+	return m
+}
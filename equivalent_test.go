@@ -0,0 +1,104 @@
+package rdb_test
+
+import (
+	"math"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+func TestEquivalent(t *testing.T) {
+	key := func(v rdb.Value) *rdb.Entry { return &rdb.Entry{Key: rdb.RedisString("k"), Value: v} }
+	zset := func(pairs ...any) rdb.ZSetValue {
+		var z rdb.ZSetValue
+		for i := 0; i < len(pairs); i += 2 {
+			z.Members = append(z.Members, rdb.ZSetMember{Member: rdb.RedisString(pairs[i].(string)), Score: pairs[i+1].(float64)})
+		}
+		return z
+	}
+	tests := []struct {
+		name   string
+		a, b   *rdb.Entry
+		equiv  bool
+		values bool // EquivalentValues
+	}{
+		{"string", key(rdb.StringValue("v")), key(rdb.StringValue("v")), true, true},
+		{"string differs", key(rdb.StringValue("v")), key(rdb.StringValue("w")), false, false},
+		{"key differs", str(0, "k", "v", 0), str(0, "l", "v", 0), false, true},
+		{"database differs", str(0, "k", "v", 0), str(1, "k", "v", 0), false, true},
+		{"expiry differs", str(0, "k", "v", 0), str(0, "k", "v", 1), false, true},
+		{"type differs", key(rdb.StringValue("a")), key(rdb.ListValue{Elements: strs("a")}), false, false},
+
+		{"list encoding", key(rdb.ListValue{Elements: strs("a", "1"), Enc: rdb.EncodingZiplist}),
+			key(rdb.ListValue{Elements: strs("a", "1"), Enc: rdb.EncodingQuicklist}), true, true},
+		{"list order", key(rdb.ListValue{Elements: strs("a", "b")}), key(rdb.ListValue{Elements: strs("b", "a")}), false, false},
+		{"list length", key(rdb.ListValue{Elements: strs("a")}), key(rdb.ListValue{Elements: strs("a", "a")}), false, false},
+
+		{"set order", key(rdb.SetValue{Members: strs("1", "2", "300"), Enc: rdb.EncodingIntset}),
+			key(rdb.SetValue{Members: strs("300", "1", "2"), Enc: rdb.EncodingListpack}), true, true},
+		{"set member differs", key(rdb.SetValue{Members: strs("a", "b")}), key(rdb.SetValue{Members: strs("a", "c")}), false, false},
+
+		{"hash order", key(rdb.HashValue{Fields: fields("f", "1", "g", "2")}),
+			key(rdb.HashValue{Fields: fields("g", "2", "f", "1")}), true, true},
+		{"hash value differs", key(rdb.HashValue{Fields: fields("f", "1")}), key(rdb.HashValue{Fields: fields("f", "2")}), false, false},
+		{"hash field missing", key(rdb.HashValue{Fields: fields("f", "1", "g", "2")}), key(rdb.HashValue{Fields: fields("f", "1")}), false, false},
+
+		{"zset order", key(zset("a", 1.0, "b", 2.0)), key(zset("b", 2.0, "a", 1.0)), true, true},
+		{"zset score differs", key(zset("a", 1.0)), key(zset("a", 1.5)), false, false},
+		{"zset NaN scores", key(zset("a", math.NaN())), key(zset("a", math.NaN())), true, true},
+		{"zset infinite scores", key(zset("a", math.Inf(-1))), key(zset("a", math.Inf(-1))), true, true},
+
+		{"stream", key(testStream()), key(testStream()), true, true},
+		{"stream differs", key(testStream()), key(&rdb.StreamValue{}), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rdb.Equivalent(tt.a, tt.b); got != tt.equiv {
+				t.Errorf("Equivalent = %v, want %v", got, tt.equiv)
+			}
+			if got := rdb.EquivalentValues(tt.a, tt.b); got != tt.values {
+				t.Errorf("EquivalentValues = %v, want %v", got, tt.values)
+			}
+			if rdb.Equivalent(tt.a, tt.b) != rdb.Equivalent(tt.b, tt.a) {
+				t.Error("not symmetric")
+			}
+		})
+	}
+}
+
+// TestEquivalentAcrossEncodings checks that the same data read from dumps
+// holding it in different encodings is equivalent.
+func TestEquivalentAcrossEncodings(t *testing.T) {
+	elems := [][]byte{[]byte("a"), []byte("1"), []byte("b"), []byte("2")}
+	tests := []struct {
+		name string
+		blob []byte
+		v    rdb.Value
+	}{
+		{"ziplist list", blobDump(rdb.ListZipList, nil, codec.EncodeZiplist(elems)),
+			rdb.ListValue{Elements: strs("a", "1", "b", "2")}},
+		{"ziplist hash", blobDump(rdb.HashZipList, nil, codec.EncodeZiplist(elems)),
+			rdb.HashValue{Fields: fields("b", "2", "a", "1")}},
+		{"zipmap hash", blobDump(rdb.HashZipmap, nil, codec.EncodeZipmap(elems)),
+			rdb.HashValue{Fields: fields("a", "1", "b", "2")}},
+		{"intset", blobDump(rdb.SetIntSet, nil, codec.EncodeIntset([]int64{2, 1, 300})),
+			rdb.SetValue{Members: strs("300", "2", "1")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := rdbtest.LoadBytes(t, tt.blob)
+			b := rdbtest.LoadBytes(t, rdbtest.Dump(t, 11, &rdb.Entry{Key: rdb.RedisString("h"), Value: tt.v}))
+			if len(a) != 1 || len(b) != 1 {
+				t.Fatalf("got %d and %d entries", len(a), len(b))
+			}
+			if a[0].Encoding() == b[0].Encoding() {
+				t.Fatalf("both stored in %v", a[0].Encoding())
+			}
+			if !rdb.Equivalent(a[0], b[0]) {
+				t.Errorf("%v in %v and %v in %v are not equivalent", a[0].Value, a[0].Encoding(), b[0].Value, b[0].Encoding())
+			}
+		})
+	}
+}
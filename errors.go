@@ -0,0 +1,44 @@
+package rdb
+
+import (
+	"errors"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// ErrFormat is returned when the input is not a well-formed RDB stream or
+// contains a malformed encoded value.
+var ErrFormat = codec.ErrFormat
+
+var (
+	// ErrVersion is returned for RDB versions the Reader does not support.
+	ErrVersion = errors.New("rdb: unsupported version")
+
+	// ErrBadOpCode is returned when a byte that should start an opcode or a
+	// key is neither a known opcode nor a known value type.
+	ErrBadOpCode = errors.New("rdb: bad opcode")
+
+	// ErrNotSupported is returned for value types and other constructs the
+	// Reader recognises but cannot decode.
+	ErrNotSupported = errors.New("rdb: not supported")
+
+	// ErrChecksum is returned when the CRC-64 stored at the end of the
+	// dump does not match its contents, unless WithoutChecksum is in
+	// effect.
+	ErrChecksum = errors.New("rdb: checksum mismatch")
+
+	// ErrConvert is returned by DecodeAs for values that do not fit the
+	// requested Go type.
+	ErrConvert = errors.New("rdb: cannot convert value")
+)
+
+// ErrTooLarge is returned for lengths that exceed the limits set with
+// WithLimits or, on 32-bit platforms, the largest int.
+var ErrTooLarge = codec.ErrTooLarge
+
+// LengthError reports a length exceeding a limit. It wraps ErrTooLarge.
+type LengthError = codec.LengthError
+
+// CorruptError describes a structural problem found in an encoded blob such
+// as a ziplist or listpack. It wraps ErrFormat.
+type CorruptError = codec.CorruptError
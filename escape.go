@@ -0,0 +1,83 @@
+package rdb
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// IsBinary reports whether s is not valid UTF-8 text, which text formats
+// such as JSON cannot carry without escaping.
+func IsBinary(s []byte) bool {
+	return !utf8.Valid(s)
+}
+
+// Escaping selects how exporters write keys and values into text formats.
+type Escaping uint8
+
+const (
+	// EscapeRaw writes strings unchanged. Invalid UTF-8 reaches the output
+	// as is, or is replaced by formats that cannot hold it, such as JSON.
+	EscapeRaw Escaping = iota
+
+	// EscapeReplace replaces invalid UTF-8 sequences with U+FFFD. Text is
+	// kept readable but binary data is lost.
+	EscapeReplace
+
+	// EscapeQuote writes backslashes as \\ and bytes that are not
+	// printable UTF-8 as \xHH, the way redis-cli shows them. It is
+	// reversible and leaves plain text unchanged.
+	EscapeQuote
+
+	// EscapeHex hex-encodes every string.
+	EscapeHex
+
+	// EscapeBase64 encodes every string in standard base64.
+	EscapeBase64
+)
+
+var escapingNames = [...]string{"raw", "replace", "quote", "hex", "base64"}
+
+func (x Escaping) String() string {
+	if int(x) < len(escapingNames) {
+		return escapingNames[x]
+	}
+	return "Escaping(" + strconv.Itoa(int(x)) + ")"
+}
+
+// Apply returns s escaped according to x.
+func (x Escaping) Apply(s []byte) string {
+	switch x {
+	case EscapeReplace:
+		return strings.ToValidUTF8(string(s), "�")
+	case EscapeQuote:
+		return quoteBinary(s)
+	case EscapeHex:
+		return hex.EncodeToString(s)
+	case EscapeBase64:
+		return base64.StdEncoding.EncodeToString(s)
+	}
+	return string(s)
+}
+
+func quoteBinary(s []byte) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		r, n := utf8.DecodeRune(s)
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == utf8.RuneError && n == 1, r < ' ', r == 0x7f:
+			const digits = "0123456789abcdef"
+			b.WriteString(`\x`)
+			b.WriteByte(digits[s[0]>>4])
+			b.WriteByte(digits[s[0]&15])
+		default:
+			b.Write(s[:n])
+		}
+		s = s[n:]
+	}
+	return b.String()
+}
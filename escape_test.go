@@ -0,0 +1,128 @@
+package rdb_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+// unquote reverses EscapeQuote.
+func unquote(t *testing.T, s string) []byte {
+	t.Helper()
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		switch {
+		case i+1 < len(s) && s[i+1] == '\\':
+			b = append(b, '\\')
+			i++
+		case i+3 < len(s) && s[i+1] == 'x':
+			n, err := strconv.ParseUint(s[i+2:i+4], 16, 8)
+			if err != nil {
+				t.Fatalf("%q: %v", s, err)
+			}
+			b = append(b, byte(n))
+			i += 3
+		default:
+			t.Fatalf("%q: lone backslash at %d", s, i)
+		}
+	}
+	return b
+}
+
+func TestEscaping(t *testing.T) {
+	tests := []struct {
+		in             string
+		binary         bool
+		replace, quote string
+		hex, base64    string
+	}{
+		{"plain", false, "plain", "plain", "706c61696e", "cGxhaW4="},
+		{`a\b`, false, `a\b`, `a\\b`, "615c62", "YVxi"},
+		{"\xff\x00k", true, "�\x00k", `\xff\x00k`, "ff006b", "/wBr"},
+		{"é", false, "é", "é", "c3a9", "w6k="},
+		{"\xc3", true, "�", `\xc3`, "c3", "ww=="},
+		{"tab\t\x7f", false, "tab\t\x7f", `tab\x09\x7f`, "746162097f", "dGFiCX8="},
+		{`\x41`, false, `\x41`, `\\x41`, "5c783431", "XHg0MQ=="},
+		{"", false, "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Quote(tt.in), func(t *testing.T) {
+			in := []byte(tt.in)
+			if got := rdb.IsBinary(in); got != tt.binary {
+				t.Errorf("IsBinary = %v", got)
+			}
+			for _, c := range []struct {
+				esc  rdb.Escaping
+				want string
+			}{
+				{rdb.EscapeRaw, tt.in},
+				{rdb.EscapeReplace, tt.replace},
+				{rdb.EscapeQuote, tt.quote},
+				{rdb.EscapeHex, tt.hex},
+				{rdb.EscapeBase64, tt.base64},
+			} {
+				if got := c.esc.Apply(in); got != c.want {
+					t.Errorf("%v: got %q, want %q", c.esc, got, c.want)
+				}
+			}
+
+			// Every escaping but replace is reversible.
+			if got := string(unquote(t, tt.quote)); got != tt.in {
+				t.Errorf("quote does not round-trip: got %q", got)
+			}
+			if got, _ := hex.DecodeString(tt.hex); string(got) != tt.in {
+				t.Errorf("hex does not round-trip: got %q", got)
+			}
+			if got, _ := base64.StdEncoding.DecodeString(tt.base64); string(got) != tt.in {
+				t.Errorf("base64 does not round-trip: got %q", got)
+			}
+		})
+	}
+	if s := rdb.Escaping(9).String(); s != "Escaping(9)" {
+		t.Errorf("got %q", s)
+	}
+}
+
+// TestEscapingRoundTrip checks that every byte value survives quoting.
+func TestEscapingRoundTrip(t *testing.T) {
+	var all []byte
+	for i := 0; i < 256; i++ {
+		all = append(all, byte(i))
+	}
+	all = append(all, "héllo\\€"...)
+	q := rdb.EscapeQuote.Apply(all)
+	if rdb.IsBinary([]byte(q)) {
+		t.Errorf("quoted form %q is not text", q)
+	}
+	if got := unquote(t, q); string(got) != string(all) {
+		t.Errorf("got %q, want %q", got, all)
+	}
+}
+
+func TestTTLExporterEscape(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	var buf bytes.Buffer
+	x, err := rdb.NewTTLExporter(&buf, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.Escape = rdb.EscapeQuote
+	x.Add(&rdb.Entry{Key: rdb.RedisString("k\xff\n"), ExpiryAt: now.UnixMilli() + 1000})
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "db,key,type,expire_at,seconds_remaining\n" +
+		`0,k\xff\x0a,string,2023-11-14T22:13:21Z,1` + "\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
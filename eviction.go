@@ -0,0 +1,169 @@
+package rdb
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// EvictionPolicy is a Redis maxmemory-policy.
+type EvictionPolicy uint8
+
+const (
+	NoEviction EvictionPolicy = iota
+	AllKeysLRU
+	VolatileLRU
+	AllKeysLFU
+	VolatileLFU
+	AllKeysRandom
+	VolatileRandom
+	VolatileTTL
+)
+
+var evictionPolicyNames = [...]string{
+	"noeviction", "allkeys-lru", "volatile-lru", "allkeys-lfu",
+	"volatile-lfu", "allkeys-random", "volatile-random", "volatile-ttl",
+}
+
+// String returns the name used in redis.conf, such as "allkeys-lru".
+func (p EvictionPolicy) String() string {
+	if int(p) < len(evictionPolicyNames) {
+		return evictionPolicyNames[p]
+	}
+	return "EvictionPolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// MarshalText formats the policy as String does.
+func (p EvictionPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// ParseEvictionPolicy parses a maxmemory-policy name as used in redis.conf.
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	for i, name := range evictionPolicyNames {
+		if s == name {
+			return EvictionPolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("rdb: unknown maxmemory-policy %q", s)
+}
+
+// volatile reports whether the policy only evicts keys with an expiry.
+func (p EvictionPolicy) volatile() bool {
+	return p == VolatileLRU || p == VolatileLFU || p == VolatileRandom || p == VolatileTTL
+}
+
+// lfuInitVal is the LFU counter Redis gives keys loaded without one.
+const lfuInitVal = 5
+
+// EvictionSim predicts which keys Redis would evict if the dump were
+// loaded into a server limited to MaxMemory bytes under Policy. It ranks
+// keys by the LRU idle times, LFU counters or expiries stored in the dump
+// and evicts them in that order until the estimated memory fits; keys
+// without access information count as just used. Redis only approximates
+// that order by sampling, and its used memory includes overhead the
+// estimate leaves out, so the result is an indication rather than a
+// prediction of individual keys.
+//
+// EvictionSim keeps a small record for every key that can be evicted.
+// Feed it every entry with Add, then call Report.
+type EvictionSim struct {
+	Config    EncodingConfig
+	MaxMemory uint64
+	Policy    EvictionPolicy
+
+	// Seed seeds the order of the random policies.
+	Seed int64
+
+	keys       uint64
+	memory     uint64
+	candidates []EvictedKey
+}
+
+// EvictedKey is a key selected for eviction.
+type EvictedKey struct {
+	DB     uint64
+	Key    RedisString
+	Type   Type
+	Memory uint64 // estimated bytes
+
+	rank int64 // evicted in increasing order
+}
+
+// EvictionReport is the result of EvictionSim.
+type EvictionReport struct {
+	Policy    EvictionPolicy
+	MaxMemory uint64
+	Keys      uint64
+	Memory    uint64 // estimated bytes of all keys
+
+	// Evicted lists the evicted keys in the order they would go.
+	Evicted       []EvictedKey
+	EvictedMemory uint64
+
+	// Remaining is the estimated memory left after evicting. If it still
+	// exceeds MaxMemory, the policy ran out of keys to evict and Redis
+	// would reject writes.
+	Remaining uint64
+}
+
+// Fits reports whether the remaining keys fit within MaxMemory.
+func (r *EvictionReport) Fits() bool {
+	return r.Remaining <= r.MaxMemory
+}
+
+// NewEvictionSim returns an EvictionSim for the given limit and policy,
+// estimating memory with cfg.
+func NewEvictionSim(cfg EncodingConfig, maxMemory uint64, policy EvictionPolicy) *EvictionSim {
+	return &EvictionSim{Config: cfg, MaxMemory: maxMemory, Policy: policy}
+}
+
+// Add accounts for a single entry.
+func (s *EvictionSim) Add(e *Entry) {
+	_, size := EstimateMemory(e, s.Config)
+	s.keys++
+	s.memory += size
+	if s.Policy == NoEviction || s.Policy.volatile() && !e.HasExpiry() {
+		return
+	}
+	var rank int64
+	switch s.Policy {
+	case AllKeysLRU, VolatileLRU:
+		if e.Access == AccessLRU {
+			rank = -int64(e.Idle)
+		}
+	case AllKeysLFU, VolatileLFU:
+		rank = lfuInitVal
+		if e.Access == AccessLFU {
+			rank = int64(e.Freq)
+		}
+	case VolatileTTL:
+		rank = e.ExpiryAt
+	}
+	s.candidates = append(s.candidates, EvictedKey{DB: e.DB, Key: e.Key, Type: e.Type(), Memory: size, rank: rank})
+}
+
+// Report runs the simulation on the entries added so far.
+func (s *EvictionSim) Report() EvictionReport {
+	r := EvictionReport{Policy: s.Policy, MaxMemory: s.MaxMemory, Keys: s.keys, Memory: s.memory, Remaining: s.memory}
+	if r.Remaining <= s.MaxMemory {
+		return r
+	}
+	c := append([]EvictedKey(nil), s.candidates...)
+	if s.Policy == AllKeysRandom || s.Policy == VolatileRandom {
+		rnd := rand.New(rand.NewSource(s.Seed))
+		rnd.Shuffle(len(c), func(i, j int) { c[i], c[j] = c[j], c[i] })
+	} else {
+		sort.SliceStable(c, func(i, j int) bool { return c[i].rank < c[j].rank })
+	}
+	for _, k := range c {
+		if r.Remaining <= s.MaxMemory {
+			break
+		}
+		r.Evicted = append(r.Evicted, k)
+		r.EvictedMemory += k.Memory
+		r.Remaining -= k.Memory
+	}
+	return r
+}
@@ -0,0 +1,97 @@
+package rdb_test
+
+import (
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestEvictionPolicyNames(t *testing.T) {
+	for p := rdb.NoEviction; p <= rdb.VolatileTTL; p++ {
+		got, err := rdb.ParseEvictionPolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("%v: got %v, %v", p, got, err)
+		}
+	}
+	if _, err := rdb.ParseEvictionPolicy("allkeys-fifo"); err == nil {
+		t.Error("allkeys-fifo was accepted")
+	}
+	if s := rdb.EvictionPolicy(42).String(); s != "EvictionPolicy(42)" {
+		t.Errorf("got %q", s)
+	}
+}
+
+func TestEvictionSim(t *testing.T) {
+	entries := []*rdb.Entry{
+		{Key: rdb.RedisString("a"), Value: rdb.StringValue("v"), Access: rdb.AccessLRU, Idle: 100},
+		{Key: rdb.RedisString("b"), Value: rdb.StringValue("v"), Access: rdb.AccessLRU, Idle: 10, ExpiryAt: 2000},
+		{Key: rdb.RedisString("c"), Value: rdb.StringValue("v"), Access: rdb.AccessLFU, Freq: 1, ExpiryAt: 1000},
+		{Key: rdb.RedisString("d"), Value: rdb.StringValue("v"), Access: rdb.AccessLFU, Freq: 200},
+		{Key: rdb.RedisString("e"), Value: rdb.StringValue("v"), ExpiryAt: 3000},
+	}
+	cfg := rdb.DefaultEncodingConfig()
+	var total uint64
+	for _, e := range entries {
+		_, size := rdb.EstimateMemory(e, cfg)
+		total += size
+	}
+	run := func(p rdb.EvictionPolicy, maxMemory uint64, seed int64) rdb.EvictionReport {
+		s := rdb.NewEvictionSim(cfg, maxMemory, p)
+		s.Seed = seed
+		for _, e := range entries {
+			s.Add(e)
+		}
+		return s.Report()
+	}
+	keys := func(r rdb.EvictionReport) string {
+		var s string
+		for _, k := range r.Evicted {
+			s += string(k.Key)
+		}
+		return s
+	}
+
+	// With no memory at all, every candidate goes, in policy order. Keys
+	// without access information count as just used, and keys loaded
+	// without an LFU counter get the initial one, 5.
+	tests := []struct {
+		policy rdb.EvictionPolicy
+		want   string
+		fits   bool
+	}{
+		{rdb.NoEviction, "", false},
+		{rdb.AllKeysLRU, "abcde", true},
+		{rdb.VolatileLRU, "bce", false},
+		{rdb.AllKeysLFU, "cabed", true},
+		{rdb.VolatileLFU, "cbe", false},
+		{rdb.VolatileTTL, "cbe", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			r := run(tt.policy, 0, 0)
+			if got := keys(r); got != tt.want {
+				t.Errorf("evicted %q, want %q", got, tt.want)
+			}
+			if r.Keys != 5 || r.Memory != total || r.EvictedMemory+r.Remaining != total || r.Fits() != tt.fits {
+				t.Errorf("got %+v", r)
+			}
+		})
+	}
+
+	// Evicting stops as soon as the rest fits.
+	if r := run(rdb.AllKeysLFU, total-1, 0); keys(r) != "c" || !r.Fits() {
+		t.Errorf("one byte over: evicted %q", keys(r))
+	}
+	if r := run(rdb.AllKeysLFU, total, 0); len(r.Evicted) != 0 || !r.Fits() {
+		t.Errorf("at the limit: evicted %q", keys(r))
+	}
+
+	// The random policies follow the seed.
+	a, b := run(rdb.VolatileRandom, 0, 7), run(rdb.VolatileRandom, 0, 7)
+	if keys(a) != keys(b) || len(a.Evicted) != 3 {
+		t.Errorf("seed 7 evicted %q then %q", keys(a), keys(b))
+	}
+	if r := run(rdb.AllKeysRandom, 0, 7); len(r.Evicted) != 5 || r.Remaining != 0 {
+		t.Errorf("allkeys-random evicted %q", keys(r))
+	}
+}
@@ -0,0 +1,72 @@
+package rdb
+
+import "time"
+
+// ExpiringBetween returns a predicate matching the keys whose expiry falls
+// in [from, to).
+func ExpiringBetween(from, to time.Time) func(*Entry) bool {
+	lo, hi := from.UnixMilli(), to.UnixMilli()
+	return func(e *Entry) bool {
+		return e.HasExpiry() && e.ExpiryAt >= lo && e.ExpiryAt < hi
+	}
+}
+
+// ExpiredAt returns a predicate matching the keys that have expired at
+// time t, which Redis would drop when loading the dump at that time.
+func ExpiredAt(t time.Time) func(*Entry) bool {
+	ms := t.UnixMilli()
+	return func(e *Entry) bool {
+		return e.HasExpiry() && e.ExpiryAt < ms
+	}
+}
+
+// ExpiryWindow lists the keys whose expiry falls within a time window, for
+// example the 24 hours after a restore, and counts them per interval so
+// that bursts of simultaneous expiries stand out. Feed it every entry with
+// Add.
+type ExpiryWindow struct {
+	From, To time.Time
+
+	// Interval is the width of the buckets in Counts; 0 means an hour.
+	Interval time.Duration
+
+	// Keys lists the matching keys in the order they were added.
+	Keys []ExpiringKey
+
+	// Counts holds the number of matching keys per Interval, starting at
+	// From.
+	Counts []uint64
+
+	match func(*Entry) bool
+}
+
+// ExpiringKey is a key expiring within the window.
+type ExpiringKey struct {
+	DB       uint64
+	Key      RedisString
+	Type     Type
+	ExpiryAt int64 // milliseconds since the Unix epoch
+}
+
+// NewExpiryWindow returns an ExpiryWindow for the keys expiring in
+// [from, to).
+func NewExpiryWindow(from, to time.Time) *ExpiryWindow {
+	return &ExpiryWindow{From: from, To: to}
+}
+
+// Add accounts for a single entry.
+func (w *ExpiryWindow) Add(e *Entry) {
+	if w.match == nil {
+		w.match = ExpiringBetween(w.From, w.To)
+		if w.Interval <= 0 {
+			w.Interval = time.Hour
+		}
+		span := time.Duration(w.To.UnixMilli()-w.From.UnixMilli()) * time.Millisecond
+		w.Counts = make([]uint64, max((span+w.Interval-1)/w.Interval, 0))
+	}
+	if !w.match(e) {
+		return
+	}
+	w.Keys = append(w.Keys, ExpiringKey{DB: e.DB, Key: e.Key, Type: e.Type(), ExpiryAt: e.ExpiryAt})
+	w.Counts[time.Duration(e.ExpiryAt-w.From.UnixMilli())*time.Millisecond/w.Interval]++
+}
@@ -0,0 +1,82 @@
+package rdb_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestExpiryPredicates(t *testing.T) {
+	t0 := time.UnixMilli(1700000000000)
+	ms := t0.UnixMilli()
+	between := rdb.ExpiringBetween(t0, t0.Add(time.Second))
+	expired := rdb.ExpiredAt(t0)
+	tests := []struct {
+		expiry           int64
+		between, expired bool
+	}{
+		{0, false, false},
+		{ms - 1, false, true},
+		{ms, true, false},
+		{ms + 999, true, false},
+		{ms + 1000, false, false},
+	}
+	for _, tt := range tests {
+		e := str(0, "k", "v", tt.expiry)
+		if got := between(e); got != tt.between {
+			t.Errorf("expiry %d: ExpiringBetween = %v", tt.expiry, got)
+		}
+		if got := expired(e); got != tt.expired {
+			t.Errorf("expiry %d: ExpiredAt = %v", tt.expiry, got)
+		}
+	}
+}
+
+func TestExpiryWindow(t *testing.T) {
+	t0 := time.UnixMilli(1700000000000)
+	at := func(d time.Duration) int64 { return t0.Add(d).UnixMilli() }
+	w := rdb.NewExpiryWindow(t0, t0.Add(150*time.Minute))
+	for _, e := range []*rdb.Entry{
+		str(0, "before", "v", at(-time.Millisecond)),
+		str(0, "first", "v", at(0)),
+		str(1, "end of first hour", "v", at(time.Hour-time.Millisecond)),
+		str(0, "second", "v", at(time.Hour)),
+		str(0, "last", "v", at(149*time.Minute)),
+		str(0, "after", "v", at(150*time.Minute)),
+		str(0, "persistent", "v", 0),
+	} {
+		w.Add(e)
+	}
+	if w.Interval != time.Hour {
+		t.Errorf("got interval %v", w.Interval)
+	}
+	// The last, partial hour has a bucket of its own.
+	if want := []uint64{2, 1, 1}; !reflect.DeepEqual(w.Counts, want) {
+		t.Errorf("got counts %v, want %v", w.Counts, want)
+	}
+	var keys []string
+	for _, k := range w.Keys {
+		keys = append(keys, string(k.Key))
+	}
+	if want := []string{"first", "end of first hour", "second", "last"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got keys %q, want %q", keys, want)
+	}
+	if k := w.Keys[1]; k.DB != 1 || k.Type != rdb.TypeString || k.ExpiryAt != at(time.Hour-time.Millisecond) {
+		t.Errorf("got %+v", k)
+	}
+
+	w = &rdb.ExpiryWindow{From: t0, To: t0.Add(time.Minute), Interval: 10 * time.Second}
+	w.Add(str(0, "k", "v", at(25*time.Second)))
+	if want := []uint64{0, 0, 1, 0, 0, 0}; !reflect.DeepEqual(w.Counts, want) {
+		t.Errorf("got counts %v, want %v", w.Counts, want)
+	}
+
+	// An empty window has no buckets and matches nothing.
+	w = rdb.NewExpiryWindow(t0, t0.Add(-time.Hour))
+	w.Add(str(0, "k", "v", at(-time.Minute)))
+	if len(w.Counts) != 0 || len(w.Keys) != 0 {
+		t.Errorf("reversed window: %+v", w)
+	}
+}
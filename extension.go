@@ -0,0 +1,248 @@
+package rdb
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The package can be extended with decoders and encoders for value types
+// it does not support, typically module data types, and with transforms
+// and sinks, all registered under a name from an init function. Tools can
+// then resolve transforms and sinks named in their configuration, and
+// Readers and Writers handle the registered types without further setup.
+
+// A ValueDecoder decodes a value the Reader cannot. raw holds the value as
+// stored in the dump, after the key and, for module values, after the
+// module type ID given as module. Decoders apply to the values the Reader
+// can step over, which include module values of RDB version 8 and later
+// and streams.
+type ValueDecoder func(t ValueType, module ModuleID, raw []byte) (Value, error)
+
+// A ValueEncoder serializes the value of e, returning the type byte and
+// the bytes to write after the key in a dump of the given version.
+type ValueEncoder func(e *Entry, version int) (ValueType, []byte, error)
+
+// NamedValue is implemented by values a registered ValueEncoder writes:
+// the Writer hands them to the encoder registered under their name.
+type NamedValue interface {
+	Value
+	ValueName() string
+}
+
+// A TransformFactory returns a Transform configured with args, the part
+// of a transform specification after the name.
+type TransformFactory func(args string) (Transform, error)
+
+// A SinkFactory returns a Sink writing to w, configured with args, the
+// part of a sink specification after the name. Sinks sending entries
+// elsewhere, such as to a message queue, may ignore w.
+type SinkFactory func(w io.Writer, args string) (Sink, error)
+
+// registry maps names to extensions.
+type registry[T any] struct {
+	what string
+	mu   sync.RWMutex
+	m    map[string]T
+}
+
+// add registers x under name. Like database/sql.Register, it panics if
+// the name is empty or taken, which are programming errors.
+func (r *registry[T]) add(name string, x T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" {
+		panic("rdb: " + r.what + " registered without a name")
+	}
+	if _, dup := r.m[name]; dup {
+		panic("rdb: " + r.what + " " + name + " registered twice")
+	}
+	if r.m == nil {
+		r.m = make(map[string]T)
+	}
+	r.m[name] = x
+}
+
+func (r *registry[T]) get(name string) (T, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	x, ok := r.m[name]
+	return x, ok
+}
+
+func (r *registry[T]) names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.m))
+	for name := range r.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	decoders   = registry[ValueDecoder]{what: "decoder"}
+	encoders   = registry[ValueEncoder]{what: "encoder"}
+	transforms = registry[TransformFactory]{what: "transform"}
+	sinks      = registry[SinkFactory]{what: "sink"}
+)
+
+// RegisterDecoder makes Readers decode values with dec. name is the module
+// type name, such as "ReJSON-RL", for module values, and the name of the
+// value type, such as "StreamListPacks", for others. It panics if name is
+// already registered.
+func RegisterDecoder(name string, dec ValueDecoder) {
+	decoders.add(name, dec)
+}
+
+// RegisterEncoder makes Writers write the NamedValues named name with enc.
+// It panics if name is already registered.
+func RegisterEncoder(name string, enc ValueEncoder) {
+	encoders.add(name, enc)
+}
+
+// RegisterTransform makes the transform available to LookupTransform under
+// name. It panics if name is already registered.
+func RegisterTransform(name string, f TransformFactory) {
+	transforms.add(name, f)
+}
+
+// RegisterSink makes the sink available to OpenSink under name. It panics
+// if name is already registered.
+func RegisterSink(name string, f SinkFactory) {
+	sinks.add(name, f)
+}
+
+// Transforms returns the names of the registered transforms, sorted.
+func Transforms() []string { return transforms.names() }
+
+// Sinks returns the names of the registered sinks, sorted.
+func Sinks() []string { return sinks.names() }
+
+// splitSpec splits a specification of the form "name" or "name:args".
+func splitSpec(spec string) (name, args string) {
+	name, args, _ = strings.Cut(spec, ":")
+	return name, args
+}
+
+// LookupTransform returns the transform described by spec, a registered
+// name optionally followed by a colon and arguments, as in "gzip:9".
+func LookupTransform(spec string) (Transform, error) {
+	name, args := splitSpec(spec)
+	f, ok := transforms.get(name)
+	if !ok {
+		return nil, fmt.Errorf("rdb: unknown transform %q", name)
+	}
+	t, err := f(args)
+	if err != nil {
+		return nil, fmt.Errorf("rdb: transform %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// OpenSink returns the sink described by spec, a registered name
+// optionally followed by a colon and arguments, as in "rdb:9", writing to
+// w.
+func OpenSink(spec string, w io.Writer) (Sink, error) {
+	name, args := splitSpec(spec)
+	f, ok := sinks.get(name)
+	if !ok {
+		return nil, fmt.Errorf("rdb: unknown sink %q", name)
+	}
+	s, err := f(w, args)
+	if err != nil {
+		return nil, fmt.Errorf("rdb: sink %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// decodeExtension decodes the value of e with the decoder registered for
+// it, if any: a module decoder for the module type and version of Module2
+// values, or a ValueDecoder. The module ID of module values has been read.
+func (r *Reader) decodeExtension(e *Entry, id ModuleID) (bool, error) {
+	if e.ValueType == Module2 {
+		if fn, ok := moduleDecoders.get(id.String()); ok {
+			m := r.in.mark()
+			err := r.skipModule2()
+			raw := r.in.since(m)
+			if err != nil {
+				return true, err
+			}
+			e.Value, err = decodeModule(fn, id, raw)
+			return true, err
+		}
+	}
+	name := e.ValueType.String()
+	if e.ValueType == Module || e.ValueType == Module2 {
+		name = id.Name()
+	}
+	dec, ok := decoders.get(name)
+	if !ok {
+		return false, nil
+	}
+	m := r.in.mark()
+	err := r.skipValue(e.ValueType)
+	raw := r.in.since(m)
+	if err != nil {
+		return true, err
+	}
+	e.Value, err = dec(e.ValueType, id, raw)
+	return true, err
+}
+
+// encodeExtension encodes v with the encoder registered for it.
+func encodeExtension(e *Entry, v NamedValue, version int) (ValueType, []byte, error) {
+	enc, ok := encoders.get(v.ValueName())
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: no encoder for %s values of key %q", ErrNotSupported, v.ValueName(), e.Key)
+	}
+	return enc(e, version)
+}
+
+func init() {
+	RegisterTransform("gzip", func(args string) (Transform, error) {
+		level := gzip.DefaultCompression
+		if args != "" {
+			var err error
+			if level, err = strconv.Atoi(args); err != nil {
+				return nil, fmt.Errorf("bad level %q", args)
+			}
+		}
+		if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+			return nil, fmt.Errorf("bad level %d", level)
+		}
+		return Gzip(level), nil
+	})
+	RegisterTransform("buffered", func(args string) (Transform, error) {
+		n, err := strconv.Atoi(args)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("bad high-water mark %q", args)
+		}
+		return Buffered(n), nil
+	})
+	RegisterSink("json", func(w io.Writer, args string) (Sink, error) {
+		return NewJSONExporter(w, JSONOptions{})
+	})
+	RegisterSink("resp", func(w io.Writer, args string) (Sink, error) {
+		return NewRESPExporter(w)
+	})
+	RegisterSink("rdb", func(w io.Writer, args string) (Sink, error) {
+		version := MaxVersion
+		if args != "" {
+			var err error
+			if version, err = strconv.Atoi(args); err != nil {
+				return nil, fmt.Errorf("bad version %q", args)
+			}
+		}
+		dw, err := NewWriter(w, version)
+		if err != nil {
+			return nil, err
+		}
+		return WriterSink(dw), nil
+	})
+}
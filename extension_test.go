@@ -0,0 +1,189 @@
+package rdb_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+// moduleID packs a 9 character module type name and an encoding version
+// the way Redis does.
+func moduleID(name string, version int) rdb.ModuleID {
+	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	var id uint64
+	for i := 0; i < 9; i++ {
+		id = id<<6 | uint64(strings.IndexByte(charset, name[i]))
+	}
+	return rdb.ModuleID(id<<10 | uint64(version))
+}
+
+// counter is a value written by a registered encoder, as a Module2 value
+// of the module type "TestCount" holding one unsigned integer, and read
+// back by a registered decoder.
+type counter uint64
+
+func (counter) Type() rdb.Type         { return rdb.TypeModule }
+func (counter) Encoding() rdb.Encoding { return rdb.EncodingModule }
+func (counter) ValueName() string      { return "test-counter" }
+
+func init() {
+	rdb.RegisterEncoder("test-counter", func(e *rdb.Entry, version int) (rdb.ValueType, []byte, error) {
+		b := codec.AppendLength(nil, uint64(moduleID("TestCount", 1)))
+		b = codec.AppendLength(b, 2) // unsigned
+		b = codec.AppendLength(b, uint64(e.Value.(counter)))
+		return rdb.Module2, codec.AppendLength(b, 0), nil
+	})
+	rdb.RegisterDecoder("TestCount", func(t rdb.ValueType, id rdb.ModuleID, raw []byte) (rdb.Value, error) {
+		if t != rdb.Module2 || id != moduleID("TestCount", 1) || len(raw) < 2 || raw[0] != 2 {
+			return nil, errors.New("not a counter")
+		}
+		n, _, _, err := codec.DecodeLength(raw[1:])
+		return counter(n), err
+	})
+	rdb.RegisterSink("test-null", func(w io.Writer, args string) (rdb.Sink, error) {
+		if args != "" {
+			return nil, errors.New("no arguments expected")
+		}
+		return rdb.SinkFunc(func(*rdb.Entry) error { return nil }), nil
+	})
+}
+
+func TestRegisteredCodec(t *testing.T) {
+	dump := rdbtest.Dump(t, 11,
+		&rdb.Entry{Key: rdb.RedisString("c"), Value: counter(300)},
+		str(0, "s", "v", 0),
+	)
+	got := rdbtest.LoadBytes(t, dump)
+	if len(got) != 2 || got[0].Value != counter(300) || got[0].ValueType != rdb.Module2 {
+		t.Fatalf("got %+v", got)
+	}
+
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteEntry(&rdb.Entry{Key: rdb.RedisString("u"), Value: named{}}); !errors.Is(err, rdb.ErrNotSupported) {
+		t.Errorf("got %v for a value without an encoder, want ErrNotSupported", err)
+	}
+}
+
+// named is a NamedValue no encoder is registered for.
+type named struct{}
+
+func (named) Type() rdb.Type         { return rdb.TypeModule }
+func (named) Encoding() rdb.Encoding { return rdb.EncodingModule }
+func (named) ValueName() string      { return "test-unregistered" }
+
+func TestLookupTransform(t *testing.T) {
+	for _, spec := range []string{"gzip", "gzip:9", "gzip:-2", "buffered:4096"} {
+		tr, err := rdb.LookupTransform(spec)
+		if err != nil {
+			t.Errorf("%s: %v", spec, err)
+			continue
+		}
+		var buf bytes.Buffer
+		wc, err := tr(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(wc, "data")
+		if err := wc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if strings.HasPrefix(spec, "gzip") {
+			zr, err := gzip.NewReader(&buf)
+			if err != nil {
+				t.Fatalf("%s: %v", spec, err)
+			}
+			b, _ := io.ReadAll(zr)
+			out = string(b)
+		}
+		if out != "data" {
+			t.Errorf("%s: got %q", spec, out)
+		}
+	}
+	for _, spec := range []string{"gzip:x", "gzip:10", "buffered", "buffered:0", "zstd", ""} {
+		if _, err := rdb.LookupTransform(spec); err == nil {
+			t.Errorf("%q was accepted", spec)
+		}
+	}
+	names := rdb.Transforms()
+	if !reflect.DeepEqual(names, []string{"buffered", "gzip"}) {
+		t.Errorf("got transforms %q", names)
+	}
+}
+
+func TestOpenSink(t *testing.T) {
+	var buf bytes.Buffer
+	s, err := rdb.OpenSink("rdb:9", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteEntry(str(0, "k", "v", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.End(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("REDIS0009")) {
+		t.Errorf("got header %q", buf.Bytes()[:9])
+	}
+	if got := rdbtest.LoadBytes(t, buf.Bytes()); len(got) != 1 || string(got[0].Key) != "k" {
+		t.Errorf("got %+v", got)
+	}
+
+	buf.Reset()
+	s, err = rdb.OpenSink("json", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.WriteEntry(str(0, "k", "v", 0))
+	s.End()
+	if buf.String() != `{"key":"k","value":"v"}`+"\n" {
+		t.Errorf("json sink wrote %q", buf.String())
+	}
+
+	if _, err := rdb.OpenSink("test-null", nil); err != nil {
+		t.Error(err)
+	}
+	for _, spec := range []string{"rdb:x", "rdb:0", "test-null:x", "kafka"} {
+		if _, err := rdb.OpenSink(spec, io.Discard); err == nil {
+			t.Errorf("%q was accepted", spec)
+		}
+	}
+	if names := rdb.Sinks(); !reflect.DeepEqual(names, []string{"json", "rdb", "resp", "test-null"}) {
+		t.Errorf("got sinks %q", names)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		f    func()
+	}{
+		{"transform", func() { rdb.RegisterTransform("gzip", nil) }},
+		{"sink", func() { rdb.RegisterSink("rdb", nil) }},
+		{"encoder", func() { rdb.RegisterEncoder("test-counter", nil) }},
+		{"decoder", func() { rdb.RegisterDecoder("TestCount", nil) }},
+		{"unnamed", func() { rdb.RegisterSink("", nil) }},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", tt.name)
+				}
+			}()
+			tt.f()
+		}()
+	}
+}
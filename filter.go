@@ -0,0 +1,380 @@
+package rdb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseFilter compiles a filter expression into a predicate, for use with
+// WithFilter, Filter or anywhere entries are selected, so that command-line
+// tools and configuration files can select keys without Go code:
+//
+//	type=hash and ttl<1h and key~"session:*" and size>1mb
+//
+// An expression compares fields of the entry with values, combined with
+// and, or, not and parentheses; and binds tighter than or. The fields are:
+//
+//	key       the key name: = and != compare it, ~ and !~ match a glob
+//	          pattern (see MatchGlob)
+//	type      string, list, set, zset, hash, stream or module (= and !=)
+//	encoding  the encoding in the dump, e.g. listpack or quicklist
+//	db        the database number
+//	ttl       the time left before expiry at now; keys without an expiry
+//	          have an infinite ttl
+//	size      the estimated memory of the key, see EstimateMemory
+//	len       the length of a string, or number of elements of a value
+//	idle      the LRU idle time, for keys saved with one
+//	freq      the LFU counter, for keys saved with one
+//
+// Numeric fields are compared with =, !=, <, <=, > and >=. Durations take
+// the units of time.ParseDuration and d for days, sizes b, kb, mb, gb and
+// tb (powers of 1024). Strings may be quoted with double quotes, and must
+// be when they hold spaces or operators.
+func ParseFilter(expr string, now time.Time) (func(e *Entry) bool, error) {
+	toks, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{toks: toks, now: now, cfg: DefaultEncodingConfig()}
+	match, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEnd {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	return match, nil
+}
+
+// WithFilter makes the Reader return only the entries keep reports true
+// for; the others are read past like skipped keys. keep sees the entry
+// before its value is decoded with ReadRecord, and without a value with
+// WithKeysOnly, so it should not look at Value then. With WithRaw, the
+// opcodes preceding a dropped key are kept in the Raw of the next entry
+// returned, or in Reader.Trailer.
+func WithFilter(keep func(e *Entry) bool) Option {
+	return func(r *Reader) {
+		r.filter = keep
+	}
+}
+
+// WithFilterExpr is WithFilter with a predicate compiled by ParseFilter.
+// NewReader fails with the error of ParseFilter if expr is invalid.
+func WithFilterExpr(expr string, now time.Time) Option {
+	return func(r *Reader) {
+		keep, err := ParseFilter(expr, now)
+		if err != nil {
+			r.optErr = err
+			return
+		}
+		r.filter = keep
+	}
+}
+
+type filterTokenKind int
+
+const (
+	tokEnd filterTokenKind = iota
+	tokWord
+	tokString // quoted
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	off  int
+}
+
+// lexFilter splits expr into tokens.
+func lexFilter(expr string) ([]filterToken, error) {
+	var toks []filterToken
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			kind := tokLParen
+			if c == ')' {
+				kind = tokRParen
+			}
+			toks = append(toks, filterToken{kind, string(c), i})
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(expr) && expr[j] != '"' {
+				if expr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("rdb: filter: unterminated string at offset %d", i)
+			}
+			s, err := strconv.Unquote(expr[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("rdb: filter: bad string at offset %d: %v", i, err)
+			}
+			toks = append(toks, filterToken{tokString, s, i})
+			i = j + 1
+		case strings.IndexByte("=!<>~", c) >= 0:
+			op := string(c)
+			if i+1 < len(expr) && (expr[i+1] == '=' || c == '!' && expr[i+1] == '~') {
+				op = expr[i : i+2]
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("rdb: filter: unexpected \"!\" at offset %d", i)
+			}
+			toks = append(toks, filterToken{tokOp, op, i})
+			i += len(op)
+		default:
+			j := i
+			for j < len(expr) && strings.IndexByte(" \t\n\r()\"=!<>~", expr[j]) < 0 {
+				j++
+			}
+			toks = append(toks, filterToken{tokWord, expr[i:j], i})
+			i = j
+		}
+	}
+	return append(toks, filterToken{tokEnd, "end of expression", len(expr)}), nil
+}
+
+type filterParser struct {
+	toks []filterToken
+	pos  int
+	now  time.Time
+	cfg  EncodingConfig
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.toks[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.toks[p.pos]
+	if t.kind != tokEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) errorf(t filterToken, format string, args ...interface{}) error {
+	return fmt.Errorf("rdb: filter: %s at offset %d", fmt.Sprintf(format, args...), t.off)
+}
+
+// keyword reports whether the next token is the keyword kw, and consumes
+// it if so.
+func (p *filterParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == tokWord && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) or() (func(*Entry) bool, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *Entry) bool { return l(e) || right(e) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (func(*Entry) bool, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *Entry) bool { return l(e) && right(e) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) not() (func(*Entry) bool, error) {
+	if p.keyword("not") {
+		m, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Entry) bool { return !m(e) }, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		m, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, p.errorf(t, "expected \")\", found %q", t.text)
+		}
+		return m, nil
+	}
+	return p.comparison()
+}
+
+func (p *filterParser) comparison() (func(*Entry) bool, error) {
+	field := p.next()
+	if field.kind != tokWord {
+		return nil, p.errorf(field, "expected a field, found %q", field.text)
+	}
+	op := p.next()
+	if op.kind != tokOp {
+		return nil, p.errorf(op, "expected an operator after %s, found %q", field.text, op.text)
+	}
+	val := p.next()
+	if val.kind != tokWord && val.kind != tokString {
+		return nil, p.errorf(val, "expected a value after %s%s, found %q", field.text, op.text, val.text)
+	}
+
+	switch name := strings.ToLower(field.text); name {
+	case "key":
+		switch op.text {
+		case "=", "!=":
+			return negate(op.text == "!=", func(e *Entry) bool { return string(e.Key) == val.text }), nil
+		case "~", "!~":
+			return negate(op.text == "!~", func(e *Entry) bool { return MatchGlob(val.text, e.Key) }), nil
+		}
+		return nil, p.errorf(op, "key does not support %s", op.text)
+	case "type", "encoding":
+		if op.text != "=" && op.text != "!=" {
+			return nil, p.errorf(op, "%s does not support %s", name, op.text)
+		}
+		want := strings.ToLower(val.text)
+		get := func(e *Entry) string { return e.Type().String() }
+		if name == "encoding" {
+			get = func(e *Entry) string { return e.Encoding().String() }
+		}
+		if !validName(name, want) {
+			return nil, p.errorf(val, "unknown %s %q", name, val.text)
+		}
+		return negate(op.text == "!=", func(e *Entry) bool { return get(e) == want }), nil
+	}
+
+	var get func(e *Entry) (float64, bool)
+	var parse func(s string) (float64, error)
+	switch strings.ToLower(field.text) {
+	case "db":
+		get = func(e *Entry) (float64, bool) { return float64(e.DB), true }
+	case "ttl":
+		now := p.now.UnixMilli()
+		get = func(e *Entry) (float64, bool) {
+			if !e.HasExpiry() {
+				return math.Inf(1), true
+			}
+			return float64(e.ExpiryAt-now) / 1000, true
+		}
+		parse = parseFilterDuration
+	case "size":
+		get = func(e *Entry) (float64, bool) {
+			_, n := EstimateMemory(e, p.cfg)
+			return float64(n), true
+		}
+		parse = parseFilterSize
+	case "len":
+		get = func(e *Entry) (float64, bool) { return float64(valueLength(e.Value)), true }
+	case "idle":
+		get = func(e *Entry) (float64, bool) { return float64(e.Idle), e.Access == AccessLRU }
+		parse = parseFilterDuration
+	case "freq":
+		get = func(e *Entry) (float64, bool) { return float64(e.Freq), e.Access == AccessLFU }
+	default:
+		return nil, p.errorf(field, "unknown field %q", field.text)
+	}
+	if parse == nil {
+		parse = func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
+	}
+	want, err := parse(val.text)
+	if err != nil {
+		return nil, p.errorf(val, "bad value %q for %s", val.text, field.text)
+	}
+	var cmp func(a float64) bool
+	switch op.text {
+	case "=":
+		cmp = func(a float64) bool { return a == want }
+	case "!=":
+		cmp = func(a float64) bool { return a != want }
+	case "<":
+		cmp = func(a float64) bool { return a < want }
+	case "<=":
+		cmp = func(a float64) bool { return a <= want }
+	case ">":
+		cmp = func(a float64) bool { return a > want }
+	case ">=":
+		cmp = func(a float64) bool { return a >= want }
+	default:
+		return nil, p.errorf(op, "%s does not support %s", field.text, op.text)
+	}
+	return func(e *Entry) bool {
+		v, ok := get(e)
+		return ok && cmp(v)
+	}, nil
+}
+
+func negate(neg bool, m func(*Entry) bool) func(*Entry) bool {
+	if neg {
+		return func(e *Entry) bool { return !m(e) }
+	}
+	return m
+}
+
+// validName reports whether s names a type or an encoding.
+func validName(field, s string) bool {
+	names := typeNames[:]
+	if field == "encoding" {
+		names = encodingNames[:]
+	}
+	for _, n := range names {
+		if n == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFilterDuration parses a duration into seconds.
+func parseFilterDuration(s string) (float64, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		return n * 86400, err
+	}
+	d, err := time.ParseDuration(s)
+	return d.Seconds(), err
+}
+
+// parseFilterSize parses a size into bytes.
+func parseFilterSize(s string) (float64, error) {
+	lower := strings.ToLower(s)
+	mult := 1.0
+	for i, unit := range []string{"kb", "mb", "gb", "tb"} {
+		if n, ok := strings.CutSuffix(lower, unit); ok {
+			lower, mult = n, math.Pow(1024, float64(i+1))
+			break
+		}
+	}
+	if mult == 1 {
+		lower = strings.TrimSuffix(lower, "b")
+	}
+	n, err := strconv.ParseFloat(lower, 64)
+	return n * mult, err
+}
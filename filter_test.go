@@ -0,0 +1,219 @@
+package rdb_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+var filterNow = time.Unix(1700000000, 0)
+
+// filterEntries returns entries covering the fields of filter
+// expressions: expiry in the future and in the past, LRU and LFU hints,
+// two databases and a key that needs quoting.
+func filterEntries() []*rdb.Entry {
+	elems := make([]rdb.RedisString, 1000)
+	for i := range elems {
+		elems[i] = rdb.RedisString(fmt.Sprint("element ", i))
+	}
+	return []*rdb.Entry{
+		{Key: rdb.RedisString("session:1"), ValueType: rdb.String, Value: rdb.StringValue("abc"),
+			ExpiryAt: filterNow.Add(30 * time.Minute).UnixMilli()},
+		{DB: 1, Key: rdb.RedisString("user:1"), ValueType: rdb.HashListPack,
+			Value:  rdb.HashValue{Fields: fields("name", "ann", "age", "31"), Enc: rdb.EncodingListpack},
+			Access: rdb.AccessLRU, Idle: 7200},
+		{Key: rdb.RedisString("big"), ValueType: rdb.ListQuickList2,
+			Value:    rdb.ListValue{Elements: elems, Enc: rdb.EncodingQuicklist},
+			ExpiryAt: filterNow.Add(-time.Second).UnixMilli(), Access: rdb.AccessLFU, Freq: 5},
+		{Key: rdb.RedisString(`we"ird key`), ValueType: rdb.String, Value: rdb.StringValue("x")},
+	}
+}
+
+// matching returns the keys of the entries expr selects.
+func matching(t *testing.T, expr string, es []*rdb.Entry) string {
+	t.Helper()
+	keep, err := rdb.ParseFilter(expr, filterNow)
+	if err != nil {
+		t.Fatalf("%s: %v", expr, err)
+	}
+	var kept []*rdb.Entry
+	for _, e := range es {
+		if keep(e) {
+			kept = append(kept, e)
+		}
+	}
+	return entryKeys(kept)
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"type=hash", "user:1"},
+		{"type=string and ttl<1h", "session:1"},
+		{"encoding=listpack", "user:1"},
+		{"TYPE=Hash AND Db=1", "user:1"},
+
+		// and binds tighter than or.
+		{"type=list or type=hash and db=0", "big"},
+		{"(type=list or type=hash) and db=1", "user:1"},
+		{"db=1 or type=string and len=1", "user:1,we\"ird key"},
+		{"(db=1 or type=string) and len=1", "we\"ird key"},
+
+		{"not type=string", "user:1,big"},
+		{"not not type=hash", "user:1"},
+		{"not (type=string or type=list)", "user:1"},
+		{"not type=string and db=1", "user:1"},
+		{"not type=string or db=1", "user:1,big"},
+
+		{`key="we\"ird key"`, `we"ird key`},
+		{`key="session:1"`, "session:1"},
+		{"key=session:1", "session:1"},
+		{`key~"session:*"`, "session:1"},
+		{`key!~"*:*"`, `big,we"ird key`},
+		{"key!=big", `session:1,user:1,we"ird key`},
+		{`key~"*\"*"`, `we"ird key`},
+
+		{"ttl>29m and ttl<31m", "session:1"},
+		{"ttl>1799s and ttl<=1800s", "session:1"},
+		{"ttl>0.02d and ttl<0.021d", "session:1"},
+		{"ttl<0", "big"},
+		// Keys without an expiry have an infinite ttl.
+		{"ttl>36500d", `user:1,we"ird key`},
+		{"ttl<36500d", "session:1,big"},
+
+		// idle and freq only match keys saved with them.
+		{"idle>=2h", "user:1"},
+		{"idle<1h", ""},
+		{"not idle<1h", `session:1,user:1,big,we"ird key`},
+		{"freq=5", "big"},
+		{"freq>=0", "big"},
+
+		{"len=1000", "big"},
+		{"len=3", "session:1"},
+		{"len=2", "user:1"},
+		{"len>=2 and len<=3", "session:1,user:1"},
+	}
+	es := filterEntries()
+	for _, tt := range tests {
+		if got := matching(t, tt.expr, es); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterSizeUnits(t *testing.T) {
+	es := filterEntries()
+	_, n := rdb.EstimateMemory(es[0], rdb.DefaultEncodingConfig())
+	units := []struct {
+		unit string
+		pow  float64
+	}{{"", 0}, {"b", 0}, {"kb", 1}, {"mb", 2}, {"gb", 3}, {"tb", 4}, {"KB", 1}, {"Mb", 2}}
+	for _, u := range units {
+		expr := fmt.Sprintf("size=%v%s", float64(n)/math.Pow(1024, u.pow), u.unit)
+		if got := matching(t, expr, es); got != "session:1" {
+			t.Errorf("%s: got %q, want session:1", expr, got)
+		}
+	}
+	if got := matching(t, "size>1kb", es); got != "big" {
+		t.Errorf("size>1kb: got %q, want big", got)
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		msg  string
+		off  int
+	}{
+		{"type=", "expected a value", 5},
+		{"type==hash", "does not support ==", 4},
+		{"key<a", "key does not support <", 3},
+		{"foo=1", "unknown field", 0},
+		{"type=blob", "unknown type", 5},
+		{"encoding=blob", "unknown encoding", 9},
+		{"type=hash and", "expected a field", 13},
+		{"type hash", "expected an operator", 5},
+		{"(type=hash", `expected ")"`, 10},
+		{"type=hash)", `unexpected ")"`, 9},
+		{`key="abc`, "unterminated string", 4},
+		{`key="\q"`, "bad string", 4},
+		{"a ! b", `unexpected "!"`, 2},
+		{"size>1zb", "bad value", 5},
+		{"ttl>soon", "bad value", 4},
+		{"db=one", "bad value", 3},
+	}
+	for _, tt := range tests {
+		_, err := rdb.ParseFilter(tt.expr, filterNow)
+		if err == nil {
+			t.Errorf("%s: no error", tt.expr)
+			continue
+		}
+		if msg := err.Error(); !strings.Contains(msg, tt.msg) || !strings.Contains(msg, fmt.Sprintf("at offset %d", tt.off)) {
+			t.Errorf("%s: got %q, want %q at offset %d", tt.expr, msg, tt.msg, tt.off)
+		}
+	}
+}
+
+func TestWithFilterExpr(t *testing.T) {
+	es := filterEntries()
+	// other follows user:1 in database 1, so the database selection
+	// before user:1 is all that puts it there.
+	es = append(es[:2:2], append([]*rdb.Entry{{DB: 1, Key: rdb.RedisString("other"), ValueType: rdb.String,
+		Value: rdb.StringValue("y")}}, es[2:]...)...)
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range es {
+		w.WriteEntry(e)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dump := buf.Bytes()
+
+	got := rdbtest.LoadBytes(t, dump, rdb.WithFilterExpr("db=1 or ttl<0", filterNow))
+	if keys := entryKeys(got); keys != "user:1,other,big" {
+		t.Errorf("got %s, want user:1,other,big", keys)
+	}
+
+	// The entries kept, with the opcodes before those dropped, still
+	// make up the dump.
+	got = rdbtest.LoadBytes(t, dump, rdb.WithRaw(), rdb.WithFilterExpr("key=other", filterNow))
+	var out bytes.Buffer
+	w, err = rdb.NewWriter(&out, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range got {
+		w.WriteEntry(e)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got = rdbtest.LoadBytes(t, out.Bytes())
+	if len(got) != 1 || got[0].DB != 1 || string(got[0].Key) != "other" {
+		t.Errorf("rewritten: got %v, want other in database 1", got)
+	}
+
+	if _, err := rdb.NewReader(bytes.NewReader(dump), rdb.WithFilterExpr("type=", filterNow)); err == nil {
+		t.Error("NewReader accepted an invalid expression")
+	}
+}
+
+func entryKeys(es []*rdb.Entry) string {
+	var keys []string
+	for _, e := range es {
+		keys = append(keys, string(e.Key))
+	}
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,84 @@
+package rdb
+
+// MatchGlob reports whether s matches the glob-style pattern the way Redis'
+// KEYS, SCAN MATCH and HSCAN MATCH do: '*' matches any run of bytes, '?'
+// any single byte, "[abc]", "[^abc]" and "[a-z]" a byte in or out of a
+// set, and '\' escapes the next byte. Matching is byte-wise and
+// case-sensitive.
+func MatchGlob(pattern string, s []byte) bool {
+	p := 0
+	for p < len(pattern) {
+		switch pattern[p] {
+		case '*':
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p++
+			}
+			if p+1 == len(pattern) {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if MatchGlob(pattern[p+1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			p++
+			not := p < len(pattern) && pattern[p] == '^'
+			if not {
+				p++
+			}
+			match := false
+			for p < len(pattern) && pattern[p] != ']' {
+				switch {
+				case pattern[p] == '\\' && p+1 < len(pattern):
+					p++
+					if pattern[p] == s[0] {
+						match = true
+					}
+				case p+2 < len(pattern) && pattern[p+1] == '-':
+					lo, hi := pattern[p], pattern[p+2]
+					if lo > hi {
+						lo, hi = hi, lo
+					}
+					if s[0] >= lo && s[0] <= hi {
+						match = true
+					}
+					p += 2
+				default:
+					if pattern[p] == s[0] {
+						match = true
+					}
+				}
+				p++
+			}
+			if p == len(pattern) {
+				p-- // unterminated set: Redis treats the end as ']'
+			}
+			if match == not {
+				return false
+			}
+			s = s[1:]
+		case '\\':
+			if p+1 < len(pattern) {
+				p++
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || pattern[p] != s[0] {
+				return false
+			}
+			s = s[1:]
+		}
+		p++
+	}
+	return len(s) == 0
+}
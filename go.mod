@@ -0,0 +1,3 @@
+module github.com/areian/go-redis-rdb
+
+go 1.23
@@ -0,0 +1,189 @@
+package rdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// GrowthTrend follows the key count and memory of every key prefix over a
+// series of snapshots, the raw material for capacity forecasts. Feed it
+// the dumps in time order, either with ReadDump or by calling Snapshot and
+// then Add for every entry of the snapshot; then call Report.
+type GrowthTrend struct {
+	Config EncodingConfig
+
+	// Prefix groups keys in the report. If nil, keys are grouped by the
+	// part before the first ':'.
+	Prefix func(key []byte) string
+
+	times    []time.Time
+	prefixes map[string]*GrowthSeries
+}
+
+// GrowthReport is the result of GrowthTrend: a series per prefix.
+type GrowthReport []GrowthSeries
+
+// GrowthSeries is the growth of one prefix, with a point per snapshot.
+type GrowthSeries struct {
+	Prefix string
+	Points []GrowthPoint
+}
+
+// GrowthPoint counts the keys of a prefix in one snapshot.
+type GrowthPoint struct {
+	Time   time.Time
+	Keys   uint64
+	Memory uint64 // estimated bytes
+}
+
+// NewGrowthTrend returns a GrowthTrend estimating memory with cfg.
+func NewGrowthTrend(cfg EncodingConfig) *GrowthTrend {
+	return &GrowthTrend{Config: cfg}
+}
+
+// Snapshot starts a snapshot taken at t; the entries added next belong to
+// it. Snapshots must come in time order.
+func (g *GrowthTrend) Snapshot(t time.Time) error {
+	if n := len(g.times); n > 0 && t.Before(g.times[n-1]) {
+		return fmt.Errorf("rdb: snapshot of %v is older than the previous one", t)
+	}
+	g.times = append(g.times, t)
+	return nil
+}
+
+// Add accounts for a single entry of the current snapshot.
+func (g *GrowthTrend) Add(e *Entry) {
+	if len(g.times) == 0 {
+		g.times = append(g.times, time.Time{})
+	}
+	var prefix string
+	if g.Prefix != nil {
+		prefix = g.Prefix(e.Key)
+	} else {
+		prefix = KeyPrefix(e.Key, ':', 1)
+	}
+	if g.prefixes == nil {
+		g.prefixes = make(map[string]*GrowthSeries)
+	}
+	s := g.prefixes[prefix]
+	if s == nil {
+		s = &GrowthSeries{Prefix: prefix}
+		g.prefixes[prefix] = s
+	}
+	for len(s.Points) < len(g.times) {
+		s.Points = append(s.Points, GrowthPoint{Time: g.times[len(s.Points)]})
+	}
+	_, m := EstimateMemory(e, g.Config)
+	p := &s.Points[len(s.Points)-1]
+	p.Keys++
+	p.Memory += m
+}
+
+// ReadDump adds every entry of src as a snapshot taken when the dump was
+// saved, according to its ctime auxiliary field.
+func (g *GrowthTrend) ReadDump(src *Reader) error {
+	e, err := src.ReadEntry()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	created, ok := src.Created()
+	if !ok {
+		return errors.New("rdb: dump without a creation time")
+	}
+	if err := g.Snapshot(created); err != nil {
+		return err
+	}
+	for err == nil {
+		g.Add(e)
+		e, err = src.ReadEntry()
+	}
+	if err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// Report returns the series of every prefix, with a point for every
+// snapshot, largest in the last snapshot first.
+func (g *GrowthTrend) Report() GrowthReport {
+	out := make(GrowthReport, 0, len(g.prefixes))
+	for _, s := range g.prefixes {
+		points := make([]GrowthPoint, len(g.times))
+		copy(points, s.Points)
+		for i := len(s.Points); i < len(points); i++ {
+			points[i].Time = g.times[i]
+		}
+		out = append(out, GrowthSeries{Prefix: s.Prefix, Points: points})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].last(), out[j].last()
+		if a.Memory != b.Memory {
+			return a.Memory > b.Memory
+		}
+		return out[i].Prefix < out[j].Prefix
+	})
+	return out
+}
+
+func (s *GrowthSeries) last() GrowthPoint {
+	if len(s.Points) == 0 {
+		return GrowthPoint{}
+	}
+	return s.Points[len(s.Points)-1]
+}
+
+// Growth returns the trend of the series as the slopes of the least
+// squares lines through its points: keys and bytes gained per day. They
+// are 0 if the series spans no time.
+func (s *GrowthSeries) Growth() (keysPerDay, bytesPerDay float64) {
+	n := float64(len(s.Points))
+	if n < 2 {
+		return 0, 0
+	}
+	t0 := s.Points[0].Time
+	var sx, sxx, sk, sm, sxk, sxm float64
+	for _, p := range s.Points {
+		x := p.Time.Sub(t0).Hours() / 24
+		sx += x
+		sxx += x * x
+		sk += float64(p.Keys)
+		sm += float64(p.Memory)
+		sxk += x * float64(p.Keys)
+		sxm += x * float64(p.Memory)
+	}
+	d := n*sxx - sx*sx
+	if d == 0 {
+		return 0, 0
+	}
+	return (n*sxk - sx*sk) / d, (n*sxm - sx*sm) / d
+}
+
+// Tables implements Tabular, with the first and last points of every
+// prefix and its growth per day.
+func (r GrowthReport) Tables() []Table {
+	t := Table{Title: "Growth", Columns: []string{"Prefix", "Keys", "Memory", "Keys/day", "Memory/day"}}
+	for i := range r {
+		s := &r[i]
+		first, last := GrowthPoint{}, s.last()
+		if len(s.Points) > 0 {
+			first = s.Points[0]
+		}
+		keys, bytes := s.Growth()
+		memPerDay := FormatBytes(uint64(max(bytes, -bytes)))
+		if bytes < 0 {
+			memPerDay = "-" + memPerDay
+		}
+		t.Rows = append(t.Rows, []string{
+			formatKey([]byte(s.Prefix)),
+			formatCount(first.Keys) + " → " + formatCount(last.Keys),
+			FormatBytes(first.Memory) + " → " + FormatBytes(last.Memory),
+			strconv.FormatFloat(keys, 'f', 1, 64),
+			memPerDay,
+		})
+	}
+	return []Table{t}
+}
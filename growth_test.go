@@ -0,0 +1,110 @@
+package rdb_test
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestGrowthTrend(t *testing.T) {
+	cfg := rdb.DefaultEncodingConfig()
+	day := 24 * time.Hour
+	t0 := time.Unix(1700000000, 0)
+	g := rdb.NewGrowthTrend(cfg)
+	// user gains a key a day; cache only exists in the first snapshot.
+	var perKey uint64
+	for i := 0; i < 3; i++ {
+		if err := g.Snapshot(t0.Add(time.Duration(i) * day)); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j <= i; j++ {
+			e := str(0, "user:"+strconv.Itoa(j), "v", 0)
+			_, perKey = rdb.EstimateMemory(e, cfg)
+			g.Add(e)
+		}
+		if i == 0 {
+			g.Add(str(0, "cache:1", "v", 0))
+		}
+	}
+	if err := g.Snapshot(t0); err == nil {
+		t.Error("an older snapshot was accepted")
+	}
+
+	rep := g.Report()
+	if len(rep) != 2 || rep[0].Prefix != "user" || rep[1].Prefix != "cache" {
+		t.Fatalf("got %+v, want user then cache", rep)
+	}
+	for i, p := range rep[0].Points {
+		if !p.Time.Equal(t0.Add(time.Duration(i)*day)) || p.Keys != uint64(i+1) {
+			t.Errorf("user point %d: %+v", i, p)
+		}
+	}
+	keys, mem := rep[0].Growth()
+	if math.Abs(keys-1) > 1e-9 || math.Abs(mem-float64(perKey)) > 1e-6 {
+		t.Errorf("user grows by %v keys, %v bytes a day, want 1, %d", keys, mem, perKey)
+	}
+	cache := rep[1].Points
+	if len(cache) != 3 || cache[0].Keys != 1 || cache[1].Keys != 0 || cache[2].Keys != 0 || !cache[2].Time.Equal(t0.Add(2*day)) {
+		t.Errorf("cache points %+v", cache)
+	}
+	if keys, _ := rep[1].Growth(); math.Abs(keys+0.5) > 1e-9 {
+		t.Errorf("cache grows by %v keys a day, want -0.5", keys)
+	}
+
+	single := rdb.GrowthSeries{Points: []rdb.GrowthPoint{{Time: t0, Keys: 5}}}
+	if k, m := single.Growth(); k != 0 || m != 0 {
+		t.Errorf("single point grows by %v, %v", k, m)
+	}
+	same := rdb.GrowthSeries{Points: []rdb.GrowthPoint{{Time: t0, Keys: 1}, {Time: t0, Keys: 9}}}
+	if k, m := same.Growth(); k != 0 || m != 0 {
+		t.Errorf("points at the same time grow by %v, %v", k, m)
+	}
+}
+
+func TestGrowthTrendReadDump(t *testing.T) {
+	dump := func(ctime int64, keys ...string) *rdb.Reader {
+		var buf bytes.Buffer
+		w, err := rdb.NewWriter(&buf, 11)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctime != 0 {
+			w.WriteAux([]byte("ctime"), []byte(strconv.FormatInt(ctime, 10)))
+		}
+		for _, k := range keys {
+			w.WriteEntry(str(0, k, "v", 0))
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		r, err := rdb.NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { r.Close() })
+		return r
+	}
+	g := rdb.NewGrowthTrend(rdb.DefaultEncodingConfig())
+	for i, keys := range [][]string{{"a:1"}, {}, {"a:1", "a:2", "b:1"}} {
+		if err := g.ReadDump(dump(1700000000+int64(i)*86400, keys...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rep := g.Report()
+	if len(rep) != 2 || rep[0].Prefix != "a" || len(rep[0].Points) != 3 {
+		t.Fatalf("got %+v", rep)
+	}
+	if p := rep[0].Points; p[0].Keys != 1 || p[1].Keys != 0 || p[2].Keys != 2 || p[1].Time.Unix() != 1700086400 {
+		t.Errorf("got points %+v", p)
+	}
+	if err := g.ReadDump(dump(0, "a:1")); err == nil {
+		t.Error("a dump without ctime was accepted")
+	}
+	if err := g.ReadDump(dump(1600000000, "a:1")); err == nil {
+		t.Error("an older dump was accepted")
+	}
+}
@@ -0,0 +1,192 @@
+package rdb
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// WithHashFields makes the Reader keep only the hash fields for which
+// match returns true. Values of other fields are stepped over without
+// being decoded or, for compact encodings, copied, which keeps memory flat
+// when only a few fields of very large hashes matter. Hashes left without
+// fields are still returned.
+func WithHashFields(match func(field []byte) bool) Option {
+	return func(r *Reader) {
+		r.hashFields = match
+	}
+}
+
+// FieldsIn returns a WithHashFields filter keeping the named fields.
+func FieldsIn(fields ...string) func(field []byte) bool {
+	set := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		set[f] = true
+	}
+	return func(field []byte) bool {
+		return set[string(field)]
+	}
+}
+
+// FieldsMatching returns a WithHashFields filter keeping the fields that
+// match a glob-style pattern, see MatchGlob.
+func FieldsMatching(pattern string) func(field []byte) bool {
+	return func(field []byte) bool {
+		return MatchGlob(pattern, field)
+	}
+}
+
+// hashNoExpiry is the expiry time that stands for none in HashMetadataPreGA
+// values, Redis' EB_EXPIRE_TIME_INVALID.
+const hashNoExpiry = 1 << 48
+
+// readHash reads a hash of type t: field/value pairs, preceded by the
+// expiry time of each field for HashMetadata, or a zipmap, ziplist or
+// listpack blob of alternating fields and values, and expiry times for
+// HashListPackEx. HashMetadata and HashListPackEx start with the earliest
+// expiry time of the fields.
+func (r *Reader) readHash(t ValueType) ([]HashField, error) {
+	var minExpire int64
+	if t == HashMetadata || t == HashListPackEx {
+		var err error
+		if minExpire, err = r.readMillis(); err != nil {
+			return nil, err
+		}
+	}
+	if t == Hash || t == HashMetadata || t == HashMetadataPreGA {
+		n, err := r.readCount()
+		if err != nil {
+			return nil, err
+		}
+		var h []HashField
+		for i := uint64(0); i < n; i++ {
+			var at int64
+			switch t {
+			case HashMetadata:
+				// Stored relative to minExpire, plus one so that 0 can
+				// stand for none.
+				ttl, err := r.readLength()
+				if err != nil {
+					return nil, err
+				}
+				if ttl != 0 {
+					at = minExpire + int64(ttl) - 1
+				}
+			case HashMetadataPreGA:
+				if at, err = r.readMillis(); err != nil {
+					return nil, err
+				}
+				if at >= hashNoExpiry {
+					at = 0
+				}
+			}
+			f, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			if r.hashFields != nil && !r.hashFields(f) {
+				if err := r.strings.Skip(r.in); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			v, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			h = append(h, HashField{Field: f, Value: v, ExpiryAt: at})
+		}
+		return h, nil
+	}
+	blob, err := r.readString()
+	if err != nil {
+		return nil, err
+	}
+	walk := codec.WalkListpack
+	switch t {
+	case HashZipmap:
+		walk = walkZipmap
+	case HashZipList:
+		walk = codec.WalkZiplist
+	}
+	per := 2
+	if t == HashListPackEx || t == HashListPackExPreGA {
+		per = 3
+	}
+	// Fields are filtered as the blob is walked, so that only the values
+	// kept are formatted or copied.
+	var h []HashField
+	var f HashField
+	var keep bool
+	var n int
+	var ferr error
+	err = walk(blob, func(s []byte, v int64, isInt bool) {
+		if ferr != nil {
+			return
+		}
+		pos := n % per
+		n++
+		if pos == 0 {
+			f = HashField{}
+			if isInt {
+				s = strconv.AppendInt(nil, v, 10)
+			}
+			keep = r.hashFields == nil || r.hashFields(s)
+			if ferr = codec.CheckLength("collection", uint64(n/per+1), r.maxElements); ferr != nil || !keep {
+				return
+			}
+			f.Field = s
+			if r.hashFields != nil && !isInt {
+				// Copy, so that the blob is not kept alive.
+				f.Field = append(RedisString(nil), s...)
+			}
+			return
+		}
+		if !keep {
+			return
+		}
+		switch {
+		case pos == 1 && isInt:
+			f.Value = strconv.AppendInt(nil, v, 10)
+		case pos == 1 && r.hashFields != nil:
+			f.Value = append(RedisString(nil), s...)
+		case pos == 1:
+			f.Value = s
+		case isInt:
+			// 0 stands for no expiry.
+			f.ExpiryAt = v
+		default:
+			if f.ExpiryAt, ferr = strconv.ParseInt(string(s), 10, 64); ferr != nil {
+				ferr = fmt.Errorf("%w: hash field expiry %q is not an integer", ErrFormat, s)
+			}
+		}
+		if pos == per-1 {
+			h = append(h, f)
+		}
+	})
+	if err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n%per != 0 {
+		return nil, fmt.Errorf("%w: hash of %d elements, not a multiple of %d", ErrFormat, n, per)
+	}
+	if h == nil {
+		h = []HashField{}
+	}
+	return h, nil
+}
+
+// walkZipmap calls fn for the fields and values of the zipmap zm, in the
+// manner of codec.WalkListpack. Zipmaps hold few fields, so it decodes them
+// first.
+func walkZipmap(zm []byte, fn func(s []byte, v int64, isInt bool)) error {
+	pairs, err := codec.DecodeZipmap(zm)
+	for _, s := range pairs {
+		fn(s, 0, false)
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package rdb_test
+
+import (
+	"reflect"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+// blobDump returns a version 12 dump holding key "h" of type t with the
+// blob as its value.
+func blobDump(t rdb.ValueType, prefix, blob []byte) []byte {
+	b := append([]byte("REDIS0012"), byte(t))
+	b = codec.AppendString(b, []byte("h"), false)
+	b = append(b, prefix...)
+	b = codec.AppendString(b, blob, false)
+	return append(b, 0xff, 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+func TestWithHashFields(t *testing.T) {
+	pairs := [][]byte{[]byte("a"), []byte("1"), []byte("b"), []byte("two"), []byte("7"), []byte("c")}
+	ttl := [][]byte{[]byte("a"), []byte("1"), []byte("0"), []byte("b"), []byte("two"), []byte("1700000000000"), []byte("7"), []byte("c"), []byte("0")}
+	tests := []struct {
+		name string
+		dump []byte
+		want []rdb.HashField
+	}{
+		{"zipmap", blobDump(rdb.HashZipmap, nil, codec.EncodeZipmap(pairs)), nil},
+		{"ziplist", blobDump(rdb.HashZipList, nil, codec.EncodeZiplist(pairs)), nil},
+		{"listpack", blobDump(rdb.HashListPack, nil, codec.EncodeListpack(pairs)), nil},
+		{"listpackex", blobDump(rdb.HashListPackEx, make([]byte, 8), codec.EncodeListpack(ttl)), []rdb.HashField{
+			{Field: rdb.RedisString("b"), Value: rdb.RedisString("two"), ExpiryAt: 1700000000000},
+			{Field: rdb.RedisString("7"), Value: rdb.RedisString("c")},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == nil {
+				want = []rdb.HashField{
+					{Field: rdb.RedisString("b"), Value: rdb.RedisString("two")},
+					{Field: rdb.RedisString("7"), Value: rdb.RedisString("c")},
+				}
+			}
+			es := rdbtest.LoadBytes(t, tt.dump, rdb.WithHashFields(rdb.FieldsIn("b", "7")))
+			if len(es) != 1 || !reflect.DeepEqual(es[0].Value.(rdb.HashValue).Fields, want) {
+				t.Errorf("got %v, want %v", es, want)
+			}
+			es = rdbtest.LoadBytes(t, tt.dump, rdb.WithHashFields(rdb.FieldsIn("none")))
+			if len(es) != 1 || len(es[0].Value.(rdb.HashValue).Fields) != 0 {
+				t.Errorf("got %v, want an empty hash", es)
+			}
+		})
+	}
+}
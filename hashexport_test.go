@@ -0,0 +1,52 @@
+package rdb_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestHashCSVExporter(t *testing.T) {
+	var buf bytes.Buffer
+	x, err := rdb.NewHashCSVExporter(&buf, []string{"name", "age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.Escape = rdb.EscapeQuote
+	for _, e := range []*rdb.Entry{
+		{Key: rdb.RedisString("user:1"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields("age", "42", "name", "Ann", "extra", "dropped")}},
+		{DB: 3, Key: rdb.RedisString("user:2"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields("name", "Bob, \"Jr\"\n")}},
+		{Key: rdb.RedisString("user:\xff"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields("name", "\x00")}},
+		str(0, "not a hash", "v", 0),
+	} {
+		if err := x.WriteEntry(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := x.End(); err != nil {
+		t.Fatal(err)
+	}
+	want := "db,key,name,age\n" +
+		"0,user:1,Ann,42\n" +
+		"3,user:2,\"Bob, \"\"Jr\"\"\\x0a\",\n" +
+		"0,user:\\xff,\\x00,\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// Raw values, separators and quotes included, survive a CSV reader.
+	buf.Reset()
+	x, _ = rdb.NewHashCSVExporter(&buf, []string{"v"})
+	x.Add(&rdb.Entry{Key: rdb.RedisString("k,\"1\""), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields("v", "a\nb,c")}})
+	x.Close()
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"db", "key", "v"}, {"0", "k,\"1\"", "a\nb,c"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %q, want %q", rows, want)
+	}
+}
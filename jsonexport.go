@@ -0,0 +1,344 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// JSONField selects optional members of the exported JSON objects. The key
+// and, unless omitted, the value are always written.
+type JSONField uint
+
+const (
+	JSONDB       JSONField = 1 << iota // "db": database number
+	JSONType                           // "type": logical type
+	JSONEncoding                       // "encoding": encoding in the dump
+	JSONExpiry                         // "expire_at": expiry in ms since the epoch, if any
+	JSONTTL                            // "ttl": seconds left at JSONOptions.Now, if any
+	JSONSize                           // "length" and "memory": element count and estimated bytes
+	JSONAccess                         // "idle" or "freq": LRU idle seconds or LFU counter, if any
+
+	// JSONAllFields selects every optional member.
+	JSONAllFields = JSONDB | JSONType | JSONEncoding | JSONExpiry | JSONTTL | JSONSize | JSONAccess
+)
+
+// FloatFormat selects how exporters write floating point numbers, such as
+// sorted set scores.
+type FloatFormat uint8
+
+const (
+	// FloatNumber writes the shortest number that parses back to the same
+	// value, and the strings "inf", "-inf" and "nan" for values JSON
+	// cannot represent.
+	FloatNumber FloatFormat = iota
+
+	// FloatString writes a string formatted with "%.17g", as Redis stores
+	// scores in dumps and older versions reply with them, or "inf",
+	// "-inf" or "nan".
+	FloatString
+
+	// FloatBits writes the IEEE 754 bit pattern as a string of 16
+	// hexadecimal digits, keeping every value, NaN payloads included,
+	// exactly.
+	FloatBits
+)
+
+var floatFormatNames = [...]string{"number", "string", "bits"}
+
+func (f FloatFormat) String() string {
+	if int(f) < len(floatFormatNames) {
+		return floatFormatNames[f]
+	}
+	return "FloatFormat(" + strconv.Itoa(int(f)) + ")"
+}
+
+// append appends v to b as a JSON value in format f.
+func (f FloatFormat) append(b []byte, v float64) []byte {
+	if f == FloatBits {
+		b = append(b, '"')
+		b = append(b, fmt.Sprintf("%016x", math.Float64bits(v))...)
+		return append(b, '"')
+	}
+	var s string
+	switch {
+	case math.IsNaN(v):
+		s = "nan"
+	case math.IsInf(v, 1):
+		s = "inf"
+	case math.IsInf(v, -1):
+		s = "-inf"
+	}
+	if s != "" {
+		return append(append(append(b, '"'), s...), '"')
+	}
+	if f == FloatString {
+		b = append(b, '"')
+		b = strconv.AppendFloat(b, v, 'g', 17, 64)
+		return append(b, '"')
+	}
+	return strconv.AppendFloat(b, v, 'g', -1, 64)
+}
+
+// JSONOptions configures a JSONExporter.
+type JSONOptions struct {
+	Fields JSONField
+
+	// KeyEscape and ValueEscape select how keys and values are written.
+	KeyEscape   Escaping
+	ValueEscape Escaping
+
+	// Scores selects how sorted set scores are written.
+	Scores FloatFormat
+
+	// OmitValues leaves values out, for a key inventory.
+	OmitValues bool
+
+	// Flatten writes one object per collection element instead of one per
+	// key: lists get "index" and "value", sets "member", sorted sets
+	// "member" and "score", hashes "field" and "value". Empty collections
+	// produce no rows.
+	Flatten bool
+
+	// Now is the reference time for JSONTTL, such as the time the dump
+	// was saved given by Reader.Created, to report TTLs as they were then.
+	// If it is zero, Begin sets it from the ctime of the dump; without
+	// either, "ttl" is left out.
+	Now time.Time
+
+	// Config is used to estimate memory for JSONSize; the zero value means
+	// DefaultEncodingConfig.
+	Config EncodingConfig
+}
+
+// JSONExporter writes entries as newline-delimited JSON objects, one per
+// key or, with Flatten, one per element.
+type JSONExporter struct {
+	NopSink // SelectDB
+
+	opts JSONOptions
+	out  *transformedOutput
+	w    *bufio.Writer
+	buf  []byte
+}
+
+// NewJSONExporter returns a JSONExporter writing to w through the given
+// transforms.
+func NewJSONExporter(w io.Writer, opts JSONOptions, transforms ...Transform) (*JSONExporter, error) {
+	out, err := wrapOutput(w, transforms)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Config == (EncodingConfig{}) {
+		opts.Config = DefaultEncodingConfig()
+	}
+	return &JSONExporter{opts: opts, out: out, w: bufio.NewWriter(out)}, nil
+}
+
+// Add writes the object or objects for e.
+func (x *JSONExporter) Add(e *Entry) error {
+	if !x.opts.Flatten || x.opts.OmitValues {
+		b := x.header(e)
+		if !x.opts.OmitValues {
+			b = append(b, `,"value":`...)
+			b = x.appendValue(b, e.Value)
+		}
+		return x.line(b)
+	}
+	switch v := e.Value.(type) {
+	case ListValue, SetValue:
+		name := `,"member":`
+		if e.Type() == TypeList {
+			name = `,"value":`
+		}
+		elems, _ := stringsOf(v)
+		for i, s := range elems {
+			b := x.header(e)
+			if e.Type() == TypeList {
+				b = append(b, `,"index":`...)
+				b = strconv.AppendInt(b, int64(i), 10)
+			}
+			b = append(b, name...)
+			b = x.appendString(b, s, x.opts.ValueEscape)
+			if err := x.line(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ZSetValue:
+		for _, m := range v.Members {
+			b := x.header(e)
+			b = append(b, `,"member":`...)
+			b = x.appendString(b, m.Member, x.opts.ValueEscape)
+			b = append(b, `,"score":`...)
+			if err := x.line(x.opts.Scores.append(b, m.Score)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case HashValue:
+		for _, f := range v.Fields {
+			b := x.header(e)
+			b = append(b, `,"field":`...)
+			b = x.appendString(b, f.Field, x.opts.ValueEscape)
+			b = append(b, `,"value":`...)
+			b = x.appendString(b, f.Value, x.opts.ValueEscape)
+			if err := x.line(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	b := x.header(e)
+	b = append(b, `,"value":`...)
+	return x.line(x.appendValue(b, e.Value))
+}
+
+// header starts an object with the key and the selected fields.
+func (x *JSONExporter) header(e *Entry) []byte {
+	b := append(x.buf[:0], `{"key":`...)
+	b = x.appendString(b, e.Key, x.opts.KeyEscape)
+	f := x.opts.Fields
+	if f&JSONDB != 0 {
+		b = append(b, `,"db":`...)
+		b = strconv.AppendUint(b, e.DB, 10)
+	}
+	if f&JSONType != 0 {
+		b = append(b, `,"type":"`...)
+		b = append(b, e.Type().String()...)
+		b = append(b, '"')
+	}
+	if f&JSONEncoding != 0 {
+		b = append(b, `,"encoding":"`...)
+		b = append(b, e.Encoding().String()...)
+		b = append(b, '"')
+	}
+	if f&JSONExpiry != 0 && e.HasExpiry() {
+		b = append(b, `,"expire_at":`...)
+		b = strconv.AppendInt(b, e.ExpiryAt, 10)
+	}
+	if f&JSONTTL != 0 && e.HasExpiry() && !x.opts.Now.IsZero() {
+		b = append(b, `,"ttl":`...)
+		b = strconv.AppendInt(b, int64(e.ExpiryTime().Sub(x.opts.Now)/time.Second), 10)
+	}
+	if f&JSONSize != 0 {
+		b = append(b, `,"length":`...)
+		b = strconv.AppendInt(b, int64(valueLength(e.Value)), 10)
+		_, mem := EstimateMemory(e, x.opts.Config)
+		b = append(b, `,"memory":`...)
+		b = strconv.AppendUint(b, mem, 10)
+	}
+	if f&JSONAccess != 0 {
+		switch e.Access {
+		case AccessLRU:
+			b = append(b, `,"idle":`...)
+			b = strconv.AppendUint(b, e.Idle, 10)
+		case AccessLFU:
+			b = append(b, `,"freq":`...)
+			b = strconv.AppendUint(b, uint64(e.Freq), 10)
+		}
+	}
+	return b
+}
+
+func (x *JSONExporter) appendValue(b []byte, v Value) []byte {
+	esc := x.opts.ValueEscape
+	switch v := v.(type) {
+	case StringValue:
+		return x.appendString(b, v, esc)
+	case ListValue, SetValue:
+		elems, _ := stringsOf(v)
+		b = append(b, '[')
+		for i, s := range elems {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = x.appendString(b, s, esc)
+		}
+		return append(b, ']')
+	case HashValue:
+		b = append(b, '{')
+		for i, f := range v.Fields {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = x.appendString(b, f.Field, esc)
+			b = append(b, ':')
+			b = x.appendString(b, f.Value, esc)
+		}
+		return append(b, '}')
+	case ZSetValue:
+		b = append(b, '{')
+		for i, m := range v.Members {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = x.appendString(b, m.Member, esc)
+			b = append(b, ':')
+			b = x.opts.Scores.append(b, m.Score)
+		}
+		return append(b, '}')
+	}
+	return append(b, "null"...)
+}
+
+func (x *JSONExporter) appendString(b, s []byte, esc Escaping) []byte {
+	q, _ := json.Marshal(esc.Apply(s))
+	return append(b, q...)
+}
+
+func (x *JSONExporter) line(b []byte) error {
+	b = append(b, '}', '\n')
+	x.buf = b
+	_, err := x.w.Write(b)
+	return err
+}
+
+// valueLength returns the number of elements of a collection, or the
+// length of a string.
+func valueLength(v Value) int {
+	switch v := v.(type) {
+	case StringValue:
+		return len(v)
+	case ListValue:
+		return len(v.Elements)
+	case SetValue:
+		return len(v.Members)
+	case HashValue:
+		return len(v.Fields)
+	case ZSetValue:
+		return len(v.Members)
+	case *StreamValue:
+		return int(v.Length)
+	}
+	return 0
+}
+
+// Close flushes the output and closes the transforms. It does not close
+// the underlying writer.
+func (x *JSONExporter) Close() error {
+	err := x.w.Flush()
+	if cerr := x.out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Begin implements Sink. The ctime of the dump becomes the reference time
+// for JSONTTL if JSONOptions.Now is zero.
+func (x *JSONExporter) Begin(info DumpInfo) error {
+	if x.opts.Now.IsZero() {
+		x.opts.Now, _ = info.Created()
+	}
+	return nil
+}
+
+// WriteEntry implements Sink by calling Add.
+func (x *JSONExporter) WriteEntry(e *Entry) error { return x.Add(e) }
+
+// End implements Sink by calling Close.
+func (x *JSONExporter) End() error { return x.Close() }
@@ -0,0 +1,238 @@
+package rdb_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestJSONExporter(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	entries := []*rdb.Entry{
+		{DB: 1, Key: rdb.RedisString("s"), Value: rdb.StringValue("hello"), ExpiryAt: now.UnixMilli() + 1500, Access: rdb.AccessLRU, Idle: 5},
+		{Key: rdb.RedisString("l"), ValueType: rdb.ListQuickList2, Value: rdb.ListValue{Elements: strs("a", "b")}},
+		{Key: rdb.RedisString("st"), ValueType: rdb.SetListPack, Value: rdb.SetValue{Members: strs("x")}},
+		{Key: rdb.RedisString("h"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields("f", "v", "g", "w")}},
+		{Key: rdb.RedisString("z"), ValueType: rdb.ZSetListPack, Value: rdb.ZSetValue{Members: []rdb.ZSetMember{
+			{Member: rdb.RedisString("m"), Score: 0.1}, {Member: rdb.RedisString("n"), Score: math.Inf(1)},
+		}}},
+		{Key: rdb.RedisString("e"), ValueType: rdb.SetListPack, Value: rdb.SetValue{}},
+		{Key: rdb.RedisString("x"), ValueType: rdb.StreamListPacks3, Value: testStream()},
+	}
+	_, mem := rdb.EstimateMemory(entries[0], rdb.DefaultEncodingConfig())
+	tests := []struct {
+		name string
+		opts rdb.JSONOptions
+		only []*rdb.Entry // nil for all entries
+		want string
+	}{
+		{"default", rdb.JSONOptions{}, nil, `{"key":"s","value":"hello"}
+{"key":"l","value":["a","b"]}
+{"key":"st","value":["x"]}
+{"key":"h","value":{"f":"v","g":"w"}}
+{"key":"z","value":{"m":0.1,"n":"inf"}}
+{"key":"e","value":[]}
+{"key":"x","value":null}
+`},
+		{"flatten", rdb.JSONOptions{Flatten: true}, nil, `{"key":"s","value":"hello"}
+{"key":"l","index":0,"value":"a"}
+{"key":"l","index":1,"value":"b"}
+{"key":"st","member":"x"}
+{"key":"h","field":"f","value":"v"}
+{"key":"h","field":"g","value":"w"}
+{"key":"z","member":"m","score":0.1}
+{"key":"z","member":"n","score":"inf"}
+{"key":"x","value":null}
+`},
+		{"inventory", rdb.JSONOptions{OmitValues: true, Flatten: true, Fields: rdb.JSONType | rdb.JSONEncoding | rdb.JSONExpiry}, nil, `{"key":"s","type":"string","encoding":"raw","expire_at":1700000001500}
+{"key":"l","type":"list","encoding":"quicklist"}
+{"key":"st","type":"set","encoding":"listpack"}
+{"key":"h","type":"hash","encoding":"listpack"}
+{"key":"z","type":"zset","encoding":"listpack"}
+{"key":"e","type":"set","encoding":"listpack"}
+{"key":"x","type":"stream","encoding":"stream"}
+`},
+		{"all fields", rdb.JSONOptions{Fields: rdb.JSONAllFields, Now: now}, entries[:1], fmt.Sprintf(
+			`{"key":"s","db":1,"type":"string","encoding":"raw","expire_at":1700000001500,"ttl":1,"length":5,"memory":%d,"idle":5,"value":"hello"}
+`, mem)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			x, err := rdb.NewJSONExporter(&buf, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			only := tt.only
+			if only == nil {
+				only = entries
+			}
+			for _, e := range only {
+				if err := x.Add(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := x.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+			for _, line := range bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n")) {
+				if !json.Valid(line) {
+					t.Errorf("invalid JSON %s", line)
+				}
+			}
+		})
+	}
+}
+
+// TestJSONExporterTTL checks that TTLs are relative to JSONOptions.Now or,
+// failing that, to the ctime of the dump, and left out without either.
+func TestJSONExporterTTL(t *testing.T) {
+	ctime := []rdb.AuxField{{Key: rdb.RedisString("ctime"), Value: rdb.RedisString("1700000000")}}
+	e := str(0, "k", "v", 1700000002500)
+	for _, tt := range []struct {
+		name string
+		now  time.Time
+		aux  []rdb.AuxField
+		want string
+	}{
+		{"ctime", time.Time{}, ctime, `{"key":"k","ttl":2}`},
+		{"Now over ctime", time.Unix(1700000001, 0), ctime, `{"key":"k","ttl":1}`},
+		{"neither", time.Time{}, nil, `{"key":"k"}`},
+	} {
+		var buf bytes.Buffer
+		x, err := rdb.NewJSONExporter(&buf, rdb.JSONOptions{Fields: rdb.JSONTTL, OmitValues: true, Now: tt.now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		x.Begin(rdb.DumpInfo{Version: 11, Aux: tt.aux})
+		x.WriteEntry(e)
+		if err := x.End(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want+"\n" {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestJSONExporterBinary checks that binary keys and values round-trip
+// through the escapings that keep them, and that the default does not
+// produce invalid JSON.
+func TestJSONExporterBinary(t *testing.T) {
+	key := "k\xff\x00\"\\"
+	value := "\xc3\x28\n"
+	e := &rdb.Entry{Key: rdb.RedisString(key), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields(value, value)}}
+	export := func(opts rdb.JSONOptions) (obj struct {
+		Key   string
+		Value map[string]string
+	}) {
+		var buf bytes.Buffer
+		x, err := rdb.NewJSONExporter(&buf, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x.Add(e)
+		if err := x.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(buf.Bytes(), &obj); err != nil {
+			t.Fatalf("%v in %s", err, buf.Bytes())
+		}
+		return obj
+	}
+
+	obj := export(rdb.JSONOptions{KeyEscape: rdb.EscapeBase64, ValueEscape: rdb.EscapeQuote})
+	if k, _ := base64.StdEncoding.DecodeString(obj.Key); string(k) != key {
+		t.Errorf("got key %q, want %q", k, key)
+	}
+	for f, v := range obj.Value {
+		if got := string(unquote(t, f)); got != value {
+			t.Errorf("got field %q, want %q", got, value)
+		}
+		if got := string(unquote(t, v)); got != value {
+			t.Errorf("got value %q, want %q", got, value)
+		}
+	}
+
+	// Raw keys lose their invalid bytes but stay valid JSON.
+	obj = export(rdb.JSONOptions{})
+	if obj.Key != "k�\x00\"\\" {
+		t.Errorf("got key %q", obj.Key)
+	}
+}
+
+func TestJSONExporterScores(t *testing.T) {
+	nan := math.Float64frombits(0x7ff8000000000123)
+	values := []float64{0.1, math.Copysign(0, -1), 3, 1e21, 5e-324, math.Inf(1), math.Inf(-1), nan}
+	var z rdb.ZSetValue
+	for _, v := range values {
+		z.Members = append(z.Members, rdb.ZSetMember{Member: rdb.RedisString("m"), Score: v})
+	}
+	e := &rdb.Entry{Key: rdb.RedisString("z"), ValueType: rdb.ZSetListPack, Value: z}
+	scores := func(f rdb.FloatFormat) []string {
+		var buf bytes.Buffer
+		x, err := rdb.NewJSONExporter(&buf, rdb.JSONOptions{Flatten: true, Scores: f})
+		if err != nil {
+			t.Fatal(err)
+		}
+		x.Add(e)
+		if err := x.Close(); err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		dec := json.NewDecoder(&buf)
+		for dec.More() {
+			var row struct{ Score json.RawMessage }
+			if err := dec.Decode(&row); err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, string(row.Score))
+		}
+		return out
+	}
+	tests := []struct {
+		f    rdb.FloatFormat
+		want []string
+	}{
+		{rdb.FloatNumber, []string{`0.1`, `-0`, `3`, `1e+21`, `5e-324`, `"inf"`, `"-inf"`, `"nan"`}},
+		{rdb.FloatString, []string{`"0.10000000000000001"`, `"-0"`, `"3"`, `"1e+21"`, `"4.9406564584124654e-324"`, `"inf"`, `"-inf"`, `"nan"`}},
+		{rdb.FloatBits, []string{`"3fb999999999999a"`, `"8000000000000000"`, `"4008000000000000"`, `"444b1ae4d6e2ef50"`,
+			`"0000000000000001"`, `"7ff0000000000000"`, `"fff0000000000000"`, `"7ff8000000000123"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.f.String(), func(t *testing.T) {
+			got := scores(tt.f)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Numbers and strings parse back to the same value, bits to the same
+	// bit pattern, NaN payload included.
+	for i, s := range scores(rdb.FloatString)[:5] {
+		var str string
+		json.Unmarshal([]byte(s), &str)
+		if v, err := strconv.ParseFloat(str, 64); err != nil || math.Float64bits(v) != math.Float64bits(values[i]) {
+			t.Errorf("%s parses as %v, %v, want %v", s, v, err, values[i])
+		}
+	}
+	for i, s := range scores(rdb.FloatBits) {
+		if n, err := strconv.ParseUint(s[1:len(s)-1], 16, 64); err != nil || n != math.Float64bits(values[i]) {
+			t.Errorf("%s parses as %x, %v", s, n, err)
+		}
+	}
+	if s := rdb.FloatFormat(7).String(); s != "FloatFormat(7)" {
+		t.Errorf("got %q", s)
+	}
+}
@@ -0,0 +1,227 @@
+package rdb
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// KeyNameStats collects statistics about key names: how long they are and
+// which naming patterns and tokens are most common. Key names are stored
+// once per key, so long names on many keys add up; the report estimates the
+// memory they take. Feed it every entry with Add, then call Report.
+type KeyNameStats struct {
+	// Separators split key names into segments. If empty, ":./-_|" is used.
+	Separators string
+
+	// Top limits the number of patterns and tokens reported; 0 means 20.
+	Top int
+
+	// MaxPatterns bounds the number of distinct patterns and tokens
+	// tracked; keys with patterns beyond it are counted under "(other)"
+	// and further tokens are ignored. 0 means 100000.
+	MaxPatterns int
+
+	keys     uint64
+	bytes    uint64
+	min, max int
+	lengths  [33]uint64 // by bits.Len of the length
+	patterns map[string]*KeyPattern
+	tokens   map[string]uint64
+}
+
+// KeyNameReport is the result of KeyNameStats.
+type KeyNameReport struct {
+	Keys   uint64
+	Bytes  uint64 // total length of the key names
+	Memory uint64 // estimated memory used by the key name strings
+	Min    int
+	Max    int
+	Mean   float64
+
+	// Lengths is the distribution of key name lengths in power of two
+	// buckets, shortest first. Empty buckets are omitted.
+	Lengths []KeyLengthBucket
+
+	// Patterns are the most common key patterns, most keys first. In a
+	// pattern, numeric segments are replaced by {n} and segments that look
+	// like hexadecimal IDs by {id}.
+	Patterns []KeyPattern
+
+	// Tokens are the most common literal segments, most frequent first.
+	Tokens []KeyToken
+}
+
+// KeyLengthBucket counts the keys whose name length is at most Max and
+// greater than the Max of the previous bucket.
+type KeyLengthBucket struct {
+	Max  int
+	Keys uint64
+}
+
+// KeyPattern aggregates the keys matching a naming pattern.
+type KeyPattern struct {
+	Pattern string
+	Keys    uint64
+	Bytes   uint64 // total length of the key names
+	Memory  uint64 // estimated memory used by the key name strings
+}
+
+// KeyToken counts the occurrences of a literal key segment.
+type KeyToken struct {
+	Token string
+	Count uint64
+}
+
+// Add accounts for a single entry.
+func (s *KeyNameStats) Add(e *Entry) {
+	n := len(e.Key)
+	if s.keys == 0 || n < s.min {
+		s.min = n
+	}
+	if n > s.max {
+		s.max = n
+	}
+	s.keys++
+	s.bytes += uint64(n)
+	s.lengths[bits.Len32(uint32(n))]++
+
+	if s.patterns == nil {
+		s.patterns = make(map[string]*KeyPattern)
+		s.tokens = make(map[string]uint64)
+	}
+	pattern := s.pattern(e.Key)
+	p := s.patterns[pattern]
+	if p == nil {
+		if len(s.patterns) >= s.limit() {
+			pattern = "(other)"
+			p = s.patterns[pattern]
+		}
+		if p == nil {
+			p = &KeyPattern{Pattern: pattern}
+			s.patterns[pattern] = p
+		}
+	}
+	p.Keys++
+	p.Bytes += uint64(n)
+	p.Memory += sdsSize(n)
+}
+
+func (s *KeyNameStats) limit() int {
+	if s.MaxPatterns == 0 {
+		return 100000
+	}
+	return s.MaxPatterns
+}
+
+// pattern splits key into segments, counts the literal ones as tokens and
+// returns the key with its variable segments replaced by placeholders.
+func (s *KeyNameStats) pattern(key []byte) string {
+	seps := s.Separators
+	if seps == "" {
+		seps = ":./-_|"
+	}
+	isSep := func(c byte) bool {
+		for i := 0; i < len(seps); i++ {
+			if seps[i] == c {
+				return true
+			}
+		}
+		return false
+	}
+	var out []byte
+	start := 0
+	for i := 0; i <= len(key); i++ {
+		if i < len(key) && !isSep(key[i]) {
+			continue
+		}
+		seg := key[start:i]
+		switch {
+		case len(seg) == 0:
+		case isNumeric(seg):
+			out = append(out, "{n}"...)
+		case isID(seg):
+			out = append(out, "{id}"...)
+		default:
+			out = append(out, seg...)
+			if _, ok := s.tokens[string(seg)]; ok || len(s.tokens) < s.limit() {
+				s.tokens[string(seg)]++
+			}
+		}
+		if i < len(key) {
+			out = append(out, key[i])
+		}
+		start = i + 1
+	}
+	return string(out)
+}
+
+func isNumeric(s []byte) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isID reports whether s looks like a generated identifier: at least 8
+// hexadecimal digits with at least one digit among them.
+func isID(s []byte) bool {
+	if len(s) < 8 {
+		return false
+	}
+	digit := false
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digit = true
+		case c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return digit
+}
+
+// Report returns the statistics collected so far.
+func (s *KeyNameStats) Report() KeyNameReport {
+	top := s.Top
+	if top == 0 {
+		top = 20
+	}
+	r := KeyNameReport{Keys: s.keys, Bytes: s.bytes, Min: s.min, Max: s.max}
+	if s.keys > 0 {
+		r.Mean = float64(s.bytes) / float64(s.keys)
+	}
+	for i, n := range s.lengths {
+		if n > 0 {
+			r.Lengths = append(r.Lengths, KeyLengthBucket{Max: 1<<uint(i) - 1, Keys: n})
+		}
+	}
+	for _, p := range s.patterns {
+		r.Memory += p.Memory
+		r.Patterns = append(r.Patterns, *p)
+	}
+	sort.Slice(r.Patterns, func(i, j int) bool {
+		if r.Patterns[i].Keys != r.Patterns[j].Keys {
+			return r.Patterns[i].Keys > r.Patterns[j].Keys
+		}
+		return r.Patterns[i].Pattern < r.Patterns[j].Pattern
+	})
+	if len(r.Patterns) > top {
+		r.Patterns = r.Patterns[:top]
+	}
+	for t, n := range s.tokens {
+		r.Tokens = append(r.Tokens, KeyToken{Token: t, Count: n})
+	}
+	sort.Slice(r.Tokens, func(i, j int) bool {
+		if r.Tokens[i].Count != r.Tokens[j].Count {
+			return r.Tokens[i].Count > r.Tokens[j].Count
+		}
+		return r.Tokens[i].Token < r.Tokens[j].Token
+	})
+	if len(r.Tokens) > top {
+		r.Tokens = r.Tokens[:top]
+	}
+	return r
+}
@@ -0,0 +1,66 @@
+package rdb_test
+
+import (
+	"reflect"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestKeyNameStats(t *testing.T) {
+	add := func(s *rdb.KeyNameStats, keys ...string) {
+		for _, k := range keys {
+			s.Add(str(0, k, "v", 0))
+		}
+	}
+	var s rdb.KeyNameStats
+	add(&s, "user:1", "user:22", "user:deadbeef01:name", "session.abc-DEF", "x", "tag:cafebabe", "a::b")
+	r := s.Report()
+
+	if r.Keys != 7 || r.Bytes != 1+6+7+20+15+12+4 || r.Min != 1 || r.Max != 20 || r.Mean != 65.0/7 {
+		t.Errorf("got keys %d, bytes %d, min %d, max %d, mean %v", r.Keys, r.Bytes, r.Min, r.Max, r.Mean)
+	}
+	lengths := []rdb.KeyLengthBucket{{Max: 1, Keys: 1}, {Max: 7, Keys: 3}, {Max: 15, Keys: 2}, {Max: 31, Keys: 1}}
+	if !reflect.DeepEqual(r.Lengths, lengths) {
+		t.Errorf("got lengths %+v, want %+v", r.Lengths, lengths)
+	}
+
+	// Numbers become {n}, hexadecimal IDs with a digit {id}; a word made
+	// of hexadecimal letters stays as it is.
+	var patterns []string
+	var memory uint64
+	for _, p := range r.Patterns {
+		patterns = append(patterns, p.Pattern)
+		memory += p.Memory
+	}
+	want := []string{"user:{n}", "a::b", "session.abc-DEF", "tag:cafebabe", "user:{id}:name", "x"}
+	if !reflect.DeepEqual(patterns, want) {
+		t.Errorf("got patterns %q, want %q", patterns, want)
+	}
+	if p := r.Patterns[0]; p.Keys != 2 || p.Bytes != 13 {
+		t.Errorf("got %+v", p)
+	}
+	if r.Memory != memory || r.Memory < r.Bytes {
+		t.Errorf("got memory %d for %d bytes, patterns sum to %d", r.Memory, r.Bytes, memory)
+	}
+
+	tokens := []rdb.KeyToken{{Token: "user", Count: 3}, {Token: "DEF", Count: 1}}
+	if len(r.Tokens) != 10 || !reflect.DeepEqual(r.Tokens[:2], tokens) {
+		t.Errorf("got tokens %+v", r.Tokens)
+	}
+
+	// Custom separators, Top and MaxPatterns.
+	s = rdb.KeyNameStats{Separators: "/", Top: 1, MaxPatterns: 2}
+	add(&s, "a/1", "a/2", "b/1", "c/1", "user:1")
+	r = s.Report()
+	if len(r.Patterns) != 1 || r.Patterns[0].Pattern != "(other)" || r.Patterns[0].Keys != 2 {
+		t.Errorf("got patterns %+v", r.Patterns)
+	}
+	if len(r.Tokens) != 1 || r.Tokens[0] != (rdb.KeyToken{Token: "a", Count: 2}) {
+		t.Errorf("got tokens %+v", r.Tokens)
+	}
+
+	if r := (&rdb.KeyNameStats{}).Report(); r.Keys != 0 || r.Mean != 0 || r.Lengths != nil {
+		t.Errorf("empty report %+v", r)
+	}
+}
@@ -0,0 +1,119 @@
+package rdb
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// LargestElements finds the biggest single elements inside collections:
+// list elements, set and sorted set members and hash fields. One
+// oversized element in an otherwise ordinary key is a common cause of
+// latency spikes that a report of big keys does not reveal. Feed it every
+// entry with Add, then call Report.
+type LargestElements struct {
+	// Top is the number of elements reported; 0 means 20.
+	Top int
+
+	h elementHeap
+}
+
+// LargeElement is an element of a collection.
+type LargeElement struct {
+	DB    uint64
+	Key   RedisString
+	Type  Type
+	Index int // position in a list, -1 for other types
+
+	// Name is the member or hash field, cut to its first 128 bytes.
+	// It is empty for list elements.
+	Name RedisString
+
+	// Size is the element's length in bytes; for a hash it covers both
+	// the field and the value.
+	Size int
+}
+
+const largeElementNameMax = 128
+
+// Add examines the elements of a single entry.
+func (l *LargestElements) Add(e *Entry) {
+	switch v := e.Value.(type) {
+	case ListValue, SetValue:
+		list := e.Type() == TypeList
+		elems, _ := stringsOf(v)
+		for i, s := range elems {
+			if !l.fits(len(s)) {
+				continue
+			}
+			el := LargeElement{DB: e.DB, Key: e.Key, Type: e.Type(), Index: -1, Size: len(s)}
+			if list {
+				el.Index = i
+			} else {
+				el.Name = truncate(s, largeElementNameMax)
+			}
+			l.push(el)
+		}
+	case ZSetValue:
+		for _, m := range v.Members {
+			if l.fits(len(m.Member)) {
+				l.push(LargeElement{DB: e.DB, Key: e.Key, Type: TypeZSet, Index: -1,
+					Name: truncate(m.Member, largeElementNameMax), Size: len(m.Member)})
+			}
+		}
+	case HashValue:
+		for _, f := range v.Fields {
+			n := len(f.Field) + len(f.Value)
+			if l.fits(n) {
+				l.push(LargeElement{DB: e.DB, Key: e.Key, Type: TypeHash, Index: -1,
+					Name: truncate(f.Field, largeElementNameMax), Size: n})
+			}
+		}
+	}
+}
+
+func (l *LargestElements) top() int {
+	if l.Top == 0 {
+		return 20
+	}
+	return l.Top
+}
+
+// fits reports whether an element of size n would make it into the top.
+func (l *LargestElements) fits(n int) bool {
+	return len(l.h) < l.top() || n > l.h[0].Size
+}
+
+func (l *LargestElements) push(el LargeElement) {
+	heap.Push(&l.h, el)
+	if len(l.h) > l.top() {
+		heap.Pop(&l.h)
+	}
+}
+
+func truncate(s RedisString, n int) RedisString {
+	if len(s) > n {
+		s = s[:n]
+	}
+	return append(RedisString(nil), s...)
+}
+
+// Report returns the largest elements found so far, largest first.
+func (l *LargestElements) Report() []LargeElement {
+	out := append([]LargeElement(nil), l.h...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Size > out[j].Size })
+	return out
+}
+
+// elementHeap is a min-heap of elements by size.
+type elementHeap []LargeElement
+
+func (h elementHeap) Len() int           { return len(h) }
+func (h elementHeap) Less(i, j int) bool { return h[i].Size < h[j].Size }
+func (h elementHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *elementHeap) Push(x any)        { *h = append(*h, x.(LargeElement)) }
+func (h *elementHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
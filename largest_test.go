@@ -0,0 +1,50 @@
+package rdb_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestLargestElements(t *testing.T) {
+	long := strings.Repeat("m", 200)
+	entries := []*rdb.Entry{
+		{Key: rdb.RedisString("l"), ValueType: rdb.ListQuickList2, Value: rdb.ListValue{Elements: strs("aaaa", "b", strings.Repeat("c", 10))}},
+		{DB: 1, Key: rdb.RedisString("s"), ValueType: rdb.SetListPack, Value: rdb.SetValue{Members: strs("x", long)}},
+		{Key: rdb.RedisString("z"), ValueType: rdb.ZSetListPack, Value: rdb.ZSetValue{Members: []rdb.ZSetMember{
+			{Member: rdb.RedisString("zzzzzzzzz"), Score: 1},
+		}}},
+		{Key: rdb.RedisString("h"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields("f", "1234567")}},
+		str(0, "str", strings.Repeat("s", 1000), 0), // not a collection
+	}
+	l := rdb.LargestElements{Top: 4}
+	for _, e := range entries {
+		l.Add(e)
+	}
+	want := []rdb.LargeElement{
+		{DB: 1, Key: rdb.RedisString("s"), Type: rdb.TypeSet, Index: -1, Name: rdb.RedisString(long[:128]), Size: 200},
+		{Key: rdb.RedisString("l"), Type: rdb.TypeList, Index: 2, Size: 10},
+		{Key: rdb.RedisString("z"), Type: rdb.TypeZSet, Index: -1, Name: rdb.RedisString("zzzzzzzzz"), Size: 9},
+		{Key: rdb.RedisString("h"), Type: rdb.TypeHash, Index: -1, Name: rdb.RedisString("f"), Size: 8},
+	}
+	got := l.Report()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// The names are copies: reusing the value does not change the report.
+	entries[1].Value.(rdb.SetValue).Members[1][0] = 'X'
+	if got := l.Report(); got[0].Name[0] != 'm' {
+		t.Error("the report shares the member")
+	}
+
+	var d rdb.LargestElements
+	for i := 0; i < 30; i++ {
+		d.Add(&rdb.Entry{Key: rdb.RedisString("l"), ValueType: rdb.ListQuickList2, Value: rdb.ListValue{Elements: strs(strings.Repeat("x", i))}})
+	}
+	if got := d.Report(); len(got) != 20 || got[0].Size != 29 || got[19].Size != 10 {
+		t.Errorf("default top: got %d elements, %+v", len(got), got)
+	}
+}
@@ -0,0 +1,23 @@
+package rdb
+
+// Limits bounds the sizes a Reader accepts, so that a malformed or hostile
+// dump cannot make it allocate more than the caller is prepared to. Sizes
+// over a limit fail with a *LengthError. A zero field means no limit, but
+// lengths that do not fit in an int are always rejected, which matters on
+// 32-bit platforms where a corrupt length could otherwise be truncated.
+type Limits struct {
+	MaxStringLen uint64 // bytes in a key or string, after decompression
+	MaxElements  uint64 // elements of a list, set or sorted set, or fields of a hash
+}
+
+// WithLimits makes the Reader enforce l.
+func WithLimits(l Limits) Option {
+	return func(r *Reader) {
+		r.strings.MaxLen = l.MaxStringLen
+		r.maxElements = l.MaxElements
+	}
+}
+
+func (r *Reader) limits() Limits {
+	return Limits{MaxStringLen: r.strings.MaxLen, MaxElements: r.maxElements}
+}
@@ -0,0 +1,100 @@
+package rdb_test
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+// lengthDump is a dump whose first record, of type t and key "k", ends
+// right after the given bytes.
+func lengthDump(t rdb.ValueType, b ...[]byte) []byte {
+	dump := append([]byte("REDIS0011"), byte(t))
+	dump = codec.AppendString(dump, []byte("k"), false)
+	for _, x := range b {
+		dump = append(dump, x...)
+	}
+	return dump
+}
+
+func TestLimits(t *testing.T) {
+	huge := codec.AppendLength(nil, 1<<40)
+	limits := rdb.Limits{MaxStringLen: 1024, MaxElements: 100}
+	zset := make([][]byte, 0, 202)
+	for i := 0; i < 101; i++ {
+		zset = append(zset, []byte("m"+strconv.Itoa(i)), []byte("1"))
+	}
+	tests := []struct {
+		name   string
+		dump   []byte
+		limits rdb.Limits
+		want   rdb.LengthError
+	}{
+		{"key", append([]byte("REDIS0011\x00"), huge...), limits, rdb.LengthError{What: "string", Length: 1 << 40, Max: 1024}},
+		{"string", lengthDump(rdb.String, huge), limits, rdb.LengthError{What: "string", Length: 1 << 40, Max: 1024}},
+		{"lzf string", lengthDump(rdb.String, []byte{0xc0 | codec.EncLZF, 10}, huge), limits, rdb.LengthError{What: "string", Length: 1 << 40, Max: 1024}},
+		{"list", lengthDump(rdb.List, huge), limits, rdb.LengthError{What: "collection", Length: 1 << 40, Max: 100}},
+		{"quicklist", lengthDump(rdb.ListQuickList2, huge), limits, rdb.LengthError{What: "collection", Length: 1 << 40, Max: 100}},
+		{"set", lengthDump(rdb.Set, huge), limits, rdb.LengthError{What: "collection", Length: 1 << 40, Max: 100}},
+		{"sorted set", lengthDump(rdb.ZSet2, huge), limits, rdb.LengthError{What: "collection", Length: 1 << 40, Max: 100}},
+		{"hash", lengthDump(rdb.Hash, huge), limits, rdb.LengthError{What: "collection", Length: 1 << 40, Max: 100}},
+		{"listpack", blobDump(rdb.ZSetListPack, nil, codec.EncodeListpack(zset)), limits, rdb.LengthError{What: "collection", Length: 101, Max: 100}},
+		// Without limits, lengths are still bounded by the largest int.
+		{"no limits", lengthDump(rdb.String, codec.AppendLength(nil, math.MaxUint64)), rdb.Limits{}, rdb.LengthError{What: "string", Length: math.MaxUint64, Max: math.MaxInt}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The dumps end right after the oversized length: any other
+			// error, such as io.ErrUnexpectedEOF, would mean the reader
+			// went on to allocate and read what the length announced.
+			err := readToEnd(tt.dump, rdb.WithLimits(tt.limits))
+			var le *rdb.LengthError
+			if !errors.As(err, &le) || !errors.Is(err, rdb.ErrTooLarge) {
+				t.Fatalf("got %v, want a *LengthError", err)
+			}
+			if *le != tt.want {
+				t.Errorf("got %+v, want %+v", *le, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitsBoundary(t *testing.T) {
+	elems := make([]string, 100)
+	for i := range elems {
+		elems[i] = strconv.Itoa(i)
+	}
+	entries := []*rdb.Entry{
+		str(0, "s", strings.Repeat("x", 1024), 0),
+		{Key: rdb.RedisString("l"), Value: rdb.ListValue{Elements: strs(elems...)}},
+	}
+	dump := rdbtest.Dump(t, 11, entries...)
+	if err := readToEnd(dump, rdb.WithLimits(rdb.Limits{MaxStringLen: 1024, MaxElements: 100})); err != nil {
+		t.Errorf("at the limits: %v", err)
+	}
+	for _, l := range []rdb.Limits{{MaxStringLen: 1023}, {MaxElements: 99}} {
+		if err := readToEnd(dump, rdb.WithLimits(l)); !errors.Is(err, rdb.ErrTooLarge) {
+			t.Errorf("%+v: got %v, want ErrTooLarge", l, err)
+		}
+	}
+}
+
+func TestLimitsBeforeAlloc(t *testing.T) {
+	// A 512 KiB key, present in full, would be allocated from the arena,
+	// whose chunks take strings up to a quarter of their size.
+	dump := append([]byte("REDIS0011\x00"), codec.AppendRawString(nil, make([]byte, 512<<10))...)
+	a := rdb.NewArena(4 << 20)
+	err := readToEnd(dump, rdb.WithArena(a), rdb.WithLimits(rdb.Limits{MaxStringLen: 1024}))
+	if !errors.Is(err, rdb.ErrTooLarge) {
+		t.Fatalf("got %v, want ErrTooLarge", err)
+	}
+	if a.Size() != 0 {
+		t.Errorf("the arena holds %d bytes after the key was rejected", a.Size())
+	}
+}
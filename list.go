@@ -0,0 +1,93 @@
+package rdb
+
+import (
+	"fmt"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// Quicklist node containers of ListQuickList2, from Redis' quicklist.h.
+const (
+	quicklistPlain  = 1 // a single element too large for a listpack
+	quicklistPacked = 2 // a listpack of elements
+)
+
+// readList reads a list of type t: a sequence of strings, a ziplist blob
+// of elements, or a quicklist, a sequence of ziplist nodes (ListQuickList)
+// or listpack nodes (ListQuickList2) whose elements are concatenated.
+func (r *Reader) readList(t ValueType) ([]RedisString, error) {
+	switch t {
+	case List:
+		return r.readStrings()
+	case ListQuickList, ListQuickList2:
+		nodes, err := r.readCount()
+		if err != nil {
+			return nil, err
+		}
+		var elems [][]byte
+		for i := uint64(0); i < nodes; i++ {
+			var node [][]byte
+			if t == ListQuickList {
+				node, err = r.readZiplist()
+			} else {
+				node, err = r.readQuicklistNode()
+			}
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, node...)
+			if err := codec.CheckLength("collection", uint64(len(elems)), r.maxElements); err != nil {
+				return nil, err
+			}
+		}
+		return r.blobStrings(elems)
+	}
+	elems, err := r.readZiplist()
+	if err != nil {
+		return nil, err
+	}
+	return r.blobStrings(elems)
+}
+
+// readZiplist reads a ziplist blob and returns its elements.
+func (r *Reader) readZiplist() ([][]byte, error) {
+	blob, err := r.readString()
+	if err != nil {
+		return nil, err
+	}
+	return codec.DecodeZiplist(blob)
+}
+
+// readQuicklistNode reads a node of a ListQuickList2 value: its container
+// format and its contents, a listpack or, for plain nodes, a single
+// element stored as is.
+func (r *Reader) readQuicklistNode() ([][]byte, error) {
+	container, err := r.readLength()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := r.readString()
+	if err != nil {
+		return nil, err
+	}
+	switch container {
+	case quicklistPlain:
+		return [][]byte{blob}, nil
+	case quicklistPacked:
+		return codec.DecodeListpack(blob)
+	}
+	return nil, fmt.Errorf("%w: invalid quicklist container %d", ErrFormat, container)
+}
+
+// blobStrings returns the elements decoded from blobs as strings, within
+// the collection limit.
+func (r *Reader) blobStrings(elems [][]byte) ([]RedisString, error) {
+	if err := codec.CheckLength("collection", uint64(len(elems)), r.maxElements); err != nil {
+		return nil, err
+	}
+	s := make([]RedisString, len(elems))
+	for i, e := range elems {
+		s[i] = e
+	}
+	return s, nil
+}
@@ -0,0 +1,391 @@
+package rdb
+
+import (
+	"math/bits"
+	"strconv"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// EncodingConfig holds the redis.conf settings that decide which encoding
+// Redis picks for a value when it is created or loaded.
+type EncodingConfig struct {
+	HashMaxListpackEntries int
+	HashMaxListpackValue   int
+	SetMaxIntsetEntries    int
+	SetMaxListpackEntries  int
+	SetMaxListpackValue    int
+	ZSetMaxListpackEntries int
+	ZSetMaxListpackValue   int
+	// ListMaxListpackSize limits quicklist nodes: a positive value is a
+	// number of elements per node, -1 to -5 select a node size of 4, 8,
+	// 16, 32 or 64 KiB.
+	ListMaxListpackSize  int
+	StreamNodeMaxBytes   int
+	StreamNodeMaxEntries int
+}
+
+// DefaultEncodingConfig returns the Redis 7.2 defaults.
+func DefaultEncodingConfig() EncodingConfig {
+	return EncodingConfig{
+		HashMaxListpackEntries: 128,
+		HashMaxListpackValue:   64,
+		SetMaxIntsetEntries:    512,
+		SetMaxListpackEntries:  128,
+		SetMaxListpackValue:    64,
+		ZSetMaxListpackEntries: 128,
+		ZSetMaxListpackValue:   64,
+		ListMaxListpackSize:    -2,
+		StreamNodeMaxBytes:     4096,
+		StreamNodeMaxEntries:   100,
+	}
+}
+
+// Sizes of Redis' internal structures on a 64-bit build.
+const (
+	robjSize       = 16
+	dictEntrySize  = 24
+	dictSize       = 56
+	quicklistSize  = 40
+	qlNodeSize     = 32
+	streamObjSize  = 88
+	raxNodeSize    = 32
+	cgroupSize     = 64
+	consumerSize   = 56
+	nackSize       = 32
+	embstrMaxLen   = 44
+	listpackHeader = 7 // header and terminator
+	sharedIntegers = 10000
+	zsetObjSize    = 16
+	zslObjSize     = 32
+	zslHeaderSize  = 24 + 32*16 // header node with all 32 levels
+	zslNodeSize    = 24 + 16*4/3
+)
+
+// EstimateMemory estimates the memory used by the key of e in a Redis
+// server that loaded it with the settings in cfg. It returns the encoding
+// the server would pick for the value and the estimated size in bytes,
+// covering the key, the value and the key's share of the keyspace and
+// expires dictionaries. The model follows a 64-bit Redis 7.2 build with
+// jemalloc and is meant for relative comparisons, not exact accounting.
+//
+// Small integer strings are counted as shared objects, taking no memory of
+// their own, unless e carries LRU or LFU access information: Redis does not
+// share them under such a maxmemory-policy, as each key needs its own
+// access clock.
+func EstimateMemory(e *Entry, cfg EncodingConfig) (Encoding, uint64) {
+	size := mallocSize(dictEntrySize) + sdsSize(len(e.Key)) + 8 // + bucket pointer
+	if e.HasExpiry() {
+		size += mallocSize(dictEntrySize) + 8
+	}
+	enc, v := estimateValue(e, cfg)
+	return enc, size + v
+}
+
+func estimateValue(e *Entry, cfg EncodingConfig) (Encoding, uint64) {
+	switch v := e.Value.(type) {
+	case StringValue:
+		return EncodingRaw, stringObjectSize(v, e.Access == AccessNone)
+	case SetValue:
+		return setSize(v.Members, cfg)
+	case ListValue:
+		return listSize(v.Elements, cfg)
+	case ZSetValue:
+		return zsetSize(v.Members, cfg)
+	case HashValue:
+		return hashSize(v.Fields, cfg)
+	case *StreamValue:
+		return EncodingStream, streamSize(v, cfg)
+	}
+	return e.Encoding(), 0
+}
+
+// stringObjectSize returns the size of a string object. With shared set,
+// integers below sharedIntegers use one of the server's shared objects.
+func stringObjectSize(s []byte, shared bool) uint64 {
+	if n, ok := codec.ParseInt(s); ok {
+		if shared && n >= 0 && n < sharedIntegers {
+			return 0
+		}
+		return robjSize
+	}
+	if len(s) <= embstrMaxLen {
+		return mallocSize(uint64(robjSize + 3 + len(s) + 1))
+	}
+	return robjSize + sdsSize(len(s))
+}
+
+func listSize(l []RedisString, cfg EncodingConfig) (Encoding, uint64) {
+	nodes := quicklistNodes(l, cfg.ListMaxListpackSize)
+	if len(nodes) <= 1 {
+		var lp uint64 = listpackHeader
+		if len(nodes) == 1 {
+			lp = nodes[0]
+		}
+		return EncodingListpack, robjSize + mallocSize(lp)
+	}
+	size := robjSize + mallocSize(quicklistSize)
+	for _, n := range nodes {
+		size += mallocSize(qlNodeSize) + mallocSize(n)
+	}
+	return EncodingQuicklist, size
+}
+
+// quicklistNodes splits l into quicklist nodes under the given
+// list-max-listpack-size and returns the listpack size of each node.
+func quicklistNodes(l []RedisString, fill int) []uint64 {
+	maxBytes, maxCount := uint64(8192), 0
+	switch {
+	case fill > 0:
+		maxCount = fill
+	case fill >= -5:
+		maxBytes = 4096 << uint(-fill-1)
+	}
+	var nodes []uint64
+	var cur uint64
+	count := 0
+	for _, s := range l {
+		n := listpackEntrySize(s)
+		full := count > 0 && (cur+n > maxBytes || (maxCount > 0 && count >= maxCount))
+		if count == 0 || full {
+			nodes = append(nodes, 0)
+			cur, count = listpackHeader, 0
+		}
+		cur += n
+		count++
+		nodes[len(nodes)-1] = cur
+	}
+	return nodes
+}
+
+func setSize(members []RedisString, cfg EncodingConfig) (Encoding, uint64) {
+	n := len(members)
+	if n <= cfg.SetMaxIntsetEntries {
+		var lo, hi int64
+		ints := true
+		for i, m := range members {
+			v, ok := codec.ParseInt(m)
+			if !ok {
+				ints = false
+				break
+			}
+			if i == 0 || v < lo {
+				lo = v
+			}
+			if i == 0 || v > hi {
+				hi = v
+			}
+		}
+		if ints {
+			width := uint64(2)
+			switch {
+			case lo < -1<<31 || hi > 1<<31-1:
+				width = 8
+			case lo < -1<<15 || hi > 1<<15-1:
+				width = 4
+			}
+			return EncodingIntset, robjSize + mallocSize(8+width*uint64(n))
+		}
+	}
+	if n <= cfg.SetMaxListpackEntries && maxLen(members) <= cfg.SetMaxListpackValue {
+		return EncodingListpack, robjSize + mallocSize(listpackSize(members))
+	}
+	size := robjSize + hashtableSize(n)
+	for _, m := range members {
+		size += mallocSize(dictEntrySize) + sdsSize(len(m))
+	}
+	return EncodingHashtable, size
+}
+
+func zsetSize(members []ZSetMember, cfg EncodingConfig) (Encoding, uint64) {
+	n := len(members)
+	if n <= cfg.ZSetMaxListpackEntries {
+		fits := true
+		lp := uint64(listpackHeader)
+		for _, m := range members {
+			if len(m.Member) > cfg.ZSetMaxListpackValue {
+				fits = false
+				break
+			}
+			lp += listpackEntrySize(m.Member) + listpackEntrySize(scoreString(m.Score))
+		}
+		if fits {
+			return EncodingListpack, robjSize + mallocSize(lp)
+		}
+	}
+	// A dict from member to score plus a skiplist, whose nodes have 4/3
+	// levels on average; both share the member strings.
+	size := robjSize + mallocSize(zsetObjSize) + hashtableSize(n)
+	size += mallocSize(zslHeaderSize) + mallocSize(zslObjSize)
+	for _, m := range members {
+		size += mallocSize(dictEntrySize) + mallocSize(zslNodeSize) + sdsSize(len(m.Member))
+	}
+	return EncodingSkiplist, size
+}
+
+// scoreString formats a score the way Redis stores it in a listpack.
+func scoreString(f float64) []byte {
+	return strconv.AppendFloat(nil, f, 'g', -1, 64)
+}
+
+func hashSize(fields []HashField, cfg EncodingConfig) (Encoding, uint64) {
+	n := len(fields)
+	if n <= cfg.HashMaxListpackEntries {
+		fits := true
+		lp := uint64(listpackHeader)
+		for _, f := range fields {
+			if len(f.Field) > cfg.HashMaxListpackValue || len(f.Value) > cfg.HashMaxListpackValue {
+				fits = false
+				break
+			}
+			lp += listpackEntrySize(f.Field) + listpackEntrySize(f.Value)
+		}
+		if fits {
+			return EncodingListpack, robjSize + mallocSize(lp)
+		}
+	}
+	size := robjSize + hashtableSize(n)
+	for _, f := range fields {
+		size += mallocSize(dictEntrySize) + sdsSize(len(f.Field)) + sdsSize(len(f.Value))
+	}
+	return EncodingHashtable, size
+}
+
+func streamSize(s *StreamValue, cfg EncodingConfig) uint64 {
+	size := uint64(robjSize + streamObjSize + raxNodeSize)
+	var node uint64
+	count := 0
+	for i, e := range s.Entries {
+		// flags, ms and seq deltas, field count, lp-count and the fields;
+		// the first entry of a node also forms the master entry.
+		n := uint64(5)
+		for _, f := range e.Fields {
+			n += listpackEntrySize(f.Field) + listpackEntrySize(f.Value)
+		}
+		if i == 0 || node+n > uint64(cfg.StreamNodeMaxBytes) || count >= cfg.StreamNodeMaxEntries {
+			if i > 0 {
+				size += mallocSize(node) + raxNodeSize + 16
+			}
+			node, count = listpackHeader, 0
+		}
+		node += n
+		count++
+	}
+	if len(s.Entries) > 0 {
+		size += mallocSize(node) + raxNodeSize + 16
+	}
+	for _, g := range s.Groups {
+		size += mallocSize(cgroupSize) + sdsSize(len(g.Name)) + 2*raxNodeSize
+		size += uint64(len(g.Pending)) * (mallocSize(nackSize) + raxNodeSize + 16)
+		for _, c := range g.Consumers {
+			size += mallocSize(consumerSize) + sdsSize(len(c.Name)) + raxNodeSize
+			size += uint64(len(c.Pending)) * (raxNodeSize + 16)
+		}
+	}
+	return size
+}
+
+// listpackSize returns the size of a listpack holding elems.
+func listpackSize(elems []RedisString) uint64 {
+	size := uint64(listpackHeader)
+	for _, e := range elems {
+		size += listpackEntrySize(e)
+	}
+	return size
+}
+
+// listpackEntrySize returns the size of s as a listpack element, including
+// its back-length.
+func listpackEntrySize(s []byte) uint64 {
+	var n uint64
+	if v, ok := codec.ParseInt(s); ok {
+		switch {
+		case v >= 0 && v <= 127:
+			n = 1
+		case v >= -4096 && v <= 4095:
+			n = 2
+		case v >= -1<<15 && v < 1<<15:
+			n = 3
+		case v >= -1<<23 && v < 1<<23:
+			n = 4
+		case v >= -1<<31 && v < 1<<31:
+			n = 5
+		default:
+			n = 9
+		}
+	} else {
+		switch l := uint64(len(s)); {
+		case l < 1<<6:
+			n = 1 + l
+		case l < 1<<12:
+			n = 2 + l
+		default:
+			n = 5 + l
+		}
+	}
+	switch {
+	case n <= 127:
+		return n + 1
+	case n < 16383:
+		return n + 2
+	case n < 2097151:
+		return n + 3
+	case n < 268435455:
+		return n + 4
+	}
+	return n + 5
+}
+
+// hashtableSize returns the size of a dict holding n entries, excluding the
+// entries themselves.
+func hashtableSize(n int) uint64 {
+	buckets := uint64(4)
+	if n > 4 {
+		buckets = 1 << bits.Len64(uint64(n-1))
+	}
+	return mallocSize(dictSize) + mallocSize(buckets*8)
+}
+
+// sdsSize returns the allocation size of an sds string of length n.
+func sdsSize(n int) uint64 {
+	var hdr int
+	switch {
+	case n < 1<<5:
+		hdr = 1
+	case n < 1<<8:
+		hdr = 3
+	case n < 1<<16:
+		hdr = 5
+	case int64(n) < 1<<32:
+		hdr = 9
+	default:
+		hdr = 17
+	}
+	return mallocSize(uint64(hdr + n + 1))
+}
+
+func maxLen(s []RedisString) int {
+	m := 0
+	for _, v := range s {
+		if len(v) > m {
+			m = len(v)
+		}
+	}
+	return m
+}
+
+// mallocSize rounds n up to the jemalloc size class it is allocated from.
+func mallocSize(n uint64) uint64 {
+	switch {
+	case n == 0:
+		return 0
+	case n <= 8:
+		return 8
+	case n <= 128:
+		return (n + 15) &^ 15
+	}
+	// Four size classes per doubling above 128 bytes.
+	shift := uint(bits.Len64(n-1)) - 3
+	step := uint64(1) << shift
+	return (n + step - 1) &^ (step - 1)
+}
@@ -0,0 +1,223 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// moduleCharset is the alphabet of module type names, from Redis' module.c.
+const moduleCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// ModuleID identifies the module data type a module value was written by.
+// Redis packs the type's 9 character name into the upper 54 bits and the
+// encoding version into the lower 10.
+type ModuleID uint64
+
+// Name returns the module type name, such as "ReJSON-RL" or "MBbloom--".
+func (id ModuleID) Name() string {
+	var name [9]byte
+	for i := range name {
+		name[i] = moduleCharset[uint64(id)>>(64-6*uint(i+1))&63]
+	}
+	return string(name[:])
+}
+
+// Version returns the encoding version of the module type.
+func (id ModuleID) Version() int {
+	return int(id & 1023)
+}
+
+// String returns the name and version, as in "ReJSON-RL v3".
+func (id ModuleID) String() string {
+	return id.Name() + " v" + strconv.Itoa(id.Version())
+}
+
+// ModuleValue is the value of a module key the Reader cannot interpret: a
+// Module2 value of a module type no decoder is registered for. Raw holds
+// the value as stored after the module ID, a sequence of module opcodes
+// ending with an EOF opcode, so that a Writer can write it back unchanged.
+type ModuleValue struct {
+	ID  ModuleID
+	Raw []byte
+}
+
+func (*ModuleValue) Type() Type         { return TypeModule }
+func (*ModuleValue) Encoding() Encoding { return EncodingModule }
+
+// A DecoderFunc decodes the value of a module type from m, the way the
+// module's rdb_load callback does with the RedisModule_Load functions.
+type DecoderFunc func(m *ModuleReader) (Value, error)
+
+var moduleDecoders = registry[DecoderFunc]{what: "module decoder"}
+
+// RegisterModuleDecoder makes Readers decode the values of the module type
+// name, such as "ReJSON-RL", stored with encoding version encver, with fn.
+// Values of other versions, and of modules without a decoder, are returned
+// as ModuleValue. It panics if the type and version are already
+// registered.
+func RegisterModuleDecoder(name string, encver int, fn DecoderFunc) {
+	moduleDecoders.add(name+" v"+strconv.Itoa(encver), fn)
+}
+
+// ModuleReader reads the values a module saved in a Module2 value, in the
+// order it saved them.
+type ModuleReader struct {
+	ID  ModuleID
+	raw []byte
+}
+
+// next consumes the opcode of the next value, which must be op.
+func (m *ModuleReader) next(op uint64) error {
+	got, _, n, err := codec.DecodeLength(m.raw)
+	if err != nil {
+		return err
+	}
+	if got != op {
+		return fmt.Errorf("%w: module value holds opcode %d where %d was expected", ErrFormat, got, op)
+	}
+	m.raw = m.raw[n:]
+	return nil
+}
+
+// fixed consumes n bytes following an opcode.
+func (m *ModuleReader) fixed(n int) ([]byte, error) {
+	if len(m.raw) < n {
+		return nil, fmt.Errorf("%w: truncated module value", ErrFormat)
+	}
+	b := m.raw[:n]
+	m.raw = m.raw[n:]
+	return b, nil
+}
+
+// LoadUnsigned reads an unsigned integer, as RedisModule_LoadUnsigned.
+func (m *ModuleReader) LoadUnsigned() (uint64, error) {
+	if err := m.next(moduleOpUInt); err != nil {
+		return 0, err
+	}
+	v, _, n, err := codec.DecodeLength(m.raw)
+	if err != nil {
+		return 0, err
+	}
+	m.raw = m.raw[n:]
+	return v, nil
+}
+
+// LoadSigned reads a signed integer, as RedisModule_LoadSigned.
+func (m *ModuleReader) LoadSigned() (int64, error) {
+	if err := m.next(moduleOpSInt); err != nil {
+		return 0, err
+	}
+	v, _, n, err := codec.DecodeLength(m.raw)
+	if err != nil {
+		return 0, err
+	}
+	m.raw = m.raw[n:]
+	return int64(v), nil
+}
+
+// LoadDouble reads a double, as RedisModule_LoadDouble.
+func (m *ModuleReader) LoadDouble() (float64, error) {
+	if err := m.next(moduleOpDouble); err != nil {
+		return 0, err
+	}
+	b, err := m.fixed(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// LoadFloat reads a float, as RedisModule_LoadFloat.
+func (m *ModuleReader) LoadFloat() (float32, error) {
+	if err := m.next(moduleOpFloat); err != nil {
+		return 0, err
+	}
+	b, err := m.fixed(4)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+}
+
+// LoadString reads a string, as RedisModule_LoadStringBuffer. The string
+// may share memory with the dump data.
+func (m *ModuleReader) LoadString() ([]byte, error) {
+	if err := m.next(moduleOpString); err != nil {
+		return nil, err
+	}
+	s, n, err := codec.DecodeString(m.raw)
+	if err != nil {
+		return nil, err
+	}
+	m.raw = m.raw[n:]
+	return s, nil
+}
+
+// decodeModule decodes raw, a Module2 value after its module ID, with fn,
+// which must read every value up to the EOF opcode.
+func decodeModule(fn DecoderFunc, id ModuleID, raw []byte) (Value, error) {
+	m := &ModuleReader{ID: id, raw: raw}
+	v, err := fn(m)
+	if err != nil {
+		return nil, fmt.Errorf("rdb: decoding %v: %w", id, err)
+	}
+	if err := m.next(moduleOpEOF); err != nil {
+		return nil, fmt.Errorf("rdb: decoding %v: %w", id, err)
+	}
+	return v, nil
+}
+
+// ModuleAux is the auxiliary data a module saves with its aux_save
+// callback, once for the whole dump rather than per key.
+type ModuleAux struct {
+	ID ModuleID
+
+	// When tells whether the data was saved before the keys (1,
+	// REDISMODULE_AUX_BEFORE_RDB) or after them (2,
+	// REDISMODULE_AUX_AFTER_RDB).
+	When uint64
+
+	// Raw holds the data as stored, a sequence of module opcodes ending
+	// with an EOF opcode.
+	Raw []byte
+}
+
+// Reader returns a ModuleReader over the data.
+func (a ModuleAux) Reader() *ModuleReader {
+	return &ModuleReader{ID: a.ID, raw: a.Raw}
+}
+
+// ModuleAux returns the module auxiliary data read so far, in file order.
+// Data saved before the keys is complete after the first call to
+// ReadEntry, data saved after them once it has returned io.EOF.
+func (r *Reader) ModuleAux() []ModuleAux {
+	return r.moduleAux
+}
+
+// readModuleAux reads the payload of a module auxiliary data opcode: the
+// module ID, the time of saving as an unsigned module value, and the data.
+func (r *Reader) readModuleAux() (ModuleAux, error) {
+	id, err := r.readLength()
+	if err != nil {
+		return ModuleAux{}, err
+	}
+	a := ModuleAux{ID: ModuleID(id)}
+	op, err := r.readLength()
+	if err != nil {
+		return a, err
+	}
+	if op != moduleOpUInt {
+		return a, fmt.Errorf("%w: module aux of %v with opcode %d", ErrFormat, a.ID, op)
+	}
+	if a.When, err = r.readLength(); err != nil {
+		return a, err
+	}
+	m := r.in.mark()
+	err = r.skipModule2()
+	a.Raw = r.in.since(m)
+	return a, err
+}
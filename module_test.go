@@ -0,0 +1,156 @@
+package rdb_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+// modulePayload is a Module2 value holding an unsigned and a signed
+// integer, a double, a float and a string, followed by the EOF opcode.
+func modulePayload() []byte {
+	b := codec.AppendLength(nil, 2)
+	b = codec.AppendLength(b, 7)
+	b = codec.AppendLength(b, 1)
+	b = codec.AppendLength(b, uint64(math.MaxUint64-2)) // -3
+	b = codec.AppendLength(b, 4)
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(2.5))
+	b = codec.AppendLength(b, 3)
+	b = binary.LittleEndian.AppendUint32(b, math.Float32bits(0.5))
+	b = codec.AppendLength(b, 5)
+	b = codec.AppendString(b, []byte("doc"), false)
+	return codec.AppendLength(b, 0)
+}
+
+func TestModuleID(t *testing.T) {
+	id := moduleID("ReJSON-RL", 3)
+	if id.Name() != "ReJSON-RL" || id.Version() != 3 || id.String() != "ReJSON-RL v3" {
+		t.Errorf("got %q, %d, %q", id.Name(), id.Version(), id.String())
+	}
+}
+
+func TestModuleSkip(t *testing.T) {
+	mod := &rdb.ModuleValue{ID: moduleID("Unknown-1", 2), Raw: modulePayload()}
+	dump := rdbtest.Dump(t, 11,
+		&rdb.Entry{Key: rdb.RedisString("m"), Value: mod},
+		str(0, "after", "v", 0),
+	)
+
+	// Without a decoder the value comes back opaque, and writes back
+	// byte for byte.
+	got := rdbtest.LoadBytes(t, dump)
+	if len(got) != 2 || string(got[1].Key) != "after" {
+		t.Fatalf("got %+v", got)
+	}
+	if !reflect.DeepEqual(got[0].Value, mod) || got[0].Type() != rdb.TypeModule {
+		t.Errorf("got %+v, want %+v", got[0].Value, mod)
+	}
+	if again := rdbtest.Dump(t, 11, got...); !bytes.Equal(again, dump) {
+		t.Error("the module value does not write back unchanged")
+	}
+
+	// WithSkipUnsupported steps over it instead.
+	r, err := rdb.NewReader(bytes.NewReader(dump), rdb.WithSkipUnsupported())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	e, err := r.ReadEntry()
+	if err != nil || string(e.Key) != "after" {
+		t.Fatalf("got %+v, %v", e, err)
+	}
+	if s := r.Skipped(); len(s) != 1 || string(s[0].Key) != "m" || s[0].Module != mod.ID {
+		t.Errorf("got skipped %+v", s)
+	}
+
+	// A bad opcode, or a payload cut short, is a format error.
+	for _, raw := range [][]byte{{6, 0}, modulePayload()[:5]} {
+		bad := rdbtest.Dump(t, 11, &rdb.Entry{Key: rdb.RedisString("m"), Value: &rdb.ModuleValue{ID: mod.ID, Raw: raw}})
+		if err := readToEnd(bad); !errors.Is(err, rdb.ErrFormat) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%x: got %v", raw, err)
+		}
+	}
+}
+
+// testModule is the value decoded from modulePayload.
+type testModule struct {
+	U   uint64
+	S   int64
+	D   float64
+	F   float32
+	Doc string
+}
+
+func (testModule) Type() rdb.Type         { return rdb.TypeModule }
+func (testModule) Encoding() rdb.Encoding { return rdb.EncodingModule }
+
+func init() {
+	rdb.RegisterModuleDecoder("TestModul", 2, func(m *rdb.ModuleReader) (rdb.Value, error) {
+		var v testModule
+		var err error
+		if v.U, err = m.LoadUnsigned(); err != nil {
+			return nil, err
+		}
+		if v.S, err = m.LoadSigned(); err != nil {
+			return nil, err
+		}
+		if v.D, err = m.LoadDouble(); err != nil {
+			return nil, err
+		}
+		if v.F, err = m.LoadFloat(); err != nil {
+			return nil, err
+		}
+		doc, err := m.LoadString()
+		v.Doc = string(doc)
+		return v, err
+	})
+	// TestShort reads less than the module saved.
+	rdb.RegisterModuleDecoder("TestShort", 2, func(m *rdb.ModuleReader) (rdb.Value, error) {
+		n, err := m.LoadUnsigned()
+		return testModule{U: n}, err
+	})
+	// TestWrong expects a string where the module saved an integer.
+	rdb.RegisterModuleDecoder("TestWrong", 2, func(m *rdb.ModuleReader) (rdb.Value, error) {
+		_, err := m.LoadString()
+		return testModule{}, err
+	})
+}
+
+func TestModuleDecoder(t *testing.T) {
+	load := func(id rdb.ModuleID) (rdb.Value, error) {
+		dump := rdbtest.Dump(t, 11, &rdb.Entry{Key: rdb.RedisString("m"), Value: &rdb.ModuleValue{ID: id, Raw: modulePayload()}})
+		r, err := rdb.NewReader(bytes.NewReader(dump))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		e, err := r.ReadEntry()
+		if err != nil {
+			return nil, err
+		}
+		return e.Value, nil
+	}
+
+	v, err := load(moduleID("TestModul", 2))
+	if want := (testModule{U: 7, S: -3, D: 2.5, F: 0.5, Doc: "doc"}); err != nil || v != want {
+		t.Errorf("got %+v, %v, want %+v", v, err, want)
+	}
+	// Decoders are registered per encoding version.
+	v, err = load(moduleID("TestModul", 3))
+	if m, ok := v.(*rdb.ModuleValue); err != nil || !ok || m.ID.Version() != 3 {
+		t.Errorf("version 3: got %+v, %v", v, err)
+	}
+	for _, name := range []string{"TestShort", "TestWrong"} {
+		if _, err := load(moduleID(name, 2)); !errors.Is(err, rdb.ErrFormat) {
+			t.Errorf("%s: got %v, want ErrFormat", name, err)
+		}
+	}
+}
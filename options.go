@@ -0,0 +1,39 @@
+package rdb
+
+// An Option configures a Reader.
+type Option func(*Reader)
+
+// OnOpcode registers fn to be called for every top-level opcode the Reader
+// consumes: auxiliary fields, function libraries, module auxiliary data,
+// database selection, resize hints, slot information, expiry times, IDLE
+// and FREQ eviction hints, the end of file marker and any fork specific
+// opcodes accepted by WithCompat. Key records are not reported. offset is
+// the position of the opcode byte in the stream and payload holds the
+// bytes that followed it, exactly as stored; fn may retain it.
+func OnOpcode(fn func(op byte, offset int64, payload []byte)) Option {
+	return func(r *Reader) {
+		r.onOpcode = fn
+	}
+}
+
+// WithRaw makes the Reader keep the bytes every entry was read from, in
+// Entry.Raw, and those following the last key, in Reader.Trailer. Passing
+// the entries of a dump unmodified through a Writer of the same version,
+// or using Copy, then reproduces the dump byte for byte.
+func WithRaw() Option {
+	return func(r *Reader) {
+		r.raw = true
+	}
+}
+
+// WithStrict makes the Reader check every ziplist and listpack blob as
+// Redis' deep sanitization does, see ValidateZiplist and ValidateListpack,
+// including those of values it steps over rather than decoding: for
+// WithKeysOnly, ReadRecord, WithSkipUnsupported and registered decoders,
+// which get the value serialized. Decoded blobs are always checked. A
+// corrupt blob fails the read with ErrFormat.
+func WithStrict() Option {
+	return func(r *Reader) {
+		r.strict = true
+	}
+}
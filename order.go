@@ -0,0 +1,149 @@
+package rdb
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+)
+
+// Compare returns -1, 0 or +1 depending on whether s sorts before, equal
+// to or after o byte-wise, the order Redis uses for ZRANGEBYLEX and SORT
+// ALPHA without a locale: bytes compare as unsigned values and a prefix
+// sorts first.
+func (s RedisString) Compare(o RedisString) int {
+	return bytes.Compare(s, o)
+}
+
+// CompareEntries orders entries by database, then byte-wise by key.
+func CompareEntries(a, b *Entry) int {
+	switch {
+	case a.DB < b.DB:
+		return -1
+	case a.DB > b.DB:
+		return 1
+	}
+	return bytes.Compare(a.Key, b.Key)
+}
+
+// SortEntries sorts entries by database, then byte-wise by key.
+func SortEntries(entries []*Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return CompareEntries(entries[i], entries[j]) < 0
+	})
+}
+
+// Collation selects an order for listing keys and members to people.
+// Only CollateBytes matches Redis; the others are for display.
+type Collation uint8
+
+const (
+	// CollateBytes orders byte-wise, as Redis does.
+	CollateBytes Collation = iota
+
+	// CollateFold orders byte-wise ignoring ASCII case, falling back to
+	// byte order for strings that differ only in case.
+	CollateFold
+
+	// CollateNatural orders runs of ASCII digits by their numeric value,
+	// so that "user:2" sorts before "user:10", and everything else
+	// byte-wise.
+	CollateNatural
+)
+
+var collationNames = [...]string{"bytes", "fold", "natural"}
+
+func (c Collation) String() string {
+	if int(c) < len(collationNames) {
+		return collationNames[c]
+	}
+	return "Collation(" + strconv.Itoa(int(c)) + ")"
+}
+
+// Compare returns -1, 0 or +1 depending on whether a sorts before, equal
+// to or after b under c.
+func (c Collation) Compare(a, b []byte) int {
+	switch c {
+	case CollateFold:
+		if n := compareFold(a, b); n != 0 {
+			return n
+		}
+	case CollateNatural:
+		if n := compareNatural(a, b); n != 0 {
+			return n
+		}
+	}
+	return bytes.Compare(a, b)
+}
+
+// Sort sorts s under c.
+func (c Collation) Sort(s []RedisString) {
+	sort.SliceStable(s, func(i, j int) bool {
+		return c.Compare(s[i], s[j]) < 0
+	})
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func compareFold(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if x, y := lower(a[i]), lower(b[i]); x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return cmpInt(len(a), len(b))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func compareNatural(a, b []byte) int {
+	for len(a) > 0 && len(b) > 0 {
+		if !isDigit(a[0]) || !isDigit(b[0]) {
+			if a[0] != b[0] {
+				if a[0] < b[0] {
+					return -1
+				}
+				return 1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		i, j := 0, 0
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		// Compare the numbers without leading zeros by length, then
+		// digit by digit; equal numbers fall back to byte order later.
+		x, y := bytes.TrimLeft(a[:i], "0"), bytes.TrimLeft(b[:j], "0")
+		if n := cmpInt(len(x), len(y)); n != 0 {
+			return n
+		}
+		if n := bytes.Compare(x, y); n != 0 {
+			return n
+		}
+		a, b = a[i:], b[j:]
+	}
+	return cmpInt(len(a), len(b))
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
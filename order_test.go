@@ -0,0 +1,80 @@
+package rdb_test
+
+import (
+	"reflect"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestCollation(t *testing.T) {
+	tests := []struct {
+		a, b                 string
+		bytes, fold, natural int
+	}{
+		{"a", "a", 0, 0, 0},
+		{"a", "ab", -1, -1, -1},
+		{"B", "a", -1, 1, -1},
+		{"A", "a", -1, -1, -1}, // equal ignoring case: byte order
+		{"\xff", "a", 1, 1, 1}, // bytes are unsigned
+		{"\x00", "", 1, 1, 1},
+		{"user:2", "user:10", 1, 1, -1},
+		{"user:02", "user:2", -1, -1, -1}, // equal numbers: byte order
+		{"v1.10", "v1.9", -1, -1, 1},
+		{"x99999999999999999999", "x100000000000000000000", 1, 1, -1},
+		{"a1b", "a1", 1, 1, 1},
+	}
+	for _, tt := range tests {
+		a, b := rdb.RedisString(tt.a), rdb.RedisString(tt.b)
+		for _, c := range []struct {
+			coll rdb.Collation
+			want int
+		}{{rdb.CollateBytes, tt.bytes}, {rdb.CollateFold, tt.fold}, {rdb.CollateNatural, tt.natural}} {
+			if got := c.coll.Compare(a, b); got != c.want {
+				t.Errorf("%v.Compare(%q, %q) = %d, want %d", c.coll, tt.a, tt.b, got, c.want)
+			}
+			if got := c.coll.Compare(b, a); got != -c.want {
+				t.Errorf("%v.Compare(%q, %q) = %d, want %d", c.coll, tt.b, tt.a, got, -c.want)
+			}
+		}
+		if got := a.Compare(b); got != tt.bytes {
+			t.Errorf("RedisString.Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.bytes)
+		}
+	}
+
+	keys := strs("user:10", "User:3", "user:2", "user:1")
+	rdb.CollateNatural.Sort(keys)
+	if got := goStrings(keys); !reflect.DeepEqual(got, []string{"User:3", "user:1", "user:2", "user:10"}) {
+		t.Errorf("natural: got %q", got)
+	}
+	rdb.CollateFold.Sort(keys)
+	if got := goStrings(keys); !reflect.DeepEqual(got, []string{"user:1", "user:10", "user:2", "User:3"}) {
+		t.Errorf("fold: got %q", got)
+	}
+	if s := rdb.Collation(5).String(); s != "Collation(5)" {
+		t.Errorf("got %q", s)
+	}
+}
+
+func goStrings(s []rdb.RedisString) []string {
+	out := make([]string, len(s))
+	for i, x := range s {
+		out[i] = string(x)
+	}
+	return out
+}
+
+func TestSortEntries(t *testing.T) {
+	entries := []*rdb.Entry{str(1, "a", "", 0), str(0, "b", "", 0), str(0, "\xff", "", 0), str(0, "B", "", 0), str(0, "a", "", 0)}
+	rdb.SortEntries(entries)
+	var got []string
+	for _, e := range entries {
+		got = append(got, string(rune('0'+e.DB))+string(e.Key))
+	}
+	if want := []string{"0B", "0a", "0b", "0\xff", "1a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if rdb.CompareEntries(entries[0], entries[0]) != 0 || rdb.CompareEntries(entries[4], entries[0]) != 1 {
+		t.Error("CompareEntries disagrees with the sort")
+	}
+}
@@ -0,0 +1,298 @@
+package rdb
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// A Source provides the bytes of a dump, wherever they come from, so that
+// tools built on Pipeline do not depend on it.
+type Source interface {
+	// Open starts reading the dump. The caller closes the returned
+	// reader.
+	Open(ctx context.Context) (io.ReadCloser, SourceInfo, error)
+}
+
+// SourceInfo describes where a dump comes from.
+type SourceInfo struct {
+	Origin string // file path, URL or server address
+	Size   int64  // length of the dump in bytes, -1 if unknown
+}
+
+// FileSource reads the dump at path.
+func FileSource(path string) Source {
+	return fileSource(path)
+}
+
+type fileSource string
+
+func (path fileSource) Open(ctx context.Context) (io.ReadCloser, SourceInfo, error) {
+	info := SourceInfo{Origin: string(path), Size: -1}
+	f, err := os.Open(string(path))
+	if err != nil {
+		return nil, info, err
+	}
+	if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+		info.Size = fi.Size()
+	}
+	return f, info, nil
+}
+
+// ReaderSource reads the dump from r, of the given size or -1 if unknown.
+// It does not close r.
+func ReaderSource(r io.Reader, size int64) Source {
+	return &readerSource{r: r, size: size}
+}
+
+type readerSource struct {
+	r    io.Reader
+	size int64
+}
+
+func (s *readerSource) Open(ctx context.Context) (io.ReadCloser, SourceInfo, error) {
+	return io.NopCloser(s.r), SourceInfo{Origin: "reader", Size: s.size}, nil
+}
+
+// URLSource downloads the dump at url with an HTTP GET request.
+func URLSource(url string) Source {
+	return urlSource(url)
+}
+
+type urlSource string
+
+func (url urlSource) Open(ctx context.Context) (io.ReadCloser, SourceInfo, error) {
+	info := SourceInfo{Origin: string(url), Size: -1}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, string(url), nil)
+	if err != nil {
+		return nil, info, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, info, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, info, fmt.Errorf("rdb: fetching %s: %s", url, resp.Status)
+	}
+	info.Size = resp.ContentLength
+	return resp.Body, info, nil
+}
+
+// A Stage processes the entries flowing through a Pipeline. It returns the
+// entry to pass on, which may be e modified in place, or nil to drop it.
+// Stages that modify an entry must clear its Raw field, or a Writer would
+// write the original bytes.
+type Stage func(e *Entry) (*Entry, error)
+
+// Filter returns a Stage keeping the entries for which keep returns true,
+// such as those matched by ExpiringBetween.
+func Filter(keep func(e *Entry) bool) Stage {
+	return func(e *Entry) (*Entry, error) {
+		if keep(e) {
+			return e, nil
+		}
+		return nil, nil
+	}
+}
+
+// RenameKeys returns a Stage replacing every key by rename(key).
+func RenameKeys(rename func(key RedisString) RedisString) Stage {
+	return func(e *Entry) (*Entry, error) {
+		e.Key = rename(e.Key)
+		e.Raw = nil
+		return e, nil
+	}
+}
+
+// Anonymize returns a Stage that hides the data of a dump while keeping
+// its shape, for sharing production snapshots. Key segments, as split by
+// KeyNameStats' default separators, are replaced by a keyed hash unless
+// they are numeric, so that key patterns survive. Values, members and
+// stream field values are replaced by strings of the same length, or of
+// 16 bytes for shorter ones so that distinct inputs keep distinct outputs;
+// hash fields, stream field names, scores and all metadata are kept.
+// Equal inputs give equal outputs under the same secret, so cardinalities
+// are preserved too. Entries without a value, as read with WithKeysOnly,
+// only have their key replaced.
+func Anonymize(secret []byte) Stage {
+	a := &anonymizer{secret: secret}
+	return func(e *Entry) (*Entry, error) {
+		e.Key = a.key(e.Key)
+		switch v := e.Value.(type) {
+		case nil:
+		case StringValue:
+			e.Value = StringValue(a.mask(RedisString(v)))
+		case ListValue, SetValue:
+			elems, _ := stringsOf(v)
+			for i := range elems {
+				elems[i] = a.mask(elems[i])
+			}
+		case ZSetValue:
+			for i := range v.Members {
+				v.Members[i].Member = a.mask(v.Members[i].Member)
+			}
+		case HashValue:
+			for i := range v.Fields {
+				v.Fields[i].Value = a.mask(v.Fields[i].Value)
+			}
+		case *StreamValue:
+			for i := range v.Entries {
+				for j := range v.Entries[i].Fields {
+					f := &v.Entries[i].Fields[j]
+					f.Value = a.mask(f.Value)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("%w: anonymizing %v for key %q", ErrNotSupported, e.ValueType, e.Key)
+		}
+		e.Aux = nil
+		e.Raw = nil
+		return e, nil
+	}
+}
+
+type anonymizer struct {
+	secret []byte
+}
+
+func (a *anonymizer) sum(s []byte) []byte {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write(s)
+	return h.Sum(nil)
+}
+
+func (a *anonymizer) key(key RedisString) RedisString {
+	const seps = ":./-_|"
+	var out RedisString
+	start := 0
+	for i := 0; i <= len(key); i++ {
+		if i < len(key) && !isSeparator(key[i], seps) {
+			continue
+		}
+		if seg := key[start:i]; isNumeric(seg) {
+			out = append(out, seg...)
+		} else {
+			out = hex.AppendEncode(out, a.sum(seg)[:6])
+		}
+		if i < len(key) {
+			out = append(out, key[i])
+		}
+		start = i + 1
+	}
+	return out
+}
+
+// minMask is the length of the shortest mask: 64 bits of the hash, so
+// that short inputs do not collide.
+const minMask = 16
+
+// mask returns a string of the same length as s, and at least minMask
+// bytes long, derived from its hash.
+func (a *anonymizer) mask(s RedisString) RedisString {
+	n := max(len(s), minMask)
+	out := make(RedisString, 0, n)
+	block := a.sum(s)
+	for len(out) < n {
+		out = hex.AppendEncode(out, block)
+		block = a.sum(block)
+	}
+	return out[:n]
+}
+
+func isSeparator(c byte, seps string) bool {
+	for i := 0; i < len(seps); i++ {
+		if seps[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Pipeline reads a dump from a Source, passes every entry through its
+// Stages in order and hands the surviving entries to all of its Sinks, in
+// a single pass:
+//
+//	p := rdb.NewPipeline(rdb.FileSource("dump.rdb")).
+//		Then(rdb.Filter(isSession), rdb.Anonymize(secret)).
+//		To(jsonExporter, rdb.Collector(stats))
+//	err := p.Run(ctx)
+type Pipeline struct {
+	Source  Source
+	Options []Option // for the Reader
+	Stages  []Stage
+	Sinks   []Sink
+
+	// Workers decodes on that many goroutines, see DecodeParallel; 0 or
+	// 1 decodes on the goroutine calling Run.
+	Workers int
+}
+
+// NewPipeline returns a Pipeline reading from src.
+func NewPipeline(src Source, opts ...Option) *Pipeline {
+	return &Pipeline{Source: src, Options: opts}
+}
+
+// Then appends stages to p and returns p.
+func (p *Pipeline) Then(stages ...Stage) *Pipeline {
+	p.Stages = append(p.Stages, stages...)
+	return p
+}
+
+// To appends sinks to p and returns p.
+func (p *Pipeline) To(sinks ...Sink) *Pipeline {
+	p.Sinks = append(p.Sinks, sinks...)
+	return p
+}
+
+// Run runs the pipeline until the end of the dump, the first error or the
+// cancellation of ctx. Like FanOut, it ends the sinks on success and
+// leaves them to the caller on error.
+func (p *Pipeline) Run(ctx context.Context) error {
+	rc, info, err := p.Source.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	r, err := NewReader(rc, p.Options...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	d := &sinkDriver{src: r, sinks: p.Sinks, origin: info}
+	process := func(e *Entry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, stage := range p.Stages {
+			var err error
+			if e, err = stage(e); err != nil || e == nil {
+				return err
+			}
+		}
+		return d.entry(e)
+	}
+	if p.Workers > 1 {
+		if err := DecodeParallel(r, p.Workers, process); err != nil {
+			return err
+		}
+		return d.end()
+	}
+	for {
+		e, err := r.ReadEntry()
+		if err == io.EOF {
+			return d.end()
+		}
+		if err != nil {
+			return err
+		}
+		if err := process(e); err != nil {
+			return err
+		}
+	}
+}
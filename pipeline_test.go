@@ -0,0 +1,103 @@
+package rdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestAnonymize(t *testing.T) {
+	anonymize := rdb.Anonymize([]byte("secret"))
+	var members []rdb.RedisString
+	for c := byte(0); c < 255; c++ {
+		members = append(members, rdb.RedisString{c})
+	}
+	e, err := anonymize(&rdb.Entry{Key: rdb.RedisString("set:1"), ValueType: rdb.Set, Value: rdb.SetValue{Members: members}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, m := range e.Value.(rdb.SetValue).Members {
+		if seen[string(m)] {
+			t.Fatalf("members collide on %q", m)
+		}
+		seen[string(m)] = true
+	}
+	if string(e.Key[len(e.Key)-2:]) != ":1" {
+		t.Errorf("key %q lost its numeric segment", e.Key)
+	}
+
+	long := rdb.RedisString("a value longer than the shortest mask")
+	e, err = anonymize(&rdb.Entry{Key: rdb.RedisString("k"), Value: rdb.StringValue(long)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := e.Value.(rdb.StringValue); len(v) != len(long) || string(v) == string(long) {
+		t.Errorf("got %q for %q", v, long)
+	}
+
+	// Entries read with WithKeysOnly have no value.
+	e, err = anonymize(&rdb.Entry{Key: rdb.RedisString("k"), ValueType: rdb.List})
+	if err != nil || e.Value != nil {
+		t.Errorf("got %v, %v for an entry without a value", e, err)
+	}
+}
+
+// TestReadersClosed checks that the functions creating a Reader close it,
+// stopping its prefetching goroutine, when they stop before the end of the
+// dump.
+func TestReadersClosed(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		w.WriteEntry(&rdb.Entry{Key: rdb.RedisString("key:" + strconv.Itoa(i)), Value: rdb.StringValue("value")})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dump := buf.Bytes()
+	// A string length running past the end of the dump, early on.
+	corrupt := append([]byte(nil), dump...)
+	copy(corrupt[100:], []byte{0x80, 0x7f, 0xff, 0xff, 0xff})
+	errStop := errors.New("stop")
+	prefetch := rdb.WithPrefetch(1024)
+
+	runs := map[string]func() error{
+		"Pipeline": func() error {
+			p := rdb.NewPipeline(rdb.ReaderSource(bytes.NewReader(dump), -1), prefetch).
+				To(rdb.SinkFunc(func(*rdb.Entry) error { return errStop }))
+			return p.Run(context.Background())
+		},
+		"DryRun": func() error {
+			_, err := rdb.DryRun(bytes.NewReader(corrupt), prefetch)
+			return err
+		},
+		"Loader": func() error {
+			_, err := rdb.Load(bytes.NewReader(corrupt), prefetch, rdb.WithVerifyChecksum())
+			return err
+		},
+	}
+	for name, run := range runs {
+		t.Run(name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			if err := run(); err == nil {
+				t.Fatal("no error")
+			}
+			for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; {
+				if time.Now().After(deadline) {
+					t.Fatalf("%d goroutines left running", runtime.NumGoroutine()-before)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package rdb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A Policy states rules the keys of a keyspace must follow, so that
+// platform teams can check the hygiene of the data their users store from
+// backups. Policies can be loaded from JSON configuration files.
+type Policy struct {
+	Name string
+
+	// Filter selects the keys the policy applies to, in the language of
+	// ParseFilter; "" selects all keys.
+	Filter string `json:",omitempty"`
+
+	// MaxSize bounds the estimated memory of a key; 0 means no limit.
+	MaxSize uint64 `json:",omitempty"`
+
+	// MaxElements bounds the number of elements of collections; 0 means
+	// no limit.
+	MaxElements uint64 `json:",omitempty"`
+
+	// RequireTTL requires keys to have an expiry.
+	RequireTTL bool `json:",omitempty"`
+
+	// ForbiddenKeys lists glob patterns (see MatchGlob) no key may match.
+	ForbiddenKeys []string `json:",omitempty"`
+}
+
+// Policy rules, as reported in PolicyViolation.Rule.
+const (
+	RuleMaxSize      = "max-size"
+	RuleMaxElements  = "max-elements"
+	RuleRequireTTL   = "require-ttl"
+	RuleForbiddenKey = "forbidden-key"
+)
+
+// PolicyViolation is a key breaking a rule of a policy.
+type PolicyViolation struct {
+	Policy string
+	Rule   string
+	DB     uint64
+	Key    RedisString
+	Type   Type
+
+	// Limit and Actual are the bound and the measured value for the size
+	// and element rules; Detail is the matching pattern for forbidden keys.
+	Limit  uint64 `json:",omitempty"`
+	Actual uint64 `json:",omitempty"`
+	Detail string `json:",omitempty"`
+}
+
+// PolicyCheck checks keys against policies. Feed it every entry with Add,
+// then call Report.
+type PolicyCheck struct {
+	Config EncodingConfig
+
+	// MaxViolations bounds the violations kept for the report; further
+	// ones are only counted. 0 means 1000.
+	MaxViolations int
+
+	policies []Policy
+	filters  []func(*Entry) bool
+	keys     uint64
+	violated uint64
+	counts   map[[2]string]uint64 // by policy and rule
+	list     []PolicyViolation
+}
+
+// PolicyReport is the result of PolicyCheck.
+type PolicyReport struct {
+	Keys     uint64 // keys checked
+	Violated uint64 // keys breaking at least one rule
+
+	// Counts holds the number of violations of every rule of every policy
+	// that was broken, in policy order.
+	Counts []PolicyCount
+
+	// Violations lists violations in the order they were found, up to
+	// PolicyCheck.MaxViolations; Truncated reports whether some were left
+	// out.
+	Violations []PolicyViolation
+	Truncated  bool
+}
+
+// PolicyCount counts the violations of a rule of a policy.
+type PolicyCount struct {
+	Policy string
+	Rule   string
+	Keys   uint64
+}
+
+// NewPolicyCheck returns a PolicyCheck for policies, estimating memory
+// with cfg. Filters relative to the current time, such as ttl<1h, are
+// evaluated against now.
+func NewPolicyCheck(cfg EncodingConfig, now time.Time, policies ...Policy) (*PolicyCheck, error) {
+	c := &PolicyCheck{Config: cfg, policies: policies, counts: make(map[[2]string]uint64)}
+	for _, p := range policies {
+		var match func(*Entry) bool
+		if p.Filter != "" {
+			var err error
+			if match, err = ParseFilter(p.Filter, now); err != nil {
+				return nil, fmt.Errorf("rdb: policy %s: %w", p.Name, err)
+			}
+		}
+		c.filters = append(c.filters, match)
+	}
+	return c, nil
+}
+
+// Add checks a single entry.
+func (c *PolicyCheck) Add(e *Entry) {
+	c.keys++
+	var size uint64
+	sized := false
+	broken := false
+	report := func(p *Policy, v PolicyViolation) {
+		broken = true
+		v.Policy, v.DB, v.Key, v.Type = p.Name, e.DB, e.Key, e.Type()
+		c.counts[[2]string{p.Name, v.Rule}]++
+		limit := c.MaxViolations
+		if limit <= 0 {
+			limit = 1000
+		}
+		if len(c.list) < limit {
+			c.list = append(c.list, v)
+		}
+	}
+	for i := range c.policies {
+		p := &c.policies[i]
+		if m := c.filters[i]; m != nil && !m(e) {
+			continue
+		}
+		if p.MaxSize > 0 {
+			if !sized {
+				_, size = EstimateMemory(e, c.Config)
+				sized = true
+			}
+			if size > p.MaxSize {
+				report(p, PolicyViolation{Rule: RuleMaxSize, Limit: p.MaxSize, Actual: size})
+			}
+		}
+		if p.MaxElements > 0 && e.Type() != TypeString {
+			if n := uint64(valueLength(e.Value)); n > p.MaxElements {
+				report(p, PolicyViolation{Rule: RuleMaxElements, Limit: p.MaxElements, Actual: n})
+			}
+		}
+		if p.RequireTTL && !e.HasExpiry() {
+			report(p, PolicyViolation{Rule: RuleRequireTTL})
+		}
+		for _, pattern := range p.ForbiddenKeys {
+			if MatchGlob(pattern, e.Key) {
+				report(p, PolicyViolation{Rule: RuleForbiddenKey, Detail: pattern})
+				break
+			}
+		}
+	}
+	if broken {
+		c.violated++
+	}
+}
+
+// Report returns the violations found so far.
+func (c *PolicyCheck) Report() PolicyReport {
+	rep := PolicyReport{Keys: c.keys, Violated: c.violated, Violations: c.list}
+	var total uint64
+	for k, n := range c.counts {
+		rep.Counts = append(rep.Counts, PolicyCount{Policy: k[0], Rule: k[1], Keys: n})
+		total += n
+	}
+	rep.Truncated = total > uint64(len(c.list))
+	order := make(map[string]int, len(c.policies))
+	for i := len(c.policies) - 1; i >= 0; i-- {
+		order[c.policies[i].Name] = i
+	}
+	sort.Slice(rep.Counts, func(i, j int) bool {
+		a, b := rep.Counts[i], rep.Counts[j]
+		if order[a.Policy] != order[b.Policy] {
+			return order[a.Policy] < order[b.Policy]
+		}
+		return a.Rule < b.Rule
+	})
+	return rep
+}
+
+// Tables implements Tabular.
+func (r PolicyReport) Tables() []Table {
+	counts := Table{Title: "Policy violations", Columns: []string{"Policy", "Rule", "Keys"}}
+	for _, c := range r.Counts {
+		counts.Rows = append(counts.Rows, []string{c.Policy, c.Rule, formatCount(c.Keys)})
+	}
+	title := "Violating keys"
+	if r.Truncated {
+		title += " (first " + strconv.Itoa(len(r.Violations)) + ")"
+	}
+	list := Table{Title: title, Columns: []string{"Policy", "Rule", "DB", "Key", "Type", "Limit", "Actual"}}
+	for _, v := range r.Violations {
+		limit, actual := "", ""
+		switch v.Rule {
+		case RuleMaxSize:
+			limit, actual = FormatBytes(v.Limit), FormatBytes(v.Actual)
+		case RuleMaxElements:
+			limit, actual = formatCount(v.Limit), formatCount(v.Actual)
+		case RuleForbiddenKey:
+			limit = v.Detail
+		}
+		list.Rows = append(list.Rows, []string{v.Policy, v.Rule, formatCount(v.DB), formatKey(v.Key), v.Type.String(), limit, actual})
+	}
+	return []Table{counts, list}
+}
@@ -0,0 +1,122 @@
+package rdb
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// prefetchChunk is the largest read issued by the prefetcher.
+const prefetchChunk = 256 << 10
+
+// WithPrefetch makes the Reader read up to window bytes ahead of the
+// decoder in a separate goroutine, so that a slow or distant source, such
+// as an object store, an HTTP download or a replication stream, keeps
+// delivering while values are decoded. It pays off when both reading and
+// decoding take time; for local files the operating system already reads
+// ahead. The Reader must be closed with Close unless it was read to the
+// end.
+func WithPrefetch(window int) Option {
+	return func(r *Reader) {
+		r.prefetchWindow = window
+	}
+}
+
+// startPrefetch wraps src in a prefetcher if WithPrefetch is in effect.
+func (r *Reader) startPrefetch(src io.Reader) {
+	if r.prefetchWindow > 0 {
+		r.prefetch = newPrefetcher(src, r.prefetchWindow)
+		r.in.r.Reset(r.prefetch)
+	}
+}
+
+// Close stops the goroutine started by WithPrefetch. It does not close the
+// underlying reader, nor interrupt a read from it in progress. Close does
+// nothing for Readers without prefetching.
+func (r *Reader) Close() error {
+	if r.prefetch != nil {
+		r.prefetch.close()
+	}
+	return nil
+}
+
+var errReaderClosed = errors.New("rdb: read from closed Reader")
+
+// prefetcher reads from an underlying reader in its own goroutine, into a
+// fixed set of buffers passed back and forth over channels.
+type prefetcher struct {
+	full chan prefetched
+	free chan []byte
+	done chan struct{}
+	once sync.Once
+
+	cur []byte // unread part of buf
+	buf []byte
+	err error
+}
+
+type prefetched struct {
+	b   []byte
+	err error
+}
+
+func newPrefetcher(r io.Reader, window int) *prefetcher {
+	size := min(window, prefetchChunk)
+	n := max(window/size, 1)
+	p := &prefetcher{
+		full: make(chan prefetched, n),
+		free: make(chan []byte, n+1),
+		done: make(chan struct{}),
+	}
+	// One more buffer than the window holds, for the one being consumed.
+	for i := 0; i <= n; i++ {
+		p.free <- make([]byte, size)
+	}
+	go p.run(r)
+	return p
+}
+
+func (p *prefetcher) run(r io.Reader) {
+	defer close(p.full)
+	for {
+		var b []byte
+		select {
+		case b = <-p.free:
+		case <-p.done:
+			return
+		}
+		n, err := r.Read(b)
+		select {
+		case p.full <- prefetched{b[:n], err}:
+		case <-p.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *prefetcher) Read(b []byte) (int, error) {
+	for len(p.cur) == 0 {
+		if p.buf != nil {
+			p.free <- p.buf[:cap(p.buf)]
+			p.buf = nil
+		}
+		if p.err != nil {
+			return 0, p.err
+		}
+		c, ok := <-p.full
+		if !ok {
+			return 0, errReaderClosed
+		}
+		p.cur, p.buf, p.err = c.b, c.b, c.err
+	}
+	n := copy(b, p.cur)
+	p.cur = p.cur[n:]
+	return n, nil
+}
+
+func (p *prefetcher) close() {
+	p.once.Do(func() { close(p.done) })
+}
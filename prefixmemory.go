@@ -0,0 +1,175 @@
+package rdb
+
+import (
+	"bytes"
+	"sort"
+)
+
+// PrefixMemory aggregates the estimated memory of a dump by key prefix, as
+// a tree: the first segment of the key names, then the second under it,
+// and so on, down to the type and encoding of the keys under each prefix.
+// The last segment of a key name, usually an ID, is not part of its
+// prefix. Feed it every entry with Add, then call Report; WriteTreemap
+// draws the result.
+type PrefixMemory struct {
+	Config EncodingConfig
+
+	// Separator splits key names into segments; 0 means ':'.
+	Separator byte
+
+	// Depth is the maximum number of segments of a prefix; 0 means 3.
+	Depth int
+
+	// MaxNodes bounds the number of prefixes tracked; keys with new
+	// prefixes beyond it are counted under "(other)" at the level where
+	// the limit was reached. 0 means 10000.
+	MaxNodes int
+
+	root  *PrefixNode
+	nodes int
+}
+
+// PrefixNode is a prefix of a PrefixMemory report with the keys under it.
+// The children of a prefix are longer prefixes and, for the keys that end
+// there, one leaf per type and encoding.
+type PrefixNode struct {
+	Name   string // segment, or type/encoding of a leaf
+	Prefix string // full prefix, including the separators
+	Keys   uint64
+	Bytes  uint64
+
+	// Type and Encoding are set on leaves only.
+	Leaf     bool
+	Type     Type
+	Encoding Encoding
+
+	Children []*PrefixNode `json:",omitempty"` // largest first
+
+	index map[string]*PrefixNode
+}
+
+// NewPrefixMemory returns a PrefixMemory estimating memory with cfg.
+func NewPrefixMemory(cfg EncodingConfig) *PrefixMemory {
+	return &PrefixMemory{Config: cfg}
+}
+
+// Add accounts for a single entry.
+func (p *PrefixMemory) Add(e *Entry) {
+	sep, depth, maxNodes := p.Separator, p.Depth, p.MaxNodes
+	if sep == 0 {
+		sep = ':'
+	}
+	if depth <= 0 {
+		depth = 3
+	}
+	if maxNodes <= 0 {
+		maxNodes = 10000
+	}
+	if p.root == nil {
+		p.root = &PrefixNode{Name: "(all)"}
+	}
+	enc, size := EstimateMemory(e, p.Config)
+	n := p.root
+	n.Keys++
+	n.Bytes += size
+
+	key := e.Key
+	end := 0
+	for d := 0; d < depth; d++ {
+		i := bytes.IndexByte(key[end:], sep)
+		if i < 0 {
+			break
+		}
+		seg := string(key[end : end+i])
+		end += i + 1
+		prefix := string(key[:end])
+		child := n.index[seg]
+		if child == nil && p.nodes >= maxNodes {
+			seg, prefix = "(other)", n.Prefix+"(other)"+string(sep)
+			child = n.index[seg]
+		}
+		if child == nil {
+			child = n.child(seg, seg, prefix)
+			p.nodes++
+		}
+		child.Keys++
+		child.Bytes += size
+		n = child
+		if seg == "(other)" {
+			break
+		}
+	}
+
+	t := e.Type()
+	name := t.String() + "/" + enc.String()
+	leaf := n.index["\x00"+name]
+	if leaf == nil {
+		leaf = n.child("\x00"+name, name, n.Prefix)
+		leaf.Type, leaf.Encoding, leaf.Leaf = t, enc, true
+	}
+	leaf.Keys++
+	leaf.Bytes += size
+}
+
+// child adds a child to n, indexed by id: leaves are indexed apart from
+// segments, which may look like their names.
+func (n *PrefixNode) child(id, name, prefix string) *PrefixNode {
+	if n.index == nil {
+		n.index = make(map[string]*PrefixNode)
+	}
+	c := &PrefixNode{Name: name, Prefix: prefix}
+	n.index[id] = c
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// Report returns the root of the tree, covering all keys, with children
+// sorted largest first. The tree belongs to p and changes with further
+// calls to Add.
+func (p *PrefixMemory) Report() *PrefixNode {
+	if p.root == nil {
+		return &PrefixNode{Name: "(all)"}
+	}
+	p.root.sort()
+	return p.root
+}
+
+func (n *PrefixNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Name < b.Name
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// Tables implements Tabular, listing the type and encoding leaves of
+// every prefix, largest first.
+func (n *PrefixNode) Tables() []Table {
+	var leaves []*PrefixNode
+	var walk func(c *PrefixNode)
+	walk = func(c *PrefixNode) {
+		for _, k := range c.Children {
+			if k.Leaf {
+				leaves = append(leaves, k)
+			} else {
+				walk(k)
+			}
+		}
+	}
+	walk(n)
+	sort.SliceStable(leaves, func(i, j int) bool { return leaves[i].Bytes > leaves[j].Bytes })
+	t := Table{Title: "Memory by prefix", Columns: []string{"Prefix", "Type/encoding", "Keys", "Memory", "Share"}}
+	for _, k := range leaves {
+		var s float64
+		if n.Bytes > 0 {
+			s = float64(k.Bytes) / float64(n.Bytes)
+		}
+		t.Rows = append(t.Rows, []string{formatKey([]byte(k.Prefix)), k.Name, formatCount(k.Keys), FormatBytes(k.Bytes), formatShare(s)})
+	}
+	return []Table{t}
+}
@@ -0,0 +1,69 @@
+package rdb
+
+import "time"
+
+// ExpiryProjection projects the state of the keyspace at a future time by
+// dropping the keys that will have expired by then, for capacity planning
+// from a snapshot. It assumes no key is written or deleted in the meantime.
+// Feed it every entry with Add, then call Report.
+type ExpiryProjection struct {
+	Config EncodingConfig
+	At     time.Time
+
+	report ProjectionReport
+}
+
+// KeyspaceSize sums the keys and estimated memory of part of a keyspace.
+type KeyspaceSize struct {
+	Keys    uint64
+	Expires uint64 // keys with an expiry
+	Memory  uint64 // estimated bytes
+}
+
+func (s *KeyspaceSize) add(e *Entry, size uint64) {
+	s.Keys++
+	if e.HasExpiry() {
+		s.Expires++
+	}
+	s.Memory += size
+}
+
+func (s *KeyspaceSize) merge(o KeyspaceSize) {
+	s.Keys += o.Keys
+	s.Expires += o.Expires
+	s.Memory += o.Memory
+}
+
+// ProjectionReport is the result of ExpiryProjection.
+type ProjectionReport struct {
+	At        time.Time
+	Total     KeyspaceSize // every key added
+	Surviving KeyspaceSize // keys still present at At
+	Expired   KeyspaceSize // keys that expire before At
+}
+
+// NewExpiryProjection returns an ExpiryProjection for the time at,
+// estimating memory with cfg.
+func NewExpiryProjection(cfg EncodingConfig, at time.Time) *ExpiryProjection {
+	return &ExpiryProjection{Config: cfg, At: at}
+}
+
+// Add accounts for a single entry.
+func (p *ExpiryProjection) Add(e *Entry) {
+	_, size := EstimateMemory(e, p.Config)
+	p.report.Total.add(e, size)
+	// Redis considers a key expired once the current time is past its
+	// expiry.
+	if e.HasExpiry() && e.ExpiryAt < p.At.UnixMilli() {
+		p.report.Expired.add(e, size)
+	} else {
+		p.report.Surviving.add(e, size)
+	}
+}
+
+// Report returns the projection for the entries added so far.
+func (p *ExpiryProjection) Report() ProjectionReport {
+	r := p.report
+	r.At = p.At
+	return r
+}
@@ -0,0 +1,49 @@
+package rdb_test
+
+import (
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestExpiryProjection(t *testing.T) {
+	at := time.UnixMilli(5000)
+	entries := []*rdb.Entry{
+		str(0, "persistent", "v", 0),
+		str(0, "gone", "v", 4999),
+		str(0, "edge", "v", 5000), // expires at At, still present then
+		str(0, "later", "v", 9000),
+	}
+	cfg := rdb.DefaultEncodingConfig()
+	size := func(e *rdb.Entry) uint64 {
+		_, n := rdb.EstimateMemory(e, cfg)
+		return n
+	}
+	p := rdb.NewExpiryProjection(cfg, at)
+	for _, e := range entries {
+		p.Add(e)
+	}
+	r := p.Report()
+	if !r.At.Equal(at) {
+		t.Errorf("got At %v", r.At)
+	}
+	want := rdb.ProjectionReport{
+		At:        r.At,
+		Total:     rdb.KeyspaceSize{Keys: 4, Expires: 3, Memory: size(entries[0]) + size(entries[1]) + size(entries[2]) + size(entries[3])},
+		Surviving: rdb.KeyspaceSize{Keys: 3, Expires: 2, Memory: size(entries[0]) + size(entries[2]) + size(entries[3])},
+		Expired:   rdb.KeyspaceSize{Keys: 1, Expires: 1, Memory: size(entries[1])},
+	}
+	if r != want {
+		t.Errorf("got %+v, want %+v", r, want)
+	}
+	if size(entries[0]) >= size(entries[1]) {
+		t.Error("an expiry takes no memory")
+	}
+
+	// Report leaves the projection open to more entries.
+	p.Add(str(0, "gone2", "v", 1))
+	if r := p.Report(); r.Expired.Keys != 2 || r.Total.Keys != 5 {
+		t.Errorf("after another entry: %+v", r)
+	}
+}
@@ -0,0 +1,558 @@
+// Package rdbfuzz generates seed corpora for fuzzing RDB parsers.
+//
+// The corpus is built deterministically from a seed: the same seed always
+// yields the same samples, so fuzzing runs are reproducible and corpora
+// can be regenerated instead of checked in. It holds well-formed dumps
+// covering every supported version, each value encoding and the length
+// boundaries of the string and length encodings, plus near-valid variants
+// of them (truncated, bit-flipped, bad headers) that exercise error paths.
+//
+// With Go's native fuzzing, add the samples as seeds:
+//
+//	for _, s := range rdbfuzz.Generate(1) {
+//		f.Add(s.Data)
+//	}
+//
+// For go-fuzz or libFuzzer, write them to a corpus directory with WriteDir.
+package rdbfuzz
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// Sample is one generated input.
+type Sample struct {
+	Name  string
+	Data  []byte
+	Valid bool // false for deliberately damaged inputs
+}
+
+// Versions are the RDB versions the corpus covers: all those package rdb
+// reads.
+var Versions = versions()
+
+func versions() []int {
+	var v []int
+	for i := rdb.MinVersion; i <= rdb.MaxVersion; i++ {
+		v = append(v, i)
+	}
+	return v
+}
+
+// edgeLengths are string lengths at the boundaries of the length and
+// string encodings: 6 and 14 bit lengths, the integer encoding limit and
+// the LZF threshold.
+var edgeLengths = []int{0, 1, 11, 12, 20, 21, 63, 64, 255, 256, 16383, 16384, 70000}
+
+// edgeInts are integers at the boundaries of the integer encodings.
+var edgeInts = []int64{
+	0, 1, -1, 12, 13, 127, 128, -128, -129, 4095, 4096, -4096, -4097,
+	32767, 32768, -32768, -32769, 1<<23 - 1, 1 << 23, -1 << 23, -1<<23 - 1,
+	1<<31 - 1, 1 << 31, -1 << 31, -1<<31 - 1, 1<<63 - 1, -1 << 63,
+}
+
+// Generate returns a corpus built deterministically from seed.
+func Generate(seed int64) []Sample {
+	g := &generator{rnd: rand.New(rand.NewSource(seed))}
+	var valid []Sample
+	var damaged []Sample
+	for _, v := range Versions {
+		for _, s := range []Sample{
+			g.dump(v, "strings", g.strings),
+			g.dump(v, "ints", g.ints),
+			g.dump(v, "collections", g.collections),
+			g.dump(v, "compact", g.compact),
+			g.dump(v, "streams", g.streams),
+			g.dump(v, "expiry", g.expiry),
+			g.dump(v, "databases", g.databases),
+			g.dump(v, "opcodes", g.opcodes),
+		} {
+			valid = append(valid, s)
+			damaged = append(damaged, g.damage(s, v)...)
+		}
+	}
+	samples := append([]Sample(nil), valid...)
+	samples = append(samples,
+		Sample{Name: "empty", Data: nil},
+		Sample{Name: "bad-magic", Data: append([]byte("REDIX0009"), 0xff)},
+		Sample{Name: "old-version", Data: append([]byte("REDIS0000"), 0xff)},
+		Sample{Name: "future-version", Data: append([]byte("REDIS0099"), 0xff)},
+		Sample{Name: "no-eof", Data: []byte("REDIS0009")},
+	)
+	return append(samples, damaged...)
+}
+
+// WriteDir writes every sample to its own file in dir, named after the
+// sample, creating dir if needed.
+func WriteDir(dir string, samples []Sample) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, s := range samples {
+		if err := os.WriteFile(filepath.Join(dir, s.Name), s.Data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type generator struct {
+	rnd *rand.Rand
+	key int
+}
+
+// dump builds a complete dump of the given version whose keys are added by
+// body. Auxiliary fields arrived with version 7 and the checksum with
+// version 5.
+func (g *generator) dump(version int, name string, body func(b []byte, version int) []byte) Sample {
+	b := []byte(fmt.Sprintf("REDIS%04d", version))
+	if version >= 7 {
+		b = aux(b, "redis-ver", "6.2.0")
+		b = aux(b, "redis-bits", "64")
+	}
+	b = append(b, 0xfe)
+	b = codec.AppendLength(b, 0)
+	b = body(b, version)
+	b = append(b, 0xff)
+	if version >= 5 {
+		b = append(b, make([]byte, 8)...) // checksum disabled
+	}
+	return Sample{Name: fmt.Sprintf("v%d-%s", version, name), Data: b, Valid: true}
+}
+
+func aux(b []byte, k, v string) []byte {
+	b = append(b, 0xfa)
+	b = codec.AppendRawString(b, []byte(k))
+	return codec.AppendRawString(b, []byte(v))
+}
+
+// keyed appends the type byte and a fresh key.
+func (g *generator) keyed(b []byte, t byte) []byte {
+	g.key++
+	b = append(b, t)
+	return codec.AppendRawString(b, []byte("key:"+strconv.Itoa(g.key)))
+}
+
+// text returns n random bytes; compressible text repeats a short pattern
+// so that LZF has something to find.
+func (g *generator) text(n int, compressible bool) []byte {
+	s := make([]byte, n)
+	for i := range s {
+		if compressible && i >= 8 {
+			s[i] = s[i%8]
+		} else {
+			s[i] = byte('a' + g.rnd.Intn(26))
+		}
+	}
+	return s
+}
+
+func (g *generator) strings(b []byte, _ int) []byte {
+	for _, n := range edgeLengths {
+		for _, compress := range []bool{false, true} {
+			b = g.keyed(b, 0)
+			b = codec.AppendString(b, g.text(n, compress), compress)
+		}
+	}
+	return b
+}
+
+func (g *generator) ints(b []byte, _ int) []byte {
+	for _, v := range edgeInts {
+		b = g.keyed(b, 0)
+		b = codec.AppendString(b, strconv.AppendInt(nil, v, 10), false)
+	}
+	return b
+}
+
+func (g *generator) elems(n int) [][]byte {
+	e := make([][]byte, n)
+	for i := range e {
+		if g.rnd.Intn(3) == 0 {
+			e[i] = strconv.AppendInt(nil, edgeInts[g.rnd.Intn(len(edgeInts))], 10)
+		} else {
+			e[i] = g.text(edgeLengths[g.rnd.Intn(8)], false)
+		}
+	}
+	return e
+}
+
+// collections adds the plain encodings: linked lists, hash table sets and
+// hashes, and sorted sets with string and binary scores.
+func (g *generator) collections(b []byte, version int) []byte {
+	for _, n := range []int{0, 1, 3, 64} {
+		b = g.keyed(b, 1)
+		b = codec.AppendLength(b, uint64(n))
+		for _, e := range g.elems(n) {
+			b = codec.AppendString(b, e, true)
+		}
+		b = g.keyed(b, 2)
+		b = codec.AppendLength(b, uint64(n))
+		for _, e := range g.members(n) {
+			b = codec.AppendString(b, e, true)
+		}
+		b = g.keyed(b, 4)
+		b = codec.AppendLength(b, uint64(n))
+		for _, e := range g.pairs(n) {
+			b = codec.AppendString(b, e, true)
+		}
+		b = g.keyed(b, 3)
+		b = codec.AppendLength(b, uint64(n))
+		for i, e := range g.members(n) {
+			b = codec.AppendString(b, e, true)
+			// 253 would be NaN, which Redis refuses to load.
+			switch i % 3 {
+			case 1:
+				b = append(b, 254) // +Inf
+			case 2:
+				b = append(b, 255) // -Inf
+			default:
+				s := strconv.FormatFloat(g.rnd.NormFloat64()*1e6, 'g', 17, 64)
+				b = append(b, byte(len(s)))
+				b = append(b, s...)
+			}
+		}
+		if version >= 8 {
+			b = g.keyed(b, 5)
+			b = codec.AppendLength(b, uint64(n))
+			for _, e := range g.members(n) {
+				b = codec.AppendString(b, e, true)
+				b = append(b, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f) // 1.0
+			}
+		}
+	}
+	return b
+}
+
+// compact adds the blob encodings of the version: zipmap, ziplist and
+// intset from version 2, ziplist hashes from version 4, quicklists from
+// version 7, listpacks and quicklists of listpacks with plain nodes from
+// version 10, listpack sets from version 11 and listpacks of hashes with
+// field expiry from version 12.
+func (g *generator) compact(b []byte, version int) []byte {
+	if version < 2 {
+		return b
+	}
+	for _, n := range []int{0, 1, 3, 300} {
+		e := g.elems(n)
+		var zset [][]byte
+		for i, m := range g.members(n) {
+			zset = append(zset, m, strconv.AppendInt(nil, int64(i), 10))
+		}
+		ints := make([]int64, n)
+		for i := range ints {
+			ints[i] = edgeInts[g.rnd.Intn(len(edgeInts))]
+		}
+		b = g.blob(b, 10, codec.EncodeZiplist(e))
+		b = g.blob(b, 12, codec.EncodeZiplist(zset))
+		b = g.blob(b, 9, codec.EncodeZipmap(g.pairs(n)))
+		b = g.blob(b, 11, codec.EncodeIntset(ints))
+		if version >= 4 {
+			b = g.blob(b, 13, codec.EncodeZiplist(g.pairs(n)))
+		}
+		if version >= 7 {
+			b = g.keyed(b, 14)
+			b = codec.AppendLength(b, 2)
+			b = codec.AppendString(b, codec.EncodeZiplist(e), true)
+			b = codec.AppendString(b, codec.EncodeZiplist(g.elems(1)), true)
+		}
+		if version >= 10 {
+			b = g.blob(b, 16, codec.EncodeListpack(g.pairs(n)))
+			b = g.blob(b, 17, codec.EncodeListpack(zset))
+			b = g.keyed(b, 18)
+			b = codec.AppendLength(b, 2)
+			b = codec.AppendLength(b, 2) // packed
+			b = codec.AppendString(b, codec.EncodeListpack(e), true)
+			b = codec.AppendLength(b, 1) // plain
+			b = codec.AppendString(b, g.text(edgeLengths[g.rnd.Intn(len(edgeLengths))], true), true)
+		}
+		if version >= 11 {
+			b = g.blob(b, 20, codec.EncodeListpack(g.members(n)))
+		}
+		if version >= 12 {
+			var fields [][]byte
+			p := g.pairs(n)
+			for i := 0; i < len(p); i += 2 {
+				ttl := []byte("0")
+				if i%4 == 0 {
+					ttl = strconv.AppendInt(nil, 1700000000000+int64(i), 10)
+				}
+				fields = append(fields, p[i], p[i+1], ttl)
+			}
+			b = g.keyed(b, 25)
+			b = binary.LittleEndian.AppendUint64(b, 1700000000000)
+			b = codec.AppendString(b, codec.EncodeListpack(fields), true)
+			b = g.blob(b, 23, codec.EncodeListpack(fields))
+		}
+	}
+	if version >= 12 {
+		// A hash table hash with field expiry, stored relative to the
+		// earliest one.
+		b = g.keyed(b, 24)
+		b = binary.LittleEndian.AppendUint64(b, 1700000000000)
+		b = codec.AppendLength(b, 2)
+		for i, f := range g.pairs(2) {
+			if i%2 == 0 {
+				b = codec.AppendLength(b, uint64(i)) // 0: no expiry
+			}
+			b = codec.AppendString(b, f, true)
+		}
+	}
+	return b
+}
+
+// blob adds a key of type t whose value is the blob b.
+func (g *generator) blob(dst []byte, t byte, b []byte) []byte {
+	dst = g.keyed(dst, t)
+	return codec.AppendString(dst, b, true)
+}
+
+// streams adds streams from version 9, in the format of the version: empty
+// ones, and ones with entries spread over several nodes, deleted entries
+// and a consumer group with pending entries.
+func (g *generator) streams(b []byte, version int) []byte {
+	var t byte
+	switch {
+	case version >= 11:
+		t = 21
+	case version == 10:
+		t = 19
+	case version == 9:
+		t = 15
+	default:
+		return b
+	}
+	for _, n := range []int{0, 1, 150} {
+		b = g.keyed(b, t)
+		var last [2]uint64
+		nodes := (n + 99) / 100
+		b = codec.AppendLength(b, uint64(nodes))
+		for node := 0; node < nodes; node++ {
+			count := min(100, n-100*node)
+			master := [2]uint64{1700000000000 + uint64(100*node), 0}
+			key := binary.BigEndian.AppendUint64(nil, master[0])
+			key = binary.BigEndian.AppendUint64(key, master[1])
+			b = codec.AppendString(b, key, false)
+			b = codec.AppendString(b, g.streamNode(master, count), true)
+			last = [2]uint64{master[0] + uint64(count-1), 0}
+		}
+		b = codec.AppendLength(b, uint64(n)) // length, ignoring the deleted entry of every node
+		b = codec.AppendLength(codec.AppendLength(b, last[0]), last[1])
+		if t != 15 {
+			b = codec.AppendLength(codec.AppendLength(b, 1700000000000), 0) // first ID
+			b = codec.AppendLength(codec.AppendLength(b, 0), 0)             // max deleted ID
+			b = codec.AppendLength(b, uint64(n))                            // entries added
+		}
+		if n == 0 {
+			b = codec.AppendLength(b, 0) // groups
+			continue
+		}
+		b = codec.AppendLength(b, 1)
+		b = codec.AppendString(b, []byte("group"), false)
+		b = codec.AppendLength(codec.AppendLength(b, 1700000000000), 0)
+		if t != 15 {
+			b = codec.AppendLength(b, 1) // entries read
+		}
+		pending := binary.BigEndian.AppendUint64(nil, 1700000000000)
+		pending = binary.BigEndian.AppendUint64(pending, 0)
+		b = codec.AppendLength(b, 1)
+		b = append(b, pending...)
+		b = binary.LittleEndian.AppendUint64(b, 1700000001000) // delivery time
+		b = codec.AppendLength(b, 2)                           // delivery count
+		b = codec.AppendLength(b, 2)                           // consumers
+		for i, owned := range [][]byte{pending, nil} {
+			b = codec.AppendString(b, []byte("consumer"+strconv.Itoa(i)), false)
+			b = binary.LittleEndian.AppendUint64(b, 1700000002000) // seen time
+			if t == 21 {
+				b = binary.LittleEndian.AppendUint64(b, 1700000001000) // active time
+			}
+			if owned == nil {
+				b = codec.AppendLength(b, 0)
+			} else {
+				b = append(codec.AppendLength(b, 1), owned...)
+			}
+		}
+	}
+	return b
+}
+
+// streamNode returns the listpack of a stream node holding count entries
+// from master on, a millisecond apart, plus a deleted one. Entries
+// alternate between the fields of the master entry and their own.
+func (g *generator) streamNode(master [2]uint64, count int) []byte {
+	num := func(v int) []byte { return strconv.AppendInt(nil, int64(v), 10) }
+	masterFields := g.members(2)
+	lp := [][]byte{num(count), num(1), num(len(masterFields))}
+	lp = append(lp, masterFields...)
+	lp = append(lp, num(0))
+	for i := 0; i <= count; i++ {
+		flags := 0
+		if i == count {
+			flags = 1 // deleted
+		}
+		if i%2 == 0 {
+			flags |= 2 // same fields
+		}
+		lp = append(lp, num(flags), num(i), num(0))
+		if flags&2 != 0 {
+			for range masterFields {
+				lp = append(lp, g.text(g.rnd.Intn(20), false))
+			}
+			lp = append(lp, num(len(masterFields)+3))
+		} else {
+			lp = append(lp, num(1), []byte("own"), g.text(g.rnd.Intn(20), false), num(6))
+		}
+	}
+	return codec.EncodeListpack(lp)
+}
+
+// members returns n distinct elements, as sets and sorted sets require.
+func (g *generator) members(n int) [][]byte {
+	m := make([][]byte, n)
+	for i := range m {
+		if i%2 == 0 {
+			m[i] = strconv.AppendInt(nil, int64(i)*7919-int64(n), 10)
+		} else {
+			m[i] = append([]byte("m"+strconv.Itoa(i)+":"), g.text(g.rnd.Intn(64), false)...)
+		}
+	}
+	return m
+}
+
+// pairs returns n field-value pairs with distinct fields, flattened.
+func (g *generator) pairs(n int) [][]byte {
+	p := make([][]byte, 0, 2*n)
+	for _, f := range g.members(n) {
+		p = append(p, f, g.text(edgeLengths[g.rnd.Intn(10)], false))
+	}
+	return p
+}
+
+// expiry adds keys with expiry times in seconds and, from version 3, in
+// milliseconds, and from version 9 with access information.
+func (g *generator) expiry(b []byte, version int) []byte {
+	if version >= 3 {
+		for _, ms := range []uint64{0, 1, 1 << 41, 1<<63 - 1} {
+			b = append(b, 0xfc)
+			b = binary.LittleEndian.AppendUint64(b, ms)
+			b = g.keyed(b, 0)
+			b = codec.AppendString(b, []byte("v"), false)
+		}
+	}
+	b = append(b, 0xfd, 0xff, 0xff, 0xff, 0x7f)
+	b = g.keyed(b, 0)
+	b = codec.AppendString(b, []byte("v"), false)
+	if version >= 7 {
+		b = append(b, 0xfb)
+		b = codec.AppendLength(b, 1)
+		b = codec.AppendLength(b, 0)
+	}
+	if version >= 9 {
+		// Redis writes the access information after the expiry; accept
+		// either order, with an auxiliary field in between.
+		expire := []byte{0xfc, 0xe8, 3, 0, 0, 0, 0, 0, 0}
+		idle := codec.AppendLength([]byte{0xf8}, 86400)
+		freq := []byte{0xf9, 255}
+		for _, ops := range [][][]byte{
+			{expire, idle},
+			{idle, expire},
+			{freq, expire},
+			{expire, aux(nil, "mvcc-tstamp", "1"), freq},
+			{idle},
+		} {
+			for _, op := range ops {
+				b = append(b, op...)
+			}
+			b = g.keyed(b, 0)
+			b = codec.AppendString(b, []byte("v"), false)
+		}
+	}
+	return b
+}
+
+// databases adds the database selection opcodes in the sequences found in
+// real dumps and some legal ones that are not: empty databases with and
+// without a size hint, back-to-back selections, auxiliary fields between a
+// selection and its hint, and a selection between an expiry and its key.
+// Size hints and auxiliary fields are left out before version 7.
+func (g *generator) databases(b []byte, version int) []byte {
+	selectDB := func(b []byte, db uint64) []byte {
+		return codec.AppendLength(append(b, 0xfe), db)
+	}
+	resizeDB := func(b []byte, keys, expires uint64) []byte {
+		if version < 7 {
+			return b
+		}
+		return codec.AppendLength(codec.AppendLength(append(b, 0xfb), keys), expires)
+	}
+	aux := func(b []byte, k, v string) []byte {
+		if version < 7 {
+			return b
+		}
+		return aux(b, k, v)
+	}
+	b = resizeDB(b, 1, 0)
+	b = g.keyed(b, 0)
+	b = codec.AppendString(b, []byte("v"), false)
+	b = resizeDB(selectDB(b, 1), 0, 0)
+	b = selectDB(selectDB(b, 2), 3)
+	b = aux(b, "lua", "")
+	b = resizeDB(b, 2, 1)
+	b = g.keyed(b, 0)
+	b = codec.AppendString(b, []byte("v"), false)
+	b = append(b, 0xfd, 1, 0, 0, 0)
+	if version >= 9 {
+		b = append(b, 0xf9, 3) // LFU counter
+	}
+	b = selectDB(b, 3)
+	b = g.keyed(b, 0)
+	b = codec.AppendString(b, []byte("v"), false)
+	b = selectDB(b, 15)
+	return selectDB(b, 16383)
+}
+
+// opcodes adds the opcodes of recent versions that carry no key: function
+// libraries from version 10 and cluster slot information from version 12.
+func (g *generator) opcodes(b []byte, version int) []byte {
+	if version >= 10 {
+		b = append(b, 0xf5)
+		b = codec.AppendString(b, []byte("#!lua name=lib\nredis.register_function('f', function() return 1 end)"), true)
+	}
+	if version >= 12 {
+		b = append(b, 0xf4)
+		b = codec.AppendLength(codec.AppendLength(codec.AppendLength(b, 866), 1), 0)
+	}
+	b = g.keyed(b, 0)
+	return codec.AppendString(b, []byte("v"), false)
+}
+
+// damage derives near-valid samples from a valid one of the given version.
+func (g *generator) damage(s Sample, version int) []Sample {
+	const header = 9
+	var out []Sample
+	add := func(kind string, b []byte) {
+		out = append(out, Sample{Name: s.Name + "-" + kind, Data: b})
+	}
+	body := len(s.Data) - header
+	add("truncated", append([]byte(nil), s.Data[:header+g.rnd.Intn(body)]...))
+	if version >= 5 {
+		add("no-checksum", append([]byte(nil), s.Data[:len(s.Data)-8]...))
+	}
+	for i := 0; i < 3; i++ {
+		b := append([]byte(nil), s.Data...)
+		b[header+g.rnd.Intn(body)] ^= 1 << uint(g.rnd.Intn(8))
+		add("flip"+strconv.Itoa(i), b)
+	}
+	b := append([]byte(nil), s.Data...)
+	b[header+g.rnd.Intn(body)] = 0xff
+	add("early-eof", b)
+	return out
+}
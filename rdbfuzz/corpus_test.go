@@ -0,0 +1,57 @@
+package rdbfuzz_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/rdbfuzz"
+)
+
+func TestGenerate(t *testing.T) {
+	samples := rdbfuzz.Generate(1)
+	if !reflect.DeepEqual(samples, rdbfuzz.Generate(1)) {
+		t.Fatal("Generate is not deterministic")
+	}
+	covered := map[int]bool{}
+	for _, s := range samples {
+		// Damaged samples must not panic; only the cut ones are sure to
+		// fail, since a flipped bit can leave a dump well-formed.
+		n, err := readAll(s.Data)
+		switch {
+		case s.Valid && err != nil:
+			t.Errorf("%s: %v after %d entries", s.Name, err, n)
+		case err == nil && (strings.HasSuffix(s.Name, "-truncated") || strings.HasSuffix(s.Name, "-no-checksum")):
+			t.Errorf("%s: read without error", s.Name)
+		}
+		if s.Valid {
+			r, _ := rdb.NewReader(bytes.NewReader(s.Data))
+			covered[r.Version()] = true
+			r.Close()
+		}
+	}
+	for _, v := range rdbfuzz.Versions {
+		if !covered[v] {
+			t.Errorf("no valid sample of version %d", v)
+		}
+	}
+}
+
+// readAll reads and decodes every entry of data.
+func readAll(data []byte) (int, error) {
+	r, err := rdb.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	for n := 0; ; n++ {
+		if _, err := r.ReadEntry(); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+	}
+}
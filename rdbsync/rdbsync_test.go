@@ -0,0 +1,300 @@
+package rdbsync_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/rdbsync"
+)
+
+// fakeServer speaks enough of the Redis protocol for the tests: it sends
+// dump in reply to PSYNC, as a diskless transfer ending with mark if mark
+// is set, refuses refuse commands with LOADING, starting with the one
+// numbered refuseFrom, and records the others with the database they ran
+// in.
+type fakeServer struct {
+	l    net.Listener
+	dump []byte
+	mark string
+
+	mu         sync.Mutex
+	received   int
+	refuseFrom int
+	refuse     int
+	ran        []string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{l: l, refuseFrom: -1}
+	t.Cleanup(func() { l.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *fakeServer) serve() {
+	for {
+		c, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(c)
+	}
+}
+
+func (s *fakeServer) handle(c net.Conn) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	db := "0"
+	for {
+		args, err := readCommand(br)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		n := s.received
+		s.received++
+		reply := "+OK\r\n"
+		switch {
+		case args[0] == "PSYNC" && s.mark != "":
+			// The replication stream follows the mark.
+			reply = fmt.Sprintf("+FULLRESYNC id 0\r\n$EOF:%s\r\n%s%s*1\r\n$4\r\nPING\r\n", s.mark, s.dump, s.mark)
+		case args[0] == "PSYNC":
+			reply = fmt.Sprintf("+FULLRESYNC id 0\r\n\n$%d\r\n%s", len(s.dump), s.dump)
+		case args[0] == "REPLCONF":
+		case n >= s.refuseFrom && s.refuse > 0 && s.refuseFrom >= 0:
+			s.refuse--
+			reply = "-LOADING Redis is loading the dataset in memory\r\n"
+		case args[0] == "SELECT":
+			db = args[1]
+		default:
+			s.ran = append(s.ran, db+" "+strings.Join(args, " "))
+		}
+		s.mu.Unlock()
+		// Short writes make the mark straddle the client's reads.
+		for b := []byte(reply); len(b) > 0; {
+			n, err := c.Write(b[:min(len(b), 7)])
+			if err != nil {
+				return
+			}
+			b = b[n:]
+		}
+	}
+}
+
+func readCommand(br *bufio.Reader) ([]string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = br.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func TestFetch(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		w.WriteEntry(&rdb.Entry{Key: rdb.RedisString("k" + strconv.Itoa(i)), Value: rdb.StringValue("v")})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, mark := range []string{"", strings.Repeat("0123456789", 4)} {
+		t.Run(fmt.Sprintf("mark=%q", mark), func(t *testing.T) {
+			s := newFakeServer(t)
+			s.dump, s.mark = buf.Bytes(), mark
+
+			snap, err := rdbsync.Fetch(context.Background(), s.addr(), rdbsync.Config{Password: "secret"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer snap.Close()
+			want := int64(buf.Len())
+			if mark != "" {
+				want = -1
+			}
+			if snap.Size != want {
+				t.Errorf("got size %d, want %d", snap.Size, want)
+			}
+			got, err := io.ReadAll(snap)
+			if err != nil || !bytes.Equal(got, buf.Bytes()) {
+				t.Fatalf("got %d bytes, %v, want the %d of the dump", len(got), err, buf.Len())
+			}
+		})
+	}
+}
+
+func TestSaveFileDiskless(t *testing.T) {
+	s := newFakeServer(t)
+	s.dump, s.mark = []byte("REDIS0011\xff\x00\x00\x00\x00\x00\x00\x00\x00"), strings.Repeat("x", 40)
+	path := t.TempDir() + "/dump.rdb"
+	if err := rdbsync.SaveFile(context.Background(), s.addr(), path, rdbsync.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, s.dump) {
+		t.Errorf("got %q, %v, want %q", got, err, s.dump)
+	}
+}
+
+// TestRestorerRetry checks that refused commands are sent again in order,
+// in the database they were meant for, without running twice any command
+// the server accepted.
+func TestRestorerRetry(t *testing.T) {
+	dbs := []uint64{0, 0, 1, 1, 0, 0}
+	// Each list takes three RPUSH commands. The first pipeline is
+	// SELECT 0, k0, k1, then SELECT 1 and k2, numbered from 0.
+	elems := make([]rdb.RedisString, 1100)
+	for i := range elems {
+		elems[i] = rdb.RedisString("e" + strconv.Itoa(i))
+	}
+	tests := []struct {
+		name               string
+		refuseFrom, refuse int
+		del                bool
+	}{
+		{"whole key", 4, 3, false},
+		{"within a key", 5, 1, true},
+		{"across keys", 2, 4, true},
+		{"SELECT", 7, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeServer(t)
+			s.refuseFrom, s.refuse = tt.refuseFrom, tt.refuse
+			r, err := rdbsync.NewRestorer(context.Background(), s.addr(), rdbsync.RestoreConfig{
+				Pipeline:   8,
+				Retries:    2,
+				RetryDelay: time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, db := range dbs {
+				e := &rdb.Entry{DB: db, Key: rdb.RedisString("k" + strconv.Itoa(i)), Value: rdb.ListValue{Elements: elems}}
+				if err := r.WriteEntry(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.End(); err != nil {
+				t.Fatal(err)
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			// Replay what ran: every list must hold its elements once,
+			// in order, in its database.
+			lists := make(map[string][]string)
+			del := false
+			for _, c := range s.ran {
+				args := strings.Fields(c)
+				key := args[0] + " " + args[2]
+				switch args[1] {
+				case "DEL":
+					delete(lists, key)
+					del = true
+				case "RPUSH":
+					lists[key] = append(lists[key], args[3:]...)
+				default:
+					t.Fatalf("ran %.40q", c)
+				}
+			}
+			if del != tt.del {
+				t.Errorf("got DEL sent %v, want %v", del, tt.del)
+			}
+			if len(lists) != len(dbs) {
+				t.Errorf("got %d lists, want %d", len(lists), len(dbs))
+			}
+			for i, db := range dbs {
+				got := lists[fmt.Sprintf("%d k%d", db, i)]
+				if len(got) != len(elems) || got[0] != "e0" || got[len(got)-1] != string(elems[len(elems)-1]) {
+					t.Errorf("k%d holds %d elements, want %d", i, len(got), len(elems))
+					continue
+				}
+				for j, el := range got {
+					if el != string(elems[j]) {
+						t.Errorf("k%d holds %s at %d", i, el, j)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRestorerRetriesExhausted(t *testing.T) {
+	s := newFakeServer(t)
+	s.refuseFrom, s.refuse = 0, 100
+	r, err := rdbsync.NewRestorer(context.Background(), s.addr(), rdbsync.RestoreConfig{
+		Retries:    2,
+		RetryDelay: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.WriteEntry(&rdb.Entry{Key: rdb.RedisString("k"), Value: rdb.StringValue("v")})
+	if err := r.End(); err == nil || !strings.Contains(err.Error(), "busy") {
+		t.Errorf("got %v, want an error about the busy server", err)
+	}
+}
+
+// TestRestorerCancel checks that nothing is sent once ctx is cancelled,
+// even with a Timeout whose deadline would outlast the cancellation.
+func TestRestorerCancel(t *testing.T) {
+	s := newFakeServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := rdbsync.NewRestorer(ctx, s.addr(), rdbsync.RestoreConfig{Timeout: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.WriteEntry(&rdb.Entry{Key: rdb.RedisString("k"), Value: rdb.StringValue("v")})
+	cancel()
+	if err := r.End(); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.ran) != 0 {
+		t.Errorf("ran %q after cancellation", s.ran)
+	}
+}
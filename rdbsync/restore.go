@@ -0,0 +1,431 @@
+package rdbsync
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+// RestoreConfig tunes a Restorer. The zero value restores over a single
+// connection, 64 commands at a time, without timeouts or retries.
+type RestoreConfig struct {
+	Config
+
+	// Options controls how keys and their expiry are recreated.
+	Options rdb.RestoreOptions
+
+	// Conns is the number of connections commands are spread over; 0
+	// means 1. The commands of a key always use the same connection, so
+	// they run in order.
+	Conns int
+
+	// Pipeline is the number of commands sent on a connection before
+	// waiting for their replies; 0 means 64.
+	Pipeline int
+
+	// Timeout bounds the time to send a pipeline and read its replies; 0
+	// means no limit.
+	Timeout time.Duration
+
+	// Retries is the number of times commands refused because the server
+	// is loading a dataset (LOADING) or running a script (BUSY) are sent
+	// again, waiting RetryDelay, doubled on each attempt, in between.
+	// RetryDelay defaults to 100ms. The commands of a key are sent again
+	// as a whole, after a DEL of the key if the server accepted some of
+	// them, so that none runs twice; keys whose commands were all
+	// accepted are not sent again.
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// A Restorer loads entries into a running server. It implements rdb.Sink,
+// so it can end an rdb.Pipeline; the commands it sends are those of
+// rdb.RestoreOptions.Commands.
+type Restorer struct {
+	ctx   context.Context
+	cfg   RestoreConfig
+	conns []*restoreConn
+	seed  maphash.Seed
+	wg    sync.WaitGroup
+	stop  func() bool
+	once  sync.Once
+
+	mu  sync.Mutex
+	err error // first error of a connection
+}
+
+// NewRestorer connects to the server at addr. The Restorer must be ended
+// with End or closed with Close. Cancelling ctx aborts the restore.
+func NewRestorer(ctx context.Context, addr string, cfg RestoreConfig) (*Restorer, error) {
+	if cfg.Conns <= 0 {
+		cfg.Conns = 1
+	}
+	if cfg.Pipeline <= 0 {
+		cfg.Pipeline = 64
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = 100 * time.Millisecond
+	}
+	r := &Restorer{ctx: ctx, cfg: cfg, seed: maphash.MakeSeed()}
+	for i := 0; i < cfg.Conns; i++ {
+		conn, err := dial(ctx, addr, cfg.Config)
+		if err != nil {
+			r.closeConns()
+			return nil, err
+		}
+		c := &restoreConn{r: r, conn: conn, br: bufio.NewReader(conn), in: make(chan restoreJob, cfg.Pipeline)}
+		r.conns = append(r.conns, c)
+		if err := authenticate(conn, c.br, cfg.Config); err != nil {
+			r.closeConns()
+			return nil, err
+		}
+	}
+	r.stop = context.AfterFunc(ctx, func() {
+		for _, c := range r.conns {
+			c.conn.SetDeadline(time.Now())
+		}
+	})
+	for _, c := range r.conns {
+		r.wg.Add(1)
+		go c.run()
+	}
+	return r, nil
+}
+
+// Begin implements rdb.Sink. The ctime of the dump becomes the reference
+// time of the restore options if their Now is zero.
+func (r *Restorer) Begin(info rdb.DumpInfo) error {
+	if r.cfg.Options.Now.IsZero() {
+		r.cfg.Options.Now, _ = info.Created()
+	}
+	return nil
+}
+
+// SelectDB implements rdb.Sink; it does nothing, as the connections select
+// the database of every entry as needed.
+func (r *Restorer) SelectDB(uint64) error { return nil }
+
+// WriteEntry queues the commands recreating e. It returns the first error
+// met by a connection so far.
+func (r *Restorer) WriteEntry(e *rdb.Entry) error {
+	if err := r.failed(); err != nil {
+		return err
+	}
+	cmds := r.cfg.Options.Commands(e)
+	if cmds == nil {
+		return nil
+	}
+	c := r.conns[maphash.Bytes(r.seed, e.Key)%uint64(len(r.conns))]
+	c.in <- restoreJob{db: e.DB, key: e.Key, cmds: cmds}
+	return nil
+}
+
+// End sends the queued commands, waits for their replies and closes the
+// connections.
+func (r *Restorer) End() error {
+	r.finish()
+	if err := r.failed(); err != nil {
+		return err
+	}
+	return r.ctx.Err()
+}
+
+// Close closes the connections without waiting for queued commands, for
+// use when the restore is abandoned. It may be called after End.
+func (r *Restorer) Close() error {
+	r.fail(net.ErrClosed)
+	r.closeConns()
+	r.finish()
+	return nil
+}
+
+// finish stops the connection goroutines once they are done with the
+// queued commands, and closes the connections.
+func (r *Restorer) finish() {
+	r.once.Do(func() {
+		for _, c := range r.conns {
+			close(c.in)
+		}
+		r.wg.Wait()
+		r.stop()
+		r.closeConns()
+	})
+}
+
+func (r *Restorer) closeConns() {
+	for _, c := range r.conns {
+		c.conn.Close()
+	}
+}
+
+func (r *Restorer) fail(err error) {
+	r.mu.Lock()
+	if r.err == nil {
+		r.err = err
+	}
+	r.mu.Unlock()
+}
+
+func (r *Restorer) failed() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// restoreJob holds the commands recreating a key. del is set once the
+// server has accepted some of them but refused others, so that the key is
+// deleted before they are all sent again.
+type restoreJob struct {
+	db   uint64
+	key  rdb.RedisString
+	cmds []rdb.Command
+	del  bool
+}
+
+// restoreConn is a connection of a Restorer, with the goroutine feeding
+// it.
+type restoreConn struct {
+	r       *Restorer
+	conn    net.Conn
+	br      *bufio.Reader
+	in      chan restoreJob
+	db      uint64 // the database selected on the server
+	dbSet   bool
+	pending []restoreJob
+	queued  int // number of commands in pending
+	buf     []byte
+}
+
+func (c *restoreConn) run() {
+	defer c.r.wg.Done()
+	var err error
+	for j := range c.in {
+		if err != nil || c.r.failed() != nil {
+			continue // drain, so that WriteEntry does not block
+		}
+		c.pending = append(c.pending, j)
+		c.queued += len(j.cmds)
+		if c.queued >= c.r.cfg.Pipeline {
+			err = c.flush()
+		}
+	}
+	if err == nil && c.r.failed() == nil {
+		err = c.flush()
+	}
+	if err != nil {
+		if c.r.ctx.Err() != nil {
+			err = c.r.ctx.Err()
+		}
+		c.r.fail(err)
+	}
+}
+
+// flush sends the pending jobs, a database at a time: the SELECT is sent
+// on its own first, so that no command runs in the wrong database should
+// the server refuse it.
+func (c *restoreConn) flush() error {
+	jobs := c.pending
+	for len(jobs) > 0 {
+		n := 1
+		for n < len(jobs) && jobs[n].db == jobs[0].db {
+			n++
+		}
+		if err := c.selectDB(jobs[0].db); err != nil {
+			return err
+		}
+		if err := c.send(jobs[:n]); err != nil {
+			return err
+		}
+		jobs = jobs[n:]
+	}
+	clear(c.pending)
+	c.pending, c.queued = c.pending[:0], 0
+	return nil
+}
+
+// selectDB selects database db, unless the server is in it already.
+func (c *restoreConn) selectDB(db uint64) error {
+	if c.dbSet && c.db == db {
+		return nil
+	}
+	cmd := rdb.NewCommand("SELECT", strconv.FormatUint(db, 10))
+	err := c.retry(func() (bool, error) {
+		if err := c.write([]rdb.Command{cmd}); err != nil {
+			return false, err
+		}
+		msg, err := readReply(c.br)
+		switch {
+		case err != nil:
+			return false, err
+		case msg == "":
+			return false, nil
+		case retryable(msg):
+			return true, nil
+		}
+		return false, fmt.Errorf("rdbsync: SELECT %d: %s", db, msg)
+	})
+	if err == nil {
+		c.db, c.dbSet = db, true
+	}
+	return err
+}
+
+// send sends the commands of jobs and reads their replies. The jobs with
+// a command refused with LOADING or BUSY are sent again as a whole, so
+// that no command runs twice and the commands of a key keep their order.
+func (c *restoreConn) send(jobs []restoreJob) error {
+	var (
+		cmds  []rdb.Command
+		owner []int // index in jobs of each command
+	)
+	return c.retry(func() (bool, error) {
+		cmds, owner = cmds[:0], owner[:0]
+		for i, j := range jobs {
+			if j.del {
+				cmds = append(cmds, rdb.Command{rdb.RedisString("DEL"), j.key})
+				owner = append(owner, i)
+			}
+			cmds = append(cmds, j.cmds...)
+			for range j.cmds {
+				owner = append(owner, i)
+			}
+		}
+		if err := c.write(cmds); err != nil {
+			return false, err
+		}
+		accepted := make([]bool, len(jobs))
+		refused := make([]bool, len(jobs))
+		for k, cmd := range cmds {
+			msg, err := readReply(c.br)
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case msg == "":
+				accepted[owner[k]] = true
+			case retryable(msg):
+				refused[owner[k]] = true
+			case len(cmd) > 1:
+				return false, fmt.Errorf("rdbsync: %s %q: %s", cmd.Name(), cmd[1], msg)
+			default:
+				return false, fmt.Errorf("rdbsync: %s: %s", cmd.Name(), msg)
+			}
+		}
+		left := jobs[:0]
+		for i, j := range jobs {
+			if !refused[i] {
+				continue
+			}
+			// What the server accepted of the key must not run twice.
+			j.del = j.del || accepted[i]
+			left = append(left, j)
+		}
+		jobs = left
+		return len(jobs) > 0, nil
+	})
+}
+
+// retry calls try until it reports that nothing is left to send again,
+// waiting RetryDelay, doubled on each attempt, in between. Each call is
+// bounded by Timeout.
+func (c *restoreConn) retry(try func() (again bool, err error)) error {
+	cfg := &c.r.cfg
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if attempt > cfg.Retries {
+				return fmt.Errorf("rdbsync: server still busy after %d retries", cfg.Retries)
+			}
+			select {
+			case <-time.After(cfg.RetryDelay << (attempt - 1)):
+			case <-c.r.ctx.Done():
+				return c.r.ctx.Err()
+			}
+		}
+		if err := c.r.ctx.Err(); err != nil {
+			return err
+		}
+		if cfg.Timeout > 0 {
+			deadline := time.Now().Add(cfg.Timeout)
+			if d, ok := c.r.ctx.Deadline(); ok && d.Before(deadline) {
+				deadline = d
+			}
+			c.conn.SetDeadline(deadline)
+			// ctx may have been cancelled since it was checked, its
+			// deadline overwritten.
+			if err := c.r.ctx.Err(); err != nil {
+				return err
+			}
+		}
+		again, err := try()
+		if err != nil || !again {
+			return err
+		}
+	}
+}
+
+// write sends cmds in a single write.
+func (c *restoreConn) write(cmds []rdb.Command) error {
+	b := c.buf[:0]
+	for _, cmd := range cmds {
+		b = cmd.AppendRESP(b)
+	}
+	c.buf = b
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// retryable reports whether an error reply means the command was refused
+// for now and may succeed later.
+func retryable(msg string) bool {
+	return strings.HasPrefix(msg, "LOADING ") || msg == "BUSY" || strings.HasPrefix(msg, "BUSY ")
+}
+
+// readReply reads a reply, returning the message of an error reply and ""
+// for any other. Errors nested in array replies are ignored.
+func readReply(br *bufio.Reader) (string, error) {
+	line, err := readLine(br)
+	if err != nil {
+		return "", err
+	}
+	if line == "" {
+		return "", fmt.Errorf("rdbsync: empty reply")
+	}
+	switch line[0] {
+	case '-':
+		return line[1:], nil
+	case '+', ':':
+		return "", nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("rdbsync: bad reply %q", line)
+		}
+		if n >= 0 {
+			if _, err := io.CopyN(io.Discard, br, int64(n)+2); err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("rdbsync: bad reply %q", line)
+		}
+		for i := 0; i < n; i++ {
+			if _, err := readReply(br); err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("rdbsync: unexpected reply %q", line)
+}
@@ -0,0 +1,293 @@
+// Package rdbtest provides helpers for tests of code built on package rdb:
+// loading fixture dumps or writing small ones, comparing the entries read
+// from them with the expected ones and checking them against golden files,
+// with diffs meant to be read by a person.
+package rdbtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+// UpdateEnv is the environment variable that makes Golden rewrite golden
+// files instead of comparing against them.
+const UpdateEnv = "RDBTEST_UPDATE"
+
+// Load reads every entry of the dump at path. It fails the test if the file
+// cannot be read or parsed.
+func Load(t testing.TB, path string, opts ...rdb.Option) []*rdb.Entry {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return LoadBytes(t, b, opts...)
+}
+
+// LoadBytes reads every entry of the dump in b. It fails the test if b
+// cannot be parsed.
+func LoadBytes(t testing.TB, b []byte, opts ...rdb.Option) []*rdb.Entry {
+	t.Helper()
+	r, err := rdb.NewReader(bytes.NewReader(b), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []*rdb.Entry
+	for {
+		e, err := r.ReadEntry()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Dump writes entries to a dump of the given version, for tests that need
+// a small dump rather than a fixture file. It fails the test if an entry
+// cannot be written.
+func Dump(t testing.TB, version int, entries ...*rdb.Entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if err := w.WriteEntry(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// AssertEntries fails the test if got and want do not hold the same keys
+// with the same values, types and expiry times. Keys are matched by
+// database and name; their order is ignored, as Redis writes keys in hash
+// table order.
+func AssertEntries(t testing.TB, got, want []*rdb.Entry) {
+	t.Helper()
+	if d := Diff(got, want); d != "" {
+		t.Errorf("entries differ (-want +got):\n%s", d)
+	}
+}
+
+// AssertFile loads the dump at path and compares its entries with want as
+// AssertEntries does.
+func AssertFile(t testing.TB, path string, want []*rdb.Entry, opts ...rdb.Option) {
+	t.Helper()
+	AssertEntries(t, Load(t, path, opts...), want)
+}
+
+// Diff returns a line per key that is missing from got ("-"), unexpected
+// in got ("+") or different (both), or "" if got and want match.
+func Diff(got, want []*rdb.Entry) string {
+	type key struct {
+		db   uint64
+		name string
+	}
+	index := func(entries []*rdb.Entry) map[key]*rdb.Entry {
+		m := make(map[key]*rdb.Entry, len(entries))
+		for _, e := range entries {
+			m[key{e.DB, string(e.Key)}] = e
+		}
+		return m
+	}
+	g, w := index(got), index(want)
+	keys := make([]*rdb.Entry, 0, len(g)+len(w))
+	for _, e := range w {
+		keys = append(keys, e)
+	}
+	for k, e := range g {
+		if _, ok := w[k]; !ok {
+			keys = append(keys, e)
+		}
+	}
+	rdb.SortEntries(keys)
+
+	var sb strings.Builder
+	for _, e := range keys {
+		k := key{e.DB, string(e.Key)}
+		ge, we := g[k], w[k]
+		if ge != nil && we != nil && equal(ge, we) {
+			continue
+		}
+		if we != nil {
+			fmt.Fprintf(&sb, "- %s\n", Format(we))
+		}
+		if ge != nil {
+			fmt.Fprintf(&sb, "+ %s\n", Format(ge))
+		}
+	}
+	return sb.String()
+}
+
+func equal(a, b *rdb.Entry) bool {
+	if a.ValueType != b.ValueType || a.ExpiryAt != b.ExpiryAt {
+		return false
+	}
+	// DeepEqual finds NaN scores unequal even to themselves.
+	if za, ok := a.Value.(rdb.ZSetValue); ok {
+		zb, ok := b.Value.(rdb.ZSetValue)
+		if !ok || len(za.Members) != len(zb.Members) {
+			return false
+		}
+		for i, m := range za.Members {
+			x, y := m.Score, zb.Members[i].Score
+			if string(m.Member) != string(zb.Members[i].Member) || x != y && !(math.IsNaN(x) && math.IsNaN(y)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(withoutEncoding(a.Value), withoutEncoding(b.Value))
+}
+
+// withoutEncoding clears the encoding of collections, which the value
+// types already compared imply, so that expected values need not set it.
+func withoutEncoding(v rdb.Value) rdb.Value {
+	switch v := v.(type) {
+	case rdb.ListValue:
+		v.Enc = 0
+		return v
+	case rdb.SetValue:
+		v.Enc = 0
+		return v
+	case rdb.HashValue:
+		v.Enc = 0
+		return v
+	}
+	return v
+}
+
+// Format renders e on a single line, quoting keys and values so that
+// binary data stays readable.
+func Format(e *rdb.Entry) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "db%d %s %v", e.DB, strconv.Quote(string(e.Key)), e.ValueType)
+	if e.HasExpiry() {
+		fmt.Fprintf(&sb, " expiry=%d", e.ExpiryAt)
+	}
+	sb.WriteString(" ")
+	formatValue(&sb, e.Value)
+	return sb.String()
+}
+
+func formatValue(sb *strings.Builder, v rdb.Value) {
+	switch v := v.(type) {
+	case rdb.StringValue:
+		sb.WriteString(strconv.Quote(string(v)))
+	case rdb.ListValue:
+		formatStrings(sb, v.Elements)
+	case rdb.SetValue:
+		formatStrings(sb, v.Members)
+	case rdb.ZSetValue:
+		sb.WriteString("[")
+		for i, m := range v.Members {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(strconv.Quote(string(m.Member)))
+			sb.WriteString("=")
+			sb.WriteString(strconv.FormatFloat(m.Score, 'g', -1, 64))
+		}
+		sb.WriteString("]")
+	case rdb.HashValue:
+		sb.WriteString("[")
+		for i, f := range v.Fields {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(strconv.Quote(string(f.Field)))
+			sb.WriteString("=")
+			sb.WriteString(strconv.Quote(string(f.Value)))
+			if f.ExpiryAt != 0 {
+				fmt.Fprintf(sb, "@%d", f.ExpiryAt)
+			}
+		}
+		sb.WriteString("]")
+	default:
+		fmt.Fprintf(sb, "%v", v)
+	}
+}
+
+func formatStrings(sb *strings.Builder, s []rdb.RedisString) {
+	sb.WriteString("[")
+	for i, x := range s {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(strconv.Quote(string(x)))
+	}
+	sb.WriteString("]")
+}
+
+// Golden loads the dump at dumpPath and compares the formatted entries,
+// one per line in key order, with the golden file at goldenPath. If the
+// environment variable named by UpdateEnv is set, the golden file is
+// written instead.
+func Golden(t testing.TB, dumpPath, goldenPath string, opts ...rdb.Option) {
+	t.Helper()
+	entries := Load(t, dumpPath, opts...)
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = Format(e)
+	}
+	sort.Strings(lines)
+	got := strings.Join(lines, "\n") + "\n"
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.WriteFile(goldenPath, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	b, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to create it)", err, UpdateEnv)
+	}
+	if d := diffLines(splitLines(string(b)), splitLines(got)); d != "" {
+		t.Errorf("%s differs from %s (-golden +got):\n%s", dumpPath, goldenPath, d)
+	}
+}
+
+// splitLines splits s into lines, dropping the newline that ends the last
+// one, which would otherwise sort before every other line.
+func splitLines(s string) []string {
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines compares two sorted lists of lines.
+func diffLines(want, got []string) string {
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case j == len(got) || i < len(want) && want[i] < got[j]:
+			fmt.Fprintf(&sb, "- %s\n", want[i])
+			i++
+		case i == len(want) || got[j] < want[i]:
+			fmt.Fprintf(&sb, "+ %s\n", got[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return sb.String()
+}
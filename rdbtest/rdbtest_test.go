@@ -0,0 +1,178 @@
+package rdbtest_test
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+// recorder is a testing.TB that records failures instead of reporting
+// them.
+type recorder struct {
+	testing.TB
+	failures []string
+	fatal    bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatal(args ...any) {
+	r.failures = append(r.failures, fmt.Sprint(args...))
+	r.fatal = true
+	runtime.Goexit()
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Fatal(fmt.Sprintf(format, args...))
+}
+
+// record runs f with a recorder in a goroutine of its own, so that Fatal
+// can stop it.
+func record(t *testing.T, f func(tb testing.TB)) *recorder {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(r)
+	}()
+	<-done
+	return r
+}
+
+func str(db uint64, key, value string) *rdb.Entry {
+	return &rdb.Entry{DB: db, Key: rdb.RedisString(key), Value: rdb.StringValue(value)}
+}
+
+func TestDumpAndLoad(t *testing.T) {
+	entries := []*rdb.Entry{
+		str(0, "a", "1"),
+		{DB: 2, Key: rdb.RedisString("l"), ValueType: rdb.List, Value: rdb.ListValue{Elements: []rdb.RedisString{rdb.RedisString("x")}}, ExpiryAt: 1700000000000},
+	}
+	for _, version := range []int{9, 12} {
+		dump := rdbtest.Dump(t, version, entries...)
+		if want := fmt.Sprintf("REDIS%04d", version); string(dump[:9]) != want {
+			t.Errorf("got header %q, want %q", dump[:9], want)
+		}
+		rdbtest.AssertEntries(t, rdbtest.LoadBytes(t, dump), entries)
+
+		path := filepath.Join(t.TempDir(), "dump.rdb")
+		if err := os.WriteFile(path, dump, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		rdbtest.AssertFile(t, path, entries)
+		rdbtest.AssertFile(t, path, entries[:1], rdb.WithFilter(func(e *rdb.Entry) bool { return e.DB == 0 }))
+	}
+
+	for name, f := range map[string]func(tb testing.TB){
+		"missing file":  func(tb testing.TB) { rdbtest.Load(tb, filepath.Join(t.TempDir(), "none.rdb")) },
+		"not a dump":    func(tb testing.TB) { rdbtest.LoadBytes(tb, []byte("not a dump")) },
+		"truncated":     func(tb testing.TB) { rdbtest.LoadBytes(tb, rdbtest.Dump(t, 11, entries...)[:20]) },
+		"bad version":   func(tb testing.TB) { rdbtest.Dump(tb, 0) },
+		"no value":      func(tb testing.TB) { rdbtest.Dump(tb, 11, &rdb.Entry{Key: rdb.RedisString("k")}) },
+		"entry differs": func(tb testing.TB) { rdbtest.AssertEntries(tb, entries[:1], entries) },
+	} {
+		if r := record(t, f); len(r.failures) != 1 {
+			t.Errorf("%s: got failures %q", name, r.failures)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	zset := func(score float64) *rdb.Entry {
+		return &rdb.Entry{Key: rdb.RedisString("z"), ValueType: rdb.ZSetListPack, Value: rdb.ZSetValue{Members: []rdb.ZSetMember{
+			{Member: rdb.RedisString("m"), Score: score},
+		}}}
+	}
+	set := func(enc rdb.Encoding) *rdb.Entry {
+		return &rdb.Entry{Key: rdb.RedisString("s"), ValueType: rdb.SetListPack, Value: rdb.SetValue{Members: []rdb.RedisString{rdb.RedisString("a")}, Enc: enc}}
+	}
+	want := []*rdb.Entry{str(0, "a", "1"), str(0, "b", "2"), str(1, "a", "1"), zset(math.NaN()), set(0)}
+
+	// Order, NaN scores and encodings of collections do not matter.
+	got := []*rdb.Entry{set(rdb.EncodingListpack), zset(math.NaN()), str(1, "a", "1"), str(0, "b", "2"), str(0, "a", "1")}
+	if d := rdbtest.Diff(got, want); d != "" {
+		t.Errorf("got diff\n%s", d)
+	}
+
+	expiring := str(0, "b", "2")
+	expiring.ExpiryAt = 1700000000000
+	got = []*rdb.Entry{str(0, "a", "changed"), expiring, str(2, "new\xff", "x"), zset(1), set(0)}
+	wantDiff := `- db0 "a" String "1"
++ db0 "a" String "changed"
+- db0 "b" String "2"
++ db0 "b" String expiry=1700000000000 "2"
+- db0 "z" ZSetListPack ["m"=NaN]
++ db0 "z" ZSetListPack ["m"=1]
+- db1 "a" String "1"
++ db2 "new\xff" String "x"
+`
+	if d := rdbtest.Diff(got, want); d != wantDiff {
+		t.Errorf("got diff\n%s\nwant\n%s", d, wantDiff)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		e    *rdb.Entry
+		want string
+	}{
+		{str(3, "k\n", "v\x00"), `db3 "k\n" String "v\x00"`},
+		{&rdb.Entry{Key: rdb.RedisString("h"), ValueType: rdb.HashListPackEx, Value: rdb.HashValue{Fields: []rdb.HashField{
+			{Field: rdb.RedisString("f"), Value: rdb.RedisString("1"), ExpiryAt: 5},
+			{Field: rdb.RedisString("g"), Value: rdb.RedisString("2")},
+		}}}, `db0 "h" HashListPackEx ["f"="1"@5 "g"="2"]`},
+		{&rdb.Entry{Key: rdb.RedisString("l"), ValueType: rdb.ListQuickList2, ExpiryAt: 9, Value: rdb.ListValue{
+			Elements: []rdb.RedisString{rdb.RedisString("a"), rdb.RedisString("b")},
+		}}, `db0 "l" ListQuickList2 expiry=9 ["a" "b"]`},
+	}
+	for _, tt := range tests {
+		if got := rdbtest.Format(tt.e); got != tt.want {
+			t.Errorf("got %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	dir := t.TempDir()
+	dumpPath, goldenPath := filepath.Join(dir, "dump.rdb"), filepath.Join(dir, "dump.golden")
+	if err := os.WriteFile(dumpPath, rdbtest.Dump(t, 11, str(0, "b", "2"), str(0, "a", "1")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := record(t, func(tb testing.TB) { rdbtest.Golden(tb, dumpPath, goldenPath) })
+	if !r.fatal || !strings.Contains(r.failures[0], rdbtest.UpdateEnv) {
+		t.Errorf("missing golden file: got %q", r.failures)
+	}
+
+	t.Setenv(rdbtest.UpdateEnv, "1")
+	rdbtest.Golden(t, dumpPath, goldenPath)
+	b, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "db0 \"a\" String \"1\"\ndb0 \"b\" String \"2\"\n"; string(b) != want {
+		t.Errorf("wrote %q, want %q", b, want)
+	}
+
+	t.Setenv(rdbtest.UpdateEnv, "")
+	rdbtest.Golden(t, dumpPath, goldenPath)
+
+	if err := os.WriteFile(goldenPath, []byte("db0 \"a\" String \"1\"\ndb0 \"c\" String \"3\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r = record(t, func(tb testing.TB) { rdbtest.Golden(tb, dumpPath, goldenPath) })
+	if len(r.failures) != 1 || !strings.HasSuffix(r.failures[0], "+ db0 \"b\" String \"2\"\n- db0 \"c\" String \"3\"\n") {
+		t.Errorf("got %q", r.failures)
+	}
+}
@@ -0,0 +1,753 @@
+package rdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// MinVersion and MaxVersion bound the RDB versions a Reader accepts
+// without compatibility flags (see WithCompat).
+const (
+	MinVersion = 1
+	MaxVersion = 12
+)
+
+// Opcodes that may appear where a value type byte is expected.
+const (
+	opSlotInfo     = 0xf4
+	opFunction2    = 0xf5
+	opFunction     = 0xf6 // pre-GA Redis 7.0 format, which Redis rejects
+	opModuleAux    = 0xf7
+	opIdle         = 0xf8
+	opFreq         = 0xf9
+	opAux          = 0xfa
+	opResizeDB     = 0xfb
+	opExpireTimeMs = 0xfc
+	opExpireTime   = 0xfd
+	opSelectDB     = 0xfe
+	opEOF          = 0xff
+)
+
+// AuxField is an auxiliary field from the dump header area, such as
+// redis-ver or ctime.
+type AuxField struct {
+	Key   RedisString
+	Value RedisString
+}
+
+// Reader reads entries from an RDB stream.
+type Reader struct {
+	in       *input
+	version  int
+	db       uint64
+	aux      []AuxField
+	keyAux   []AuxField // auxiliary fields seen since the previous key
+	seenKeys bool
+	done     bool
+
+	compat          Compat
+	skipUnsupported bool
+	keysOnly        bool
+	strict          bool                // see WithStrict
+	filter          func(e *Entry) bool // see WithFilter
+	optErr          error               // set by an invalid option
+	captureSkipped  bool
+	skipped         []SkippedEntry
+	onOpcode        func(op byte, offset int64, payload []byte)
+	raw             bool
+	trailer         []byte
+	checksum        uint64
+	restring        func() error // replaces skipString, see recompress
+	hints           map[uint64]DBSizeHint
+	moduleAux       []ModuleAux
+	functions       []RedisString
+	slot            *SlotInfo // slot information for the keys that follow
+	hashFields      func(field []byte) bool
+	strings         codec.StringDecoder // see WithArena and WithLimits
+	maxElements     uint64
+	keys            uint64 // keys returned
+	checkpointEvery time.Duration
+	checkpoints     CheckpointStore
+	lastCheckpoint  time.Time
+	clock           func() time.Time // see WithClock
+	deferDecode     bool             // set by ReadRecord
+	deferred        []byte           // value captured for ReadRecord
+	deferredOff     int64            // offset of its record
+	prefetchWindow  int
+	prefetch        *prefetcher
+	ctx             context.Context // see WithContext
+}
+
+// NewReader returns a Reader reading from r. It reads and checks the file
+// header, returning ErrFormat if r is not an RDB stream and ErrVersion if
+// its version is not supported. The checksum at the end of the dump is
+// verified unless WithoutChecksum is given.
+func NewReader(r io.Reader, opts ...Option) (*Reader, error) {
+	rd := &Reader{in: newInput(r), clock: time.Now}
+	rd.in.hash = true
+	for _, opt := range opts {
+		opt(rd)
+	}
+	if rd.optErr != nil {
+		return nil, rd.optErr
+	}
+	rd.startPrefetch(r)
+	var header [9]byte
+	if _, err := io.ReadFull(rd.in, header[:]); err != nil {
+		rd.Close()
+		return nil, fmt.Errorf("%w: reading header: %v", ErrFormat, err)
+	}
+	v, err := rd.parseHeader(header)
+	if err != nil {
+		rd.Close()
+		return nil, err
+	}
+	rd.version = v
+	return rd, nil
+}
+
+// Version returns the RDB version of the stream.
+func (r *Reader) Version() int {
+	return r.version
+}
+
+// Aux returns the auxiliary fields found before the first key, in file
+// order. Redis writes them all there, so after the first call to ReadEntry
+// they are complete.
+func (r *Reader) Aux() []AuxField {
+	return r.aux
+}
+
+// AuxValue returns the value of the named auxiliary field.
+func (r *Reader) AuxValue(key string) (RedisString, bool) {
+	for _, a := range r.aux {
+		if string(a.Key) == key {
+			return a.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Created returns the time the dump was saved, from its ctime auxiliary
+// field. Use it as the reference time of TTL-relative analyses to see a
+// historical snapshot as it was when taken. Like Aux, it is complete after
+// the first call to ReadEntry.
+func (r *Reader) Created() (time.Time, bool) {
+	return auxCreated(r.aux)
+}
+
+// auxCreated returns the time in the ctime field of aux.
+func auxCreated(aux []AuxField) (time.Time, bool) {
+	for _, a := range aux {
+		if string(a.Key) != "ctime" {
+			continue
+		}
+		sec, err := strconv.ParseInt(string(a.Value), 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(sec, 0), true
+	}
+	return time.Time{}, false
+}
+
+// SlotInfo is the information Redis Cluster nodes write before the keys
+// of each hash slot: the slot and how many keys and keys with an expiry it
+// holds.
+type SlotInfo struct {
+	Slot    uint64
+	Keys    uint64
+	Expires uint64
+}
+
+// DBSizeHint holds the sizes a RESIZEDB opcode announces for a database,
+// which Redis uses to presize its hash tables when loading.
+type DBSizeHint struct {
+	Keys    uint64
+	Expires uint64 // keys with an expiry
+}
+
+// SizeHints returns the size hints read so far, by database.
+func (r *Reader) SizeHints() map[uint64]DBSizeHint {
+	return r.hints
+}
+
+// Functions returns the source code of the function libraries read so
+// far, in file order. Redis writes them before the keys, so after the
+// first call to ReadEntry they are complete.
+func (r *Reader) Functions() []RedisString {
+	return r.functions
+}
+
+// Trailer returns the bytes between the last key and the EOF opcode, which
+// are the auxiliary fields and other opcodes written after the keys, if
+// any. It is only set once ReadEntry has returned io.EOF with WithRaw in
+// effect.
+func (r *Reader) Trailer() []byte {
+	return r.trailer
+}
+
+// Checksum returns the CRC-64 stored at the end of the dump, which is 0 if
+// the dump was saved with rdbchecksum off or predates version 5, which
+// introduced it. It is only set once ReadEntry has returned io.EOF.
+func (r *Reader) Checksum() uint64 {
+	return r.checksum
+}
+
+// WithVerifyChecksum makes the Reader compute the CRC-64 of the dump as it
+// reads it and compare it with the stored one at the end, failing with
+// ErrChecksum if they differ. Dumps saved with rdbchecksum off store 0 and
+// are not checked, nor are dumps older than version 5, which have no
+// checksum. Readers do this by default; the option overrides an earlier
+// WithoutChecksum.
+func WithVerifyChecksum() Option {
+	return func(r *Reader) {
+		r.in.hash = true
+	}
+}
+
+// WithoutChecksum makes the Reader skip computing the CRC-64 of the dump,
+// which saves a few percent of the reading time when the dump is known to
+// be sound. A mismatched checksum then goes unnoticed.
+func WithoutChecksum() Option {
+	return func(r *Reader) {
+		r.in.hash = false
+	}
+}
+
+// WithContext makes the Reader stop once ctx is done: ReadEntry then
+// returns the error of ctx, such as context.DeadlineExceeded. ctx is
+// checked between entries, so that a long parse ends cleanly at a key
+// boundary rather than in the middle of a value.
+func WithContext(ctx context.Context) Option {
+	return func(r *Reader) {
+		r.ctx = ctx
+	}
+}
+
+// ReadEntry reads the next key from the stream. It returns io.EOF once the
+// end of the dump has been reached.
+func (r *Reader) ReadEntry() (*Entry, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	if err := r.ctxErr(); err != nil {
+		return nil, err
+	}
+	if r.checkpoints != nil {
+		if err := r.autoCheckpoint(); err != nil {
+			return nil, err
+		}
+	}
+	r.in.resetCapture()
+	var start int
+	var startOff int64
+	var kept []byte // opcodes before keys the filter dropped
+	if r.raw {
+		start, startOff = r.in.mark(), r.in.off
+	}
+	// Any number of opcodes may precede a key, in any order: database
+	// selections, size hints and auxiliary fields apply from where they
+	// appear, and the last expiry and access information seen belong to
+	// the key that follows.
+	var expiry int64
+	var access entryAccess
+	for {
+		off := r.in.off
+		op, err := r.in.ReadByte()
+		if err != nil {
+			return nil, r.fail(off, err)
+		}
+		var payload int
+		if r.onOpcode != nil {
+			payload = r.in.mark()
+		}
+		switch op {
+		case opAux:
+			key, err := codec.ReadString(r.in)
+			if err != nil {
+				return nil, r.fail(off, err)
+			}
+			val, err := codec.ReadString(r.in)
+			if err != nil {
+				return nil, r.fail(off, err)
+			}
+			if r.seenKeys {
+				r.keyAux = append(r.keyAux, AuxField{Key: key, Value: val})
+			} else {
+				r.aux = append(r.aux, AuxField{Key: key, Value: val})
+			}
+			r.emitOpcode(op, off, payload)
+		case opSlotInfo:
+			var si SlotInfo
+			if si.Slot, err = r.readLength(); err == nil {
+				if si.Keys, err = r.readLength(); err == nil {
+					si.Expires, err = r.readLength()
+				}
+			}
+			if err != nil {
+				return nil, r.fail(off, err)
+			}
+			r.slot = &si
+			r.emitOpcode(op, off, payload)
+		case opFunction2:
+			code, err := codec.ReadString(r.in)
+			if err != nil {
+				return nil, r.fail(off, err)
+			}
+			r.functions = append(r.functions, code)
+			r.emitOpcode(op, off, payload)
+		case opFunction:
+			return nil, fmt.Errorf("%w: pre-release function library at offset %d", ErrNotSupported, off)
+		case opModuleAux:
+			a, err := r.readModuleAux()
+			if err != nil {
+				return nil, r.fail(off, err)
+			}
+			r.moduleAux = append(r.moduleAux, a)
+			r.emitOpcode(op, off, payload)
+		case opSelectDB:
+			if r.db, err = r.readLength(); err != nil {
+				return nil, r.fail(off, err)
+			}
+			r.emitOpcode(op, off, payload)
+		case opResizeDB:
+			var h DBSizeHint
+			if h.Keys, err = r.readLength(); err == nil {
+				h.Expires, err = r.readLength()
+			}
+			if err != nil {
+				return nil, r.fail(off, err)
+			}
+			if r.hints == nil {
+				r.hints = make(map[uint64]DBSizeHint)
+			}
+			r.hints[r.db] = h
+			r.emitOpcode(op, off, payload)
+		case opExpireTimeMs:
+			var b [8]byte
+			if _, err := io.ReadFull(r.in, b[:]); err != nil {
+				return nil, r.fail(off, err)
+			}
+			expiry = int64(binary.LittleEndian.Uint64(b[:]))
+			r.emitOpcode(op, off, payload)
+		case opExpireTime:
+			var b [4]byte
+			if _, err := io.ReadFull(r.in, b[:]); err != nil {
+				return nil, r.fail(off, err)
+			}
+			expiry = int64(binary.LittleEndian.Uint32(b[:])) * 1000
+			r.emitOpcode(op, off, payload)
+		case opIdle:
+			if access.idle, err = r.readLength(); err != nil {
+				return nil, r.fail(off, err)
+			}
+			access.kind = AccessLRU
+			r.emitOpcode(op, off, payload)
+		case opFreq:
+			if access.freq, err = r.in.ReadByte(); err != nil {
+				return nil, r.fail(off, err)
+			}
+			access.kind = AccessLFU
+			r.emitOpcode(op, off, payload)
+		case opEOF:
+			if r.raw {
+				b := r.in.since(start)
+				r.trailer = b[:len(b)-1]
+				if kept != nil {
+					r.trailer = append(kept, r.trailer...)
+				}
+			}
+			// Versions 5 and later end with an 8 byte CRC64 checksum.
+			if r.version >= 5 {
+				crc := r.in.crc
+				var sum [8]byte
+				if _, err := io.ReadFull(r.in, sum[:]); err != nil {
+					if err != io.EOF || r.compat&CompatElastiCache == 0 {
+						return nil, r.fail(off, err)
+					}
+				}
+				r.checksum = binary.LittleEndian.Uint64(sum[:])
+				if r.in.hash && r.checksum != 0 && r.checksum != crc {
+					return nil, fmt.Errorf("%w: stored %016x, computed %016x", ErrChecksum, r.checksum, crc)
+				}
+			}
+			r.done = true
+			r.emitOpcode(op, off, payload)
+			return nil, io.EOF
+		default:
+			if ok, err := r.readCompatOpcode(op); ok {
+				if err != nil {
+					return nil, r.fail(off, err)
+				}
+				r.emitOpcode(op, off, payload)
+				continue
+			}
+			if r.onOpcode != nil {
+				r.in.since(payload) // a key, not an opcode
+			}
+			t := ValueType(op)
+			if !t.Valid() {
+				return nil, fmt.Errorf("%w: 0x%02x at offset %d", ErrBadOpCode, op, off)
+			}
+			e, err := r.readKeyValue(t, expiry, off)
+			if e != nil {
+				e.Access, e.Idle, e.Freq = access.kind, access.idle, access.freq
+				e.Slot = r.slot
+				if r.raw {
+					e.Raw, e.RawVersion = r.in.since(start), r.version
+				}
+				if r.filter != nil && !r.filter(e) {
+					if r.raw {
+						kept = append(kept, e.Raw[:off-startOff]...)
+						start, startOff = r.in.mark(), r.in.off
+					}
+					e = nil
+				} else {
+					r.keys++
+					if kept != nil {
+						e.Raw = append(kept, e.Raw...)
+					}
+				}
+			}
+			if e != nil || err != nil {
+				return e, err
+			}
+			expiry, access = 0, entryAccess{} // skipped
+			if err := r.ctxErr(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Entries returns an iterator over the entries left in the stream, for use
+// with range:
+//
+//	for e, err := range r.Entries() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The end of the dump ends the iteration without an error; any other error
+// is yielded with a nil entry and ends it too.
+func (r *Reader) Entries() iter.Seq2[*Entry, error] {
+	return func(yield func(*Entry, error) bool) {
+		for {
+			e, err := r.ReadEntry()
+			if err == io.EOF {
+				return
+			}
+			if !yield(e, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ctxErr returns the error of the context set with WithContext, if any.
+func (r *Reader) ctxErr() error {
+	if r.ctx == nil {
+		return nil
+	}
+	return r.ctx.Err()
+}
+
+// entryAccess holds the IDLE or FREQ opcode read before a key.
+type entryAccess struct {
+	kind AccessKind
+	idle uint64
+	freq uint8
+}
+
+// emitOpcode passes the opcode just read, with the payload captured since
+// the mark at payload, to the OnOpcode hook.
+func (r *Reader) emitOpcode(op byte, off int64, payload int) {
+	if r.onOpcode != nil {
+		r.onOpcode(op, off, r.in.since(payload))
+	}
+}
+
+// readKeyValue reads a key and its value. It returns nil, nil if the value
+// was skipped.
+func (r *Reader) readKeyValue(t ValueType, expiry int64, off int64) (*Entry, error) {
+	expanded := r.strings.Expanded
+	key, err := r.readString()
+	if err != nil {
+		return nil, r.fail(off, err)
+	}
+	e := &Entry{DB: r.db, Key: key, ValueType: t, ExpiryAt: expiry, Aux: r.keyAux}
+	r.keyAux = nil
+	r.seenKeys = true
+	decode := valueDecoders[t]
+	switch {
+	case r.keysOnly && t != Module:
+		if t == Module2 {
+			_, err = r.readLength() // module ID
+		}
+		if err == nil {
+			err = r.skipValue(t)
+		}
+	case decode == nil:
+		return r.unsupported(e, off)
+	case r.deferDecode:
+		// Capture the value for Record.Decode instead.
+		m := r.in.mark()
+		err = r.skipValue(t)
+		r.deferred, r.deferredOff = r.in.since(m), off
+	default:
+		e.Value, err = decode(r, t)
+	}
+	if err != nil {
+		return nil, r.fail(off, err)
+	}
+	e.Size = r.in.off - off
+	e.UncompressedSize = e.Size + int64(r.strings.Expanded-expanded)
+	return e, nil
+}
+
+// valueDecoders decode the value types the Reader supports.
+var valueDecoders = map[ValueType]func(r *Reader, t ValueType) (Value, error){
+	String:              readStringValue,
+	List:                readListValue,
+	ListZipList:         readListValue,
+	ListQuickList:       readListValue,
+	ListQuickList2:      readListValue,
+	Set:                 readSetValue,
+	SetIntSet:           readSetValue,
+	SetListPack:         readSetValue,
+	StreamListPacks:     readStreamValue,
+	StreamListPacks2:    readStreamValue,
+	StreamListPacks3:    readStreamValue,
+	ZSet:                readZSetValue,
+	ZSet2:               readZSetValue,
+	ZSetZipList:         readZSetValue,
+	ZSetListPack:        readZSetValue,
+	Hash:                readHashValue,
+	HashZipmap:          readHashValue,
+	HashZipList:         readHashValue,
+	HashListPack:        readHashValue,
+	HashMetadataPreGA:   readHashValue,
+	HashListPackExPreGA: readHashValue,
+	HashMetadata:        readHashValue,
+	HashListPackEx:      readHashValue,
+}
+
+func readStringValue(r *Reader, t ValueType) (Value, error) {
+	s, err := r.readString()
+	return StringValue(s), err
+}
+
+func readListValue(r *Reader, t ValueType) (Value, error) {
+	l, err := r.readList(t)
+	return ListValue{Elements: l, Enc: t.Encoding()}, err
+}
+
+func readSetValue(r *Reader, t ValueType) (Value, error) {
+	s, err := r.readSet(t)
+	return SetValue{Members: s, Enc: t.Encoding()}, err
+}
+
+func readZSetValue(r *Reader, t ValueType) (Value, error) {
+	z, err := r.readZSet(t)
+	return ZSetValue{Members: z, Enc: t.Encoding()}, err
+}
+
+func readHashValue(r *Reader, t ValueType) (Value, error) {
+	h, err := r.readHash(t)
+	return HashValue{Fields: h, Enc: t.Encoding()}, err
+}
+
+func readStreamValue(r *Reader, t ValueType) (Value, error) {
+	return r.readStream(t)
+}
+
+func (r *Reader) readLength() (uint64, error) {
+	n, encoded, err := codec.ReadLength(r.in)
+	if err == nil && encoded {
+		err = fmt.Errorf("%w: unexpected string encoding for a length", ErrFormat)
+	}
+	return n, err
+}
+
+func (r *Reader) readString() (RedisString, error) {
+	return r.strings.Read(r.in)
+}
+
+// readCount reads the number of elements of a collection.
+func (r *Reader) readCount() (uint64, error) {
+	n, err := r.readLength()
+	if err == nil {
+		err = codec.CheckLength("collection", n, r.maxElements)
+	}
+	return n, err
+}
+
+func (r *Reader) readStrings() ([]RedisString, error) {
+	n, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	var s []RedisString
+	for i := uint64(0); i < n; i++ {
+		v, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+	return s, nil
+}
+
+// readZSet reads a sorted set stored with scores in the string encoding
+// (ZSet), as binary doubles (ZSet2) or in a ziplist or listpack blob.
+// Members are returned in file order, which Redis writes from the highest
+// score down, except in blobs, which hold them from the lowest score up.
+func (r *Reader) readZSet(t ValueType) ([]ZSetMember, error) {
+	if t == ZSetZipList || t == ZSetListPack {
+		return r.readZSetBlob(t)
+	}
+	n, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	var z []ZSetMember
+	for i := uint64(0); i < n; i++ {
+		m, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		var score float64
+		if t == ZSet {
+			score, err = codec.ReadScore(r.in)
+		} else {
+			score, err = codec.ReadBinaryScore(r.in)
+		}
+		if err != nil {
+			return nil, err
+		}
+		z = append(z, ZSetMember{Member: m, Score: score})
+	}
+	return z, nil
+}
+
+// readZSetBlob reads a sorted set stored as a blob of alternating members
+// and scores, the scores in decimal form.
+func (r *Reader) readZSetBlob(t ValueType) ([]ZSetMember, error) {
+	blob, err := r.readString()
+	if err != nil {
+		return nil, err
+	}
+	var elems [][]byte
+	if t == ZSetZipList {
+		elems, err = codec.DecodeZiplist(blob)
+	} else {
+		elems, err = codec.DecodeListpack(blob)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(elems)%2 != 0 {
+		return nil, fmt.Errorf("%w: sorted set with an odd number of elements", ErrFormat)
+	}
+	if err := codec.CheckLength("collection", uint64(len(elems)/2), r.maxElements); err != nil {
+		return nil, err
+	}
+	z := make([]ZSetMember, len(elems)/2)
+	for i := range z {
+		score, err := strconv.ParseFloat(string(elems[2*i+1]), 64)
+		if err != nil || math.IsNaN(score) {
+			return nil, fmt.Errorf("%w: invalid score %q", ErrFormat, elems[2*i+1])
+		}
+		z[i] = ZSetMember{Member: elems[2*i], Score: score}
+	}
+	return z, nil
+}
+
+// fail annotates an error that occurred while reading the record starting
+// at off. A premature end of input is reported as io.ErrUnexpectedEOF.
+func (r *Reader) fail(off int64, err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("%w (record at offset %d)", err, off)
+}
+
+// input is the buffered source of a Reader. It keeps track of the offset
+// into the stream and, between calls to mark and since, keeps a copy of the
+// bytes read.
+type input struct {
+	r     *bufio.Reader
+	off   int64
+	buf   []byte // captured bytes
+	depth int    // number of captures in progress
+	hash  bool   // update crc, see WithoutChecksum
+	crc   uint64
+}
+
+func newInput(r io.Reader) *input {
+	return &input{r: bufio.NewReaderSize(r, 64<<10)}
+}
+
+func (in *input) Read(p []byte) (int, error) {
+	n, err := in.r.Read(p)
+	in.off += int64(n)
+	if in.hash {
+		in.crc = codec.CRC64(in.crc, p[:n])
+	}
+	if in.depth > 0 {
+		in.buf = append(in.buf, p[:n]...)
+	}
+	return n, err
+}
+
+func (in *input) ReadByte() (byte, error) {
+	c, err := in.r.ReadByte()
+	if err == nil {
+		in.off++
+		if in.hash {
+			in.crc = codec.CRC64(in.crc, []byte{c})
+		}
+		if in.depth > 0 {
+			in.buf = append(in.buf, c)
+		}
+	}
+	return c, err
+}
+
+// mark starts capturing the bytes read and returns the position to pass to
+// since. Captures nest.
+func (in *input) mark() int {
+	if in.depth == 0 {
+		in.buf = []byte{}
+	}
+	in.depth++
+	return len(in.buf)
+}
+
+// since ends the capture started at m and returns the bytes read since.
+func (in *input) since(m int) []byte {
+	in.depth--
+	if in.depth == 0 {
+		b := in.buf[m:]
+		in.buf = nil
+		return b
+	}
+	return append([]byte(nil), in.buf[m:]...)
+}
+
+// resetCapture abandons captures left open by an error.
+func (in *input) resetCapture() {
+	in.buf, in.depth = nil, 0
+}
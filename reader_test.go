@@ -0,0 +1,196 @@
+package rdb_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+func readToEnd(dump []byte, opts ...rdb.Option) error {
+	r, err := rdb.NewReader(bytes.NewReader(dump), opts...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for {
+		if _, err := r.ReadEntry(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteEntry(&rdb.Entry{Key: rdb.RedisString("k"), Value: rdb.StringValue("v")})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dump := buf.Bytes()
+	bad := append([]byte(nil), dump...)
+	bad[len(bad)-1] ^= 1
+	off := append(append([]byte(nil), dump[:len(dump)-8]...), make([]byte, 8)...)
+
+	tests := []struct {
+		name string
+		dump []byte
+		opts []rdb.Option
+		want error
+	}{
+		{"sound", dump, nil, nil},
+		{"mismatch", bad, nil, rdb.ErrChecksum},
+		{"WithoutChecksum", bad, []rdb.Option{rdb.WithoutChecksum()}, nil},
+		{"WithVerifyChecksum after WithoutChecksum", bad, []rdb.Option{rdb.WithoutChecksum(), rdb.WithVerifyChecksum()}, rdb.ErrChecksum},
+		{"rdbchecksum off", off, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := readToEnd(tt.dump, tt.opts...); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestStrict checks that WithStrict rejects corrupt ziplist and listpack
+// blobs in values the Reader steps over instead of decoding.
+func TestStrict(t *testing.T) {
+	elems := [][]byte{[]byte("a"), []byte("1"), []byte("b"), []byte("2")}
+	// corrupt replaces the terminator of a blob.
+	corrupt := func(b []byte) []byte {
+		b[len(b)-1] = 0
+		return b
+	}
+	tests := []struct {
+		name   string
+		t      rdb.ValueType
+		prefix []byte
+		blob   func() []byte
+	}{
+		{"ziplist list", rdb.ListZipList, nil, func() []byte { return codec.EncodeZiplist(elems) }},
+		{"quicklist", rdb.ListQuickList, []byte{1}, func() []byte { return codec.EncodeZiplist(elems) }},
+		{"quicklist2", rdb.ListQuickList2, []byte{1, 2}, func() []byte { return codec.EncodeListpack(elems) }},
+		{"listpack set", rdb.SetListPack, nil, func() []byte { return codec.EncodeListpack(elems) }},
+		{"listpack zset", rdb.ZSetListPack, nil, func() []byte { return codec.EncodeListpack(elems) }},
+		{"ziplist hash", rdb.HashZipList, nil, func() []byte { return codec.EncodeZiplist(elems) }},
+		{"listpackex hash", rdb.HashListPackEx, make([]byte, 8), func() []byte {
+			return codec.EncodeListpack(append(elems[:2:2], []byte("0")))
+		}},
+	}
+	readRecords := func(dump []byte, opts ...rdb.Option) error {
+		r, err := rdb.NewReader(bytes.NewReader(dump), opts...)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		for {
+			if _, err := r.ReadRecord(); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+	modes := []struct {
+		name string
+		read func(dump []byte, opts ...rdb.Option) error
+		opts []rdb.Option
+	}{
+		{"keys only", readToEnd, []rdb.Option{rdb.WithKeysOnly()}},
+		{"raw", readToEnd, []rdb.Option{rdb.WithRaw()}},
+		{"ReadRecord", readRecords, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			good := blobDump(tt.t, tt.prefix, tt.blob())
+			bad := blobDump(tt.t, tt.prefix, corrupt(tt.blob()))
+			if err := readToEnd(bad, rdb.WithKeysOnly()); err != nil {
+				t.Fatalf("stepping over the corrupt blob without WithStrict: %v", err)
+			}
+			for _, m := range modes {
+				opts := append(m.opts[:len(m.opts):len(m.opts)], rdb.WithStrict())
+				if err := m.read(good, opts...); err != nil {
+					t.Errorf("%s: %v", m.name, err)
+				}
+				if err := m.read(bad, opts...); !errors.Is(err, rdb.ErrFormat) {
+					t.Errorf("%s: got %v, want ErrFormat", m.name, err)
+				}
+			}
+		})
+	}
+}
+
+// TestCreated checks that the ctime of a dump can serve as the reference
+// time of expiry analyses, so that they see the dump as it was saved.
+func TestCreated(t *testing.T) {
+	created := time.Unix(1700000000, 0)
+	dump := func(ctime string) []byte {
+		var buf bytes.Buffer
+		w, err := rdb.NewWriter(&buf, 11)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctime != "" {
+			w.WriteAux([]byte("ctime"), []byte(ctime))
+		}
+		w.WriteEntry(str(0, "gone", "v", created.UnixMilli()-1))
+		w.WriteEntry(str(0, "live", "v", created.UnixMilli()+1))
+		w.WriteEntry(str(0, "forever", "v", 0))
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	r, err := rdb.NewReader(bytes.NewReader(dump("1700000000")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var expired []string
+	for {
+		e, err := r.ReadEntry()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		at, ok := r.Created()
+		if !ok || !at.Equal(created) {
+			t.Fatalf("got %v, %v, want %v", at, ok, created)
+		}
+		if rdb.ExpiredAt(at)(e) {
+			expired = append(expired, string(e.Key))
+		}
+	}
+	if len(expired) != 1 || expired[0] != "gone" {
+		t.Errorf("got %q expired at creation, want gone", expired)
+	}
+	info := rdb.DumpInfo{Aux: r.Aux()}
+	if at, ok := info.Created(); !ok || !at.Equal(created) {
+		t.Errorf("DumpInfo.Created: got %v, %v", at, ok)
+	}
+
+	for _, ctime := range []string{"", "yesterday"} {
+		r, err := rdb.NewReader(bytes.NewReader(dump(ctime)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.ReadEntry()
+		if at, ok := r.Created(); ok || !at.IsZero() {
+			t.Errorf("ctime %q: got %v, %v", ctime, at, ok)
+		}
+		r.Close()
+	}
+}
@@ -0,0 +1,117 @@
+package rdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// Compression selects how a Writer compresses strings.
+type Compression int
+
+const (
+	// CompressPreserve keeps the compression of entries read with WithRaw,
+	// as they are written verbatim, and compresses the strings of other
+	// entries as Redis does with rdbcompression yes.
+	CompressPreserve Compression = iota
+
+	// CompressAll recompresses every string, including those of entries
+	// read with WithRaw, as Redis does with rdbcompression yes.
+	CompressAll
+
+	// CompressNone stores every string uncompressed, as Redis does with
+	// rdbcompression no. This suits targets that cannot decompress LZF or
+	// tools that want to inspect the dump.
+	CompressNone
+)
+
+// WithCompression sets how the Writer compresses strings. The default is
+// CompressPreserve.
+func WithCompression(c Compression) WriterOption {
+	return func(w *Writer) {
+		w.compression = c
+	}
+}
+
+// recompress re-encodes every string in raw, a sequence of records as kept
+// in Entry.Raw, compressing them with LZF or not. Everything else is copied
+// as is.
+func recompress(raw []byte, compress bool) ([]byte, error) {
+	r := &Reader{in: newInput(bytes.NewReader(raw)), compat: CompatAll}
+	out := make([]byte, 0, len(raw))
+	r.in.mark()
+	r.restring = func() error {
+		out = append(out, r.in.buf...)
+		r.in.buf = r.in.buf[:0]
+		s, err := codec.ReadString(r.in)
+		if err != nil {
+			return err
+		}
+		r.in.buf = r.in.buf[:0]
+		out = codec.AppendString(out, s, compress)
+		return nil
+	}
+	for {
+		op, err := r.in.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opAux:
+			if err = r.skipString(); err == nil {
+				err = r.skipString()
+			}
+		case opSlotInfo:
+			err = r.skipLengths(3)
+		case opFunction2:
+			err = r.skipString()
+		case opModuleAux:
+			// Module ID, opcode and time of saving, then the data.
+			if err = r.skipLengths(3); err == nil {
+				err = r.skipModule2()
+			}
+		case opSelectDB:
+			_, err = r.readLength()
+		case opResizeDB:
+			err = r.skipLengths(2)
+		case opExpireTimeMs:
+			err = codec.Skip(r.in, 8)
+		case opExpireTime:
+			err = codec.Skip(r.in, 4)
+		case opIdle:
+			_, err = r.readLength()
+		case opFreq:
+			err = codec.Skip(r.in, 1)
+		default:
+			var ok bool
+			if ok, err = r.readCompatOpcode(op); ok {
+				break
+			}
+			t := ValueType(op)
+			if !t.Valid() {
+				return nil, fmt.Errorf("%w: 0x%02x", ErrBadOpCode, op)
+			}
+			if err = r.skipString(); err != nil { // key
+				break
+			}
+			if t == Module || t == Module2 {
+				if _, err = r.readLength(); err != nil {
+					break
+				}
+			}
+			err = r.skipValue(t)
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w (recompressing, at offset %d of the entry)", err, r.in.off)
+		}
+	}
+	return append(out, r.in.buf...), nil
+}
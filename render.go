@@ -0,0 +1,294 @@
+package rdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// A Table is a titled grid of cells, the form in which reports are shown
+// to humans.
+type Table struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+}
+
+// Tabular is implemented by the reports of the analyzers, such as Report,
+// MemoryReport and EvictionReport. Their fields are the data for machines,
+// their Tables the same data laid out for humans; Render writes either.
+type Tabular interface {
+	Tables() []Table
+}
+
+// Format selects how Render writes a report.
+type Format uint8
+
+const (
+	// FormatJSON writes the report itself as indented JSON.
+	FormatJSON Format = iota
+
+	// FormatText writes the tables with aligned columns for terminals.
+	FormatText
+
+	// FormatMarkdown writes the tables as Markdown, for tickets and wikis.
+	FormatMarkdown
+)
+
+var formatNames = [...]string{"json", "text", "markdown"}
+
+func (f Format) String() string {
+	if int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// ParseFormat parses a format name: json, text or markdown.
+func ParseFormat(s string) (Format, error) {
+	for i, name := range formatNames {
+		if strings.EqualFold(s, name) {
+			return Format(i), nil
+		}
+	}
+	if strings.EqualFold(s, "md") {
+		return FormatMarkdown, nil
+	}
+	return 0, fmt.Errorf("rdb: unknown report format %q", s)
+}
+
+// Render writes report to w in format f.
+func Render(w io.Writer, f Format, report Tabular) error {
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case FormatText:
+		return renderText(w, report.Tables())
+	case FormatMarkdown:
+		return renderMarkdown(w, report.Tables())
+	}
+	return fmt.Errorf("rdb: unknown report format %v", f)
+}
+
+func renderText(w io.Writer, tables []Table) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, t := range tables {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		if t.Title != "" {
+			fmt.Fprintln(tw, t.Title)
+		}
+		if len(t.Rows) == 0 {
+			fmt.Fprintln(tw, "(none)")
+			continue
+		}
+		fmt.Fprintln(tw, strings.Join(t.Columns, "\t"))
+		for _, row := range t.Rows {
+			cells := make([]string, len(row))
+			for j, c := range row {
+				cells[j] = textEscaper.Replace(c)
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+		// Flush per table, so that each aligns its own columns.
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+var (
+	textEscaper     = strings.NewReplacer("\t", " ", "\n", " ")
+	markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ", `\`, `\\`)
+)
+
+func renderMarkdown(w io.Writer, tables []Table) error {
+	var b strings.Builder
+	row := func(cells []string, esc *strings.Replacer) {
+		b.WriteString("|")
+		for _, c := range cells {
+			b.WriteString(" ")
+			if esc != nil {
+				c = esc.Replace(c)
+			}
+			b.WriteString(c)
+			b.WriteString(" |")
+		}
+		b.WriteString("\n")
+	}
+	for i, t := range tables {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if t.Title != "" {
+			fmt.Fprintf(&b, "### %s\n\n", t.Title)
+		}
+		if len(t.Rows) == 0 {
+			b.WriteString("_None._\n")
+			continue
+		}
+		row(t.Columns, markdownEscaper)
+		sep := make([]string, len(t.Columns))
+		for j := range sep {
+			sep[j] = "---"
+		}
+		row(sep, nil)
+		for _, r := range t.Rows {
+			row(r, markdownEscaper)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// FormatBytes formats a size with a binary unit, such as "1.5 MiB".
+func FormatBytes(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return strconv.FormatUint(n, 10) + " B"
+	}
+	v, i := float64(n)/1024, 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64) + " " + units[i:i+1] + "iB"
+}
+
+func formatCount(n uint64) string {
+	return strconv.FormatUint(n, 10)
+}
+
+func formatShare(f float64) string {
+	return strconv.FormatFloat(f*100, 'f', 1, 64) + "%"
+}
+
+func formatKey(key []byte) string {
+	return EscapeQuote.Apply(key)
+}
+
+// Tables implements Tabular.
+func (r Report) Tables() []Table {
+	summary := Table{Title: "Dump", Columns: []string{"Property", "Value"}}
+	add := func(k, v string) { summary.Rows = append(summary.Rows, []string{k, v}) }
+	add("Version", strconv.Itoa(r.Version))
+	if !r.Created.IsZero() {
+		add("Created", r.Created.UTC().Format(time.RFC3339))
+	}
+	add("Size", FormatBytes(uint64(r.Size)))
+	add("Keys", formatCount(r.Keys))
+	add("Expires", formatCount(r.Expires))
+	add("Checksum", fmt.Sprintf("%016x", r.Checksum))
+	for _, a := range r.Aux {
+		add("aux "+formatKey(a.Key), formatKey(a.Value))
+	}
+	dbs := Table{Title: "Databases", Columns: []string{"DB", "Keys", "Expires", "Memory", "Stored", "Hint keys", "Hint expires", "Mismatch"}}
+	for _, s := range r.DBs {
+		hk, he := "-", "-"
+		if s.HasHint {
+			hk, he = formatCount(s.Hint.Keys), formatCount(s.Hint.Expires)
+		}
+		mismatch := ""
+		if s.Mismatch() {
+			mismatch = "yes"
+		}
+		dbs.Rows = append(dbs.Rows, []string{
+			formatCount(s.DB), formatCount(s.Keys), formatCount(s.Expires),
+			FormatBytes(s.Memory), FormatBytes(s.Stored), hk, he, mismatch,
+		})
+	}
+	tables := []Table{summary, dbs}
+	if len(r.Skipped) > 0 {
+		skipped := Table{Title: "Skipped", Columns: []string{"Value type", "Keys"}}
+		for t := 0; t < 256; t++ {
+			if n, ok := r.Skipped[ValueType(t)]; ok {
+				skipped.Rows = append(skipped.Rows, []string{ValueType(t).String(), formatCount(n)})
+			}
+		}
+		tables = append(tables, skipped)
+	}
+	return append(tables, r.Memory.Tables()...)
+}
+
+// Tables implements Tabular.
+func (r MemoryReport) Tables() []Table {
+	byType := Table{Title: "Memory by type", Columns: []string{"Type", "Keys", "Memory", "Share"}}
+	for _, s := range r.ByType {
+		byType.Rows = append(byType.Rows, []string{s.Type.String(), formatCount(s.Keys), FormatBytes(s.Bytes), formatShare(s.Share)})
+	}
+	byEnc := Table{Title: "Memory by encoding", Columns: []string{"Type", "Encoding", "Keys", "Memory", "Share"}}
+	for _, s := range r.ByEncoding {
+		byEnc.Rows = append(byEnc.Rows, []string{s.Type.String(), s.Encoding.String(), formatCount(s.Keys), FormatBytes(s.Bytes), formatShare(s.Share)})
+	}
+	return []Table{byType, byEnc}
+}
+
+// Tables implements Tabular.
+func (r EvictionReport) Tables() []Table {
+	fits := "yes"
+	if !r.Fits() {
+		fits = "no"
+	}
+	summary := Table{Title: "Eviction", Columns: []string{"Property", "Value"}, Rows: [][]string{
+		{"Policy", r.Policy.String()},
+		{"Max memory", FormatBytes(r.MaxMemory)},
+		{"Keys", formatCount(r.Keys)},
+		{"Memory", FormatBytes(r.Memory)},
+		{"Evicted keys", strconv.Itoa(len(r.Evicted))},
+		{"Evicted memory", FormatBytes(r.EvictedMemory)},
+		{"Remaining", FormatBytes(r.Remaining)},
+		{"Fits", fits},
+	}}
+	evicted := Table{Title: "Evicted keys", Columns: []string{"DB", "Key", "Type", "Memory"}}
+	for _, k := range r.Evicted {
+		evicted.Rows = append(evicted.Rows, []string{formatCount(k.DB), formatKey(k.Key), k.Type.String(), FormatBytes(k.Memory)})
+	}
+	return []Table{summary, evicted}
+}
+
+// Tables implements Tabular.
+func (r ThresholdReport) Tables() []Table {
+	t := Table{Title: "Compact encoding limits exceeded", Columns: []string{"DB", "Key", "Type", "Encoding", "Setting", "Limit", "Actual"}}
+	for _, k := range r.Keys {
+		for _, v := range k.Violations {
+			t.Rows = append(t.Rows, []string{
+				formatCount(k.DB), formatKey(k.Key), k.Type.String(), k.Encoding.String(),
+				v.Setting, strconv.Itoa(v.Limit), strconv.Itoa(v.Actual),
+			})
+		}
+	}
+	return []Table{t}
+}
+
+// Tables implements Tabular.
+func (r KeyNameReport) Tables() []Table {
+	summary := Table{Title: "Key names", Columns: []string{"Property", "Value"}, Rows: [][]string{
+		{"Keys", formatCount(r.Keys)},
+		{"Total length", FormatBytes(r.Bytes)},
+		{"Memory", FormatBytes(r.Memory)},
+		{"Shortest", strconv.Itoa(r.Min)},
+		{"Longest", strconv.Itoa(r.Max)},
+		{"Mean length", strconv.FormatFloat(r.Mean, 'f', 1, 64)},
+	}}
+	lengths := Table{Title: "Key name lengths", Columns: []string{"Up to", "Keys"}}
+	for _, b := range r.Lengths {
+		lengths.Rows = append(lengths.Rows, []string{strconv.Itoa(b.Max), formatCount(b.Keys)})
+	}
+	patterns := Table{Title: "Key patterns", Columns: []string{"Pattern", "Keys", "Total length", "Memory"}}
+	for _, p := range r.Patterns {
+		patterns.Rows = append(patterns.Rows, []string{formatKey([]byte(p.Pattern)), formatCount(p.Keys), FormatBytes(p.Bytes), FormatBytes(p.Memory)})
+	}
+	tokens := Table{Title: "Key tokens", Columns: []string{"Token", "Count"}}
+	for _, t := range r.Tokens {
+		tokens.Rows = append(tokens.Rows, []string{formatKey([]byte(t.Token)), formatCount(t.Count)})
+	}
+	return []Table{summary, lengths, patterns, tokens}
+}
@@ -0,0 +1,96 @@
+package rdb_test
+
+import (
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+// tables is a report made of fixed tables.
+type tables struct {
+	Name string
+	T    []rdb.Table `json:"-"`
+}
+
+func (t tables) Tables() []rdb.Table { return t.T }
+
+func TestRender(t *testing.T) {
+	report := tables{Name: "r", T: []rdb.Table{
+		{Title: "Keys", Columns: []string{"Key", "Size"}, Rows: [][]string{
+			{"a", "1 B"},
+			{"long\tname\nhere", "2.0 KiB"},
+			{`pipe|back\slash`, "3"},
+		}},
+		{Title: "Empty", Columns: []string{"X"}},
+	}}
+	tests := []struct {
+		f    rdb.Format
+		want string
+	}{
+		{rdb.FormatText, "Keys\n" +
+			"Key              Size\n" +
+			"a                1 B\n" +
+			"long name here   2.0 KiB\n" +
+			"pipe|back\\slash  3\n" +
+			"\n" +
+			"Empty\n" +
+			"(none)\n"},
+		{rdb.FormatMarkdown, "### Keys\n\n" +
+			"| Key | Size |\n" +
+			"| --- | --- |\n" +
+			"| a | 1 B |\n" +
+			"| long\tname here | 2.0 KiB |\n" +
+			"| pipe\\|back\\\\slash | 3 |\n" +
+			"\n" +
+			"### Empty\n\n" +
+			"_None._\n"},
+		{rdb.FormatJSON, "{\n  \"Name\": \"r\"\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.f.String(), func(t *testing.T) {
+			var b strings.Builder
+			if err := rdb.Render(&b, tt.f, report); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+	if err := rdb.Render(&strings.Builder{}, rdb.Format(9), report); err == nil {
+		t.Error("no error for an unknown format")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for s, want := range map[string]rdb.Format{
+		"json": rdb.FormatJSON, "TEXT": rdb.FormatText, "markdown": rdb.FormatMarkdown, "md": rdb.FormatMarkdown,
+	} {
+		if f, err := rdb.ParseFormat(s); err != nil || f != want {
+			t.Errorf("%s: got %v, %v, want %v", s, f, err, want)
+		}
+	}
+	if _, err := rdb.ParseFormat("html"); err == nil {
+		t.Error("html was accepted")
+	}
+	if s := rdb.Format(9).String(); s != "Format(9)" {
+		t.Errorf("got %q", s)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[uint64]string{
+		0:       "0 B",
+		1023:    "1023 B",
+		1024:    "1.0 KiB",
+		1536:    "1.5 KiB",
+		1 << 20: "1.0 MiB",
+		5 << 40: "5.0 TiB",
+		1 << 63: "8.0 EiB",
+	} {
+		if got := rdb.FormatBytes(n); got != want {
+			t.Errorf("%d: got %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// AppendRESP appends the command to b in the Redis protocol, as an array of
+// bulk strings.
+func (c Command) AppendRESP(b []byte) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(c)), 10)
+	b = append(b, '\r', '\n')
+	for _, arg := range c {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(arg)), 10)
+		b = append(b, '\r', '\n')
+		b = append(b, arg...)
+		b = append(b, '\r', '\n')
+	}
+	return b
+}
+
+// commandBatch is the number of elements added per command, so that huge
+// collections do not make a single huge command.
+const commandBatch = 512
+
+// EntryCommands returns the commands that recreate e on a server: SET,
+// RPUSH, SADD, ZADD, HSET and HPEXPIREAT for fields that expire, or those
+// of StreamValue.Commands, followed by PEXPIREAT if the key expires. Large
+// collections are added in batches of 512 elements. It returns nil for
+// values it cannot recreate, such as undecoded module values.
+// RestoreOptions.Commands offers other ways of restoring keys and their
+// expiry.
+func EntryCommands(e *Entry) []Command {
+	var o RestoreOptions
+	return o.Commands(e)
+}
+
+// valueCommands returns the commands that recreate the value of e, or nil.
+func valueCommands(e *Entry) []Command {
+	var cmds []Command
+	batch := func(name string, n int, add func(cmd Command, i int) Command) {
+		for i := 0; i < n; i += commandBatch {
+			cmd := Command{RedisString(name), e.Key}
+			for j := i; j < n && j < i+commandBatch; j++ {
+				cmd = add(cmd, j)
+			}
+			cmds = append(cmds, cmd)
+		}
+	}
+	switch v := e.Value.(type) {
+	case StringValue:
+		cmds = append(cmds, Command{RedisString("SET"), e.Key, RedisString(v)})
+	case ListValue:
+		batch("RPUSH", len(v.Elements), func(cmd Command, i int) Command {
+			return append(cmd, v.Elements[i])
+		})
+	case SetValue:
+		batch("SADD", len(v.Members), func(cmd Command, i int) Command {
+			return append(cmd, v.Members[i])
+		})
+	case ZSetValue:
+		batch("ZADD", len(v.Members), func(cmd Command, i int) Command {
+			m := v.Members[i]
+			return append(cmd, RedisString(formatScore(m.Score)), m.Member)
+		})
+	case HashValue:
+		batch("HSET", len(v.Fields), func(cmd Command, i int) Command {
+			return append(cmd, v.Fields[i].Field, v.Fields[i].Value)
+		})
+		for _, f := range v.Fields {
+			if f.ExpiryAt != 0 {
+				at := RedisString(strconv.FormatInt(f.ExpiryAt, 10))
+				cmds = append(cmds, Command{RedisString("HPEXPIREAT"), e.Key, at, RedisString("FIELDS"), RedisString("1"), f.Field})
+			}
+		}
+	case *StreamValue:
+		cmds = v.Commands(e.Key)
+	}
+	return cmds
+}
+
+// TTLMode selects how restore output sets the expiry of keys.
+type TTLMode int
+
+const (
+	// TTLAbsolute keeps the expiry time of the dump: PEXPIREAT, or RESTORE
+	// with ABSTTL.
+	TTLAbsolute TTLMode = iota
+
+	// TTLRelative converts the expiry into the time left at the reference
+	// time: PEXPIRE, or RESTORE with a TTL. Keys then live as long on the
+	// target as they had left when the dump was taken, if the reference
+	// time is the time of the dump.
+	TTLRelative
+)
+
+// ExpiredPolicy says what restore output does with keys that have already
+// expired at the reference time.
+type ExpiredPolicy int
+
+const (
+	// ExpiredKeep restores them with their expiry, which makes the
+	// server drop them.
+	ExpiredKeep ExpiredPolicy = iota
+
+	// ExpiredDrop leaves them out.
+	ExpiredDrop
+
+	// ExpiredClamp restores them with RestoreOptions.MinTTL left to live.
+	ExpiredClamp
+)
+
+// RestoreOptions controls how restore output recreates keys and their
+// expiry. The zero value writes data commands followed by PEXPIREAT.
+type RestoreOptions struct {
+	TTL     TTLMode
+	Expired ExpiredPolicy
+
+	// Now is the reference time of TTLRelative and ExpiredPolicy, such as
+	// the time of the dump given by Reader.Created. While it is zero,
+	// expiries stay absolute and no key counts as expired. RESPExporter
+	// and rdbsync.Restorer use the ctime of the dump they receive instead
+	// of a zero Now.
+	Now time.Time
+
+	// MinTTL is the time to live of expired keys with ExpiredClamp. It is
+	// at least a millisecond.
+	MinTTL time.Duration
+
+	// Restore makes the output write a RESTORE command with a DUMP
+	// payload per key instead of data commands, which is faster to load
+	// and keeps huge collections in a single command. Payloads are of
+	// PayloadVersion, 9 if 0, which the target server must support.
+	// Values the version cannot hold, such as streams before version 9,
+	// still use data commands.
+	Restore        bool
+	PayloadVersion int
+
+	// Replace adds REPLACE to RESTORE commands, overwriting existing keys
+	// instead of failing.
+	Replace bool
+}
+
+// Commands returns the commands that recreate e, or nil for values they
+// cannot recreate and for expired keys dropped by ExpiredDrop.
+func (o *RestoreOptions) Commands(e *Entry) []Command {
+	expiry := e.ExpiryAt
+	now := o.Now
+	if expiry != 0 && !now.IsZero() && o.Expired != ExpiredKeep && expiry <= now.UnixMilli() {
+		if o.Expired == ExpiredDrop {
+			return nil
+		}
+		expiry = now.Add(max(o.MinTTL, time.Millisecond)).UnixMilli()
+	}
+	// A relative TTL of 0 or less is an error for RESTORE and a deletion
+	// for PEXPIRE: keep the absolute time of keys already expired.
+	relative := o.TTL == TTLRelative && !now.IsZero() && (expiry == 0 || expiry > now.UnixMilli())
+
+	if o.Restore {
+		payload, err := DumpPayload(e, o.payloadVersion())
+		if err == nil {
+			cmd := Command{RedisString("RESTORE"), e.Key}
+			switch {
+			case expiry == 0:
+				cmd = append(cmd, RedisString("0"))
+			case relative:
+				cmd = append(cmd, RedisString(strconv.FormatInt(expiry-now.UnixMilli(), 10)))
+			default:
+				cmd = append(cmd, RedisString(strconv.FormatInt(expiry, 10)))
+			}
+			cmd = append(cmd, payload)
+			if o.Replace {
+				cmd = append(cmd, RedisString("REPLACE"))
+			}
+			if expiry != 0 && !relative {
+				cmd = append(cmd, RedisString("ABSTTL"))
+			}
+			return []Command{cmd}
+		}
+	}
+	cmds := valueCommands(e)
+	if cmds == nil || expiry == 0 {
+		return cmds
+	}
+	if relative {
+		return append(cmds, Command{RedisString("PEXPIRE"), e.Key,
+			RedisString(strconv.FormatInt(expiry-now.UnixMilli(), 10))})
+	}
+	return append(cmds, Command{RedisString("PEXPIREAT"), e.Key,
+		RedisString(strconv.FormatInt(expiry, 10))})
+}
+
+func (o *RestoreOptions) payloadVersion() int {
+	if o.PayloadVersion == 0 {
+		return 9
+	}
+	return o.PayloadVersion
+}
+
+// DumpPayload returns the value of e serialized as the DUMP command does,
+// for use with RESTORE: the value type and the value as in a dump of the
+// given RDB version, followed by the version and a CRC-64 checksum.
+func DumpPayload(e *Entry, version int) ([]byte, error) {
+	t, value, err := encodeValue(e, version, true)
+	if err != nil {
+		return nil, err
+	}
+	b := append([]byte{byte(t)}, value...)
+	b = binary.LittleEndian.AppendUint16(b, uint16(version))
+	return binary.LittleEndian.AppendUint64(b, codec.CRC64(0, b)), nil
+}
+
+// formatScore formats a score the way ZADD accepts it. ZADD rejects NaN.
+func formatScore(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', 17, 64)
+}
+
+// RESPExporter writes the commands recreating each entry in the Redis
+// protocol, preceded by SELECT when the database changes. The output can
+// be piped into redis-cli --pipe to restore a dump into a live server.
+type RESPExporter struct {
+	NopSink // SelectDB
+
+	// Options controls how keys and their expiry are recreated.
+	Options RestoreOptions
+
+	created time.Time // of the dump given to Begin
+	out     *transformedOutput
+	w       *bufio.Writer
+	buf     []byte
+	db      uint64
+	dbSet   bool
+}
+
+// NewRESPExporter returns a RESPExporter writing to w through the given
+// transforms.
+func NewRESPExporter(w io.Writer, transforms ...Transform) (*RESPExporter, error) {
+	out, err := wrapOutput(w, transforms)
+	if err != nil {
+		return nil, err
+	}
+	return &RESPExporter{out: out, w: bufio.NewWriter(out)}, nil
+}
+
+// Add writes the commands for e. Values the commands cannot recreate are
+// left out.
+func (x *RESPExporter) Add(e *Entry) error {
+	o := x.Options
+	if o.Now.IsZero() {
+		o.Now = x.created
+	}
+	cmds := o.Commands(e)
+	if cmds == nil {
+		return nil
+	}
+	b := x.buf[:0]
+	if !x.dbSet || x.db != e.DB {
+		b = NewCommand("SELECT", strconv.FormatUint(e.DB, 10)).AppendRESP(b)
+		x.db, x.dbSet = e.DB, true
+	}
+	for _, c := range cmds {
+		b = c.AppendRESP(b)
+	}
+	x.buf = b
+	_, err := x.w.Write(b)
+	return err
+}
+
+// Close flushes the output and closes the transforms. It does not close
+// the underlying writer.
+func (x *RESPExporter) Close() error {
+	err := x.w.Flush()
+	if cerr := x.out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Begin implements Sink. The ctime of the dump becomes the reference time
+// of Options if its Now is zero.
+func (x *RESPExporter) Begin(info DumpInfo) error {
+	x.created, _ = info.Created()
+	return nil
+}
+
+// WriteEntry implements Sink by calling Add.
+func (x *RESPExporter) WriteEntry(e *Entry) error { return x.Add(e) }
+
+// End implements Sink by calling Close.
+func (x *RESPExporter) End() error { return x.Close() }
@@ -0,0 +1,82 @@
+package rdb_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestRestoreOptionsNow(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	live := str(0, "live", "v", now.UnixMilli()+1500)
+	gone := str(0, "gone", "v", now.UnixMilli()-1)
+	tests := []struct {
+		name string
+		opts rdb.RestoreOptions
+		e    *rdb.Entry
+		want []string
+	}{
+		{"absolute", rdb.RestoreOptions{Now: now}, live, []string{"SET live v", "PEXPIREAT live 1700000001500"}},
+		{"relative", rdb.RestoreOptions{TTL: rdb.TTLRelative, Now: now}, live, []string{"SET live v", "PEXPIRE live 1500"}},
+		{"drop", rdb.RestoreOptions{Expired: rdb.ExpiredDrop, Now: now}, gone, nil},
+		{"clamp", rdb.RestoreOptions{Expired: rdb.ExpiredClamp, MinTTL: time.Second, Now: now}, gone, []string{"SET gone v", "PEXPIREAT gone 1700000001000"}},
+		// Without a reference time, expiries stay absolute and nothing
+		// counts as expired.
+		{"relative without Now", rdb.RestoreOptions{TTL: rdb.TTLRelative}, live, []string{"SET live v", "PEXPIREAT live 1700000001500"}},
+		{"drop without Now", rdb.RestoreOptions{Expired: rdb.ExpiredDrop}, gone, []string{"SET gone v", "PEXPIREAT gone 1699999999999"}},
+		{"restore without Now", rdb.RestoreOptions{TTL: rdb.TTLRelative, Restore: true}, live, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := commandStrings(tt.opts.Commands(tt.e))
+			if tt.opts.Restore {
+				// The payload is binary: check the arguments around it.
+				if len(got) != 1 || !strings.HasPrefix(got[0], "RESTORE live 1700000001500 ") || !strings.HasSuffix(got[0], " ABSTTL") {
+					t.Errorf("got %q", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRESPExporterCreated checks that the exporter takes the reference
+// time of relative TTLs from the ctime of the dump when Now is not set.
+func TestRESPExporterCreated(t *testing.T) {
+	ctime := []rdb.AuxField{{Key: rdb.RedisString("ctime"), Value: rdb.RedisString("1700000000")}}
+	for _, tt := range []struct {
+		name string
+		aux  []rdb.AuxField
+		now  time.Time
+		want string
+	}{
+		{"ctime", ctime, time.Time{}, "PEXPIRE k 1500"},
+		{"Now over ctime", ctime, time.UnixMilli(1700000001000), "PEXPIRE k 500"},
+		{"neither", nil, time.Time{}, "PEXPIREAT k 1700000001500"},
+	} {
+		var buf bytes.Buffer
+		x, err := rdb.NewRESPExporter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x.Options = rdb.RestoreOptions{TTL: rdb.TTLRelative, Now: tt.now}
+		if err := x.Begin(rdb.DumpInfo{Version: 11, Aux: tt.aux}); err != nil {
+			t.Fatal(err)
+		}
+		x.WriteEntry(str(0, "k", "v", 1700000001500))
+		if err := x.End(); err != nil {
+			t.Fatal(err)
+		}
+		want := string(rdb.NewCommand(strings.Fields(tt.want)...).AppendRESP(nil))
+		if !strings.HasSuffix(buf.String(), want) {
+			t.Errorf("%s: got %q, want it to end with %q", tt.name, buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package rdb
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Sampler keeps a uniform random sample of the keys of a dump, of at most
+// N keys per stratum: per type, per key prefix or any other grouping. Rare
+// types and prefixes are thus represented as well as common ones, which
+// makes the sample suited to building synthetic workloads that resemble a
+// production snapshot. It uses reservoir sampling, so memory is bounded by
+// the sample size however large the dump. Feed it every entry with Add,
+// then call Samples.
+type Sampler struct {
+	N int
+
+	// Stratum names the stratum of an entry. If nil, all entries are
+	// sampled together.
+	Stratum func(e *Entry) string
+
+	// Seed seeds the random choice; samples are reproducible for a given
+	// seed and input.
+	Seed int64
+
+	rnd    *rand.Rand
+	strata map[string]*Stratum
+}
+
+// Stratum is the sample of one stratum.
+type Stratum struct {
+	Name    string
+	Seen    uint64   // number of keys in the stratum
+	Entries []*Entry // at most N keys, in no particular order
+}
+
+// NewSampler returns a Sampler keeping n keys per stratum, as named by
+// stratum.
+func NewSampler(n int, stratum func(e *Entry) string) *Sampler {
+	return &Sampler{N: n, Stratum: stratum}
+}
+
+// ByType is a Sampler stratum grouping keys by type.
+func ByType(e *Entry) string {
+	return e.Type().String()
+}
+
+// ByPrefix returns a Sampler stratum grouping keys by their first depth
+// segments, see KeyPrefix.
+func ByPrefix(sep byte, depth int) func(e *Entry) string {
+	return func(e *Entry) string {
+		return KeyPrefix(e.Key, sep, depth)
+	}
+}
+
+// Add offers a single entry to the sample. The Sampler keeps e, which must
+// not be modified afterwards.
+func (s *Sampler) Add(e *Entry) {
+	if s.strata == nil {
+		s.strata = make(map[string]*Stratum)
+		s.rnd = rand.New(rand.NewSource(s.Seed))
+	}
+	var name string
+	if s.Stratum != nil {
+		name = s.Stratum(e)
+	}
+	st := s.strata[name]
+	if st == nil {
+		st = &Stratum{Name: name}
+		s.strata[name] = st
+	}
+	st.Seen++
+	if len(st.Entries) < s.N {
+		st.Entries = append(st.Entries, e)
+	} else if i := s.rnd.Int63n(int64(st.Seen)); i < int64(s.N) {
+		st.Entries[i] = e
+	}
+}
+
+// Samples returns the sample of every stratum seen so far, sorted by name.
+func (s *Sampler) Samples() []Stratum {
+	out := make([]Stratum, 0, len(s.strata))
+	for _, st := range s.strata {
+		out = append(out, Stratum{Name: st.Name, Seen: st.Seen, Entries: append([]*Entry(nil), st.Entries...)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,71 @@
+package rdb_test
+
+import (
+	"strconv"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestSampler(t *testing.T) {
+	var entries []*rdb.Entry
+	for i := 0; i < 10; i++ {
+		entries = append(entries, str(0, "user:"+strconv.Itoa(i), "v", 0))
+	}
+	entries = append(entries,
+		str(0, "cache:1", "v", 0),
+		&rdb.Entry{Key: rdb.RedisString("queue:1"), ValueType: rdb.ListQuickList2, Value: rdb.ListValue{Elements: strs("a")}},
+	)
+	sample := func(seed int64, stratum func(*rdb.Entry) string) []rdb.Stratum {
+		s := rdb.NewSampler(3, stratum)
+		s.Seed = seed
+		for _, e := range entries {
+			s.Add(e)
+		}
+		return s.Samples()
+	}
+	keys := func(st rdb.Stratum) []string {
+		var k []string
+		for _, e := range st.Entries {
+			k = append(k, string(e.Key))
+		}
+		return k
+	}
+
+	got := sample(1, rdb.ByPrefix(':', 1))
+	if len(got) != 3 || got[0].Name != "cache" || got[1].Name != "queue" || got[2].Name != "user" {
+		t.Fatalf("got strata %+v", got)
+	}
+	if got[0].Seen != 1 || len(got[0].Entries) != 1 || got[2].Seen != 10 || len(got[2].Entries) != 3 {
+		t.Errorf("got %+v", got)
+	}
+	// The sample is reproducible for a seed.
+	first := keys(got[2])
+	again := keys(sample(1, rdb.ByPrefix(':', 1))[2])
+	if len(first) != 3 || len(again) != 3 || first[0] != again[0] || first[1] != again[1] || first[2] != again[2] {
+		t.Errorf("seed 1 sampled %v, then %v", first, again)
+	}
+
+	byType := sample(1, rdb.ByType)
+	if len(byType) != 2 || byType[0].Name != "list" || byType[0].Seen != 1 || byType[1].Name != "string" || byType[1].Seen != 11 {
+		t.Errorf("by type: got %+v", byType)
+	}
+	if all := sample(1, nil); len(all) != 1 || all[0].Name != "" || all[0].Seen != 12 || len(all[0].Entries) != 3 {
+		t.Errorf("one stratum: got %+v", all)
+	}
+
+	// Every user key is as likely to be kept: 3 out of 10.
+	counts := make(map[string]int)
+	const runs = 2000
+	for seed := int64(0); seed < runs; seed++ {
+		for _, k := range keys(sample(seed, rdb.ByPrefix(':', 1))[2]) {
+			counts[k]++
+		}
+	}
+	for i := 0; i < 10; i++ {
+		k := "user:" + strconv.Itoa(i)
+		if n := counts[k]; n < runs*3/10*8/10 || n > runs*3/10*12/10 {
+			t.Errorf("%s kept %d times out of %d, want about %d", k, n, runs, runs*3/10)
+		}
+	}
+}
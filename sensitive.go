@@ -0,0 +1,302 @@
+package rdb
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Detector finds one kind of sensitive data, such as email addresses, in
+// keys and values.
+type Detector struct {
+	Name string
+
+	// Pattern matches candidates.
+	Pattern *regexp.Regexp
+
+	// Check, if not nil, confirms a candidate, for data with a checksum or
+	// structure a regular expression cannot verify.
+	Check func(match []byte) bool
+
+	// Redact, if not nil, masks a match for reports. By default all but
+	// its first and last two bytes are masked.
+	Redact func(match []byte) string
+}
+
+// RegexpDetector returns a Detector reporting the matches of re.
+func RegexpDetector(name string, re *regexp.Regexp) Detector {
+	return Detector{Name: name, Pattern: re}
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	cardPattern  = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	jwtPattern   = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+)
+
+// EmailDetector returns a Detector for email addresses. Samples keep the
+// first byte of the local part and the domain.
+func EmailDetector() Detector {
+	return Detector{Name: "email", Pattern: emailPattern, Redact: func(m []byte) string {
+		at := bytes.LastIndexByte(m, '@')
+		return string(m[:1]) + "***" + string(m[at:])
+	}}
+}
+
+// CardNumberDetector returns a Detector for payment card numbers: 13 to 19
+// digits, optionally grouped with spaces or dashes, that pass the Luhn
+// check. Samples keep the last four digits only, as PCI DSS allows.
+func CardNumberDetector() Detector {
+	return Detector{
+		Name:    "card-number",
+		Pattern: cardPattern,
+		Check:   func(m []byte) bool { return luhn(digitsOf(m)) },
+		Redact: func(m []byte) string {
+			d := digitsOf(m)
+			return strings.Repeat("*", len(d)-4) + string(d[len(d)-4:])
+		},
+	}
+}
+
+// JWTDetector returns a Detector for JSON Web Tokens. Samples keep the
+// start of the header, which holds the algorithm and no secret.
+func JWTDetector() Detector {
+	return Detector{Name: "jwt", Pattern: jwtPattern, Redact: func(m []byte) string {
+		return string(m[:min(len(m), 12)]) + "…"
+	}}
+}
+
+// DefaultDetectors returns the detectors SensitiveScan uses by default:
+// email addresses, card numbers and JSON Web Tokens.
+func DefaultDetectors() []Detector {
+	return []Detector{EmailDetector(), CardNumberDetector(), JWTDetector()}
+}
+
+func digitsOf(b []byte) []byte {
+	d := make([]byte, 0, len(b))
+	for _, c := range b {
+		if c >= '0' && c <= '9' {
+			d = append(d, c)
+		}
+	}
+	return d
+}
+
+// luhn reports whether the decimal digits d pass the Luhn check.
+func luhn(d []byte) bool {
+	sum := 0
+	for i := range d {
+		n := int(d[len(d)-1-i] - '0')
+		if i%2 == 1 {
+			if n *= 2; n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
+
+func redact(m []byte) string {
+	if len(m) <= 4 {
+		return strings.Repeat("*", len(m))
+	}
+	return string(m[:2]) + strings.Repeat("*", min(len(m)-4, 8)) + string(m[len(m)-2:])
+}
+
+// SensitiveScan looks for sensitive data in keys and values, for GDPR or
+// PCI audits of backups. Every string of an entry is checked: the key,
+// string values, the elements of lists and sets, the members of sorted
+// sets, the fields and values of hashes and those of stream entries. Feed
+// it every entry with Add, then call Report.
+type SensitiveScan struct {
+	// Detectors lists the kinds of data to look for; nil means
+	// DefaultDetectors.
+	Detectors []Detector
+
+	// SkipKeys leaves key names out of the scan.
+	SkipKeys bool
+
+	// MaxFindings bounds the findings kept for the report; further ones
+	// are only counted. 0 means 1000.
+	MaxFindings int
+
+	keys    uint64
+	flagged uint64
+	counts  map[string]*SensitiveCount
+	list    []SensitiveFinding
+}
+
+// SensitiveFinding is a key holding data found by a detector. Key is
+// reported as is, so that the key can be located; only samples are
+// redacted.
+type SensitiveFinding struct {
+	Detector string
+	DB       uint64
+	Key      RedisString
+	Type     Type
+
+	// Where is the part of the entry the sample was found in: "key",
+	// "value" or, for hashes and streams, "field".
+	Where   string
+	Sample  string // redacted first match
+	Matches uint64 // matches in the entry
+}
+
+// SensitiveCount counts the findings of a detector.
+type SensitiveCount struct {
+	Detector string
+	Keys     uint64
+	Matches  uint64
+}
+
+// SensitiveReport is the result of SensitiveScan.
+type SensitiveReport struct {
+	Keys    uint64 // keys scanned
+	Flagged uint64 // keys with at least one finding
+
+	// Detectors counts the findings of every detector that found
+	// something, in detector order.
+	Detectors []SensitiveCount
+
+	// Findings lists a finding per key and detector in the order they
+	// were found, up to SensitiveScan.MaxFindings; Truncated reports
+	// whether some were left out.
+	Findings  []SensitiveFinding
+	Truncated bool
+}
+
+func (s *SensitiveScan) detectors() []Detector {
+	if s.Detectors == nil {
+		return DefaultDetectors()
+	}
+	return s.Detectors
+}
+
+// Add scans a single entry.
+func (s *SensitiveScan) Add(e *Entry) {
+	s.keys++
+	if s.counts == nil {
+		s.counts = make(map[string]*SensitiveCount)
+	}
+	detectors := s.detectors()
+	found := make([]SensitiveFinding, len(detectors))
+	scan := func(where string, b []byte) {
+		for i := range detectors {
+			d := &detectors[i]
+			for _, loc := range d.Pattern.FindAllIndex(b, -1) {
+				m := b[loc[0]:loc[1]]
+				if d.Check != nil && !d.Check(m) {
+					continue
+				}
+				f := &found[i]
+				if f.Matches == 0 {
+					f.Where = where
+					if d.Redact != nil {
+						f.Sample = d.Redact(m)
+					} else {
+						f.Sample = redact(m)
+					}
+				}
+				f.Matches++
+			}
+		}
+	}
+	if !s.SkipKeys {
+		scan("key", e.Key)
+	}
+	switch v := e.Value.(type) {
+	case StringValue:
+		scan("value", RedisString(v))
+	case ListValue, SetValue:
+		elems, _ := stringsOf(v)
+		for _, x := range elems {
+			scan("value", x)
+		}
+	case ZSetValue:
+		for _, m := range v.Members {
+			scan("value", m.Member)
+		}
+	case HashValue:
+		for _, f := range v.Fields {
+			scan("field", f.Field)
+			scan("value", f.Value)
+		}
+	case *StreamValue:
+		for _, se := range v.Entries {
+			for _, f := range se.Fields {
+				scan("field", f.Field)
+				scan("value", f.Value)
+			}
+		}
+	}
+
+	flagged := false
+	for i, f := range found {
+		if f.Matches == 0 {
+			continue
+		}
+		flagged = true
+		name := detectors[i].Name
+		c := s.counts[name]
+		if c == nil {
+			c = &SensitiveCount{Detector: name}
+			s.counts[name] = c
+		}
+		c.Keys++
+		c.Matches += f.Matches
+		limit := s.MaxFindings
+		if limit <= 0 {
+			limit = 1000
+		}
+		if len(s.list) < limit {
+			f.Detector, f.DB, f.Key, f.Type = name, e.DB, e.Key, e.Type()
+			s.list = append(s.list, f)
+		}
+	}
+	if flagged {
+		s.flagged++
+	}
+}
+
+// Report returns the findings so far.
+func (s *SensitiveScan) Report() SensitiveReport {
+	rep := SensitiveReport{Keys: s.keys, Flagged: s.flagged, Findings: s.list}
+	var total uint64
+	for _, c := range s.counts {
+		rep.Detectors = append(rep.Detectors, *c)
+		total += c.Keys
+	}
+	rep.Truncated = total > uint64(len(s.list))
+	order := make(map[string]int)
+	detectors := s.detectors()
+	for i := len(detectors) - 1; i >= 0; i-- {
+		order[detectors[i].Name] = i
+	}
+	sort.Slice(rep.Detectors, func(i, j int) bool {
+		return order[rep.Detectors[i].Detector] < order[rep.Detectors[j].Detector]
+	})
+	return rep
+}
+
+// Tables implements Tabular.
+func (r SensitiveReport) Tables() []Table {
+	counts := Table{Title: "Sensitive data", Columns: []string{"Detector", "Keys", "Matches"}}
+	for _, c := range r.Detectors {
+		counts.Rows = append(counts.Rows, []string{c.Detector, formatCount(c.Keys), formatCount(c.Matches)})
+	}
+	title := "Flagged keys"
+	if r.Truncated {
+		title += " (first " + strconv.Itoa(len(r.Findings)) + ")"
+	}
+	list := Table{Title: title, Columns: []string{"Detector", "DB", "Key", "Type", "Where", "Matches", "Sample"}}
+	for _, f := range r.Findings {
+		list.Rows = append(list.Rows, []string{
+			f.Detector, formatCount(f.DB), formatKey(f.Key), f.Type.String(),
+			f.Where, formatCount(f.Matches), formatKey([]byte(f.Sample)),
+		})
+	}
+	return []Table{counts, list}
+}
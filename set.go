@@ -0,0 +1,39 @@
+package rdb
+
+import (
+	"strconv"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// readSet reads a set of type t: a sequence of strings, a listpack blob
+// of members, or an intset blob whose members are returned in decimal
+// form, as SMEMBERS replies with them.
+func (r *Reader) readSet(t ValueType) ([]RedisString, error) {
+	if t == Set {
+		return r.readStrings()
+	}
+	blob, err := r.readString()
+	if err != nil {
+		return nil, err
+	}
+	if t == SetListPack {
+		elems, err := codec.DecodeListpack(blob)
+		if err != nil {
+			return nil, err
+		}
+		return r.blobStrings(elems)
+	}
+	var is codec.Intset
+	if err := is.Decode(blob); err != nil {
+		return nil, err
+	}
+	if err := codec.CheckLength("collection", uint64(is.Len()), r.maxElements); err != nil {
+		return nil, err
+	}
+	s := make([]RedisString, is.Len())
+	for i := range s {
+		s[i] = strconv.AppendInt(nil, is.At(i), 10)
+	}
+	return s, nil
+}
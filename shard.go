@@ -0,0 +1,100 @@
+package rdb
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// A ShardFunc maps a key to the index of the shard that holds it. It lets
+// the split and restore features follow client-side sharding schemes, as
+// used with twemproxy or Envoy, as well as Redis Cluster slots.
+type ShardFunc func(key []byte) int
+
+// ModuloShard returns a ShardFunc spreading keys over n shards by the
+// FNV-1a 64-bit hash of their hash tag modulo n, twemproxy's fnv1a_64 hash
+// with modula distribution.
+func ModuloShard(n int) ShardFunc {
+	return func(key []byte) int {
+		h := fnv.New64a()
+		h.Write(HashTag(key))
+		return int(h.Sum64() % uint64(n))
+	}
+}
+
+// ClusterShard returns a ShardFunc mapping keys to the nodes of a Redis
+// Cluster by their hash slot, with the node names in the order of their
+// indexes. Every slot must be assigned.
+func ClusterShard(ranges []SlotRange) (ShardFunc, []string, error) {
+	nodes, err := slotNodes(ranges)
+	if err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	index := make(map[string]int)
+	var shards [ClusterSlots]int
+	for s, n := range nodes {
+		if n == "" {
+			return nil, nil, fmt.Errorf("rdb: slot %d is not assigned", s)
+		}
+		i, ok := index[n]
+		if !ok {
+			i = len(names)
+			index[n] = i
+			names = append(names, n)
+		}
+		shards[s] = i
+	}
+	return func(key []byte) int {
+		return shards[KeySlot(key)]
+	}, names, nil
+}
+
+// ShardSink returns a Sink splitting the entries over sinks, one per
+// shard: shard(key) is the index of the sink that receives an entry. With
+// Writers as sinks it splits a dump into one dump per shard; with restore
+// sinks it loads each shard into its own server. Every sink sees the
+// database selections. Entries read with WithRaw are passed without their
+// Raw bytes, which hold opcodes meant for a single output.
+func ShardSink(shard ShardFunc, sinks ...Sink) Sink {
+	return &shardSink{shard: shard, sinks: sinks}
+}
+
+type shardSink struct {
+	shard ShardFunc
+	sinks []Sink
+}
+
+func (s *shardSink) each(fn func(Sink) error) error {
+	for i, k := range s.sinks {
+		if err := fn(k); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (s *shardSink) Begin(info DumpInfo) error {
+	info.Raw = false
+	return s.each(func(k Sink) error { return k.Begin(info) })
+}
+
+func (s *shardSink) SelectDB(db uint64) error {
+	return s.each(func(k Sink) error { return k.SelectDB(db) })
+}
+
+func (s *shardSink) WriteEntry(e *Entry) error {
+	i := s.shard(e.Key)
+	if i < 0 || i >= len(s.sinks) {
+		return fmt.Errorf("rdb: shard %d of key %q out of range", i, e.Key)
+	}
+	if e.Raw != nil {
+		c := *e
+		c.Raw = nil
+		e = &c
+	}
+	return s.sinks[i].WriteEntry(e)
+}
+
+func (s *shardSink) End() error {
+	return s.each(Sink.End)
+}
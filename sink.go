@@ -0,0 +1,185 @@
+package rdb
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// A Sink is a destination for the entries of a dump: a file format, a
+// live server, a message queue. The exporters and the Writer (through
+// WriterSink) are Sinks, so custom destinations slot into FanOut and
+// Pipeline next to them.
+//
+// A Sink receives one call to Begin, then for every entry a call to
+// SelectDB if the database changed followed by WriteEntry, and finally
+// one call to End if the whole dump was read without error.
+type Sink interface {
+	Begin(info DumpInfo) error
+	SelectDB(db uint64) error
+	WriteEntry(e *Entry) error
+	End() error
+}
+
+// DumpInfo describes the dump a Sink receives.
+type DumpInfo struct {
+	Version int
+	Aux     []AuxField // auxiliary fields before the first key
+
+	// Raw is set if the entries were read with WithRaw. The Raw bytes of
+	// the first entry then include the auxiliary fields.
+	Raw bool
+
+	// Source tells where the dump comes from when it is read by a
+	// Pipeline.
+	Source SourceInfo
+}
+
+// Created returns the time the dump was saved, from its ctime auxiliary
+// field, like Reader.Created.
+func (d DumpInfo) Created() (time.Time, bool) {
+	return auxCreated(d.Aux)
+}
+
+// NopSink implements Begin, SelectDB and End as no-ops. Embed it in sinks
+// that only need WriteEntry.
+type NopSink struct{}
+
+// Begin does nothing.
+func (NopSink) Begin(DumpInfo) error { return nil }
+
+// SelectDB does nothing.
+func (NopSink) SelectDB(uint64) error { return nil }
+
+// End does nothing.
+func (NopSink) End() error { return nil }
+
+// SinkFunc adapts a function to the Sink interface; the function is
+// called by WriteEntry.
+type SinkFunc func(e *Entry) error
+
+// Begin does nothing.
+func (f SinkFunc) Begin(DumpInfo) error { return nil }
+
+// SelectDB does nothing.
+func (f SinkFunc) SelectDB(uint64) error { return nil }
+
+// WriteEntry calls f(e).
+func (f SinkFunc) WriteEntry(e *Entry) error { return f(e) }
+
+// End does nothing.
+func (f SinkFunc) End() error { return nil }
+
+// Collector adapts an analyser whose Add cannot fail, such as a
+// MemoryBreakdown or a KeyNameStats, to the Sink interface.
+func Collector(c interface{ Add(e *Entry) }) Sink {
+	return SinkFunc(func(e *Entry) error {
+		c.Add(e)
+		return nil
+	})
+}
+
+// WriterSink adapts w to the Sink interface. Begin writes the auxiliary
+// fields, unless the entries carry them in Raw bytes of the version of w,
+// and End closes w. Use Copy instead to reproduce a dump byte for byte,
+// including what follows its last key.
+func WriterSink(w *Writer) Sink {
+	return &writerSink{w: w}
+}
+
+type writerSink struct {
+	w *Writer
+}
+
+func (s *writerSink) Begin(info DumpInfo) error {
+	if !info.Raw || info.Version != s.w.version {
+		for _, a := range info.Aux {
+			s.w.WriteAux(a.Key, a.Value)
+		}
+	}
+	return s.w.err
+}
+
+// SelectDB does nothing, as WriteEntry selects the database of each entry.
+func (s *writerSink) SelectDB(uint64) error { return nil }
+
+func (s *writerSink) WriteEntry(e *Entry) error { return s.w.WriteEntry(e) }
+
+func (s *writerSink) End() error { return s.w.Close() }
+
+// FanOut reads src to the end in a single pass and hands every entry to
+// each sink in turn, so that a dump can feed an export, a restore and
+// statistics without being parsed several times. The sinks share the
+// entries and must not modify them. FanOut stops at the first error,
+// leaving the sinks to the caller; otherwise it ends them.
+func FanOut(src *Reader, sinks ...Sink) error {
+	d := &sinkDriver{src: src, sinks: sinks}
+	for {
+		e, err := src.ReadEntry()
+		if err == io.EOF {
+			return d.end()
+		}
+		if err != nil {
+			return err
+		}
+		if err := d.entry(e); err != nil {
+			return err
+		}
+	}
+}
+
+// sinkDriver passes the entries read from src to sinks following the Sink
+// protocol.
+type sinkDriver struct {
+	src    *Reader
+	sinks  []Sink
+	origin SourceInfo
+	begun  bool
+	db     uint64
+	dbSeen bool
+}
+
+// each calls fn for each sink, annotating errors.
+func (d *sinkDriver) each(fn func(s Sink) error) error {
+	for i, s := range d.sinks {
+		if err := fn(s); err != nil {
+			return fmt.Errorf("sink %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (d *sinkDriver) begin() error {
+	d.begun = true
+	info := DumpInfo{Version: d.src.version, Aux: d.src.aux, Raw: d.src.raw, Source: d.origin}
+	return d.each(func(s Sink) error { return s.Begin(info) })
+}
+
+func (d *sinkDriver) entry(e *Entry) error {
+	if !d.begun {
+		if err := d.begin(); err != nil {
+			return err
+		}
+	}
+	if !d.dbSeen || d.db != e.DB {
+		d.db, d.dbSeen = e.DB, true
+		if err := d.each(func(s Sink) error { return s.SelectDB(e.DB) }); err != nil {
+			return err
+		}
+	}
+	return d.each(func(s Sink) error {
+		if err := s.WriteEntry(e); err != nil {
+			return fmt.Errorf("key %q: %w", e.Key, err)
+		}
+		return nil
+	})
+}
+
+func (d *sinkDriver) end() error {
+	if !d.begun {
+		if err := d.begin(); err != nil {
+			return err
+		}
+	}
+	return d.each(Sink.End)
+}
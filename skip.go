@@ -0,0 +1,380 @@
+package rdb
+
+import (
+	"fmt"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// WithSkipUnsupported makes the Reader step over values it cannot decode
+// instead of failing with ErrNotSupported. Skipped keys are listed by
+// Reader.Skipped. This includes module values without a registered
+// decoder, which are otherwise returned as ModuleValue values. Values of
+// the pre-GA module type (Module) carry no structure the Reader could
+// follow and still stop the parse.
+func WithSkipUnsupported() Option {
+	return func(r *Reader) {
+		r.skipUnsupported = true
+	}
+}
+
+// WithCaptureSkipped makes the Reader keep the serialized value of every
+// key it skips, in SkippedEntry.Raw, so that callers can copy it verbatim
+// into another dump or restore it untouched. It implies
+// WithSkipUnsupported.
+func WithCaptureSkipped() Option {
+	return func(r *Reader) {
+		r.skipUnsupported = true
+		r.captureSkipped = true
+	}
+}
+
+// WithKeysOnly makes the Reader step over values instead of decoding them,
+// for jobs that only need keys, their types, expiry and sizes. Values are
+// skipped using the lengths stored in the dump, without decompressing or
+// copying them, and Entry.Value is left nil. Entry.UncompressedSize is
+// still exact, as compressed strings record their decompressed length.
+// Values of the pre-GA module type (Module) cannot be stepped over and are
+// handled as without the option.
+func WithKeysOnly() Option {
+	return func(r *Reader) {
+		r.keysOnly = true
+	}
+}
+
+// SkippedEntry describes a key the Reader stepped over.
+type SkippedEntry struct {
+	DB        uint64
+	Key       RedisString
+	ValueType ValueType
+	ExpiryAt  int64    // milliseconds since the Unix epoch, 0 if the key does not expire
+	Module    ModuleID // module type of module values, 0 otherwise
+	Offset    int64    // offset of the record in the stream
+	Size      int64    // size of the record in bytes
+
+	// Raw holds the value exactly as serialized in the dump, without the
+	// type byte and the key, when WithCaptureSkipped is in effect. Together
+	// with ValueType it is what a dump or a DUMP payload holds for the key.
+	Raw []byte
+}
+
+// Skipped returns the keys skipped so far because WithSkipUnsupported was
+// in effect.
+func (r *Reader) Skipped() []SkippedEntry {
+	return r.skipped
+}
+
+// unsupported handles the value of e, which the Reader cannot decode. It
+// hands the value to the ValueDecoder registered for it if there is one.
+// Otherwise it skips the value and returns nil, nil if WithSkipUnsupported
+// is in effect, or returns module values as ModuleValue.
+func (r *Reader) unsupported(e *Entry, off int64) (*Entry, error) {
+	var start int
+	if r.captureSkipped {
+		start = r.in.mark()
+	}
+	var id ModuleID
+	if e.ValueType == Module || e.ValueType == Module2 {
+		n, err := r.readLength()
+		if err != nil {
+			return nil, r.fail(off, err)
+		}
+		id = ModuleID(n)
+	}
+	if ok, err := r.decodeExtension(e, id); ok {
+		return r.decoded(e, off, start, err)
+	}
+	if !r.skipUnsupported && e.ValueType == Module2 {
+		m := r.in.mark()
+		err := r.skipModule2()
+		e.Value = &ModuleValue{ID: id, Raw: r.in.since(m)}
+		return r.decoded(e, off, start, err)
+	}
+	if !r.skipUnsupported || e.ValueType == Module {
+		if id != 0 {
+			return nil, fmt.Errorf("%w: %v of module type %v for key %q at offset %d",
+				ErrNotSupported, e.ValueType, id, e.Key, off)
+		}
+		return nil, fmt.Errorf("%w: %v for key %q at offset %d", ErrNotSupported, e.ValueType, e.Key, off)
+	}
+	if err := r.skipValue(e.ValueType); err != nil {
+		return nil, r.fail(off, err)
+	}
+	var raw []byte
+	if r.captureSkipped {
+		raw = r.in.since(start)
+	}
+	r.skipped = append(r.skipped, SkippedEntry{
+		DB:        e.DB,
+		Key:       e.Key,
+		ValueType: e.ValueType,
+		ExpiryAt:  e.ExpiryAt,
+		Module:    id,
+		Offset:    off,
+		Size:      r.in.off - off,
+		Raw:       raw,
+	})
+	return nil, nil
+}
+
+// decoded completes e, whose value unsupported decoded after all, ending
+// the capture started at start for WithCaptureSkipped.
+func (r *Reader) decoded(e *Entry, off int64, start int, err error) (*Entry, error) {
+	if r.captureSkipped {
+		r.in.since(start)
+	}
+	if err != nil {
+		return nil, r.fail(off, err)
+	}
+	e.Size = r.in.off - off
+	e.UncompressedSize = e.Size
+	return e, nil
+}
+
+// skipValue reads past a value of type t. For module values the module ID
+// must already have been read.
+func (r *Reader) skipValue(t ValueType) error {
+	switch t {
+	case String, HashZipmap, SetIntSet:
+		return r.skipString()
+	case ListZipList, ZSetZipList, HashZipList:
+		return r.skipBlob(codec.ValidateZiplist)
+	case HashListPack, ZSetListPack, SetListPack, HashListPackExPreGA:
+		return r.skipBlob(codec.ValidateListpack)
+	case List, Set:
+		return r.skipStrings(1)
+	case ListQuickList:
+		return r.skipCollection(func() error {
+			return r.skipBlob(codec.ValidateZiplist)
+		})
+	case Hash:
+		return r.skipStrings(2)
+	case HashListPackEx:
+		if err := codec.Skip(r.in, 8); err != nil { // earliest field expiry
+			return err
+		}
+		return r.skipBlob(codec.ValidateListpack)
+	case HashMetadataPreGA:
+		return r.skipCollection(func() error {
+			if err := codec.Skip(r.in, 8); err != nil {
+				return err
+			}
+			return r.skipFieldValue()
+		})
+	case HashMetadata:
+		if err := codec.Skip(r.in, 8); err != nil {
+			return err
+		}
+		return r.skipCollection(func() error {
+			if _, err := r.readLength(); err != nil { // field expiry
+				return err
+			}
+			return r.skipFieldValue()
+		})
+	case ZSet:
+		return r.skipCollection(func() error {
+			if err := r.skipString(); err != nil {
+				return err
+			}
+			return r.skipScore()
+		})
+	case ZSet2:
+		return r.skipCollection(func() error {
+			if err := r.skipString(); err != nil {
+				return err
+			}
+			return codec.Skip(r.in, 8)
+		})
+	case ListQuickList2:
+		return r.skipCollection(func() error {
+			container, err := r.readLength()
+			if err != nil {
+				return err
+			}
+			if container == quicklistPacked {
+				return r.skipBlob(codec.ValidateListpack)
+			}
+			return r.skipString()
+		})
+	case StreamListPacks, StreamListPacks2, StreamListPacks3:
+		return r.skipStream(t)
+	case Module2:
+		return r.skipModule2()
+	}
+	return fmt.Errorf("%w: cannot skip %v", ErrNotSupported, t)
+}
+
+// skipString reads past a string, or hands it to the restring hook if one
+// is set.
+func (r *Reader) skipString() error {
+	if r.restring != nil {
+		return r.restring()
+	}
+	return r.strings.Skip(r.in)
+}
+
+// skipBlob reads past a ziplist or listpack blob, checking it with
+// validate if WithStrict is in effect.
+func (r *Reader) skipBlob(validate func([]byte) error) error {
+	if !r.strict {
+		return r.skipString()
+	}
+	b, err := r.readString()
+	if err != nil {
+		return err
+	}
+	return validate(b)
+}
+
+// skipCollection reads a length and calls skip that many times.
+func (r *Reader) skipCollection(skip func() error) error {
+	n, err := r.readLength()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		if err := skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipStrings skips a collection whose elements are each made of per
+// strings.
+func (r *Reader) skipStrings(per int) error {
+	return r.skipCollection(func() error {
+		for i := 0; i < per; i++ {
+			if err := r.skipString(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// skipFieldValue skips a hash field and its value.
+func (r *Reader) skipFieldValue() error {
+	if err := r.skipString(); err != nil {
+		return err
+	}
+	return r.skipString()
+}
+
+// skipScore skips a ZSet score, a length byte followed by its decimal
+// form. Lengths 253 to 255 stand for NaN, +Inf and -Inf and have no data.
+func (r *Reader) skipScore() error {
+	n, err := r.in.ReadByte()
+	if err != nil {
+		return err
+	}
+	if n >= 253 {
+		return nil
+	}
+	return codec.Skip(r.in, uint64(n))
+}
+
+func (r *Reader) skipLengths(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := r.readLength(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reader) skipStream(t ValueType) error {
+	// Node keys and listpacks.
+	err := r.skipCollection(func() error {
+		if err := r.skipString(); err != nil {
+			return err
+		}
+		return r.skipBlob(codec.ValidateListpack)
+	})
+	if err != nil {
+		return err
+	}
+	// Length and last ID, then for version 2 and later the first ID, the
+	// maximal deleted ID and the number of entries ever added.
+	meta := 3
+	if t != StreamListPacks {
+		meta += 5
+	}
+	if err := r.skipLengths(meta); err != nil {
+		return err
+	}
+	return r.skipCollection(func() error {
+		if err := r.skipString(); err != nil { // group name
+			return err
+		}
+		groupMeta := 2 // last delivered ID
+		if t != StreamListPacks {
+			groupMeta++ // entries read
+		}
+		if err := r.skipLengths(groupMeta); err != nil {
+			return err
+		}
+		// Global PEL: raw ID, delivery time, delivery count.
+		err := r.skipCollection(func() error {
+			if err := codec.Skip(r.in, 16+8); err != nil {
+				return err
+			}
+			_, err := r.readLength()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return r.skipCollection(func() error {
+			if err := r.skipString(); err != nil { // consumer name
+				return err
+			}
+			times := uint64(8) // seen time
+			if t == StreamListPacks3 {
+				times += 8 // active time
+			}
+			if err := codec.Skip(r.in, times); err != nil {
+				return err
+			}
+			return r.skipCollection(func() error { // consumer PEL: raw IDs
+				return codec.Skip(r.in, 16)
+			})
+		})
+	})
+}
+
+// Opcodes of the self-describing module value encoding.
+const (
+	moduleOpEOF    = 0
+	moduleOpSInt   = 1
+	moduleOpUInt   = 2
+	moduleOpFloat  = 3
+	moduleOpDouble = 4
+	moduleOpString = 5
+)
+
+func (r *Reader) skipModule2() error {
+	for {
+		op, err := r.readLength()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case moduleOpEOF:
+			return nil
+		case moduleOpSInt, moduleOpUInt:
+			_, err = r.readLength()
+		case moduleOpFloat:
+			err = codec.Skip(r.in, 4)
+		case moduleOpDouble:
+			err = codec.Skip(r.in, 8)
+		case moduleOpString:
+			err = r.skipString()
+		default:
+			err = fmt.Errorf("%w: bad module value opcode %d", ErrFormat, op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
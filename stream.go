@@ -0,0 +1,248 @@
+package rdb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamID identifies a stream entry by its millisecond timestamp and
+// sequence number.
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// String formats the ID as "<ms>-<seq>".
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// IsZero reports whether the ID is 0-0.
+func (id StreamID) IsZero() bool {
+	return id.Ms == 0 && id.Seq == 0
+}
+
+// ParseStreamID parses an ID in the form "<ms>-<seq>", or "<ms>" for a
+// sequence number of 0, as XADD and XRANGE accept it.
+func ParseStreamID(s string) (StreamID, error) {
+	ms, seq, dash := strings.Cut(s, "-")
+	var id StreamID
+	var err error
+	if id.Ms, err = strconv.ParseUint(ms, 10, 64); err == nil && dash {
+		id.Seq, err = strconv.ParseUint(seq, 10, 64)
+	}
+	if err != nil {
+		return StreamID{}, fmt.Errorf("rdb: invalid stream ID %q", s)
+	}
+	return id, nil
+}
+
+// Compare returns -1, 0 or +1 depending on whether id sorts before, equal
+// to or after other.
+func (id StreamID) Compare(other StreamID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+// Next returns the smallest ID greater than id. The largest possible ID
+// is returned unchanged.
+func (id StreamID) Next() StreamID {
+	switch {
+	case id.Seq < math.MaxUint64:
+		id.Seq++
+	case id.Ms < math.MaxUint64:
+		id.Ms, id.Seq = id.Ms+1, 0
+	}
+	return id
+}
+
+// Prev returns the largest ID smaller than id. 0-0 is returned unchanged.
+func (id StreamID) Prev() StreamID {
+	switch {
+	case id.Seq > 0:
+		id.Seq--
+	case id.Ms > 0:
+		id.Ms, id.Seq = id.Ms-1, math.MaxUint64
+	}
+	return id
+}
+
+// Time returns the time encoded in the millisecond part of the ID, which
+// is when the entry was added unless the ID was chosen explicitly.
+func (id StreamID) Time() time.Time {
+	return time.UnixMilli(int64(id.Ms))
+}
+
+// MarshalText formats the ID as String does.
+func (id StreamID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText parses the ID as ParseStreamID does.
+func (id *StreamID) UnmarshalText(b []byte) error {
+	v, err := ParseStreamID(string(b))
+	if err != nil {
+		return err
+	}
+	*id = v
+	return nil
+}
+
+// StreamField is a single field/value pair of a stream entry.
+type StreamField struct {
+	Field RedisString
+	Value RedisString
+}
+
+// StreamEntry is a single entry of a stream.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []StreamField
+}
+
+// StreamPendingEntry is an entry of a consumer group's pending entries list
+// (PEL): a message that was delivered but not yet acknowledged.
+type StreamPendingEntry struct {
+	ID            StreamID
+	Consumer      RedisString
+	DeliveryTime  int64 // milliseconds since the Unix epoch
+	DeliveryCount uint64
+}
+
+// StreamConsumer is a consumer of a consumer group.
+type StreamConsumer struct {
+	Name     RedisString
+	SeenTime int64 // milliseconds since the Unix epoch
+	Pending  []StreamID
+
+	// ActiveTime is when the consumer last read or claimed entries, in
+	// milliseconds since the Unix epoch. Dumps record it from Redis 7.2
+	// (StreamListPacks3) on; it is 0 in older ones.
+	ActiveTime int64
+}
+
+// StreamGroup is a consumer group of a stream.
+type StreamGroup struct {
+	Name      RedisString
+	LastID    StreamID
+	Pending   []StreamPendingEntry
+	Consumers []StreamConsumer
+
+	// EntriesRead is the logical read counter of the group, from which
+	// XINFO computes its lag. Dumps record it from Redis 7.0
+	// (StreamListPacks2) on; it is 0 in older ones.
+	EntriesRead uint64
+}
+
+// StreamValue is a decoded stream.
+type StreamValue struct {
+	Entries []StreamEntry
+	Length  uint64
+	LastID  StreamID
+	Groups  []StreamGroup
+
+	// FirstID is the ID of the first entry, MaxDeletedID the largest ID
+	// ever deleted and EntriesAdded the number of entries ever added. Dumps
+	// record them from Redis 7.0 (StreamListPacks2) on; they are zero in
+	// older ones.
+	FirstID      StreamID
+	MaxDeletedID StreamID
+	EntriesAdded uint64
+}
+
+func (*StreamValue) Type() Type         { return TypeStream }
+func (*StreamValue) Encoding() Encoding { return EncodingStream }
+
+// Commands returns the commands that recreate the stream under key on
+// another server, preserving entry IDs, the stream's last ID, consumer
+// groups, consumers and pending entries.
+//
+// Entries are added with XADD using their original IDs. An empty stream is
+// created with XGROUP CREATE ... MKSTREAM, using its first group or, if it
+// has none, a placeholder group destroyed right away. When the last ID
+// differs from the ID of the last entry (entries were deleted or trimmed),
+// or the dump records the number of entries ever added or the largest
+// deleted ID, XSETID restores them. Groups are created with XGROUP CREATE
+// at their last delivered ID and with their read counter, consumers
+// without pending entries with XGROUP CREATECONSUMER, and pending entries
+// are reassigned to their consumer with XCLAIM ... FORCE JUSTID, keeping
+// the original delivery time and count. Pending entries that refer to
+// deleted messages cannot be recreated by XCLAIM and are silently dropped
+// by the server.
+func (s *StreamValue) Commands(key RedisString) []Command {
+	var cmds []Command
+	for _, e := range s.Entries {
+		cmd := Command{RedisString("XADD"), key, RedisString(e.ID.String())}
+		for _, f := range e.Fields {
+			cmd = append(cmd, f.Field, f.Value)
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	groups := s.Groups
+	var top StreamID
+	if len(s.Entries) > 0 {
+		top = s.Entries[len(s.Entries)-1].ID
+	} else if len(groups) > 0 {
+		cmds = append(cmds, append(groupCreate(key, &groups[0]), RedisString("MKSTREAM")))
+	} else {
+		name := RedisString("rdb-placeholder")
+		cmds = append(cmds,
+			Command{RedisString("XGROUP"), RedisString("CREATE"), key, name, RedisString("0"), RedisString("MKSTREAM")},
+			Command{RedisString("XGROUP"), RedisString("DESTROY"), key, name})
+	}
+	if s.LastID != top || s.EntriesAdded != 0 || !s.MaxDeletedID.IsZero() {
+		cmd := Command{RedisString("XSETID"), key, RedisString(s.LastID.String())}
+		if s.EntriesAdded != 0 {
+			cmd = append(cmd, RedisString("ENTRIESADDED"), RedisString(strconv.FormatUint(s.EntriesAdded, 10)))
+		}
+		if !s.MaxDeletedID.IsZero() {
+			cmd = append(cmd, RedisString("MAXDELETEDID"), RedisString(s.MaxDeletedID.String()))
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	for i := range groups {
+		g := &groups[i]
+		if i > 0 || len(s.Entries) > 0 {
+			cmds = append(cmds, groupCreate(key, g))
+		}
+		for _, c := range g.Consumers {
+			if len(c.Pending) == 0 {
+				cmds = append(cmds, Command{RedisString("XGROUP"), RedisString("CREATECONSUMER"),
+					key, g.Name, c.Name})
+			}
+		}
+		for _, p := range g.Pending {
+			cmds = append(cmds, Command{RedisString("XCLAIM"), key, g.Name, p.Consumer,
+				RedisString("0"), RedisString(p.ID.String()),
+				RedisString("TIME"), RedisString(strconv.FormatInt(p.DeliveryTime, 10)),
+				RedisString("RETRYCOUNT"), RedisString(strconv.FormatUint(p.DeliveryCount, 10)),
+				RedisString("FORCE"), RedisString("JUSTID")})
+		}
+	}
+	return cmds
+}
+
+// groupCreate returns the XGROUP CREATE command for g. The read counter is
+// left out when the dump does not record it, or records it as unknown
+// (-1), and Redis derives it from the last delivered ID.
+func groupCreate(key RedisString, g *StreamGroup) Command {
+	cmd := Command{RedisString("XGROUP"), RedisString("CREATE"), key, g.Name, RedisString(g.LastID.String())}
+	if g.EntriesRead != 0 && g.EntriesRead != math.MaxUint64 {
+		cmd = append(cmd, RedisString("ENTRIESREAD"), RedisString(strconv.FormatUint(g.EntriesRead, 10)))
+	}
+	return cmd
+}
@@ -0,0 +1,69 @@
+package rdb_test
+
+import (
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func commandStrings(cmds []rdb.Command) []string {
+	var out []string
+	for _, c := range cmds {
+		var args []string
+		for _, a := range c {
+			args = append(args, string(a))
+		}
+		out = append(out, strings.Join(args, " "))
+	}
+	return out
+}
+
+func TestStreamCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		s    rdb.StreamValue
+		want []string
+	}{
+		{
+			name: "empty",
+			want: []string{
+				"XGROUP CREATE s rdb-placeholder 0 MKSTREAM",
+				"XGROUP DESTROY s rdb-placeholder",
+			},
+		},
+		{
+			name: "empty with group",
+			s: rdb.StreamValue{
+				LastID:       rdb.StreamID{Ms: 5, Seq: 1},
+				EntriesAdded: 3,
+				MaxDeletedID: rdb.StreamID{Ms: 5, Seq: 1},
+				Groups:       []rdb.StreamGroup{{Name: rdb.RedisString("g"), LastID: rdb.StreamID{Ms: 5, Seq: 1}, EntriesRead: 3}},
+			},
+			want: []string{
+				"XGROUP CREATE s g 5-1 ENTRIESREAD 3 MKSTREAM",
+				"XSETID s 5-1 ENTRIESADDED 3 MAXDELETEDID 5-1",
+			},
+		},
+		{
+			name: "trimmed",
+			s: rdb.StreamValue{
+				Entries: []rdb.StreamEntry{{ID: rdb.StreamID{Ms: 2}, Fields: []rdb.StreamField{{Field: rdb.RedisString("f"), Value: rdb.RedisString("v")}}}},
+				Length:  1,
+				LastID:  rdb.StreamID{Ms: 3},
+				Groups:  []rdb.StreamGroup{{Name: rdb.RedisString("g"), EntriesRead: 1<<64 - 1}},
+			},
+			want: []string{
+				"XADD s 2-0 f v",
+				"XSETID s 3-0",
+				"XGROUP CREATE s g 0-0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := commandStrings(tt.s.Commands(rdb.RedisString("s")))
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// Flags of the entries of a stream listpack, from Redis' stream.h.
+const (
+	streamItemDeleted    = 1 // the entry was deleted with XDEL
+	streamItemSameFields = 2 // the entry has the fields of the master entry
+)
+
+// readStream reads a stream of type t: the listpacks holding its entries,
+// each keyed by the ID of its master entry, the stream's length and last
+// ID, from StreamListPacks2 on its first ID, maximal deleted ID and count
+// of entries ever added, and its consumer groups. Deleted entries are left
+// out.
+func (r *Reader) readStream(t ValueType) (*StreamValue, error) {
+	nodes, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	s := &StreamValue{}
+	for i := uint64(0); i < nodes; i++ {
+		key, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != 16 {
+			return nil, fmt.Errorf("%w: stream node key of %d bytes", ErrFormat, len(key))
+		}
+		master := StreamID{binary.BigEndian.Uint64(key), binary.BigEndian.Uint64(key[8:])}
+		blob, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		elems, err := codec.DecodeListpack(blob)
+		if err != nil {
+			return nil, err
+		}
+		if s.Entries, err = appendStreamEntries(s.Entries, master, elems); err != nil {
+			return nil, err
+		}
+		if err := codec.CheckLength("collection", uint64(len(s.Entries)), r.maxElements); err != nil {
+			return nil, err
+		}
+	}
+	if s.Length, err = r.readLength(); err != nil {
+		return nil, err
+	}
+	if s.LastID, err = r.readStreamID(); err != nil {
+		return nil, err
+	}
+	if t != StreamListPacks {
+		if s.FirstID, err = r.readStreamID(); err != nil {
+			return nil, err
+		}
+		if s.MaxDeletedID, err = r.readStreamID(); err != nil {
+			return nil, err
+		}
+		if s.EntriesAdded, err = r.readLength(); err != nil {
+			return nil, err
+		}
+	}
+	groups, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < groups; i++ {
+		g, err := r.readStreamGroup(t)
+		if err != nil {
+			return nil, err
+		}
+		s.Groups = append(s.Groups, g)
+	}
+	return s, nil
+}
+
+// readStreamGroup reads a consumer group of a stream of type t: its name,
+// last delivered ID and, from StreamListPacks2 on, read counter, its
+// pending entries list and its consumers with the IDs they own.
+func (r *Reader) readStreamGroup(t ValueType) (StreamGroup, error) {
+	var g StreamGroup
+	var err error
+	if g.Name, err = r.readString(); err != nil {
+		return g, err
+	}
+	if g.LastID, err = r.readStreamID(); err != nil {
+		return g, err
+	}
+	if t != StreamListPacks {
+		if g.EntriesRead, err = r.readLength(); err != nil {
+			return g, err
+		}
+	}
+	n, err := r.readCount()
+	if err != nil {
+		return g, err
+	}
+	pending := make(map[StreamID]int, n)
+	for i := uint64(0); i < n; i++ {
+		var p StreamPendingEntry
+		if p.ID, err = r.readRawStreamID(); err != nil {
+			return g, err
+		}
+		if p.DeliveryTime, err = r.readMillis(); err != nil {
+			return g, err
+		}
+		if p.DeliveryCount, err = r.readLength(); err != nil {
+			return g, err
+		}
+		pending[p.ID] = len(g.Pending)
+		g.Pending = append(g.Pending, p)
+	}
+	if n, err = r.readCount(); err != nil {
+		return g, err
+	}
+	for i := uint64(0); i < n; i++ {
+		var c StreamConsumer
+		if c.Name, err = r.readString(); err != nil {
+			return g, err
+		}
+		if c.SeenTime, err = r.readMillis(); err != nil {
+			return g, err
+		}
+		if t == StreamListPacks3 {
+			if c.ActiveTime, err = r.readMillis(); err != nil {
+				return g, err
+			}
+		}
+		owned, err := r.readCount()
+		if err != nil {
+			return g, err
+		}
+		for j := uint64(0); j < owned; j++ {
+			id, err := r.readRawStreamID()
+			if err != nil {
+				return g, err
+			}
+			k, ok := pending[id]
+			if !ok {
+				return g, fmt.Errorf("%w: consumer owns %v, which is not pending", ErrFormat, id)
+			}
+			g.Pending[k].Consumer = c.Name
+			c.Pending = append(c.Pending, id)
+		}
+		g.Consumers = append(g.Consumers, c)
+	}
+	return g, nil
+}
+
+// readStreamID reads an ID stored as two lengths.
+func (r *Reader) readStreamID() (StreamID, error) {
+	ms, err := r.readLength()
+	if err != nil {
+		return StreamID{}, err
+	}
+	seq, err := r.readLength()
+	return StreamID{ms, seq}, err
+}
+
+// readRawStreamID reads an ID stored as two big-endian 64-bit integers.
+func (r *Reader) readRawStreamID() (StreamID, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(r.in, b[:]); err != nil {
+		return StreamID{}, err
+	}
+	return StreamID{binary.BigEndian.Uint64(b[:]), binary.BigEndian.Uint64(b[8:])}, nil
+}
+
+// readMillis reads a time in milliseconds stored as a little-endian 64-bit
+// integer.
+func (r *Reader) readMillis() (int64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r.in, b[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(b[:])), nil
+}
+
+// appendStreamEntries appends the live entries of a stream listpack to
+// entries. The listpack starts with a master entry: the number of live and
+// deleted entries, the master fields and a 0 terminator. Each entry
+// follows as its flags, its ID as the difference from master, its values
+// alone if it has the master fields or else its field count and
+// field/value pairs, and finally the number of listpack elements it took.
+func appendStreamEntries(entries []StreamEntry, master StreamID, lp [][]byte) ([]StreamEntry, error) {
+	p := streamListpack{elems: lp}
+	live, deleted := p.int(), p.int()
+	masterFields := p.strings(p.int())
+	if p.int() != 0 && p.err == nil {
+		p.err = fmt.Errorf("%w: stream master entry not terminated", ErrFormat)
+	}
+	var n, dead int64
+	for p.err == nil && len(p.elems) > 0 {
+		flags, ms, seq := p.int(), p.int(), p.int()
+		var fields []StreamField
+		if flags&streamItemSameFields != 0 {
+			values := p.strings(int64(len(masterFields)))
+			for i := range values {
+				fields = append(fields, StreamField{Field: masterFields[i], Value: values[i]})
+			}
+		} else {
+			pairs := p.strings(2 * p.int())
+			for i := 0; i+1 < len(pairs); i += 2 {
+				fields = append(fields, StreamField{Field: pairs[i], Value: pairs[i+1]})
+			}
+		}
+		p.int() // elements of the entry, for backward iteration
+		if flags&streamItemDeleted != 0 {
+			dead++
+			continue
+		}
+		n++
+		id := StreamID{master.Ms + uint64(ms), master.Seq + uint64(seq)}
+		entries = append(entries, StreamEntry{ID: id, Fields: fields})
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if n != live || dead != deleted {
+		return nil, fmt.Errorf("%w: stream node of %d live and %d deleted entries holds %d and %d", ErrFormat, live, deleted, n, dead)
+	}
+	return entries, nil
+}
+
+// streamListpack consumes the elements of a stream listpack, recording the
+// first error.
+type streamListpack struct {
+	elems [][]byte
+	err   error
+}
+
+func (p *streamListpack) strings(n int64) []RedisString {
+	if p.err != nil {
+		return nil
+	}
+	if n < 0 || n > int64(len(p.elems)) {
+		p.err = fmt.Errorf("%w: truncated stream node", ErrFormat)
+		return nil
+	}
+	s := make([]RedisString, n)
+	for i := range s {
+		s[i] = p.elems[i]
+	}
+	p.elems = p.elems[n:]
+	return s
+}
+
+func (p *streamListpack) int() int64 {
+	s := p.strings(1)
+	if p.err != nil {
+		return 0
+	}
+	v, err := strconv.ParseInt(string(s[0]), 10, 64)
+	if err != nil {
+		p.err = fmt.Errorf("%w: stream node element %q is not an integer", ErrFormat, s[0])
+	}
+	return v
+}
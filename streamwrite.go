@@ -0,0 +1,120 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// streamNodeEntries is the number of entries per listpack node, Redis'
+// default stream-node-max-entries.
+const streamNodeEntries = 100
+
+// encodeStream encodes s in the stream type of the version: listpacks of
+// up to streamNodeEntries entries, each keyed by its first entry's ID and
+// using that entry's fields as its master fields, followed by the metadata
+// and the consumer groups the type records. Streams arrived with version
+// 9; the format of older versions has no place for them.
+func encodeStream(e *Entry, s *StreamValue, version int, compress bool) (ValueType, []byte, error) {
+	var t ValueType
+	switch {
+	case version >= 11:
+		t = StreamListPacks3
+	case version == 10:
+		t = StreamListPacks2
+	case version == 9:
+		t = StreamListPacks
+	default:
+		return 0, nil, fmt.Errorf("%w: stream %q in version %d", ErrNotSupported, e.Key, version)
+	}
+	nodes := (len(s.Entries) + streamNodeEntries - 1) / streamNodeEntries
+	b := codec.AppendLength(nil, uint64(nodes))
+	for i := 0; i < len(s.Entries); i += streamNodeEntries {
+		node := s.Entries[i:min(i+streamNodeEntries, len(s.Entries))]
+		b = codec.AppendString(b, appendRawStreamID(nil, node[0].ID), false)
+		b = codec.AppendString(b, encodeStreamNode(node), compress)
+	}
+	b = codec.AppendLength(b, s.Length)
+	b = appendStreamID(b, s.LastID)
+	if t != StreamListPacks {
+		b = appendStreamID(b, s.FirstID)
+		b = appendStreamID(b, s.MaxDeletedID)
+		b = codec.AppendLength(b, s.EntriesAdded)
+	}
+	b = codec.AppendLength(b, uint64(len(s.Groups)))
+	for _, g := range s.Groups {
+		b = codec.AppendString(b, g.Name, false)
+		b = appendStreamID(b, g.LastID)
+		if t != StreamListPacks {
+			b = codec.AppendLength(b, g.EntriesRead)
+		}
+		b = codec.AppendLength(b, uint64(len(g.Pending)))
+		for _, p := range g.Pending {
+			b = appendRawStreamID(b, p.ID)
+			b = binary.LittleEndian.AppendUint64(b, uint64(p.DeliveryTime))
+			b = codec.AppendLength(b, p.DeliveryCount)
+		}
+		b = codec.AppendLength(b, uint64(len(g.Consumers)))
+		for _, c := range g.Consumers {
+			b = codec.AppendString(b, c.Name, false)
+			b = binary.LittleEndian.AppendUint64(b, uint64(c.SeenTime))
+			if t == StreamListPacks3 {
+				b = binary.LittleEndian.AppendUint64(b, uint64(c.ActiveTime))
+			}
+			b = codec.AppendLength(b, uint64(len(c.Pending)))
+			for _, id := range c.Pending {
+				b = appendRawStreamID(b, id)
+			}
+		}
+	}
+	return t, b, nil
+}
+
+// encodeStreamNode returns the listpack of a stream node holding entries,
+// in the layout appendStreamEntries reads. The node has no deleted entries.
+func encodeStreamNode(entries []StreamEntry) []byte {
+	num := func(v int64) []byte { return strconv.AppendInt(nil, v, 10) }
+	master := entries[0]
+	lp := [][]byte{num(int64(len(entries))), num(0), num(int64(len(master.Fields)))}
+	for _, f := range master.Fields {
+		lp = append(lp, f.Field)
+	}
+	lp = append(lp, num(0))
+	for _, e := range entries {
+		same := len(e.Fields) == len(master.Fields)
+		for i := 0; same && i < len(e.Fields); i++ {
+			same = string(e.Fields[i].Field) == string(master.Fields[i].Field)
+		}
+		flags := int64(0)
+		if same {
+			flags = streamItemSameFields
+		}
+		// The differences wrap around like Redis' signed ones do.
+		lp = append(lp, num(flags), num(int64(e.ID.Ms-master.ID.Ms)), num(int64(e.ID.Seq-master.ID.Seq)))
+		if same {
+			for _, f := range e.Fields {
+				lp = append(lp, f.Value)
+			}
+			lp = append(lp, num(int64(len(e.Fields)+3)))
+			continue
+		}
+		lp = append(lp, num(int64(len(e.Fields))))
+		for _, f := range e.Fields {
+			lp = append(lp, f.Field, f.Value)
+		}
+		lp = append(lp, num(int64(2*len(e.Fields)+4)))
+	}
+	return codec.EncodeListpack(lp)
+}
+
+// appendStreamID appends id as two lengths.
+func appendStreamID(b []byte, id StreamID) []byte {
+	return codec.AppendLength(codec.AppendLength(b, id.Ms), id.Seq)
+}
+
+// appendRawStreamID appends id as two big-endian 64-bit integers.
+func appendRawStreamID(b []byte, id StreamID) []byte {
+	return binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(b, id.Ms), id.Seq)
+}
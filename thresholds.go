@@ -0,0 +1,112 @@
+package rdb
+
+import "github.com/areian/go-redis-rdb/codec"
+
+// ThresholdViolation reports a collection that exceeds one of the limits
+// for a compact encoding, which makes Redis convert it to a hash table,
+// skiplist or quicklist.
+type ThresholdViolation struct {
+	Setting string // redis.conf setting, such as "hash-max-listpack-entries"
+	Limit   int
+	Actual  int // number of elements or size in bytes, like Limit
+}
+
+// Over returns how far Actual exceeds Limit.
+func (v ThresholdViolation) Over() int {
+	return v.Actual - v.Limit
+}
+
+// Ratio returns Actual relative to Limit; 2 means twice the limit.
+func (v ThresholdViolation) Ratio() float64 {
+	if v.Limit <= 0 {
+		return 0
+	}
+	return float64(v.Actual) / float64(v.Limit)
+}
+
+// CheckThresholds returns the compact-encoding limits of cfg that the value
+// of e exceeds. It returns nil for values that fit a compact encoding and
+// for types without one.
+func CheckThresholds(e *Entry, cfg EncodingConfig) []ThresholdViolation {
+	var out []ThresholdViolation
+	check := func(setting string, limit, actual int) {
+		if actual > limit {
+			out = append(out, ThresholdViolation{Setting: setting, Limit: limit, Actual: actual})
+		}
+	}
+	switch v := e.Value.(type) {
+	case SetValue:
+		if allInts(v.Members) && len(v.Members) <= cfg.SetMaxIntsetEntries {
+			return nil
+		}
+		check("set-max-listpack-entries", cfg.SetMaxListpackEntries, len(v.Members))
+		check("set-max-listpack-value", cfg.SetMaxListpackValue, maxLen(v.Members))
+	case ListValue:
+		fill := cfg.ListMaxListpackSize
+		if fill > 0 {
+			check("list-max-listpack-size", fill, len(v.Elements))
+		} else if fill >= -5 {
+			check("list-max-listpack-size", 4096<<uint(-fill-1), int(listpackSize(v.Elements)))
+		}
+	case HashValue:
+		check("hash-max-listpack-entries", cfg.HashMaxListpackEntries, len(v.Fields))
+		longest := 0
+		for _, f := range v.Fields {
+			longest = max(longest, len(f.Field), len(f.Value))
+		}
+		check("hash-max-listpack-value", cfg.HashMaxListpackValue, longest)
+	case ZSetValue:
+		check("zset-max-listpack-entries", cfg.ZSetMaxListpackEntries, len(v.Members))
+		longest := 0
+		for _, m := range v.Members {
+			longest = max(longest, len(m.Member))
+		}
+		check("zset-max-listpack-value", cfg.ZSetMaxListpackValue, longest)
+	}
+	return out
+}
+
+func allInts(s []RedisString) bool {
+	for _, m := range s {
+		if _, ok := codec.ParseInt(m); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ThresholdReport collects the keys that exceed compact-encoding limits.
+// Feed it every entry with Add.
+type ThresholdReport struct {
+	Config EncodingConfig
+
+	// Keys lists the offending keys in the order they were added.
+	Keys []ThresholdKey
+}
+
+// ThresholdKey is a key exceeding compact-encoding limits.
+type ThresholdKey struct {
+	DB         uint64
+	Key        RedisString
+	Type       Type
+	Encoding   Encoding // encoding the value was stored with
+	Violations []ThresholdViolation
+}
+
+// NewThresholdReport returns a ThresholdReport checking against cfg.
+func NewThresholdReport(cfg EncodingConfig) *ThresholdReport {
+	return &ThresholdReport{Config: cfg}
+}
+
+// Add checks a single entry.
+func (r *ThresholdReport) Add(e *Entry) {
+	if v := CheckThresholds(e, r.Config); v != nil {
+		r.Keys = append(r.Keys, ThresholdKey{
+			DB:         e.DB,
+			Key:        e.Key,
+			Type:       e.Type(),
+			Encoding:   e.Encoding(),
+			Violations: v,
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package rdb_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestCheckThresholds(t *testing.T) {
+	cfg := rdb.EncodingConfig{
+		HashMaxListpackEntries: 2, HashMaxListpackValue: 3,
+		SetMaxIntsetEntries: 3, SetMaxListpackEntries: 2, SetMaxListpackValue: 3,
+		ZSetMaxListpackEntries: 2, ZSetMaxListpackValue: 3,
+		ListMaxListpackSize: 2,
+	}
+	key := func(v rdb.Value) *rdb.Entry { return &rdb.Entry{Key: rdb.RedisString("k"), Value: v} }
+	zset := func(members ...string) rdb.ZSetValue {
+		var z rdb.ZSetValue
+		for _, m := range members {
+			z.Members = append(z.Members, rdb.ZSetMember{Member: rdb.RedisString(m)})
+		}
+		return z
+	}
+	v := func(setting string, limit, actual int) rdb.ThresholdViolation {
+		return rdb.ThresholdViolation{Setting: setting, Limit: limit, Actual: actual}
+	}
+	tests := []struct {
+		name string
+		e    *rdb.Entry
+		want []rdb.ThresholdViolation
+	}{
+		{"string", key(rdb.StringValue(strings.Repeat("x", 100))), nil},
+		{"small hash", key(rdb.HashValue{Fields: fields("a", "abc", "b", "c")}), nil},
+		{"hash entries", key(rdb.HashValue{Fields: fields("a", "1", "b", "2", "c", "3")}),
+			[]rdb.ThresholdViolation{v("hash-max-listpack-entries", 2, 3)}},
+		{"hash field and value", key(rdb.HashValue{Fields: fields("long", "1", "a", "longer")}),
+			[]rdb.ThresholdViolation{v("hash-max-listpack-value", 3, 6)}},
+		{"intset", key(rdb.SetValue{Members: strs("1", "2", "3")}), nil},
+		{"intset entries", key(rdb.SetValue{Members: strs("1", "2", "3", "4")}),
+			[]rdb.ThresholdViolation{v("set-max-listpack-entries", 2, 4)}},
+		{"set both", key(rdb.SetValue{Members: strs("a", "b", "long")}),
+			[]rdb.ThresholdViolation{v("set-max-listpack-entries", 2, 3), v("set-max-listpack-value", 3, 4)}},
+		{"zset", key(zset("a", "b")), nil},
+		{"zset both", key(zset("a", "b", "long")),
+			[]rdb.ThresholdViolation{v("zset-max-listpack-entries", 2, 3), v("zset-max-listpack-value", 3, 4)}},
+		{"list", key(rdb.ListValue{Elements: strs("a", "b")}), nil},
+		{"list entries", key(rdb.ListValue{Elements: strs("a", "b", "c")}),
+			[]rdb.ThresholdViolation{v("list-max-listpack-size", 2, 3)}},
+		{"stream", key(testStream()), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rdb.CheckThresholds(tt.e, cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// A negative list-max-listpack-size limits the bytes of a node.
+	cfg.ListMaxListpackSize = -1
+	got := rdb.CheckThresholds(key(rdb.ListValue{Elements: strs(strings.Repeat("x", 5000))}), cfg)
+	if len(got) != 1 || got[0].Setting != "list-max-listpack-size" || got[0].Limit != 4096 || got[0].Actual <= 5000 {
+		t.Errorf("got %+v", got)
+	}
+	if got := rdb.CheckThresholds(key(rdb.ListValue{Elements: strs("a", "b", "c")}), cfg); got != nil {
+		t.Errorf("got %+v for a small list", got)
+	}
+}
+
+func TestThresholdViolation(t *testing.T) {
+	v := rdb.ThresholdViolation{Limit: 128, Actual: 320}
+	if v.Over() != 192 || v.Ratio() != 2.5 {
+		t.Errorf("got over %d, ratio %v", v.Over(), v.Ratio())
+	}
+	if r := (rdb.ThresholdViolation{Actual: 1}).Ratio(); r != 0 {
+		t.Errorf("ratio to a zero limit is %v", r)
+	}
+}
+
+func TestThresholdReport(t *testing.T) {
+	r := rdb.NewThresholdReport(rdb.EncodingConfig{HashMaxListpackEntries: 1, HashMaxListpackValue: 64})
+	r.Add(&rdb.Entry{Key: rdb.RedisString("small"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields("a", "1")}})
+	r.Add(&rdb.Entry{DB: 2, Key: rdb.RedisString("big"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields("a", "1", "b", "2")}})
+	r.Add(str(0, "s", "v", 0))
+	want := []rdb.ThresholdKey{{
+		DB: 2, Key: rdb.RedisString("big"), Type: rdb.TypeHash, Encoding: rdb.EncodingListpack,
+		Violations: []rdb.ThresholdViolation{{Setting: "hash-max-listpack-entries", Limit: 1, Actual: 2}},
+	}}
+	if !reflect.DeepEqual(r.Keys, want) {
+		t.Errorf("got %+v, want %+v", r.Keys, want)
+	}
+}
@@ -0,0 +1,145 @@
+package rdb
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// A Transform wraps the destination of a Writer or exporter, to compress
+// the output or send it elsewhere, such as a multipart upload. Closing the
+// returned writer must flush everything it buffered to w but should not
+// close w.
+type Transform func(w io.Writer) (io.WriteCloser, error)
+
+// Gzip returns a Transform compressing the output with gzip at the given
+// level, one of the compress/gzip levels.
+func Gzip(level int) Transform {
+	return func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	}
+}
+
+// WithTransform makes the Writer pass its output through the transforms,
+// the first one being applied last, next to the destination.
+// Writer.Close closes them.
+func WithTransform(t ...Transform) WriterOption {
+	return func(w *Writer) {
+		w.transforms = append(w.transforms, t...)
+	}
+}
+
+// transformedOutput is a destination wrapped in transforms.
+type transformedOutput struct {
+	io.Writer
+	closers []io.Closer // outermost first
+}
+
+// wrapOutput wraps w in ts, ts[0] being next to w.
+func wrapOutput(w io.Writer, ts []Transform) (*transformedOutput, error) {
+	out := &transformedOutput{Writer: w}
+	for _, t := range ts {
+		wc, err := t(out.Writer)
+		if err != nil {
+			out.Close()
+			return nil, err
+		}
+		out.Writer = wc
+		out.closers = append([]io.Closer{wc}, out.closers...)
+	}
+	return out, nil
+}
+
+// Close closes the transforms from the outermost in, so each one flushes
+// into the next.
+func (o *transformedOutput) Close() error {
+	var first error
+	for _, c := range o.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	o.closers = nil
+	return first
+}
+
+// Buffered returns a Transform that decouples the producer from a slow
+// destination: writes are queued and written to the destination by a
+// background goroutine, so parsing and output overlap. At most highWater
+// bytes are queued; beyond that, writes block until the destination has
+// caught up, so a slow destination throttles the parse instead of making
+// memory grow. A write error is returned by the next Write or by Close.
+//
+// Without this transform Writers and exporters write synchronously and a
+// slow destination blocks every call.
+func Buffered(highWater int) Transform {
+	return func(w io.Writer) (io.WriteCloser, error) {
+		b := &bufferedOutput{w: w, highWater: highWater, done: make(chan struct{})}
+		b.cond = sync.NewCond(&b.mu)
+		go b.drain()
+		return b, nil
+	}
+}
+
+type bufferedOutput struct {
+	w         io.Writer
+	highWater int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  [][]byte
+	queued int
+	closed bool
+	err    error
+	done   chan struct{}
+}
+
+func (b *bufferedOutput) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.err == nil && b.queued > 0 && b.queued+len(p) > b.highWater {
+		b.cond.Wait()
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	b.queue = append(b.queue, append([]byte(nil), p...))
+	b.queued += len(p)
+	b.cond.Broadcast()
+	return len(p), nil
+}
+
+func (b *bufferedOutput) drain() {
+	defer close(b.done)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		for len(b.queue) == 0 && !b.closed {
+			b.cond.Wait()
+		}
+		if len(b.queue) == 0 {
+			return
+		}
+		chunk := b.queue[0]
+		b.queue = b.queue[1:]
+		b.mu.Unlock()
+		_, err := b.w.Write(chunk)
+		b.mu.Lock()
+		b.queued -= len(chunk)
+		if err != nil && b.err == nil {
+			b.err = err
+			b.queue, b.queued = nil, 0
+		}
+		b.cond.Broadcast()
+	}
+}
+
+// Close waits until everything queued has been written.
+func (b *bufferedOutput) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+	<-b.done
+	return b.err
+}
@@ -0,0 +1,175 @@
+package rdb
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"time"
+)
+
+// WriteTreemap writes a self-contained HTML page drawing root, a
+// PrefixMemory report, as a treemap: every prefix is a rectangle sized by
+// its estimated memory, coloured by the type of its keys. Clicking a
+// prefix zooms into it, down to the types and encodings of its keys, and
+// the path above the map leads back up. The page needs no network access,
+// so it can be attached to a ticket or mailed as is. asOf, typically the
+// time of the dump given by Reader.Created, is shown as the time the data
+// describes; a zero asOf is left out.
+func WriteTreemap(w io.Writer, title string, root *PrefixNode, asOf time.Time) error {
+	data, err := json.Marshal(treemapNode(root))
+	if err != nil {
+		return err
+	}
+	var at string
+	if !asOf.IsZero() {
+		at = asOf.UTC().Format(time.RFC3339)
+	}
+	return treemapTemplate.Execute(w, struct {
+		Title string
+		AsOf  string
+		Data  template.JS
+	}{title, at, template.JS(data)})
+}
+
+// treemapJSON is the compact form of a PrefixNode used by the page.
+type treemapJSON struct {
+	N string         `json:"n"`           // name
+	P string         `json:"p,omitempty"` // prefix
+	K uint64         `json:"k"`           // keys
+	B uint64         `json:"b"`           // bytes
+	T string         `json:"t,omitempty"` // type of a leaf
+	C []*treemapJSON `json:"c,omitempty"`
+}
+
+func treemapNode(n *PrefixNode) *treemapJSON {
+	j := &treemapJSON{N: n.Name, P: n.Prefix, K: n.Keys, B: n.Bytes}
+	if n.Leaf {
+		j.T = n.Type.String()
+	}
+	for _, c := range n.Children {
+		j.C = append(j.C, treemapNode(c))
+	}
+	return j
+}
+
+var treemapTemplate = template.Must(template.New("treemap").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font: 13px sans-serif; margin: 16px; color: #222; }
+h1 { font-size: 18px; margin: 0 0 4px; }
+#meta { color: #777; margin-bottom: 8px; }
+#path span { cursor: pointer; color: #06c; }
+#path span:last-child { cursor: default; color: #222; font-weight: bold; }
+#map { position: relative; width: 100%; height: 80vh; margin-top: 8px; }
+#map div { position: absolute; box-sizing: border-box; border: 1px solid #fff; overflow: hidden; padding: 2px 4px; cursor: pointer; color: #fff; }
+#map div.leaf { cursor: default; }
+#legend span { display: inline-block; padding: 2px 6px; margin-right: 4px; color: #fff; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div id="meta">Estimated memory by key prefix{{if .AsOf}} as of {{.AsOf}}{{end}}.</div>
+<div id="legend"></div>
+<div id="path"></div>
+<div id="map"></div>
+<script>
+var root = {{.Data}};
+var colors = {string: "#4e79a7", list: "#f28e2b", set: "#59a14f", zset: "#e15759", hash: "#76b7b2", stream: "#b07aa1", module: "#9c755f"};
+
+function size(n) {
+	var u = ["B", "KiB", "MiB", "GiB", "TiB"], i = 0;
+	while (n >= 1024 && i < u.length - 1) { n /= 1024; i++; }
+	return (i ? n.toFixed(1) : n) + " " + u[i];
+}
+
+// dominant returns the type holding most of the memory of a node.
+function dominant(n) {
+	if (n.t) return n.t;
+	var by = {};
+	(function walk(c) {
+		if (c.t) { by[c.t] = (by[c.t] || 0) + c.b; return; }
+		(c.c || []).forEach(walk);
+	})(n);
+	var best = "", max = -1;
+	for (var t in by) if (by[t] > max) { best = t; max = by[t]; }
+	return best;
+}
+
+// squarify lays out the nodes, largest first, in the rectangle r, keeping
+// the rectangles close to squares.
+function squarify(nodes, r, out) {
+	nodes = nodes.filter(function (n) { return n.b > 0; });
+	var total = 0;
+	nodes.forEach(function (n) { total += n.b; });
+	if (!total) return;
+	var scale = r.w * r.h / total;
+	var i = 0;
+	while (i < nodes.length) {
+		var side = Math.min(r.w, r.h), row = [], sum = 0, worst = Infinity;
+		while (i < nodes.length) {
+			var a = nodes[i].b * scale, s = sum + a;
+			var rowMax = Math.max(a, row.length ? row[0].b * scale : 0);
+			var rowMin = Math.min(a, row.length ? row[row.length - 1].b * scale : a);
+			var w = Math.max(side * side * rowMax / (s * s), s * s / (side * side * rowMin));
+			if (w > worst) break;
+			worst = w; row.push(nodes[i]); sum = s; i++;
+		}
+		var thick = sum / side, off = 0;
+		row.forEach(function (n) {
+			var len = n.b * scale / thick;
+			if (r.w >= r.h) out.push({n: n, x: r.x, y: r.y + off, w: thick, h: len});
+			else out.push({n: n, x: r.x + off, y: r.y, w: len, h: thick});
+			off += len;
+		});
+		if (r.w >= r.h) r = {x: r.x + thick, y: r.y, w: r.w - thick, h: r.h};
+		else r = {x: r.x, y: r.y + thick, w: r.w, h: r.h - thick};
+	}
+}
+
+var current;
+
+function show(stack) {
+	current = stack;
+	var node = stack[stack.length - 1];
+	var path = document.getElementById("path");
+	path.textContent = "";
+	stack.forEach(function (n, i) {
+		if (i) path.appendChild(document.createTextNode(" › "));
+		var s = document.createElement("span");
+		s.textContent = (n.p || n.n) + " (" + size(n.b) + ")";
+		s.onclick = function () { show(stack.slice(0, i + 1)); };
+		path.appendChild(s);
+	});
+	var map = document.getElementById("map");
+	map.textContent = "";
+	var out = [];
+	squarify(node.c || [], {x: 0, y: 0, w: map.clientWidth, h: map.clientHeight}, out);
+	out.forEach(function (b) {
+		var n = b.n, d = document.createElement("div");
+		d.style.left = b.x + "px"; d.style.top = b.y + "px";
+		d.style.width = b.w + "px"; d.style.height = b.h + "px";
+		d.style.background = colors[dominant(n)] || "#999";
+		var label = n.t ? n.n : n.n + (n.c && n.c.some(function (c) { return !c.t; }) ? " …" : "");
+		d.title = (n.p || "") + (n.t ? " " + n.n : "") + "\n" + size(n.b) + ", " + n.k + " keys, " + (100 * n.b / root.b).toFixed(1) + "% of all";
+		if (b.w > 40 && b.h > 14) d.textContent = label + " " + size(n.b);
+		if (n.t) d.className = "leaf";
+		else d.onclick = function () { show(stack.concat([n])); };
+		map.appendChild(d);
+	});
+}
+
+var legend = document.getElementById("legend");
+for (var t in colors) {
+	var s = document.createElement("span");
+	s.style.background = colors[t]; s.textContent = t;
+	legend.appendChild(s);
+}
+window.onresize = function () { show(current); };
+show([root]);
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,92 @@
+package rdb_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestWriteTreemap(t *testing.T) {
+	p := rdb.NewPrefixMemory(rdb.DefaultEncodingConfig())
+	p.Add(str(0, "user:1", "ann", 0))
+	p.Add(str(0, "user:2", "bob", 0))
+	p.Add(str(0, "</script><b>:1", "x", 0))
+	p.Add(&rdb.Entry{Key: rdb.RedisString("user:list:1"), ValueType: rdb.ListQuickList2, Value: rdb.ListValue{Elements: strs("a")}})
+
+	var b strings.Builder
+	if err := rdb.WriteTreemap(&b, "cache <prod>", p.Report(), time.Unix(1700000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+	if !strings.Contains(page, "<title>cache &lt;prod&gt;</title>") {
+		t.Error("title not escaped")
+	}
+	if !strings.Contains(page, "by key prefix as of 2023-11-14T22:13:20Z.") {
+		t.Error("missing the time of the data")
+	}
+	if strings.Count(page, "</script>") != 1 {
+		t.Error("a key name closes the script")
+	}
+
+	// The data is the line assigning root, which must parse as JSON.
+	_, data, ok := strings.Cut(page, "var root = ")
+	if !ok {
+		t.Fatal("no data in the page")
+	}
+	data, _, _ = strings.Cut(data, ";\n")
+	type node struct {
+		N, P, T string
+		K, B    uint64
+		C       []node
+	}
+	var root node
+	if err := json.Unmarshal([]byte(data), &root); err != nil {
+		t.Fatalf("%v in %s", err, data)
+	}
+	if root.N != "(all)" || root.K != 4 || len(root.C) != 2 {
+		t.Fatalf("got root %+v", root)
+	}
+	var user, odd node
+	for _, c := range root.C {
+		switch c.N {
+		case "user":
+			user = c
+		case "</script><b>":
+			odd = c
+		}
+	}
+	if user.P != "user:" || user.K != 3 || odd.K != 1 {
+		t.Errorf("got user %+v, other %+v", user, odd)
+	}
+	var sum uint64
+	for _, c := range root.C {
+		sum += c.B
+	}
+	if sum != root.B {
+		t.Errorf("children hold %d bytes, root %d", sum, root.B)
+	}
+	var types []string
+	for _, c := range user.C {
+		if c.T != "" {
+			types = append(types, c.T)
+		} else if c.N != "list" || len(c.C) != 1 || c.C[0].T != "list" {
+			t.Errorf("got user:list %+v", c)
+		}
+	}
+	if len(types) != 1 || types[0] != "string" {
+		t.Errorf("got leaf types %v under user", types)
+	}
+}
+
+func TestWriteTreemapNoTime(t *testing.T) {
+	var b strings.Builder
+	if err := rdb.WriteTreemap(&b, "cache", rdb.NewPrefixMemory(rdb.DefaultEncodingConfig()).Report(), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Estimated memory by key prefix.</div>") {
+		t.Error("a zero time is shown")
+	}
+}
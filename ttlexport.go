@@ -0,0 +1,83 @@
+package rdb
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// TTLExporter writes a CSV audit of the keys that have an expiry, one row
+// per key with the columns db, key, type, expire_at (RFC 3339, UTC) and
+// seconds_remaining relative to a reference time, negative for keys that
+// had already expired. Remaining times are rounded away from zero, so that
+// a key expiring within the second shows 1 and one expired within the
+// second -1, never 0. Keys without an expiry are left out.
+type TTLExporter struct {
+	NopSink // Begin and SelectDB
+
+	// Escape selects how key names are written; the default, EscapeRaw,
+	// writes them as they are.
+	Escape Escaping
+
+	out *transformedOutput
+	csv *csv.Writer
+	now time.Time
+}
+
+// NewTTLExporter returns a TTLExporter writing to w, through the given
+// transforms, and writes the header row. Remaining times are computed
+// against now.
+func NewTTLExporter(w io.Writer, now time.Time, transforms ...Transform) (*TTLExporter, error) {
+	out, err := wrapOutput(w, transforms)
+	if err != nil {
+		return nil, err
+	}
+	x := &TTLExporter{out: out, csv: csv.NewWriter(out), now: now}
+	x.csv.Write([]string{"db", "key", "type", "expire_at", "seconds_remaining"})
+	return x, x.csv.Error()
+}
+
+// Add writes the row for e if it has an expiry.
+func (x *TTLExporter) Add(e *Entry) error {
+	if !e.HasExpiry() {
+		return nil
+	}
+	at := e.ExpiryTime()
+	return x.csv.Write([]string{
+		strconv.FormatUint(e.DB, 10),
+		x.Escape.Apply(e.Key),
+		e.Type().String(),
+		at.UTC().Format(time.RFC3339Nano),
+		strconv.FormatInt(secondsAway(at.Sub(x.now)), 10),
+	})
+}
+
+// Close flushes the output and closes the transforms. It does not close
+// the underlying writer.
+func (x *TTLExporter) Close() error {
+	x.csv.Flush()
+	err := x.csv.Error()
+	if cerr := x.out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// WriteEntry implements Sink by calling Add.
+func (x *TTLExporter) WriteEntry(e *Entry) error { return x.Add(e) }
+
+// End implements Sink by calling Close.
+func (x *TTLExporter) End() error { return x.Close() }
+
+// secondsAway returns d in seconds, rounded away from zero.
+func secondsAway(d time.Duration) int64 {
+	s := int64(d / time.Second)
+	switch {
+	case d%time.Second > 0:
+		s++
+	case d%time.Second < 0:
+		s--
+	}
+	return s
+}
@@ -0,0 +1,38 @@
+package rdb_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestTTLExporter(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	var buf bytes.Buffer
+	x, err := rdb.NewTTLExporter(&buf, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []*rdb.Entry{
+		{Key: rdb.RedisString("soon"), ExpiryAt: now.UnixMilli() + 200},
+		{Key: rdb.RedisString("later"), ExpiryAt: now.UnixMilli() + 2000},
+		{Key: rdb.RedisString("gone"), ExpiryAt: now.UnixMilli() - 200},
+		{Key: rdb.RedisString("forever")},
+	} {
+		if err := x.WriteEntry(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := x.End(); err != nil {
+		t.Fatal(err)
+	}
+	want := "db,key,type,expire_at,seconds_remaining\n" +
+		"0,soon,string,2023-11-14T22:13:20.2Z,1\n" +
+		"0,later,string,2023-11-14T22:13:22Z,2\n" +
+		"0,gone,string,2023-11-14T22:13:19.8Z,-1\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,203 @@
+package rdb
+
+import "strconv"
+
+// RedisString is a binary-safe Redis string as stored in a dump. Keys,
+// values, fields and members are all RedisStrings; they are not required to
+// be valid UTF-8.
+type RedisString []byte
+
+// String returns the string as a Go string.
+func (s RedisString) String() string {
+	return string(s)
+}
+
+// HashField is a single field/value pair of a hash.
+type HashField struct {
+	Field RedisString
+	Value RedisString
+
+	// ExpiryAt is the expiry time of the field set with HPEXPIREAT and
+	// friends (Redis 7.4, RDB version 12), in milliseconds since the Unix
+	// epoch, or 0 if the field does not expire.
+	ExpiryAt int64
+}
+
+// ZSetMember is a member of a sorted set with its score. Scores may be
+// infinite; legacy dumps can also hold NaN.
+type ZSetMember struct {
+	Member RedisString
+	Score  float64
+}
+
+// ValueType is the type byte that precedes a key in an RDB file. It
+// identifies both the logical type of the value and how it is encoded.
+type ValueType byte
+
+// Value types as defined by Redis' rdb.h.
+const (
+	String           ValueType = 0
+	List             ValueType = 1
+	Set              ValueType = 2
+	ZSet             ValueType = 3
+	Hash             ValueType = 4
+	ZSet2            ValueType = 5
+	Module           ValueType = 6
+	Module2          ValueType = 7
+	HashZipmap       ValueType = 9
+	ListZipList      ValueType = 10
+	SetIntSet        ValueType = 11
+	ZSetZipList      ValueType = 12
+	HashZipList      ValueType = 13
+	ListQuickList    ValueType = 14
+	StreamListPacks  ValueType = 15
+	HashListPack     ValueType = 16
+	ZSetListPack     ValueType = 17
+	ListQuickList2   ValueType = 18
+	StreamListPacks2 ValueType = 19
+	SetListPack      ValueType = 20
+	StreamListPacks3 ValueType = 21
+
+	// Hashes with field expiry, from RDB version 12. The PreGA types were
+	// written by the Redis 7.4 release candidates.
+	HashMetadataPreGA   ValueType = 22
+	HashListPackExPreGA ValueType = 23
+	HashMetadata        ValueType = 24
+	HashListPackEx      ValueType = 25
+)
+
+var valueTypes = map[ValueType]struct {
+	name string
+	typ  Type
+	enc  Encoding
+}{
+	String:           {"String", TypeString, EncodingRaw},
+	List:             {"List", TypeList, EncodingLinkedList},
+	Set:              {"Set", TypeSet, EncodingHashtable},
+	ZSet:             {"ZSet", TypeZSet, EncodingSkiplist},
+	Hash:             {"Hash", TypeHash, EncodingHashtable},
+	ZSet2:            {"ZSet2", TypeZSet, EncodingSkiplist},
+	Module:           {"Module", TypeModule, EncodingModule},
+	Module2:          {"Module2", TypeModule, EncodingModule},
+	HashZipmap:       {"HashZipmap", TypeHash, EncodingZipmap},
+	ListZipList:      {"ListZipList", TypeList, EncodingZiplist},
+	SetIntSet:        {"SetIntSet", TypeSet, EncodingIntset},
+	ZSetZipList:      {"ZSetZipList", TypeZSet, EncodingZiplist},
+	HashZipList:      {"HashZipList", TypeHash, EncodingZiplist},
+	ListQuickList:    {"ListQuickList", TypeList, EncodingQuicklist},
+	StreamListPacks:  {"StreamListPacks", TypeStream, EncodingStream},
+	HashListPack:     {"HashListPack", TypeHash, EncodingListpack},
+	ZSetListPack:     {"ZSetListPack", TypeZSet, EncodingListpack},
+	ListQuickList2:   {"ListQuickList2", TypeList, EncodingQuicklist},
+	StreamListPacks2: {"StreamListPacks2", TypeStream, EncodingStream},
+	SetListPack:      {"SetListPack", TypeSet, EncodingListpack},
+	StreamListPacks3: {"StreamListPacks3", TypeStream, EncodingStream},
+
+	HashMetadataPreGA:   {"HashMetadataPreGA", TypeHash, EncodingHashtable},
+	HashListPackExPreGA: {"HashListPackExPreGA", TypeHash, EncodingListpackEx},
+	HashMetadata:        {"HashMetadata", TypeHash, EncodingHashtable},
+	HashListPackEx:      {"HashListPackEx", TypeHash, EncodingListpackEx},
+}
+
+// Valid reports whether t is a value type this package knows about.
+func (t ValueType) Valid() bool {
+	_, ok := valueTypes[t]
+	return ok
+}
+
+// Type returns the logical type of values stored with type byte t.
+func (t ValueType) Type() Type {
+	return valueTypes[t].typ
+}
+
+// Encoding returns the on-disk encoding of values stored with type byte t.
+func (t ValueType) Encoding() Encoding {
+	return valueTypes[t].enc
+}
+
+func (t ValueType) String() string {
+	if vt, ok := valueTypes[t]; ok {
+		return vt.name
+	}
+	return "ValueType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Type is the logical type of a value, as reported by the TYPE command.
+type Type uint8
+
+// Logical types.
+const (
+	TypeString Type = iota
+	TypeList
+	TypeSet
+	TypeZSet
+	TypeHash
+	TypeStream
+	TypeModule
+)
+
+var typeNames = [...]string{"string", "list", "set", "zset", "hash", "stream", "module"}
+
+func (t Type) String() string {
+	if int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return "Type(" + strconv.Itoa(int(t)) + ")"
+}
+
+// MarshalText formats the type as String does.
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Encoding is the representation a value is stored in. Names follow the
+// OBJECT ENCODING command where Redis has an equivalent.
+type Encoding uint8
+
+// Encodings.
+const (
+	EncodingRaw        Encoding = iota // plain string
+	EncodingLinkedList                 // list of strings, one per element
+	EncodingHashtable                  // set or hash stored element by element
+	EncodingSkiplist                   // sorted set stored member by member
+	EncodingZipmap
+	EncodingZiplist
+	EncodingIntset
+	EncodingQuicklist // list of ziplist or listpack nodes
+	EncodingListpack
+	EncodingStream // radix tree of listpacks
+	EncodingModule // opaque module value
+
+	// EncodingListpackEx is a listpack of hash fields with their expiry
+	// times.
+	EncodingListpackEx
+)
+
+var encodingNames = [...]string{
+	"raw", "linkedlist", "hashtable", "skiplist", "zipmap", "ziplist",
+	"intset", "quicklist", "listpack", "stream", "module", "listpackex",
+}
+
+func (e Encoding) String() string {
+	if int(e) < len(encodingNames) {
+		return encodingNames[e]
+	}
+	return "Encoding(" + strconv.Itoa(int(e)) + ")"
+}
+
+// MarshalText formats the encoding as String does.
+func (e Encoding) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// Compact reports whether e is one of the memory-efficient encodings Redis
+// uses for small collections (zipmap, ziplist, intset or listpack, with
+// or without field expiry) and converts away from once the collection
+// grows past its configured limits.
+func (e Encoding) Compact() bool {
+	switch e {
+	case EncodingZipmap, EncodingZiplist, EncodingIntset, EncodingListpack, EncodingListpackEx:
+		return true
+	}
+	return false
+}
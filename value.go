@@ -0,0 +1,72 @@
+package rdb
+
+// Value is the decoded value of a key, as held by Entry.Value. The Reader
+// returns a StringValue, ListValue, SetValue, ZSetValue or HashValue, a
+// *StreamValue, a *ModuleValue, or the Value a registered decoder returns.
+// Encoding reports the encoding the value was stored with in the dump, so
+// that consumers need not look at the raw bytes to learn it.
+type Value interface {
+	Type() Type
+	Encoding() Encoding
+}
+
+// StringValue is the value of a string key. Strings are always stored in
+// EncodingRaw: the integer and LZF forms of strings are a property of the
+// string, not of the key.
+type StringValue RedisString
+
+// String returns the string as a Go string.
+func (v StringValue) String() string { return string(v) }
+
+func (StringValue) Type() Type         { return TypeString }
+func (StringValue) Encoding() Encoding { return EncodingRaw }
+
+// ListValue is the value of a list key, with its elements from head to
+// tail.
+type ListValue struct {
+	Elements []RedisString
+	Enc      Encoding // encoding the list was stored with
+}
+
+func (ListValue) Type() Type           { return TypeList }
+func (v ListValue) Encoding() Encoding { return v.Enc }
+
+// SetValue is the value of a set key, with its members in file order.
+type SetValue struct {
+	Members []RedisString
+	Enc     Encoding // encoding the set was stored with
+}
+
+func (SetValue) Type() Type           { return TypeSet }
+func (v SetValue) Encoding() Encoding { return v.Enc }
+
+// ZSetValue is the value of a sorted set key, with its members and their
+// scores in file order: from the highest score down, except in ziplist and
+// listpack blobs, which hold them from the lowest up.
+type ZSetValue struct {
+	Members []ZSetMember
+	Enc     Encoding // encoding the sorted set was stored with
+}
+
+func (ZSetValue) Type() Type           { return TypeZSet }
+func (v ZSetValue) Encoding() Encoding { return v.Enc }
+
+// HashValue is the value of a hash key, with its fields in file order.
+type HashValue struct {
+	Fields []HashField
+	Enc    Encoding // encoding the hash was stored with
+}
+
+func (HashValue) Type() Type           { return TypeHash }
+func (v HashValue) Encoding() Encoding { return v.Enc }
+
+// stringsOf returns the elements of a list or the members of a set.
+func stringsOf(v Value) ([]RedisString, bool) {
+	switch v := v.(type) {
+	case ListValue:
+		return v.Elements, true
+	case SetValue:
+		return v.Members, true
+	}
+	return nil, false
+}
@@ -0,0 +1,48 @@
+package rdb_test
+
+import (
+	"reflect"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+	"github.com/areian/go-redis-rdb/rdbtest"
+)
+
+func TestValueEncoding(t *testing.T) {
+	blob := func(s ...string) [][]byte {
+		out := make([][]byte, len(s))
+		for i, x := range s {
+			out[i] = []byte(x)
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		dump []byte
+		want rdb.Value
+	}{
+		{"string", blobDump(rdb.String, nil, []byte("v")), rdb.StringValue("v")},
+		{"ziplist list", blobDump(rdb.ListZipList, nil, codec.EncodeZiplist(blob("a", "12"))),
+			rdb.ListValue{Elements: strs("a", "12"), Enc: rdb.EncodingZiplist}},
+		{"intset", blobDump(rdb.SetIntSet, nil, codec.EncodeIntset([]int64{1, 300})),
+			rdb.SetValue{Members: strs("1", "300"), Enc: rdb.EncodingIntset}},
+		{"listpack set", blobDump(rdb.SetListPack, nil, codec.EncodeListpack(blob("x", "y"))),
+			rdb.SetValue{Members: strs("x", "y"), Enc: rdb.EncodingListpack}},
+		{"listpack zset", blobDump(rdb.ZSetListPack, nil, codec.EncodeListpack(blob("x", "1.5"))),
+			rdb.ZSetValue{Members: []rdb.ZSetMember{{Member: rdb.RedisString("x"), Score: 1.5}}, Enc: rdb.EncodingListpack}},
+		{"ziplist hash", blobDump(rdb.HashZipList, nil, codec.EncodeZiplist(blob("f", "v"))),
+			rdb.HashValue{Fields: []rdb.HashField{{Field: rdb.RedisString("f"), Value: rdb.RedisString("v")}}, Enc: rdb.EncodingZiplist}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := rdbtest.LoadBytes(t, tt.dump)
+			if len(es) != 1 || !reflect.DeepEqual(es[0].Value, tt.want) {
+				t.Fatalf("got %v, want %v", es, tt.want)
+			}
+			if v := es[0].Value; v.Type() != es[0].Type() || v.Encoding() != es[0].Encoding() {
+				t.Errorf("value reports %v in %v, entry %v in %v", v.Type(), v.Encoding(), es[0].Type(), es[0].Encoding())
+			}
+		})
+	}
+}
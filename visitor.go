@@ -0,0 +1,111 @@
+package rdb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// A Visitor receives the contents of a dump from Parse, in the order they
+// appear in it. Returning an error from a method ends the parse; ErrStop
+// ends it without an error. Embed NopVisitor to implement only the methods
+// of interest.
+type Visitor interface {
+	// OnHeader is called first, with the RDB version of the dump.
+	OnHeader(version int) error
+
+	// OnAux is called for every auxiliary field, including those that
+	// ReadEntry attaches to the entry following them.
+	OnAux(key, value RedisString) error
+
+	OnSelectDB(db uint64) error
+	OnResizeDB(db uint64, hint DBSizeHint) error
+	OnEntry(e *Entry) error
+
+	// OnFunction is called with the code of every function library.
+	OnFunction(code RedisString) error
+
+	// OnEnd is called last, at the end of the dump, with the stored
+	// checksum; see Reader.Checksum.
+	OnEnd(checksum uint64) error
+}
+
+// ErrStop can be returned by a Visitor to end Parse early without an
+// error.
+var ErrStop = errors.New("rdb: stop parsing")
+
+// NopVisitor implements Visitor with methods that do nothing.
+type NopVisitor struct{}
+
+func (NopVisitor) OnHeader(version int) error                  { return nil }
+func (NopVisitor) OnAux(key, value RedisString) error          { return nil }
+func (NopVisitor) OnSelectDB(db uint64) error                  { return nil }
+func (NopVisitor) OnResizeDB(db uint64, hint DBSizeHint) error { return nil }
+func (NopVisitor) OnEntry(e *Entry) error                      { return nil }
+func (NopVisitor) OnFunction(code RedisString) error           { return nil }
+func (NopVisitor) OnEnd(checksum uint64) error                 { return nil }
+
+// Parse reads the dump from r, calling the methods of v as it goes. opts
+// configure the underlying Reader; an OnOpcode hook among them is replaced
+// by Parse's own.
+func Parse(r io.Reader, v Visitor, opts ...Option) error {
+	var events []func() error
+	var db uint64
+	// The Reader has read the payloads already, so they decode.
+	hook := func(op byte, offset int64, payload []byte) {
+		p := bytes.NewReader(payload)
+		switch op {
+		case opAux:
+			key, _ := codec.ReadString(p)
+			value, _ := codec.ReadString(p)
+			events = append(events, func() error { return v.OnAux(key, value) })
+		case opSelectDB:
+			db, _, _ = codec.ReadLength(p)
+			n := db
+			events = append(events, func() error { return v.OnSelectDB(n) })
+		case opResizeDB:
+			var h DBSizeHint
+			h.Keys, _, _ = codec.ReadLength(p)
+			h.Expires, _, _ = codec.ReadLength(p)
+			n := db
+			events = append(events, func() error { return v.OnResizeDB(n, h) })
+		case opFunction2:
+			code, _ := codec.ReadString(p)
+			events = append(events, func() error { return v.OnFunction(code) })
+		}
+	}
+	rd, err := NewReader(r, append(opts[:len(opts):len(opts)], OnOpcode(hook))...)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	err = v.OnHeader(rd.Version())
+	for err == nil {
+		e, rerr := rd.ReadEntry()
+		// The opcodes read before the entry or the end come first.
+		for _, ev := range events {
+			if err = ev(); err != nil {
+				break
+			}
+		}
+		events = events[:0]
+		switch {
+		case err != nil:
+		case rerr == io.EOF:
+			err = v.OnEnd(rd.Checksum())
+			if err == nil {
+				return nil
+			}
+		case rerr != nil:
+			return rerr
+		default:
+			err = v.OnEntry(e)
+		}
+	}
+	if err == ErrStop {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package rdb_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// recorder records the calls of Parse, stopping at the key named stop.
+type recorder struct {
+	rdb.NopVisitor
+	calls []string
+	stop  string
+}
+
+func (v *recorder) OnHeader(version int) error {
+	v.calls = append(v.calls, fmt.Sprint("header ", version))
+	return nil
+}
+
+func (v *recorder) OnAux(key, value rdb.RedisString) error {
+	v.calls = append(v.calls, fmt.Sprintf("aux %s=%s", key, value))
+	return nil
+}
+
+func (v *recorder) OnSelectDB(db uint64) error {
+	v.calls = append(v.calls, fmt.Sprint("select ", db))
+	return nil
+}
+
+func (v *recorder) OnResizeDB(db uint64, hint rdb.DBSizeHint) error {
+	v.calls = append(v.calls, fmt.Sprintf("resize %d %d/%d", db, hint.Keys, hint.Expires))
+	return nil
+}
+
+func (v *recorder) OnFunction(code rdb.RedisString) error {
+	v.calls = append(v.calls, fmt.Sprintf("function %s", code))
+	return nil
+}
+
+func (v *recorder) OnEntry(e *rdb.Entry) error {
+	if string(e.Key) == v.stop {
+		return rdb.ErrStop
+	}
+	v.calls = append(v.calls, fmt.Sprintf("entry %d %s=%s", e.DB, e.Key, e.Value))
+	return nil
+}
+
+func (v *recorder) OnEnd(checksum uint64) error {
+	v.calls = append(v.calls, "end")
+	return nil
+}
+
+func TestParse(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteAux([]byte("redis-ver"), []byte("7.2.0"))
+	w.WriteRaw(codec.AppendString([]byte{0xf5}, []byte("lib"), false))
+	w.SelectDB(0)
+	w.ResizeDB(1, 0)
+	w.WriteEntry(&rdb.Entry{Key: rdb.RedisString("a"), Value: rdb.StringValue("1")})
+	w.SelectDB(3)
+	w.ResizeDB(1, 0)
+	w.WriteEntry(&rdb.Entry{DB: 3, Key: rdb.RedisString("b"), Value: rdb.StringValue("2")})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &recorder{}
+	if err := rdb.Parse(bytes.NewReader(buf.Bytes()), v); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"header 11",
+		"aux redis-ver=7.2.0",
+		"function lib",
+		"select 0",
+		"resize 0 1/0",
+		"entry 0 a=1",
+		"select 3",
+		"resize 3 1/0",
+		"entry 3 b=2",
+		"end",
+	}
+	if !reflect.DeepEqual(v.calls, want) {
+		t.Errorf("got calls\n%q\nwant\n%q", v.calls, want)
+	}
+
+	v = &recorder{stop: "b"}
+	if err := rdb.Parse(bytes.NewReader(buf.Bytes()), v); err != nil {
+		t.Fatalf("ErrStop: %v", err)
+	}
+	if got := v.calls[len(v.calls)-1]; got != "resize 3 1/0" {
+		t.Errorf("ErrStop: last call %q, want the one before the stopping entry", got)
+	}
+}
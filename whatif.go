@@ -0,0 +1,99 @@
+package rdb
+
+import "sort"
+
+// KeyPrefix returns the first depth segments of key, split on sep. Keys
+// with fewer segments are returned whole.
+func KeyPrefix(key []byte, sep byte, depth int) string {
+	n := 0
+	for i, c := range key {
+		if c == sep {
+			n++
+			if n == depth {
+				return string(key[:i])
+			}
+		}
+	}
+	return string(key)
+}
+
+// WhatIf projects how the memory used by a dump would change if Redis ran
+// with different encoding thresholds, for example a higher
+// hash-max-listpack-entries. Feed it every entry with Add, then call
+// Report.
+type WhatIf struct {
+	Current     EncodingConfig
+	Alternative EncodingConfig
+
+	// Prefix groups keys in the report. If nil, keys are grouped by the
+	// part before the first ':'.
+	Prefix func(key []byte) string
+
+	prefixes map[string]*WhatIfPrefix
+}
+
+// WhatIfPrefix is the projection for one key prefix.
+type WhatIfPrefix struct {
+	Prefix    string
+	Keys      uint64
+	Converted uint64 // keys whose encoding changes
+	Current   uint64 // estimated bytes under the current configuration
+	Projected uint64 // estimated bytes under the alternative configuration
+}
+
+// Savings returns the projected reduction in bytes; it is negative if the
+// alternative configuration uses more memory.
+func (p *WhatIfPrefix) Savings() int64 {
+	return int64(p.Current) - int64(p.Projected)
+}
+
+// NewWhatIf returns a WhatIf comparing current against alternative.
+func NewWhatIf(current, alternative EncodingConfig) *WhatIf {
+	return &WhatIf{Current: current, Alternative: alternative}
+}
+
+// Add accounts for a single entry.
+func (w *WhatIf) Add(e *Entry) {
+	var prefix string
+	if w.Prefix != nil {
+		prefix = w.Prefix(e.Key)
+	} else {
+		prefix = KeyPrefix(e.Key, ':', 1)
+	}
+	if w.prefixes == nil {
+		w.prefixes = make(map[string]*WhatIfPrefix)
+	}
+	p := w.prefixes[prefix]
+	if p == nil {
+		p = &WhatIfPrefix{Prefix: prefix}
+		w.prefixes[prefix] = p
+	}
+	curEnc, cur := EstimateMemory(e, w.Current)
+	altEnc, alt := EstimateMemory(e, w.Alternative)
+	p.Keys++
+	p.Current += cur
+	p.Projected += alt
+	if curEnc != altEnc {
+		p.Converted++
+	}
+}
+
+// Report returns the projection per prefix, largest savings first, and the
+// totals over all keys.
+func (w *WhatIf) Report() (prefixes []WhatIfPrefix, total WhatIfPrefix) {
+	for _, p := range w.prefixes {
+		prefixes = append(prefixes, *p)
+		total.Keys += p.Keys
+		total.Converted += p.Converted
+		total.Current += p.Current
+		total.Projected += p.Projected
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		si, sj := prefixes[i].Savings(), prefixes[j].Savings()
+		if si != sj {
+			return si > sj
+		}
+		return prefixes[i].Prefix < prefixes[j].Prefix
+	})
+	return prefixes, total
+}
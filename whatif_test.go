@@ -0,0 +1,71 @@
+package rdb_test
+
+import (
+	"strconv"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+)
+
+func TestKeyPrefix(t *testing.T) {
+	tests := []struct {
+		key   string
+		depth int
+		want  string
+	}{
+		{"user:1:name", 1, "user"},
+		{"user:1:name", 2, "user:1"},
+		{"user:1:name", 3, "user:1:name"},
+		{"plain", 1, "plain"},
+		{":1", 1, ""},
+	}
+	for _, tt := range tests {
+		if got := rdb.KeyPrefix([]byte(tt.key), ':', tt.depth); got != tt.want {
+			t.Errorf("KeyPrefix(%q, %d) = %q, want %q", tt.key, tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestWhatIf(t *testing.T) {
+	var big []rdb.HashField
+	for i := 0; i < 200; i++ {
+		big = append(big, rdb.HashField{Field: rdb.RedisString("f" + strconv.Itoa(i)), Value: rdb.RedisString("v")})
+	}
+	cur := rdb.DefaultEncodingConfig()
+	alt := cur
+	alt.HashMaxListpackEntries = 256
+
+	w := rdb.NewWhatIf(cur, alt)
+	w.Add(&rdb.Entry{Key: rdb.RedisString("user:1"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: big}})
+	w.Add(&rdb.Entry{Key: rdb.RedisString("user:2"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: fields("f", "v")}})
+	w.Add(str(0, "cache:1", "v", 0))
+	w.Add(str(0, "cache:2", "v", 0))
+
+	prefixes, total := w.Report()
+	// The big hash turns from a hashtable of 11776 bytes into a listpack
+	// of 1856; the small one and the strings stay as they are.
+	want := []rdb.WhatIfPrefix{
+		{Prefix: "user", Keys: 2, Converted: 1, Current: 11776 + 80, Projected: 1856 + 80},
+		{Prefix: "cache", Keys: 2, Current: 2 * 88, Projected: 2 * 88},
+	}
+	if len(prefixes) != len(want) {
+		t.Fatalf("got %+v, want %+v", prefixes, want)
+	}
+	for i := range want {
+		if prefixes[i] != want[i] {
+			t.Errorf("got %+v, want %+v", prefixes[i], want[i])
+		}
+	}
+	if total.Keys != 4 || total.Converted != 1 || total.Savings() != 11776-1856 {
+		t.Errorf("got total %+v", total)
+	}
+
+	// Going the other way costs memory: savings are negative.
+	w = rdb.NewWhatIf(alt, cur)
+	w.Prefix = func([]byte) string { return "all" }
+	w.Add(&rdb.Entry{Key: rdb.RedisString("user:1"), ValueType: rdb.HashListPack, Value: rdb.HashValue{Fields: big}})
+	prefixes, _ = w.Report()
+	if len(prefixes) != 1 || prefixes[0].Prefix != "all" || prefixes[0].Savings() != 1856-11776 {
+		t.Errorf("got %+v", prefixes)
+	}
+}
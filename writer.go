@@ -0,0 +1,306 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+// Writer writes an RDB stream.
+//
+// Entries read with WithRaw from a dump of the Writer's version carry the
+// bytes they were read from and are written back verbatim. Other entries
+// are encoded from their values in the plain encodings every version
+// reads, a list as List rather than a quicklist for example, which Redis
+// converts to its compact encodings as it loads them. Errors are sticky:
+// after the first failed write, every method returns the same error.
+type Writer struct {
+	w        *bufio.Writer
+	version  int
+	crc      uint64
+	db       uint64
+	dbSet    bool
+	checksum bool
+	err      error
+
+	compression Compression
+	transforms  []Transform
+	out         *transformedOutput
+}
+
+// A WriterOption configures a Writer.
+type WriterOption func(*Writer)
+
+// NewWriter returns a Writer writing a dump of the given RDB version to w
+// and writes the header. Versions from 80 on get Valkey's header.
+func NewWriter(w io.Writer, version int, opts ...WriterOption) (*Writer, error) {
+	if version < 1 || version > 999 {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, version)
+	}
+	wr := &Writer{version: version, checksum: version >= 5}
+	for _, opt := range opts {
+		opt(wr)
+	}
+	out, err := wrapOutput(w, wr.transforms)
+	if err != nil {
+		return nil, err
+	}
+	wr.out = out
+	wr.w = bufio.NewWriterSize(out, 64<<10)
+	header := fmt.Sprintf("REDIS%04d", version)
+	if version >= valkeyMinVersion {
+		header = fmt.Sprintf("VALKEY%03d", version)
+	}
+	wr.write([]byte(header))
+	return wr, wr.err
+}
+
+// Version returns the RDB version the Writer writes.
+func (w *Writer) Version() int {
+	return w.version
+}
+
+func (w *Writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	w.crc = codec.CRC64(w.crc, b)
+	_, w.err = w.w.Write(b)
+}
+
+// WriteAux writes an auxiliary field.
+func (w *Writer) WriteAux(key, value []byte) error {
+	b := []byte{opAux}
+	b = codec.AppendString(b, key, w.compress())
+	b = codec.AppendString(b, value, w.compress())
+	w.write(b)
+	return w.err
+}
+
+// SelectDB switches to database db for the entries that follow.
+func (w *Writer) SelectDB(db uint64) error {
+	w.write(codec.AppendLength([]byte{opSelectDB}, db))
+	w.db, w.dbSet = db, true
+	return w.err
+}
+
+// ResizeDB writes the hash table size hints for the current database.
+func (w *Writer) ResizeDB(keys, expires uint64) error {
+	b := codec.AppendLength([]byte{opResizeDB}, keys)
+	w.write(codec.AppendLength(b, expires))
+	return w.err
+}
+
+// WriteRaw writes b verbatim, such as the Trailer of a Reader.
+func (w *Writer) WriteRaw(b []byte) error {
+	w.write(b)
+	return w.err
+}
+
+// WriteEntry writes e, preceded by its auxiliary fields and a database
+// selection if needed. If e.Raw is set and e.RawVersion is the version of
+// w, Raw is written verbatim instead. Raw bytes of another version are
+// ignored, along with the opcodes they hold before the key, and the value
+// is encoded; entries without a value then fail with ErrVersion.
+func (w *Writer) WriteEntry(e *Entry) error {
+	if w.err != nil {
+		return w.err
+	}
+	if e.Raw != nil && e.RawVersion != w.version && e.Value == nil {
+		return fmt.Errorf("%w: raw bytes of key %q are of version %d, not %d", ErrVersion, e.Key, e.RawVersion, w.version)
+	}
+	if e.Raw != nil && e.RawVersion == w.version {
+		raw := e.Raw
+		if w.compression != CompressPreserve {
+			var err error
+			if raw, err = recompress(raw, w.compress()); err != nil {
+				return fmt.Errorf("key %q: %w", e.Key, err)
+			}
+		}
+		w.write(raw)
+		w.db, w.dbSet = e.DB, true
+		return w.err
+	}
+	t, value, err := encodeValue(e, w.version, w.compress())
+	if err != nil {
+		return err
+	}
+	for _, a := range e.Aux {
+		w.WriteAux(a.Key, a.Value)
+	}
+	if !w.dbSet || w.db != e.DB {
+		w.SelectDB(e.DB)
+	}
+	var b []byte
+	switch {
+	case e.ExpiryAt == 0:
+	case w.version >= 3:
+		b = append(b, opExpireTimeMs)
+		b = binary.LittleEndian.AppendUint64(b, uint64(e.ExpiryAt))
+	default:
+		// Earlier versions store seconds; round up so that the key does
+		// not expire early.
+		b = append(b, opExpireTime)
+		b = binary.LittleEndian.AppendUint32(b, uint32((e.ExpiryAt+999)/1000))
+	}
+	// IDLE and FREQ arrived with version 9; older dumps drop the hint.
+	switch {
+	case w.version < 9:
+	case e.Access == AccessLRU:
+		b = codec.AppendLength(append(b, opIdle), e.Idle)
+	case e.Access == AccessLFU:
+		b = append(b, opFreq, e.Freq)
+	}
+	b = append(b, byte(t))
+	b = codec.AppendString(b, e.Key, w.compress())
+	w.write(append(b, value...))
+	return w.err
+}
+
+// encodeValue returns the value type and the serialized value of e for a
+// dump of the given version.
+func encodeValue(e *Entry, version int, compress bool) (ValueType, []byte, error) {
+	var t ValueType
+	var value []byte
+	switch v := e.Value.(type) {
+	case StringValue:
+		t, value = String, codec.AppendString(nil, v, compress)
+	case ListValue, SetValue:
+		t = List
+		if _, ok := v.(SetValue); ok {
+			t = Set
+		}
+		elems, _ := stringsOf(v)
+		value = codec.AppendLength(nil, uint64(len(elems)))
+		for _, s := range elems {
+			value = codec.AppendString(value, s, compress)
+		}
+	case HashValue:
+		t = Hash
+		var minExpire int64
+		for _, f := range v.Fields {
+			if f.ExpiryAt != 0 && (minExpire == 0 || f.ExpiryAt < minExpire) {
+				minExpire = f.ExpiryAt
+			}
+		}
+		if minExpire != 0 {
+			// Field expiry arrived with version 12.
+			if version < 12 {
+				return 0, nil, fmt.Errorf("%w: field expiry of key %q in version %d", ErrNotSupported, e.Key, version)
+			}
+			t = HashMetadata
+			value = binary.LittleEndian.AppendUint64(nil, uint64(minExpire))
+		}
+		value = codec.AppendLength(value, uint64(len(v.Fields)))
+		for _, f := range v.Fields {
+			if t == HashMetadata {
+				var ttl uint64
+				if f.ExpiryAt != 0 {
+					ttl = uint64(f.ExpiryAt-minExpire) + 1
+				}
+				value = codec.AppendLength(value, ttl)
+			}
+			value = codec.AppendString(value, f.Field, compress)
+			value = codec.AppendString(value, f.Value, compress)
+		}
+	case ZSetValue:
+		// Binary scores arrived with version 8.
+		t = ZSet2
+		if version < 8 {
+			t = ZSet
+		}
+		value = codec.AppendLength(nil, uint64(len(v.Members)))
+		for _, m := range v.Members {
+			value = codec.AppendString(value, m.Member, compress)
+			if t == ZSet {
+				value = codec.AppendScore(value, m.Score)
+			} else {
+				value = codec.AppendBinaryScore(value, m.Score)
+			}
+		}
+	case *StreamValue:
+		return encodeStream(e, v, version, compress)
+	case *ModuleValue:
+		t = Module2
+		value = append(codec.AppendLength(nil, uint64(v.ID)), v.Raw...)
+	case NamedValue:
+		return encodeExtension(e, v, version)
+	default:
+		return 0, nil, fmt.Errorf("%w: writing %v for key %q", ErrNotSupported, e.ValueType, e.Key)
+	}
+	return t, value, nil
+}
+
+// compress reports whether strings the Writer encodes are compressed.
+func (w *Writer) compress() bool {
+	return w.compression != CompressNone
+}
+
+// DisableChecksum makes Close write a zero checksum, as Redis does with
+// rdbchecksum off.
+func (w *Writer) DisableChecksum() {
+	w.checksum = false
+}
+
+// Close writes the EOF opcode and the checksum, flushes the output and
+// closes the transforms set with WithTransform. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	w.write([]byte{opEOF})
+	if w.version >= 5 {
+		var sum uint64
+		if w.checksum {
+			sum = w.crc
+		}
+		w.write(binary.LittleEndian.AppendUint64(nil, sum))
+	}
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+	if err := w.out.Close(); w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// Copy writes every entry read from src to dst and closes dst. When src
+// was created with WithRaw and dst writes the same version, the copy is
+// identical to the source, checksum included.
+func Copy(dst *Writer, src *Reader) error {
+	// Without raw bytes dst can write, the header auxiliary fields are not
+	// part of the first entry and are written separately.
+	raw := src.raw && src.version == dst.version
+	auxDone := raw
+	writeAux := func() {
+		if !auxDone {
+			for _, a := range src.Aux() {
+				dst.WriteAux(a.Key, a.Value)
+			}
+			auxDone = true
+		}
+	}
+	for {
+		e, err := src.ReadEntry()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		writeAux()
+		if err := dst.WriteEntry(e); err != nil {
+			return err
+		}
+	}
+	writeAux()
+	if raw {
+		dst.WriteRaw(src.Trailer())
+	}
+	if src.Checksum() == 0 {
+		dst.DisableChecksum()
+	}
+	return dst.Close()
+}
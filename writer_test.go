@@ -0,0 +1,228 @@
+package rdb_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	rdb "github.com/areian/go-redis-rdb"
+	"github.com/areian/go-redis-rdb/codec"
+)
+
+func testStream() *rdb.StreamValue {
+	s := &rdb.StreamValue{Length: 150, EntriesAdded: 152}
+	for i := 0; i < 150; i++ {
+		e := rdb.StreamEntry{ID: rdb.StreamID{Ms: 1700000000000 + uint64(i/2), Seq: uint64(i % 2)}}
+		e.Fields = []rdb.StreamField{{Field: rdb.RedisString("n"), Value: rdb.RedisString(strconv.Itoa(i))}}
+		if i%3 == 0 {
+			e.Fields = append(e.Fields, rdb.StreamField{Field: rdb.RedisString("extra"), Value: rdb.RedisString("x")})
+		}
+		s.Entries = append(s.Entries, e)
+	}
+	s.FirstID = s.Entries[0].ID
+	s.LastID = rdb.StreamID{Ms: 1700000000100}
+	s.MaxDeletedID = rdb.StreamID{Ms: 1700000000090}
+	pending := s.Entries[1].ID
+	s.Groups = []rdb.StreamGroup{{
+		Name:        rdb.RedisString("g"),
+		LastID:      pending,
+		EntriesRead: 2,
+		Pending: []rdb.StreamPendingEntry{
+			{ID: pending, Consumer: rdb.RedisString("c"), DeliveryTime: 1700000001000, DeliveryCount: 3},
+		},
+		Consumers: []rdb.StreamConsumer{
+			{Name: rdb.RedisString("c"), SeenTime: 1700000002000, ActiveTime: 1700000001000, Pending: []rdb.StreamID{pending}},
+			{Name: rdb.RedisString("idle"), SeenTime: 1700000003000, ActiveTime: 1700000003000},
+		},
+	}}
+	return s
+}
+
+// versionedStream returns s as a dump of version reads it back.
+func versionedStream(s *rdb.StreamValue, version int) *rdb.StreamValue {
+	c := *s
+	c.Groups = append([]rdb.StreamGroup(nil), s.Groups...)
+	if version < 10 {
+		c.FirstID, c.MaxDeletedID, c.EntriesAdded = rdb.StreamID{}, rdb.StreamID{}, 0
+		for i := range c.Groups {
+			c.Groups[i].EntriesRead = 0
+		}
+	}
+	if version < 11 {
+		for i := range c.Groups {
+			g := &c.Groups[i]
+			g.Consumers = append([]rdb.StreamConsumer(nil), g.Consumers...)
+			for j := range g.Consumers {
+				g.Consumers[j].ActiveTime = 0
+			}
+		}
+	}
+	return &c
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for _, version := range []int{6, 9, 10, 11, 12} {
+		t.Run(strconv.Itoa(version), func(t *testing.T) {
+			want := []*rdb.Entry{
+				{Key: rdb.RedisString("string"), Value: rdb.StringValue("value"), ExpiryAt: 1700000000000},
+				{Key: rdb.RedisString("long"), Value: rdb.StringValue(bytes.Repeat([]byte("abc"), 100))},
+				{Key: rdb.RedisString("list"), Value: rdb.ListValue{Elements: []rdb.RedisString{rdb.RedisString("a"), rdb.RedisString("12")}, Enc: rdb.EncodingLinkedList}},
+				{Key: rdb.RedisString("hash"), Value: rdb.HashValue{Fields: []rdb.HashField{{Field: rdb.RedisString("f"), Value: rdb.RedisString("v")}}, Enc: rdb.EncodingHashtable}},
+				{Key: rdb.RedisString("zset"), Value: rdb.ZSetValue{Members: []rdb.ZSetMember{{Member: rdb.RedisString("m"), Score: 1.5}}, Enc: rdb.EncodingSkiplist}},
+				{DB: 2, Key: rdb.RedisString("other"), Value: rdb.StringValue("db")},
+			}
+			if version >= 9 {
+				want = append(want, &rdb.Entry{Key: rdb.RedisString("stream"), Value: testStream()})
+				want = append(want, &rdb.Entry{Key: rdb.RedisString("empty"), Value: &rdb.StreamValue{LastID: rdb.StreamID{Ms: 5}}})
+			}
+			if version >= 12 {
+				want = append(want, &rdb.Entry{Key: rdb.RedisString("ttl"), Value: rdb.HashValue{Fields: []rdb.HashField{
+					{Field: rdb.RedisString("a"), Value: rdb.RedisString("1"), ExpiryAt: 1700000000000},
+					{Field: rdb.RedisString("b"), Value: rdb.RedisString("2")},
+				}, Enc: rdb.EncodingHashtable}})
+			}
+			var buf bytes.Buffer
+			w, err := rdb.NewWriter(&buf, version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range want {
+				if err := w.WriteEntry(e); err != nil {
+					t.Fatalf("%s: %v", e.Key, err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := rdb.NewReader(&buf, rdb.WithVerifyChecksum())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			for _, we := range want {
+				e, err := r.ReadEntry()
+				if err != nil {
+					t.Fatalf("%s: %v", we.Key, err)
+				}
+				wv := we.Value
+				if s, ok := wv.(*rdb.StreamValue); ok {
+					wv = versionedStream(s, version)
+				}
+				if string(e.Key) != string(we.Key) || e.DB != we.DB || e.ExpiryAt != we.ExpiryAt || !reflect.DeepEqual(e.Value, wv) {
+					t.Errorf("got %+v, want %+v", e, we)
+				}
+			}
+			if _, err := r.ReadEntry(); err != io.EOF {
+				t.Errorf("got %v after the entries, want EOF", err)
+			}
+		})
+	}
+}
+
+func TestWriterStreamBeforeVersion9(t *testing.T) {
+	w, err := rdb.NewWriter(io.Discard, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.WriteEntry(&rdb.Entry{Key: rdb.RedisString("s"), Value: testStream()})
+	if !errors.Is(err, rdb.ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+}
+
+func TestDumpPayloadStream(t *testing.T) {
+	e := &rdb.Entry{Key: rdb.RedisString("s"), Value: testStream()}
+	payload, err := rdb.DumpPayload(e, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload[0] != byte(rdb.StreamListPacks3) {
+		t.Errorf("payload of type %d, want %d", payload[0], rdb.StreamListPacks3)
+	}
+}
+
+// rawSource is a version 11 dump with auxiliary fields before and after
+// the keys, a listpack hash and an LZF-compressed string.
+func rawSource(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w, err := rdb.NewWriter(&buf, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteAux([]byte("redis-ver"), []byte("7.0.0"))
+	w.SelectDB(0)
+	w.ResizeDB(2, 1)
+	hash := codec.EncodeListpack([][]byte{[]byte("f"), []byte("1"), []byte("g"), []byte("2")})
+	w.WriteRaw(codec.AppendString(codec.AppendString([]byte{byte(rdb.HashListPack)}, []byte("h"), false), hash, false))
+	w.WriteEntry(str(0, "s", strings.Repeat("abc", 100), 1700000000000))
+	w.WriteAux([]byte("aof-base"), []byte("0"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRawRoundTrip(t *testing.T) {
+	src := rawSource(t)
+	copyTo := func(version int, opts ...rdb.Option) ([]byte, error) {
+		r, err := rdb.NewReader(bytes.NewReader(src), append(opts, rdb.WithRaw())...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		var out bytes.Buffer
+		w, err := rdb.NewWriter(&out, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = rdb.Copy(w, r)
+		return out.Bytes(), err
+	}
+
+	same, err := copyTo(11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(same, src) {
+		t.Errorf("the copy differs from the source:\n%q\n%q", same, src)
+	}
+
+	// Raw bytes of version 11 do not go into a version 9 dump: the values
+	// are encoded again, and the header auxiliary fields written apart.
+	older, err := copyTo(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := rdb.NewReader(bytes.NewReader(older))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	h, err := r.ReadEntry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.ValueType != rdb.Hash || !reflect.DeepEqual(h.Value.(rdb.HashValue).Fields, fields("f", "1", "g", "2")) {
+		t.Errorf("got %+v", h)
+	}
+	if v, ok := r.AuxValue("redis-ver"); !ok || string(v) != "7.0.0" {
+		t.Errorf("got aux %q", r.Aux())
+	}
+	s, err := r.ReadEntry()
+	if err != nil || s.ExpiryAt != 1700000000000 || string(s.Value.(rdb.StringValue)) != strings.Repeat("abc", 100) {
+		t.Errorf("got %+v, %v", s, err)
+	}
+
+	// Without values there is nothing to encode.
+	if _, err := copyTo(11, rdb.WithKeysOnly()); err != nil {
+		t.Errorf("keys only, same version: %v", err)
+	}
+	if _, err := copyTo(9, rdb.WithKeysOnly()); !errors.Is(err, rdb.ErrVersion) {
+		t.Errorf("keys only, version 9: got %v, want ErrVersion", err)
+	}
+}